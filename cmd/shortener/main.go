@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"net"
 	"net/http"
@@ -11,10 +12,14 @@ import (
 	"time"
 
 	"github.com/shekshuev/shortener/internal/app/config"
+	"github.com/shekshuev/shortener/internal/app/deletion"
 	"github.com/shekshuev/shortener/internal/app/handler"
+	"github.com/shekshuev/shortener/internal/app/jwt"
+	"github.com/shekshuev/shortener/internal/app/keygen"
 	"github.com/shekshuev/shortener/internal/app/logger"
 	"github.com/shekshuev/shortener/internal/app/service"
 	"github.com/shekshuev/shortener/internal/app/store"
+	"github.com/shekshuev/shortener/internal/utils"
 	"google.golang.org/grpc"
 
 	_ "github.com/joho/godotenv/autoload"
@@ -54,6 +59,17 @@ func main() {
 	l := logger.NewLogger()
 	cfg := config.GetConfig()
 
+	if args := flag.Args(); len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(&cfg, args[1:]); err != nil {
+			l.Log.Fatal("Migration failed", zap.Error(err))
+		}
+		return
+	}
+
+	if err := cfg.Validate(); err != nil {
+		l.Log.Fatal("Invalid config", zap.Error(err))
+	}
+
 	var trustedSubnet *net.IPNet
 	if cfg.TrustedSubnet != "" {
 		_, subnet, err := net.ParseCIDR(cfg.TrustedSubnet)
@@ -62,14 +78,60 @@ func main() {
 		}
 		trustedSubnet = subnet
 	}
+	if err := utils.ValidateRedirectType(cfg.RedirectType); err != nil {
+		l.Log.Fatal("Invalid redirect type", zap.Int("redirect_type", cfg.RedirectType), zap.Error(err))
+	}
 
-	var urlStore store.URLStore
-	if cfg.DatabaseDSN == cfg.DefaultDatabaseDSN {
-		urlStore = store.NewMemoryURLStore(&cfg)
+	jwt.SetRefreshWindow(cfg.JWTRefreshWindow)
+	keyring, err := jwt.LoadKeyring(cfg.JWTSecret, cfg.JWTKeyFile, cfg.JWTPrivateKeyFile, cfg.JWTKeyGrace)
+	if err != nil {
+		l.Log.Fatal("Error loading JWT keys", zap.Error(err))
+	}
+	if keyring != nil {
+		jwt.SetKeyring(keyring)
+		l.Log.Info("JWT keys loaded", zap.String("active_kid", keyring.ActiveKeyID()))
 	} else {
-		urlStore = store.NewPostgresURLStore(&cfg)
+		l.Log.Warn("JWT secret is not configured, auth tokens will be signed with a random key and invalidated on restart")
+	}
+
+	urlStore, err := store.New(&cfg)
+	if err != nil {
+		l.Log.Fatal("Error initializing store", zap.String("backend", store.Backend(&cfg)), zap.Error(err))
 	}
-	urlService := service.NewURLService(urlStore, &cfg)
+	// Встроенные хранилища сохраняют счётчик ключей сами, поэтому ключи удалённых ссылок не выдаются повторно.
+	// Счётчик в памяти остаётся запасным вариантом для хранилищ без собственного счётчика.
+	counter, ok := urlStore.(keygen.Counter)
+	if !ok {
+		count, err := urlStore.CountURLs(context.Background())
+		if err != nil {
+			l.Log.Fatal("Error counting urls", zap.Error(err))
+		}
+		counter = keygen.NewAtomicCounter(uint64(count))
+	}
+	keyGenerator, err := keygen.New(&cfg, counter)
+	if err != nil {
+		l.Log.Fatal("Invalid key generator settings", zap.Error(err))
+	}
+	serviceOpts := []service.Option{service.WithKeyGenerator(keyGenerator)}
+	// Задачи удаления сохраняются в хранилище, если оно это умеет, иначе живут только в памяти процесса.
+	if jobs, ok := urlStore.(deletion.JobStore); ok {
+		serviceOpts = append(serviceOpts, service.WithDeletionJobStore(jobs))
+	}
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	if snapshotter, ok := urlStore.(store.Snapshotter); ok {
+		go store.RunSnapshotter(backgroundCtx, snapshotter, cfg.SnapshotInterval)
+	}
+	if cfg.CacheSize > 0 {
+		cachedStore := store.NewCachedURLStore(urlStore, cfg.CacheSize, cfg.CacheTTL)
+		if store.Backend(&cfg) == store.BackendPostgres {
+			go store.RunChangeListener(backgroundCtx, cfg.DatabaseDSN, cachedStore)
+		}
+		urlStore = cachedStore
+	}
+	go store.RunExpiredReaper(backgroundCtx, urlStore, cfg.ReapInterval)
+	go store.RunDeletedPurger(backgroundCtx, urlStore, cfg.ReapInterval, cfg.DeletedRetention)
+
+	urlService := service.NewURLService(urlStore, &cfg, serviceOpts...)
 
 	urlHandler := handler.NewURLHandler(urlService, trustedSubnet)
 	httpServer := &http.Server{
@@ -77,7 +139,10 @@ func main() {
 		Handler: urlHandler.Router,
 	}
 
-	grpcSrv := grpc.NewServer()
+	grpcSrv := grpc.NewServer(
+		grpc.UnaryInterceptor(grpcserver.UnaryAuthInterceptor(urlService)),
+		grpc.StreamInterceptor(grpcserver.StreamAuthInterceptor(urlService)),
+	)
 	grpcHandler := grpcserver.NewServer(urlService)
 	proto.RegisterURLShortenerServer(grpcSrv, grpcHandler)
 
@@ -131,6 +196,9 @@ func main() {
 		l.Log.Info("HTTP server shutdown gracefully")
 	}
 
+	stopBackground()
+	urlService.Close()
+
 	if err := urlStore.Close(); err != nil {
 		l.Log.Error("Error closing store", zap.Error(err))
 	} else {
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	_ "github.com/jackc/pgx/stdlib"
+
+	"github.com/shekshuev/shortener/internal/app/config"
+	"github.com/shekshuev/shortener/internal/app/store/migrations"
+)
+
+// errMigrateUsage - ошибка, описывающая синтаксис команды миграций.
+var errMigrateUsage = fmt.Errorf("usage: shortener [flags] migrate up|down|status")
+
+// runMigrate выполняет команду миграций схемы базы данных из конфигурации:
+// up применяет все недостающие миграции, down откатывает последнюю, status выводит состояние миграций.
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errMigrateUsage
+	}
+	if cfg.DatabaseDSN == "" {
+		return fmt.Errorf("database DSN is required for migrations")
+	}
+	db, err := sql.Open("pgx", cfg.DatabaseDSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		count, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied migrations: %d\n", count)
+	case "down":
+		migration, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back migration: %04d_%s\n", migration.Version, migration.Name)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+	default:
+		return errMigrateUsage
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Prefix - начало каждого ключа API, по которому его можно отличить от JWT-токена.
+const Prefix = "shk_"
+
+// Разрешения ключа API.
+const (
+	ScopeRead   = "read"   // Просмотр ссылок пользователя и статистики.
+	ScopeCreate = "create" // Создание и изменение ссылок.
+	ScopeDelete = "delete" // Удаление и восстановление ссылок.
+)
+
+// secretLength - количество случайных байт ключа.
+const secretLength = 32
+
+// displayLength - длина начала ключа, сохраняемого для отображения в списке ключей.
+const displayLength = len(Prefix) + 8
+
+// Scopes - все допустимые разрешения ключа API.
+var Scopes = []string{ScopeRead, ScopeCreate, ScopeDelete}
+
+var (
+	ErrInvalidScope = fmt.Errorf("invalid api key scope") // Ошибка: неизвестное разрешение
+	ErrEmptyScopes  = fmt.Errorf("api key has no scopes") // Ошибка: у ключа не указано ни одного разрешения
+	ErrEmptyName    = fmt.Errorf("api key name is empty") // Ошибка: у ключа не указано название
+	ErrInvalidKey   = fmt.Errorf("invalid api key")       // Ошибка: ключ не найден или имеет неверный формат
+)
+
+// Generate создаёт новый ключ API и возвращает сам ключ, его начало для отображения и хэш для хранения.
+func Generate() (key, prefix, hash string, err error) {
+	buf := make([]byte, secretLength)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	key = Prefix + base64.RawURLEncoding.EncodeToString(buf)
+	return key, key[:displayLength], Hash(key), nil
+}
+
+// Hash возвращает хэш ключа API, под которым он хранится.
+// Ключ содержит 256 случайных бит, поэтому соль и медленное хэширование не нужны.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey проверяет, что значение заголовка Authorization похоже на ключ API, а не на JWT-токен.
+func IsAPIKey(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// ValidateScopes проверяет список разрешений и возвращает его без повторов в отсортированном виде.
+func ValidateScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, ErrEmptyScopes
+	}
+	seen := make(map[string]struct{}, len(scopes))
+	result := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !HasScope(Scopes, scope) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+		if _, ok := seen[scope]; ok {
+			continue
+		}
+		seen[scope] = struct{}{}
+		result = append(result, scope)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// HasScope проверяет, что разрешение scope есть в списке scopes.
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// scopesKey - ключ контекста запроса, под которым хранятся разрешения ключа API.
+type scopesKey struct{}
+
+// WithScopes возвращает контекст с разрешениями ключа API, которым аутентифицирован запрос.
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesKey{}, scopes)
+}
+
+// ScopesFromContext возвращает разрешения ключа API из контекста.
+// Второе значение false означает, что запрос аутентифицирован не ключом API.
+func ScopesFromContext(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(scopesKey{}).([]string)
+	return scopes, ok
+}
+
+// Allowed проверяет, разрешено ли действие scope запросу с контекстом ctx.
+// Запросам, аутентифицированным куки или JWT-токеном, разрешено всё.
+func Allowed(ctx context.Context, scope string) bool {
+	scopes, ok := ScopesFromContext(ctx)
+	return !ok || HasScope(scopes, scope)
+}
@@ -0,0 +1,57 @@
+package apikey
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	key, prefix, hash, err := Generate()
+	assert.NoError(t, err)
+	assert.True(t, IsAPIKey(key))
+	assert.True(t, strings.HasPrefix(key, prefix))
+	assert.Len(t, prefix, displayLength)
+	assert.Equal(t, Hash(key), hash)
+	assert.NotContains(t, hash, key)
+
+	other, _, otherHash, err := Generate()
+	assert.NoError(t, err)
+	assert.NotEqual(t, key, other)
+	assert.NotEqual(t, hash, otherHash)
+}
+
+func TestValidateScopes(t *testing.T) {
+	testCases := []struct {
+		name        string
+		scopes      []string
+		expected    []string
+		expectedErr error
+	}{
+		{name: "All scopes", scopes: []string{ScopeRead, ScopeCreate, ScopeDelete}, expected: []string{ScopeCreate, ScopeDelete, ScopeRead}},
+		{name: "Duplicates", scopes: []string{ScopeRead, ScopeRead}, expected: []string{ScopeRead}},
+		{name: "Empty", scopes: nil, expectedErr: ErrEmptyScopes},
+		{name: "Unknown scope", scopes: []string{ScopeRead, "admin"}, expectedErr: ErrInvalidScope},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scopes, err := ValidateScopes(tc.scopes)
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expected, scopes)
+		})
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	assert.True(t, Allowed(context.Background(), ScopeDelete), "requests without api key are not restricted")
+
+	ctx := WithScopes(context.Background(), []string{ScopeRead})
+	assert.True(t, Allowed(ctx, ScopeRead))
+	assert.False(t, Allowed(ctx, ScopeDelete))
+
+	scopes, ok := ScopesFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, []string{ScopeRead}, scopes)
+}
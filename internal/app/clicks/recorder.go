@@ -0,0 +1,118 @@
+package clicks
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shekshuev/shortener/internal/app/logger"
+	"github.com/shekshuev/shortener/internal/app/models"
+)
+
+// Параметры записи событий по умолчанию.
+const (
+	DefaultBufferSize    = 4096
+	DefaultBatchSize     = 256
+	DefaultFlushInterval = time.Second
+	saveTimeout          = 5 * time.Second
+)
+
+// Saver - интерфейс хранилища, в которое сохраняются события переходов.
+type Saver interface {
+	SaveClicks(ctx context.Context, events []models.ClickEvent) error
+}
+
+// Recorder асинхронно записывает события переходов в хранилище пачками.
+// Запись события не блокирует вызывающего: при переполнении буфера событие отбрасывается.
+type Recorder struct {
+	saver         Saver
+	events        chan models.ClickEvent
+	batchSize     int
+	flushInterval time.Duration
+	mx            sync.RWMutex
+	closed        bool
+	done          chan struct{}
+}
+
+// NewRecorder создаёт Recorder и запускает фоновую запись событий.
+func NewRecorder(saver Saver, bufferSize, batchSize int, flushInterval time.Duration) *Recorder {
+	r := &Recorder{
+		saver:         saver,
+		events:        make(chan models.ClickEvent, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Record ставит событие в очередь на запись. Возвращает false, если событие отброшено.
+func (r *Recorder) Record(event models.ClickEvent) bool {
+	r.mx.RLock()
+	defer r.mx.RUnlock()
+	if r.closed {
+		return false
+	}
+	select {
+	case r.events <- event:
+		return true
+	default:
+		logger.NewLogger().Log.Warn("Click buffer is full, event dropped", zap.String("short_url", event.ShortURL))
+		return false
+	}
+}
+
+// Close прекращает приём событий и дожидается записи уже поставленных в очередь.
+func (r *Recorder) Close() {
+	r.mx.Lock()
+	if r.closed {
+		r.mx.Unlock()
+		return
+	}
+	r.closed = true
+	close(r.events)
+	r.mx.Unlock()
+	<-r.done
+}
+
+// run собирает события в пачки и сохраняет их по заполнении пачки или по таймеру.
+func (r *Recorder) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+	batch := make([]models.ClickEvent, 0, r.batchSize)
+	for {
+		select {
+		case event, ok := <-r.events:
+			if !ok {
+				r.flush(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= r.batchSize {
+				r.flush(batch)
+				batch = make([]models.ClickEvent, 0, r.batchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				r.flush(batch)
+				batch = make([]models.ClickEvent, 0, r.batchSize)
+			}
+		}
+	}
+}
+
+// flush сохраняет пачку событий в хранилище.
+func (r *Recorder) flush(batch []models.ClickEvent) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), saveTimeout)
+	defer cancel()
+	if err := r.saver.SaveClicks(ctx, batch); err != nil {
+		logger.NewLogger().Log.Error("Error saving clicks", zap.Int("count", len(batch)), zap.Error(err))
+	}
+}
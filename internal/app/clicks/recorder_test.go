@@ -0,0 +1,66 @@
+package clicks
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shekshuev/shortener/internal/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type saverStub struct {
+	mx      sync.Mutex
+	batches [][]models.ClickEvent
+}
+
+func (s *saverStub) SaveClicks(_ context.Context, events []models.ClickEvent) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.batches = append(s.batches, events)
+	return nil
+}
+
+func (s *saverStub) count() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	total := 0
+	for _, batch := range s.batches {
+		total += len(batch)
+	}
+	return total
+}
+
+func TestRecorder_FlushesFullBatch(t *testing.T) {
+	saver := &saverStub{}
+	r := NewRecorder(saver, 10, 2, time.Hour)
+	defer r.Close()
+
+	assert.True(t, r.Record(models.ClickEvent{ShortURL: "short1"}))
+	assert.True(t, r.Record(models.ClickEvent{ShortURL: "short1"}))
+
+	assert.Eventually(t, func() bool { return saver.count() == 2 }, time.Second, 5*time.Millisecond)
+}
+
+func TestRecorder_FlushesByInterval(t *testing.T) {
+	saver := &saverStub{}
+	r := NewRecorder(saver, 10, 100, 10*time.Millisecond)
+	defer r.Close()
+
+	assert.True(t, r.Record(models.ClickEvent{ShortURL: "short1"}))
+
+	assert.Eventually(t, func() bool { return saver.count() == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestRecorder_CloseDrainsBuffer(t *testing.T) {
+	saver := &saverStub{}
+	r := NewRecorder(saver, 10, 100, time.Hour)
+	for i := 0; i < 5; i++ {
+		assert.True(t, r.Record(models.ClickEvent{ShortURL: "short1"}))
+	}
+	r.Close()
+
+	assert.Equal(t, 5, saver.count())
+	assert.False(t, r.Record(models.ClickEvent{ShortURL: "short1"}), "Closed recorder should drop events")
+}
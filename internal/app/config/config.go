@@ -3,7 +3,11 @@ package config
 import (
 	"encoding/json"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 	"github.com/shekshuev/shortener/internal/app/logger"
@@ -12,48 +16,134 @@ import (
 
 // Config содержит настройки приложения, включая параметры сервера, базы данных и файлового хранилища.
 type Config struct {
-	ServerAddress            string // Адрес и порт, на котором запускается сервер.
-	BaseURL                  string // Базовый URL для сокращённых ссылок.
-	FileStoragePath          string // Путь к файлу для хранения сокращённых URL.
-	DatabaseDSN              string // Строка подключения к базе данных.
-	EnableHTTPS              bool   // Включить HTTPS.
-	CertFile                 string // Путь к файлу с сертификатом.
-	KeyFile                  string // путь к файлу с ключом.
-	TrustedSubnet            string // Доверенная подсеть в CIDR-формате.
-	GRPCServerAddress        string // Адрес GRPC
-	DefaultServerAddress     string // Значение по умолчанию для ServerAddress.
-	DefaultBaseURL           string // Значение по умолчанию для BaseURL.
-	DefaultFileStoragePath   string // Значение по умолчанию для FileStoragePath.
-	DefaultDatabaseDSN       string // Значение по умолчанию для DatabaseDSN.
-	DefaultEnableHTTPS       bool   // Значение по умолчанию для EnableHTTPS.
-	DefaultCertFile          string // Значение по умолчанию для CertFile.
-	DefaultKeyFile           string // Значение по умолчанию для KeyFile.
-	DefaultTrustedSubnet     string // Значение по умолчанию для TrustedSubnet.
-	DefaultGRPCServerAddress string // Значение по умолчанию для GRPCServerAddress.
+	ServerAddress             string        // Адрес и порт, на котором запускается сервер.
+	BaseURL                   string        // Базовый URL для сокращённых ссылок.
+	FileStoragePath           string        // Путь к файлу для хранения сокращённых URL.
+	DatabaseDSN               string        // Строка подключения к базе данных.
+	EnableHTTPS               bool          // Включить HTTPS.
+	CertFile                  string        // Путь к файлу с сертификатом.
+	KeyFile                   string        // путь к файлу с ключом.
+	TrustedSubnet             string        // Доверенная подсеть в CIDR-формате.
+	GRPCServerAddress         string        // Адрес GRPC
+	ReapInterval              time.Duration // Интервал удаления ссылок с истёкшим сроком действия и давно удалённых ссылок.
+	WALSyncPolicy             string        // Политика fsync журнала упреждающей записи: always, interval или never.
+	WALSyncInterval           time.Duration // Интервал fsync журнала упреждающей записи для политики interval.
+	SnapshotInterval          time.Duration // Интервал периодического создания снапшота хранилища в памяти.
+	StoreBackend              string        // Тип хранилища: memory, file или postgres. Пустое значение - выбор по DatabaseDSN.
+	KeyStrategy               string        // Стратегия генерации коротких ключей: random, sequence, hash или obfuscated.
+	KeyLength                 int           // Длина генерируемых коротких ключей.
+	KeyCharset                string        // Алфавит генерируемых коротких ключей.
+	CacheSize                 int           // Максимальное количество ссылок в кэше перед хранилищем, 0 - кэш отключён.
+	CacheTTL                  time.Duration // Время жизни записей кэша, включая закэшированные промахи.
+	RestoreGracePeriod        time.Duration // Время после удаления ссылки, в течение которого её можно восстановить.
+	DeletedRetention          time.Duration // Время хранения удалённых ссылок до окончательного удаления, 0 - хранить бессрочно.
+	RedirectType              int           // Код ответа по умолчанию при переходе по ссылке без собственного типа перенаправления.
+	JWTSecret                 string        // Секретный ключ подписи токенов авторизации.
+	JWTKeyFile                string        // Путь к JSON-файлу с ключами подписи токенов авторизации.
+	JWTKeyGrace               time.Duration // Время, в течение которого принимаются токены, подписанные выведенным из оборота ключом.
+	JWTPrivateKeyFile         string        // Путь к PEM-файлу закрытого ключа RSA или Ed25519 для подписи токенов авторизации.
+	JWTRefreshWindow          time.Duration // Время после истечения токена, в течение которого куки перевыпускается для того же пользователя.
+	DefaultServerAddress      string        // Значение по умолчанию для ServerAddress.
+	DefaultBaseURL            string        // Значение по умолчанию для BaseURL.
+	DefaultFileStoragePath    string        // Значение по умолчанию для FileStoragePath.
+	DefaultDatabaseDSN        string        // Значение по умолчанию для DatabaseDSN.
+	DefaultEnableHTTPS        bool          // Значение по умолчанию для EnableHTTPS.
+	DefaultCertFile           string        // Значение по умолчанию для CertFile.
+	DefaultKeyFile            string        // Значение по умолчанию для KeyFile.
+	DefaultTrustedSubnet      string        // Значение по умолчанию для TrustedSubnet.
+	DefaultGRPCServerAddress  string        // Значение по умолчанию для GRPCServerAddress.
+	DefaultReapInterval       time.Duration // Значение по умолчанию для ReapInterval.
+	DefaultWALSyncPolicy      string        // Значение по умолчанию для WALSyncPolicy.
+	DefaultWALSyncInterval    time.Duration // Значение по умолчанию для WALSyncInterval.
+	DefaultSnapshotInterval   time.Duration // Значение по умолчанию для SnapshotInterval.
+	DefaultStoreBackend       string        // Значение по умолчанию для StoreBackend.
+	DefaultKeyStrategy        string        // Значение по умолчанию для KeyStrategy.
+	DefaultKeyLength          int           // Значение по умолчанию для KeyLength.
+	DefaultKeyCharset         string        // Значение по умолчанию для KeyCharset.
+	DefaultCacheSize          int           // Значение по умолчанию для CacheSize.
+	DefaultCacheTTL           time.Duration // Значение по умолчанию для CacheTTL.
+	DefaultRestoreGracePeriod time.Duration // Значение по умолчанию для RestoreGracePeriod.
+	DefaultDeletedRetention   time.Duration // Значение по умолчанию для DeletedRetention.
+	DefaultRedirectType       int           // Значение по умолчанию для RedirectType.
+	DefaultJWTSecret          string        // Значение по умолчанию для JWTSecret.
+	DefaultJWTKeyFile         string        // Значение по умолчанию для JWTKeyFile.
+	DefaultJWTKeyGrace        time.Duration // Значение по умолчанию для JWTKeyGrace.
+	DefaultJWTPrivateKeyFile  string        // Значение по умолчанию для JWTPrivateKeyFile.
+	DefaultJWTRefreshWindow   time.Duration // Значение по умолчанию для JWTRefreshWindow.
 }
 
 type envConfig struct {
-	ServerAddress     string `env:"SERVER_ADDRESS"`
-	BaseURL           string `env:"BASE_URL"`
-	FileStoragePath   string `env:"FILE_STORAGE_PATH"`
-	DatabaseDSN       string `env:"DATABASE_DSN"`
-	EnableHTTPS       string `env:"ENABLE_HTTPS"`
-	CertFile          string `env:"TLS_CERT"`
-	KeyFile           string `env:"TLS_KEY"`
-	TrustedSubnet     string `env:"TRUSTED_SUBNET"`
-	GRPCServerAddress string `env:"GRPC_SERVER_ADDRESS"`
+	ServerAddress      string `env:"SERVER_ADDRESS"`
+	BaseURL            string `env:"BASE_URL"`
+	FileStoragePath    string `env:"FILE_STORAGE_PATH"`
+	DatabaseDSN        string `env:"DATABASE_DSN"`
+	EnableHTTPS        string `env:"ENABLE_HTTPS"`
+	CertFile           string `env:"TLS_CERT"`
+	KeyFile            string `env:"TLS_KEY"`
+	TrustedSubnet      string `env:"TRUSTED_SUBNET"`
+	GRPCServerAddress  string `env:"GRPC_SERVER_ADDRESS"`
+	ReapInterval       string `env:"REAP_INTERVAL"`
+	WALSyncPolicy      string `env:"WAL_SYNC"`
+	WALSyncInterval    string `env:"WAL_SYNC_INTERVAL"`
+	SnapshotInterval   string `env:"SNAPSHOT_INTERVAL"`
+	StoreBackend       string `env:"STORE_BACKEND"`
+	KeyStrategy        string `env:"KEY_STRATEGY"`
+	KeyLength          string `env:"KEY_LENGTH"`
+	KeyCharset         string `env:"KEY_CHARSET"`
+	CacheSize          string `env:"CACHE_SIZE"`
+	CacheTTL           string `env:"CACHE_TTL"`
+	RestoreGracePeriod string `env:"RESTORE_GRACE_PERIOD"`
+	DeletedRetention   string `env:"DELETED_RETENTION"`
+	RedirectType       string `env:"REDIRECT_TYPE"`
+	JWTSecret          string `env:"JWT_SECRET"`
+	JWTKeyFile         string `env:"JWT_KEY_FILE"`
+	JWTKeyGrace        string `env:"JWT_KEY_GRACE"`
+	JWTPrivateKeyFile  string `env:"JWT_PRIVATE_KEY_FILE"`
+	JWTRefreshWindow   string `env:"JWT_REFRESH_WINDOW"`
 }
 
 type jsonConfig struct {
-	ServerAddress     string `json:"server_address"`
-	BaseURL           string `json:"base_url"`
-	FileStoragePath   string `json:"file_storage_path"`
-	DatabaseDSN       string `json:"database_dsn"`
-	EnableHTTPS       bool   `json:"enable_https"`
-	CertFile          string `json:"cert_file"`
-	KeyFile           string `json:"key_file"`
-	TrustedSubnet     string `json:"trusted_subnet"`
-	GRPCServerAddress string `json:"grpc_server_address"`
+	ServerAddress      string `json:"server_address"`
+	BaseURL            string `json:"base_url"`
+	FileStoragePath    string `json:"file_storage_path"`
+	DatabaseDSN        string `json:"database_dsn"`
+	EnableHTTPS        bool   `json:"enable_https"`
+	CertFile           string `json:"cert_file"`
+	KeyFile            string `json:"key_file"`
+	TrustedSubnet      string `json:"trusted_subnet"`
+	GRPCServerAddress  string `json:"grpc_server_address"`
+	ReapInterval       string `json:"reap_interval"`
+	WALSyncPolicy      string `json:"wal_sync"`
+	WALSyncInterval    string `json:"wal_sync_interval"`
+	SnapshotInterval   string `json:"snapshot_interval"`
+	StoreBackend       string `json:"store_backend"`
+	KeyStrategy        string `json:"key_strategy"`
+	KeyLength          int    `json:"key_length"`
+	KeyCharset         string `json:"key_charset"`
+	CacheSize          int    `json:"cache_size"`
+	CacheTTL           string `json:"cache_ttl"`
+	RestoreGracePeriod string `json:"restore_grace_period"`
+	DeletedRetention   string `json:"deleted_retention"`
+	RedirectType       int    `json:"redirect_type"`
+	JWTSecret          string `json:"jwt_secret"`
+	JWTKeyFile         string `json:"jwt_key_file"`
+	JWTKeyGrace        string `json:"jwt_key_grace"`
+	JWTPrivateKeyFile  string `json:"jwt_private_key_file"`
+	JWTRefreshWindow   string `json:"jwt_refresh_window"`
+}
+
+// Ошибки проверки настроек.
+var (
+	ErrDeletedRetentionTooShort = fmt.Errorf("deleted retention must not be shorter than restore grace period") // Ошибка: удалённые ссылки удалялись бы раньше, чем истечёт срок их восстановления
+)
+
+// Validate проверяет согласованность настроек.
+// DeletedRetention, равное 0, означает бессрочное хранение и допустимо при любом RestoreGracePeriod.
+func (c *Config) Validate() error {
+	if c.DeletedRetention > 0 && c.DeletedRetention < c.RestoreGracePeriod {
+		return fmt.Errorf("%w: %s < %s", ErrDeletedRetentionTooShort, c.DeletedRetention, c.RestoreGracePeriod)
+	}
+	return nil
 }
 
 // GetConfig возвращает экземпляр конфига
@@ -68,6 +158,24 @@ func GetConfig() Config {
 	cfg.DefaultKeyFile = ""
 	cfg.DefaultTrustedSubnet = ""
 	cfg.DefaultGRPCServerAddress = "localhost:50051"
+	cfg.DefaultReapInterval = time.Minute
+	cfg.DefaultWALSyncPolicy = "interval"
+	cfg.DefaultWALSyncInterval = time.Second
+	cfg.DefaultSnapshotInterval = 5 * time.Minute
+	cfg.DefaultStoreBackend = ""
+	cfg.DefaultKeyStrategy = "random"
+	cfg.DefaultKeyLength = 8
+	cfg.DefaultKeyCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	cfg.DefaultCacheSize = 0
+	cfg.DefaultCacheTTL = time.Minute
+	cfg.DefaultRestoreGracePeriod = 24 * time.Hour
+	cfg.DefaultDeletedRetention = 30 * 24 * time.Hour
+	cfg.DefaultRedirectType = http.StatusTemporaryRedirect
+	cfg.DefaultJWTSecret = ""
+	cfg.DefaultJWTKeyFile = ""
+	cfg.DefaultJWTKeyGrace = 3 * time.Hour
+	cfg.DefaultJWTPrivateKeyFile = ""
+	cfg.DefaultJWTRefreshWindow = 24 * time.Hour
 	parseFlags(&cfg)
 	parsEnv(&cfg)
 	return cfg
@@ -126,6 +234,96 @@ func parseFlags(cfg *Config) {
 	} else {
 		cfg.GRPCServerAddress = cfg.DefaultGRPCServerAddress
 	}
+	if f := flag.Lookup("reap-interval"); f == nil {
+		flag.DurationVar(&cfg.ReapInterval, "reap-interval", cfg.DefaultReapInterval, "interval of deleting expired urls and purging deleted urls")
+	} else {
+		cfg.ReapInterval = cfg.DefaultReapInterval
+	}
+	if f := flag.Lookup("wal-sync"); f == nil {
+		flag.StringVar(&cfg.WALSyncPolicy, "wal-sync", cfg.DefaultWALSyncPolicy, "write-ahead log fsync policy: always, interval or never")
+	} else {
+		cfg.WALSyncPolicy = cfg.DefaultWALSyncPolicy
+	}
+	if f := flag.Lookup("wal-sync-interval"); f == nil {
+		flag.DurationVar(&cfg.WALSyncInterval, "wal-sync-interval", cfg.DefaultWALSyncInterval, "interval of write-ahead log fsync for interval policy")
+	} else {
+		cfg.WALSyncInterval = cfg.DefaultWALSyncInterval
+	}
+	if f := flag.Lookup("snapshot-interval"); f == nil {
+		flag.DurationVar(&cfg.SnapshotInterval, "snapshot-interval", cfg.DefaultSnapshotInterval, "interval of in-memory storage snapshots, 0 disables periodic snapshots")
+	} else {
+		cfg.SnapshotInterval = cfg.DefaultSnapshotInterval
+	}
+	if f := flag.Lookup("store-backend"); f == nil {
+		flag.StringVar(&cfg.StoreBackend, "store-backend", cfg.DefaultStoreBackend, "storage backend: memory, file or postgres (by default postgres if database DSN is set, memory otherwise)")
+	} else {
+		cfg.StoreBackend = cfg.DefaultStoreBackend
+	}
+	if f := flag.Lookup("key-strategy"); f == nil {
+		flag.StringVar(&cfg.KeyStrategy, "key-strategy", cfg.DefaultKeyStrategy, "short key generation strategy: random, sequence, hash or obfuscated")
+	} else {
+		cfg.KeyStrategy = cfg.DefaultKeyStrategy
+	}
+	if f := flag.Lookup("key-length"); f == nil {
+		flag.IntVar(&cfg.KeyLength, "key-length", cfg.DefaultKeyLength, "length of generated short keys")
+	} else {
+		cfg.KeyLength = cfg.DefaultKeyLength
+	}
+	if f := flag.Lookup("key-charset"); f == nil {
+		flag.StringVar(&cfg.KeyCharset, "key-charset", cfg.DefaultKeyCharset, "alphabet of generated short keys")
+	} else {
+		cfg.KeyCharset = cfg.DefaultKeyCharset
+	}
+	if f := flag.Lookup("cache-size"); f == nil {
+		flag.IntVar(&cfg.CacheSize, "cache-size", cfg.DefaultCacheSize, "maximum number of cached short links, 0 disables the cache")
+	} else {
+		cfg.CacheSize = cfg.DefaultCacheSize
+	}
+	if f := flag.Lookup("cache-ttl"); f == nil {
+		flag.DurationVar(&cfg.CacheTTL, "cache-ttl", cfg.DefaultCacheTTL, "lifetime of cached short links and cached misses")
+	} else {
+		cfg.CacheTTL = cfg.DefaultCacheTTL
+	}
+	if f := flag.Lookup("restore-grace-period"); f == nil {
+		flag.DurationVar(&cfg.RestoreGracePeriod, "restore-grace-period", cfg.DefaultRestoreGracePeriod, "period after deletion during which a link can be restored")
+	} else {
+		cfg.RestoreGracePeriod = cfg.DefaultRestoreGracePeriod
+	}
+	if f := flag.Lookup("deleted-retention"); f == nil {
+		flag.DurationVar(&cfg.DeletedRetention, "deleted-retention", cfg.DefaultDeletedRetention, "retention of deleted links before they are purged permanently, 0 keeps them forever")
+	} else {
+		cfg.DeletedRetention = cfg.DefaultDeletedRetention
+	}
+	if f := flag.Lookup("redirect-type"); f == nil {
+		flag.IntVar(&cfg.RedirectType, "redirect-type", cfg.DefaultRedirectType, "default HTTP status for redirects of links without their own redirect type: 301, 302, 307 or 308")
+	} else {
+		cfg.RedirectType = cfg.DefaultRedirectType
+	}
+	if f := flag.Lookup("jwt-secret"); f == nil {
+		flag.StringVar(&cfg.JWTSecret, "jwt-secret", cfg.DefaultJWTSecret, "secret key for signing auth tokens")
+	} else {
+		cfg.JWTSecret = cfg.DefaultJWTSecret
+	}
+	if f := flag.Lookup("jwt-key-file"); f == nil {
+		flag.StringVar(&cfg.JWTKeyFile, "jwt-key-file", cfg.DefaultJWTKeyFile, "path to JSON file with auth token signing keys")
+	} else {
+		cfg.JWTKeyFile = cfg.DefaultJWTKeyFile
+	}
+	if f := flag.Lookup("jwt-key-grace"); f == nil {
+		flag.DurationVar(&cfg.JWTKeyGrace, "jwt-key-grace", cfg.DefaultJWTKeyGrace, "how long tokens signed with a retired key stay valid")
+	} else {
+		cfg.JWTKeyGrace = cfg.DefaultJWTKeyGrace
+	}
+	if f := flag.Lookup("jwt-private-key-file"); f == nil {
+		flag.StringVar(&cfg.JWTPrivateKeyFile, "jwt-private-key-file", cfg.DefaultJWTPrivateKeyFile, "path to PEM file with RSA or Ed25519 private key for signing auth tokens")
+	} else {
+		cfg.JWTPrivateKeyFile = cfg.DefaultJWTPrivateKeyFile
+	}
+	if f := flag.Lookup("jwt-refresh-window"); f == nil {
+		flag.DurationVar(&cfg.JWTRefreshWindow, "jwt-refresh-window", cfg.DefaultJWTRefreshWindow, "how long after expiry an auth cookie is reissued for the same user")
+	} else {
+		cfg.JWTRefreshWindow = cfg.DefaultJWTRefreshWindow
+	}
 	flag.Parse()
 	parseJSON(configPath, cfg)
 	parsEnv(cfg)
@@ -165,6 +363,104 @@ func parsEnv(cfg *Config) {
 	if len(envCfg.GRPCServerAddress) > 0 {
 		cfg.GRPCServerAddress = envCfg.GRPCServerAddress
 	}
+	if len(envCfg.ReapInterval) > 0 {
+		if interval, err := time.ParseDuration(envCfg.ReapInterval); err == nil {
+			cfg.ReapInterval = interval
+		} else {
+			l.Log.Error("Invalid reap interval", zap.Error(err))
+		}
+	}
+	if len(envCfg.WALSyncPolicy) > 0 {
+		cfg.WALSyncPolicy = envCfg.WALSyncPolicy
+	}
+	if len(envCfg.WALSyncInterval) > 0 {
+		if value, err := time.ParseDuration(envCfg.WALSyncInterval); err == nil {
+			cfg.WALSyncInterval = value
+		} else {
+			l.Log.Error("Invalid write-ahead log sync interval", zap.Error(err))
+		}
+	}
+	if len(envCfg.SnapshotInterval) > 0 {
+		if value, err := time.ParseDuration(envCfg.SnapshotInterval); err == nil {
+			cfg.SnapshotInterval = value
+		} else {
+			l.Log.Error("Invalid snapshot interval", zap.Error(err))
+		}
+	}
+	if len(envCfg.StoreBackend) > 0 {
+		cfg.StoreBackend = envCfg.StoreBackend
+	}
+	if len(envCfg.KeyStrategy) > 0 {
+		cfg.KeyStrategy = envCfg.KeyStrategy
+	}
+	if len(envCfg.KeyLength) > 0 {
+		if value, err := strconv.Atoi(envCfg.KeyLength); err == nil {
+			cfg.KeyLength = value
+		} else {
+			l.Log.Error("Invalid key length", zap.Error(err))
+		}
+	}
+	if len(envCfg.KeyCharset) > 0 {
+		cfg.KeyCharset = envCfg.KeyCharset
+	}
+	if len(envCfg.CacheSize) > 0 {
+		if value, err := strconv.Atoi(envCfg.CacheSize); err == nil {
+			cfg.CacheSize = value
+		} else {
+			l.Log.Error("Invalid cache size", zap.Error(err))
+		}
+	}
+	if len(envCfg.CacheTTL) > 0 {
+		if value, err := time.ParseDuration(envCfg.CacheTTL); err == nil {
+			cfg.CacheTTL = value
+		} else {
+			l.Log.Error("Invalid cache ttl", zap.Error(err))
+		}
+	}
+	if len(envCfg.RestoreGracePeriod) > 0 {
+		if value, err := time.ParseDuration(envCfg.RestoreGracePeriod); err == nil {
+			cfg.RestoreGracePeriod = value
+		} else {
+			l.Log.Error("Invalid restore grace period", zap.Error(err))
+		}
+	}
+	if len(envCfg.DeletedRetention) > 0 {
+		if value, err := time.ParseDuration(envCfg.DeletedRetention); err == nil {
+			cfg.DeletedRetention = value
+		} else {
+			l.Log.Error("Invalid deleted retention", zap.Error(err))
+		}
+	}
+	if len(envCfg.RedirectType) > 0 {
+		if value, err := strconv.Atoi(envCfg.RedirectType); err == nil {
+			cfg.RedirectType = value
+		} else {
+			l.Log.Error("Invalid redirect type", zap.Error(err))
+		}
+	}
+	if len(envCfg.JWTSecret) > 0 {
+		cfg.JWTSecret = envCfg.JWTSecret
+	}
+	if len(envCfg.JWTKeyFile) > 0 {
+		cfg.JWTKeyFile = envCfg.JWTKeyFile
+	}
+	if len(envCfg.JWTKeyGrace) > 0 {
+		if value, err := time.ParseDuration(envCfg.JWTKeyGrace); err == nil {
+			cfg.JWTKeyGrace = value
+		} else {
+			l.Log.Error("Invalid JWT key grace", zap.Error(err))
+		}
+	}
+	if len(envCfg.JWTPrivateKeyFile) > 0 {
+		cfg.JWTPrivateKeyFile = envCfg.JWTPrivateKeyFile
+	}
+	if len(envCfg.JWTRefreshWindow) > 0 {
+		if value, err := time.ParseDuration(envCfg.JWTRefreshWindow); err == nil {
+			cfg.JWTRefreshWindow = value
+		} else {
+			l.Log.Error("Invalid JWT refresh window", zap.Error(err))
+		}
+	}
 }
 
 func parseJSON(path string, cfg *Config) {
@@ -215,4 +511,90 @@ func parseJSON(path string, cfg *Config) {
 	if cfg.GRPCServerAddress == cfg.DefaultGRPCServerAddress && jCfg.GRPCServerAddress != "" {
 		cfg.GRPCServerAddress = jCfg.GRPCServerAddress
 	}
+	if cfg.ReapInterval == cfg.DefaultReapInterval && jCfg.ReapInterval != "" {
+		if interval, err := time.ParseDuration(jCfg.ReapInterval); err == nil {
+			cfg.ReapInterval = interval
+		} else {
+			logger.NewLogger().Log.Warn("Invalid reap interval in config JSON", zap.Error(err))
+		}
+	}
+	if cfg.WALSyncPolicy == cfg.DefaultWALSyncPolicy && jCfg.WALSyncPolicy != "" {
+		cfg.WALSyncPolicy = jCfg.WALSyncPolicy
+	}
+	if cfg.WALSyncInterval == cfg.DefaultWALSyncInterval && jCfg.WALSyncInterval != "" {
+		if value, err := time.ParseDuration(jCfg.WALSyncInterval); err == nil {
+			cfg.WALSyncInterval = value
+		} else {
+			logger.NewLogger().Log.Warn("Invalid write-ahead log sync interval in config JSON", zap.Error(err))
+		}
+	}
+	if cfg.SnapshotInterval == cfg.DefaultSnapshotInterval && jCfg.SnapshotInterval != "" {
+		if value, err := time.ParseDuration(jCfg.SnapshotInterval); err == nil {
+			cfg.SnapshotInterval = value
+		} else {
+			logger.NewLogger().Log.Warn("Invalid snapshot interval in config JSON", zap.Error(err))
+		}
+	}
+	if cfg.StoreBackend == cfg.DefaultStoreBackend && jCfg.StoreBackend != "" {
+		cfg.StoreBackend = jCfg.StoreBackend
+	}
+	if cfg.KeyStrategy == cfg.DefaultKeyStrategy && jCfg.KeyStrategy != "" {
+		cfg.KeyStrategy = jCfg.KeyStrategy
+	}
+	if cfg.KeyLength == cfg.DefaultKeyLength && jCfg.KeyLength > 0 {
+		cfg.KeyLength = jCfg.KeyLength
+	}
+	if cfg.KeyCharset == cfg.DefaultKeyCharset && jCfg.KeyCharset != "" {
+		cfg.KeyCharset = jCfg.KeyCharset
+	}
+	if cfg.CacheSize == cfg.DefaultCacheSize && jCfg.CacheSize > 0 {
+		cfg.CacheSize = jCfg.CacheSize
+	}
+	if cfg.CacheTTL == cfg.DefaultCacheTTL && jCfg.CacheTTL != "" {
+		if value, err := time.ParseDuration(jCfg.CacheTTL); err == nil {
+			cfg.CacheTTL = value
+		} else {
+			logger.NewLogger().Log.Warn("Invalid cache ttl in config JSON", zap.Error(err))
+		}
+	}
+	if cfg.RestoreGracePeriod == cfg.DefaultRestoreGracePeriod && jCfg.RestoreGracePeriod != "" {
+		if value, err := time.ParseDuration(jCfg.RestoreGracePeriod); err == nil {
+			cfg.RestoreGracePeriod = value
+		} else {
+			logger.NewLogger().Log.Warn("Invalid restore grace period in config JSON", zap.Error(err))
+		}
+	}
+	if cfg.DeletedRetention == cfg.DefaultDeletedRetention && jCfg.DeletedRetention != "" {
+		if value, err := time.ParseDuration(jCfg.DeletedRetention); err == nil {
+			cfg.DeletedRetention = value
+		} else {
+			logger.NewLogger().Log.Warn("Invalid deleted retention in config JSON", zap.Error(err))
+		}
+	}
+	if cfg.RedirectType == cfg.DefaultRedirectType && jCfg.RedirectType > 0 {
+		cfg.RedirectType = jCfg.RedirectType
+	}
+	if cfg.JWTSecret == cfg.DefaultJWTSecret && jCfg.JWTSecret != "" {
+		cfg.JWTSecret = jCfg.JWTSecret
+	}
+	if cfg.JWTKeyFile == cfg.DefaultJWTKeyFile && jCfg.JWTKeyFile != "" {
+		cfg.JWTKeyFile = jCfg.JWTKeyFile
+	}
+	if cfg.JWTKeyGrace == cfg.DefaultJWTKeyGrace && jCfg.JWTKeyGrace != "" {
+		if value, err := time.ParseDuration(jCfg.JWTKeyGrace); err == nil {
+			cfg.JWTKeyGrace = value
+		} else {
+			logger.NewLogger().Log.Warn("Invalid JWT key grace in config JSON", zap.Error(err))
+		}
+	}
+	if cfg.JWTPrivateKeyFile == cfg.DefaultJWTPrivateKeyFile && jCfg.JWTPrivateKeyFile != "" {
+		cfg.JWTPrivateKeyFile = jCfg.JWTPrivateKeyFile
+	}
+	if cfg.JWTRefreshWindow == cfg.DefaultJWTRefreshWindow && jCfg.JWTRefreshWindow != "" {
+		if value, err := time.ParseDuration(jCfg.JWTRefreshWindow); err == nil {
+			cfg.JWTRefreshWindow = value
+		} else {
+			logger.NewLogger().Log.Warn("Invalid JWT refresh window in config JSON", zap.Error(err))
+		}
+	}
 }
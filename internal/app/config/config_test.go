@@ -4,6 +4,7 @@ import (
 	"flag"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -18,6 +19,23 @@ func TestGetConfig_EnvPriority(t *testing.T) {
 	key := "key"
 	subnet := "10.0.0.0/24"
 	grpcAddress := "localhost:50051"
+	reapInterval := "30s"
+	walSyncPolicy := "always"
+	walSyncInterval := "200ms"
+	snapshotInterval := "45s"
+	storeBackend := "file"
+	keyStrategy := "hash"
+	keyLength := "10"
+	keyCharset := "abc123"
+	cacheSize := "1000"
+	cacheTTL := "30s"
+	restoreGracePeriod := "2h"
+	deletedRetention := "48h"
+	redirectType := "301"
+	jwtSecret := "env-secret"
+	jwtKeyFile := "/tmp/env-keys.json"
+	jwtKeyGrace := "1h"
+	jwtPrivateKeyFile := "/tmp/env-key.pem"
 	os.Setenv("SERVER_ADDRESS", serverAddress)
 	os.Setenv("BASE_URL", baseURL)
 	os.Setenv("FILE_STORAGE_PATH", fileStoragePath)
@@ -27,6 +45,24 @@ func TestGetConfig_EnvPriority(t *testing.T) {
 	os.Setenv("TLS_KEY", key)
 	os.Setenv("TRUSTED_SUBNET", subnet)
 	os.Setenv("GRPC_SERVER_ADDRESS", grpcAddress)
+	os.Setenv("REAP_INTERVAL", reapInterval)
+	os.Setenv("WAL_SYNC", walSyncPolicy)
+	os.Setenv("WAL_SYNC_INTERVAL", walSyncInterval)
+	os.Setenv("SNAPSHOT_INTERVAL", snapshotInterval)
+	os.Setenv("STORE_BACKEND", storeBackend)
+	os.Setenv("KEY_STRATEGY", keyStrategy)
+	os.Setenv("KEY_LENGTH", keyLength)
+	os.Setenv("KEY_CHARSET", keyCharset)
+	os.Setenv("CACHE_SIZE", cacheSize)
+	os.Setenv("CACHE_TTL", cacheTTL)
+	os.Setenv("RESTORE_GRACE_PERIOD", restoreGracePeriod)
+	os.Setenv("DELETED_RETENTION", deletedRetention)
+	os.Setenv("REDIRECT_TYPE", redirectType)
+	os.Setenv("JWT_SECRET", jwtSecret)
+	os.Setenv("JWT_KEY_FILE", jwtKeyFile)
+	os.Setenv("JWT_KEY_GRACE", jwtKeyGrace)
+	os.Setenv("JWT_PRIVATE_KEY_FILE", jwtPrivateKeyFile)
+	os.Setenv("JWT_REFRESH_WINDOW", "6h")
 	defer os.Unsetenv("SERVER_ADDRESS")
 	defer os.Unsetenv("BASE_URL")
 	defer os.Unsetenv("FILE_STORAGE_PATH")
@@ -36,6 +72,24 @@ func TestGetConfig_EnvPriority(t *testing.T) {
 	defer os.Unsetenv("TLS_KEY")
 	defer os.Unsetenv("TRUSTED_SUBNET")
 	defer os.Unsetenv("GRPC_SERVER_ADDRESS")
+	defer os.Unsetenv("REAP_INTERVAL")
+	defer os.Unsetenv("WAL_SYNC")
+	defer os.Unsetenv("WAL_SYNC_INTERVAL")
+	defer os.Unsetenv("SNAPSHOT_INTERVAL")
+	defer os.Unsetenv("STORE_BACKEND")
+	defer os.Unsetenv("KEY_STRATEGY")
+	defer os.Unsetenv("KEY_LENGTH")
+	defer os.Unsetenv("KEY_CHARSET")
+	defer os.Unsetenv("CACHE_SIZE")
+	defer os.Unsetenv("CACHE_TTL")
+	defer os.Unsetenv("RESTORE_GRACE_PERIOD")
+	defer os.Unsetenv("DELETED_RETENTION")
+	defer os.Unsetenv("REDIRECT_TYPE")
+	defer os.Unsetenv("JWT_SECRET")
+	defer os.Unsetenv("JWT_KEY_FILE")
+	defer os.Unsetenv("JWT_KEY_GRACE")
+	defer os.Unsetenv("JWT_PRIVATE_KEY_FILE")
+	defer os.Unsetenv("JWT_REFRESH_WINDOW")
 	cfg := GetConfig()
 	assert.Equal(t, cfg.BaseURL, baseURL)
 	assert.Equal(t, cfg.ServerAddress, serverAddress)
@@ -46,6 +100,24 @@ func TestGetConfig_EnvPriority(t *testing.T) {
 	assert.Equal(t, cfg.KeyFile, key)
 	assert.Equal(t, cfg.TrustedSubnet, subnet)
 	assert.Equal(t, cfg.GRPCServerAddress, grpcAddress)
+	assert.Equal(t, cfg.ReapInterval, 30*time.Second)
+	assert.Equal(t, cfg.WALSyncPolicy, "always")
+	assert.Equal(t, cfg.WALSyncInterval, 200*time.Millisecond)
+	assert.Equal(t, cfg.SnapshotInterval, 45*time.Second)
+	assert.Equal(t, cfg.StoreBackend, storeBackend)
+	assert.Equal(t, cfg.KeyStrategy, "hash")
+	assert.Equal(t, cfg.KeyLength, 10)
+	assert.Equal(t, cfg.KeyCharset, "abc123")
+	assert.Equal(t, cfg.CacheSize, 1000)
+	assert.Equal(t, cfg.CacheTTL, 30*time.Second)
+	assert.Equal(t, cfg.RestoreGracePeriod, 2*time.Hour)
+	assert.Equal(t, cfg.DeletedRetention, 48*time.Hour)
+	assert.Equal(t, cfg.RedirectType, 301)
+	assert.Equal(t, cfg.JWTSecret, "env-secret")
+	assert.Equal(t, cfg.JWTKeyFile, "/tmp/env-keys.json")
+	assert.Equal(t, cfg.JWTKeyGrace, time.Hour)
+	assert.Equal(t, cfg.JWTPrivateKeyFile, "/tmp/env-key.pem")
+	assert.Equal(t, cfg.JWTRefreshWindow, 6*time.Hour)
 }
 
 func TestGetConfig_FlagPriority(t *testing.T) {
@@ -58,8 +130,9 @@ func TestGetConfig_FlagPriority(t *testing.T) {
 	key := "key"
 	subnet := "10.0.0.0/24"
 	grpcAddress := "localhost:50051"
+	reapInterval := 30 * time.Second
 	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
-	os.Args = []string{"cmd", "-a", serverAddress, "-b", baseURL, "-f", fileStoragePath, "-d", databaseDSN, "-s", "-cert", cert, "-key", key, "-t", subnet, "-grpc", grpcAddress}
+	os.Args = []string{"cmd", "-a", serverAddress, "-b", baseURL, "-f", fileStoragePath, "-d", databaseDSN, "-s", "-cert", cert, "-key", key, "-t", subnet, "-grpc", grpcAddress, "-reap-interval", reapInterval.String(), "-wal-sync", "never", "-wal-sync-interval", "2s", "-snapshot-interval", "90s", "-store-backend", "postgres", "-key-strategy", "sequence", "-key-length", "6", "-key-charset", "0123456789", "-cache-size", "500", "-cache-ttl", "2m", "-restore-grace-period", "3h", "-deleted-retention", "72h", "-redirect-type", "308", "-jwt-secret", "flag-secret", "-jwt-key-file", "/tmp/flag-keys.json", "-jwt-key-grace", "2h", "-jwt-private-key-file", "/tmp/flag-key.pem", "-jwt-refresh-window", "12h"}
 	defer func() { os.Args = os.Args[:1] }()
 	cfg := GetConfig()
 	assert.Equal(t, cfg.BaseURL, baseURL)
@@ -71,7 +144,24 @@ func TestGetConfig_FlagPriority(t *testing.T) {
 	assert.Equal(t, cfg.KeyFile, key)
 	assert.Equal(t, cfg.TrustedSubnet, subnet)
 	assert.Equal(t, cfg.GRPCServerAddress, grpcAddress)
-
+	assert.Equal(t, cfg.ReapInterval, reapInterval)
+	assert.Equal(t, cfg.WALSyncPolicy, "never")
+	assert.Equal(t, cfg.WALSyncInterval, 2*time.Second)
+	assert.Equal(t, cfg.SnapshotInterval, 90*time.Second)
+	assert.Equal(t, cfg.StoreBackend, "postgres")
+	assert.Equal(t, cfg.KeyStrategy, "sequence")
+	assert.Equal(t, cfg.KeyLength, 6)
+	assert.Equal(t, cfg.KeyCharset, "0123456789")
+	assert.Equal(t, cfg.CacheSize, 500)
+	assert.Equal(t, cfg.CacheTTL, 2*time.Minute)
+	assert.Equal(t, cfg.RestoreGracePeriod, 3*time.Hour)
+	assert.Equal(t, cfg.DeletedRetention, 72*time.Hour)
+	assert.Equal(t, cfg.RedirectType, 308)
+	assert.Equal(t, cfg.JWTSecret, "flag-secret")
+	assert.Equal(t, cfg.JWTKeyFile, "/tmp/flag-keys.json")
+	assert.Equal(t, cfg.JWTKeyGrace, 2*time.Hour)
+	assert.Equal(t, cfg.JWTPrivateKeyFile, "/tmp/flag-key.pem")
+	assert.Equal(t, cfg.JWTRefreshWindow, 12*time.Hour)
 }
 
 func TestGetConfig_DefaultPriority(t *testing.T) {
@@ -84,6 +174,24 @@ func TestGetConfig_DefaultPriority(t *testing.T) {
 	os.Unsetenv("TLS_KEY")
 	os.Unsetenv("TRUSTED_SUBNET")
 	os.Unsetenv("GRPC_SERVER_ADDRESS")
+	os.Unsetenv("REAP_INTERVAL")
+	os.Unsetenv("WAL_SYNC")
+	os.Unsetenv("WAL_SYNC_INTERVAL")
+	os.Unsetenv("SNAPSHOT_INTERVAL")
+	os.Unsetenv("STORE_BACKEND")
+	os.Unsetenv("KEY_STRATEGY")
+	os.Unsetenv("KEY_LENGTH")
+	os.Unsetenv("KEY_CHARSET")
+	os.Unsetenv("CACHE_SIZE")
+	os.Unsetenv("CACHE_TTL")
+	os.Unsetenv("RESTORE_GRACE_PERIOD")
+	os.Unsetenv("DELETED_RETENTION")
+	os.Unsetenv("REDIRECT_TYPE")
+	os.Unsetenv("JWT_SECRET")
+	os.Unsetenv("JWT_KEY_FILE")
+	os.Unsetenv("JWT_KEY_GRACE")
+	os.Unsetenv("JWT_PRIVATE_KEY_FILE")
+	os.Unsetenv("JWT_REFRESH_WINDOW")
 	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	os.Args = []string{"cmd"}
 	cfg := GetConfig()
@@ -96,6 +204,24 @@ func TestGetConfig_DefaultPriority(t *testing.T) {
 	assert.Equal(t, cfg.KeyFile, cfg.DefaultKeyFile)
 	assert.Equal(t, cfg.TrustedSubnet, cfg.DefaultTrustedSubnet)
 	assert.Equal(t, cfg.GRPCServerAddress, cfg.DefaultGRPCServerAddress)
+	assert.Equal(t, cfg.ReapInterval, cfg.DefaultReapInterval)
+	assert.Equal(t, cfg.WALSyncPolicy, cfg.DefaultWALSyncPolicy)
+	assert.Equal(t, cfg.WALSyncInterval, cfg.DefaultWALSyncInterval)
+	assert.Equal(t, cfg.SnapshotInterval, cfg.DefaultSnapshotInterval)
+	assert.Equal(t, cfg.StoreBackend, cfg.DefaultStoreBackend)
+	assert.Equal(t, cfg.KeyStrategy, cfg.DefaultKeyStrategy)
+	assert.Equal(t, cfg.KeyLength, cfg.DefaultKeyLength)
+	assert.Equal(t, cfg.KeyCharset, cfg.DefaultKeyCharset)
+	assert.Equal(t, cfg.CacheSize, cfg.DefaultCacheSize)
+	assert.Equal(t, cfg.CacheTTL, cfg.DefaultCacheTTL)
+	assert.Equal(t, cfg.RestoreGracePeriod, cfg.DefaultRestoreGracePeriod)
+	assert.Equal(t, cfg.DeletedRetention, cfg.DefaultDeletedRetention)
+	assert.Equal(t, cfg.RedirectType, cfg.DefaultRedirectType)
+	assert.Equal(t, cfg.JWTSecret, cfg.DefaultJWTSecret)
+	assert.Equal(t, cfg.JWTKeyFile, cfg.DefaultJWTKeyFile)
+	assert.Equal(t, cfg.JWTKeyGrace, cfg.DefaultJWTKeyGrace)
+	assert.Equal(t, cfg.JWTPrivateKeyFile, cfg.DefaultJWTPrivateKeyFile)
+	assert.Equal(t, cfg.JWTRefreshWindow, cfg.DefaultJWTRefreshWindow)
 }
 
 func TestGetConfig_JSONPriority(t *testing.T) {
@@ -113,7 +239,25 @@ func TestGetConfig_JSONPriority(t *testing.T) {
 		"cert_file": "json_cert.pem",
 		"key_file": "json_key.pem",
 		"trusted_subnet": "10.0.0.0/24",
-		"grpc_server_address": "localhost:50051"
+		"grpc_server_address": "localhost:50051",
+		"reap_interval": "5m",
+		"wal_sync": "always",
+		"wal_sync_interval": "500ms",
+		"snapshot_interval": "10m",
+		"store_backend": "memory",
+		"key_strategy": "obfuscated",
+		"key_length": 12,
+		"key_charset": "abcdef",
+		"cache_size": 2000,
+		"cache_ttl": "5m",
+		"restore_grace_period": "4h",
+		"deleted_retention": "96h",
+		"redirect_type": 302,
+		"jwt_secret": "json-secret",
+		"jwt_key_file": "/tmp/json-keys.json",
+		"jwt_key_grace": "30m",
+		"jwt_private_key_file": "/tmp/json-key.pem",
+		"jwt_refresh_window": "2h"
 	}`
 	_, err = tmpFile.WriteString(jsonContent)
 	assert.NoError(t, err)
@@ -128,6 +272,24 @@ func TestGetConfig_JSONPriority(t *testing.T) {
 	os.Unsetenv("TLS_KEY")
 	os.Unsetenv("TRUSTED_SUBNET")
 	os.Unsetenv("GRPC_SERVER_ADDRESS")
+	os.Unsetenv("REAP_INTERVAL")
+	os.Unsetenv("WAL_SYNC")
+	os.Unsetenv("WAL_SYNC_INTERVAL")
+	os.Unsetenv("SNAPSHOT_INTERVAL")
+	os.Unsetenv("STORE_BACKEND")
+	os.Unsetenv("KEY_STRATEGY")
+	os.Unsetenv("KEY_LENGTH")
+	os.Unsetenv("KEY_CHARSET")
+	os.Unsetenv("CACHE_SIZE")
+	os.Unsetenv("CACHE_TTL")
+	os.Unsetenv("RESTORE_GRACE_PERIOD")
+	os.Unsetenv("DELETED_RETENTION")
+	os.Unsetenv("REDIRECT_TYPE")
+	os.Unsetenv("JWT_SECRET")
+	os.Unsetenv("JWT_KEY_FILE")
+	os.Unsetenv("JWT_KEY_GRACE")
+	os.Unsetenv("JWT_PRIVATE_KEY_FILE")
+	os.Unsetenv("JWT_REFRESH_WINDOW")
 
 	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	os.Args = []string{"cmd", "-c", tmpFile.Name()}
@@ -143,4 +305,47 @@ func TestGetConfig_JSONPriority(t *testing.T) {
 	assert.Equal(t, cfg.KeyFile, "json_key.pem")
 	assert.Equal(t, cfg.TrustedSubnet, "10.0.0.0/24")
 	assert.Equal(t, cfg.GRPCServerAddress, "localhost:50051")
+	assert.Equal(t, cfg.ReapInterval, 5*time.Minute)
+	assert.Equal(t, cfg.WALSyncPolicy, "always")
+	assert.Equal(t, cfg.WALSyncInterval, 500*time.Millisecond)
+	assert.Equal(t, cfg.SnapshotInterval, 10*time.Minute)
+	assert.Equal(t, cfg.StoreBackend, "memory")
+	assert.Equal(t, cfg.KeyStrategy, "obfuscated")
+	assert.Equal(t, cfg.KeyLength, 12)
+	assert.Equal(t, cfg.KeyCharset, "abcdef")
+	assert.Equal(t, cfg.CacheSize, 2000)
+	assert.Equal(t, cfg.CacheTTL, 5*time.Minute)
+	assert.Equal(t, cfg.RestoreGracePeriod, 4*time.Hour)
+	assert.Equal(t, cfg.DeletedRetention, 96*time.Hour)
+	assert.Equal(t, cfg.RedirectType, 302)
+	assert.Equal(t, cfg.JWTSecret, "json-secret")
+	assert.Equal(t, cfg.JWTKeyFile, "/tmp/json-keys.json")
+	assert.Equal(t, cfg.JWTKeyGrace, 30*time.Minute)
+	assert.Equal(t, cfg.JWTPrivateKeyFile, "/tmp/json-key.pem")
+	assert.Equal(t, cfg.JWTRefreshWindow, 2*time.Hour)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		name               string
+		restoreGracePeriod time.Duration
+		deletedRetention   time.Duration
+		expectedErr        error
+	}{
+		{name: "Retention longer than grace period", restoreGracePeriod: time.Hour, deletedRetention: 48 * time.Hour, expectedErr: nil},
+		{name: "Retention equal to grace period", restoreGracePeriod: time.Hour, deletedRetention: time.Hour, expectedErr: nil},
+		{name: "Retention forever", restoreGracePeriod: time.Hour, deletedRetention: 0, expectedErr: nil},
+		{name: "Retention shorter than grace period", restoreGracePeriod: 24 * time.Hour, deletedRetention: time.Hour, expectedErr: ErrDeletedRetentionTooShort},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := Config{RestoreGracePeriod: tc.restoreGracePeriod, DeletedRetention: tc.deletedRetention}
+			err := cfg.Validate()
+			if tc.expectedErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.expectedErr)
+		})
+	}
 }
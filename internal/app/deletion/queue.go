@@ -0,0 +1,310 @@
+// Package deletion содержит очередь асинхронного удаления ссылок пользователей.
+package deletion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/shekshuev/shortener/internal/app/logger"
+	"github.com/shekshuev/shortener/internal/app/models"
+)
+
+// Параметры очереди удаления по умолчанию.
+const (
+	DefaultWorkers       = 4
+	DefaultBufferSize    = 1024
+	DefaultBatchSize     = 512
+	DefaultFlushInterval = 100 * time.Millisecond
+	deleteTimeout        = 30 * time.Second
+	jobRetention         = time.Hour
+)
+
+// Ошибки очереди удаления.
+var (
+	ErrQueueFull   = fmt.Errorf("deletion queue is full")   // Ошибка: очередь переполнена
+	ErrQueueClosed = fmt.Errorf("deletion queue is closed") // Ошибка: очередь остановлена
+	ErrJobNotFound = fmt.Errorf("deletion job not found")   // Ошибка: задача не найдена
+)
+
+// Deleter - интерфейс хранилища, удаляющего ссылки нескольких пользователей за один запрос.
+type Deleter interface {
+	DeleteURLsBatch(ctx context.Context, tasks []models.DeletionTask) error
+}
+
+// JobStore - интерфейс хранилища задач удаления. Задачи, сохранённые в нём, переживают перезапуск:
+// невыполненные задачи снова ставятся в очередь при её создании, а состояние задач читается из хранилища.
+type JobStore interface {
+	SaveDeletionJob(ctx context.Context, job models.DeletionJob) error
+	GetDeletionJob(ctx context.Context, id string) (models.DeletionJob, error)
+	PendingDeletionJobs(ctx context.Context) ([]models.DeletionJob, error)
+	PurgeDeletionJobs(ctx context.Context, finishedBefore time.Time) (int, error)
+}
+
+// job - задача удаления вместе с её состоянием.
+type job struct {
+	task   models.DeletionTask
+	status models.DeletionJobDTO
+}
+
+// record возвращает задачу в том виде, в котором она сохраняется в хранилище задач.
+func (j *job) record() models.DeletionJob {
+	return models.DeletionJob{DeletionJobDTO: j.status, UserID: j.task.UserID, ShortURLs: j.task.ShortURLs}
+}
+
+// Option - необязательная настройка Queue.
+type Option func(*Queue)
+
+// WithJobStore задаёт хранилище задач. Без него задачи и их состояние хранятся только в памяти
+// и теряются при перезапуске.
+func WithJobStore(store JobStore) Option {
+	return func(q *Queue) {
+		q.store = store
+	}
+}
+
+// Queue - ограниченная очередь удаления ссылок с пулом обработчиков.
+// Обработчики объединяют задачи разных пользователей в пачки и удаляют каждую пачку одним запросом к хранилищу.
+// Состояние задачи доступно по её идентификатору в течение часа после завершения.
+type Queue struct {
+	deleter       Deleter
+	store         JobStore
+	jobs          chan *job
+	batchSize     int
+	flushInterval time.Duration
+	mx            sync.RWMutex
+	closed        bool
+	wg            sync.WaitGroup
+	statusMx      sync.Mutex
+	statuses      map[string]*job
+	finished      []*job
+}
+
+// NewQueue создаёт очередь на bufferSize задач и запускает workers обработчиков.
+// Обработчик удаляет накопленные задачи, как только в них набирается batchSize ссылок, либо по таймеру.
+// Если задано хранилище задач, невыполненные до перезапуска задачи снова ставятся в очередь.
+func NewQueue(deleter Deleter, workers, bufferSize, batchSize int, flushInterval time.Duration, opts ...Option) *Queue {
+	q := &Queue{
+		deleter:       deleter,
+		jobs:          make(chan *job, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		statuses:      make(map[string]*job),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.run()
+	}
+	q.resume()
+	return q
+}
+
+// resume ставит в очередь невыполненные задачи из хранилища задач.
+// Очередь ещё не доступна вызывающему, поэтому задачи передаются обработчикам с ожиданием места в буфере.
+func (q *Queue) resume() {
+	if q.store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), deleteTimeout)
+	defer cancel()
+	pending, err := q.store.PendingDeletionJobs(ctx)
+	if err != nil {
+		logger.NewLogger().Log.Error("Error loading pending deletion jobs", zap.Error(err))
+		return
+	}
+	for _, record := range pending {
+		q.jobs <- &job{task: models.DeletionTask{UserID: record.UserID, ShortURLs: record.ShortURLs}, status: record.DeletionJobDTO}
+	}
+	if len(pending) > 0 {
+		logger.NewLogger().Log.Info("Pending deletion jobs resumed", zap.Int("jobs", len(pending)))
+	}
+}
+
+// Enqueue ставит удаление ссылок пользователя в очередь и возвращает идентификатор задачи.
+// Если задано хранилище задач, задача сохраняется в нём до постановки в очередь.
+// Не блокирует вызывающего: при переполнении очереди возвращает ErrQueueFull.
+func (q *Queue) Enqueue(ctx context.Context, userID string, urls []string) (string, error) {
+	j := &job{
+		task: models.DeletionTask{UserID: userID, ShortURLs: urls},
+		status: models.DeletionJobDTO{
+			ID:        uuid.NewString(),
+			Status:    models.DeletionJobPending,
+			URLs:      len(urls),
+			CreatedAt: time.Now(),
+		},
+	}
+	q.mx.RLock()
+	defer q.mx.RUnlock()
+	if q.closed {
+		return "", ErrQueueClosed
+	}
+	if q.store != nil {
+		if err := q.store.SaveDeletionJob(ctx, j.record()); err != nil {
+			return "", err
+		}
+	} else {
+		q.statusMx.Lock()
+		q.statuses[j.status.ID] = j
+		q.statusMx.Unlock()
+	}
+	select {
+	case q.jobs <- j:
+		return j.status.ID, nil
+	default:
+		q.forget(j)
+		return "", ErrQueueFull
+	}
+}
+
+// forget убирает задачу, не попавшую в очередь. Сохранённая задача помечается невыполненной,
+// чтобы она не была поставлена в очередь при перезапуске.
+func (q *Queue) forget(j *job) {
+	if q.store == nil {
+		q.statusMx.Lock()
+		delete(q.statuses, j.status.ID)
+		q.statusMx.Unlock()
+		return
+	}
+	now := time.Now()
+	j.status.Status = models.DeletionJobFailed
+	j.status.Error = ErrQueueFull.Error()
+	j.status.FinishedAt = &now
+	ctx, cancel := context.WithTimeout(context.Background(), deleteTimeout)
+	defer cancel()
+	if err := q.store.SaveDeletionJob(ctx, j.record()); err != nil {
+		logger.NewLogger().Log.Error("Error saving deletion job", zap.String("job_id", j.status.ID), zap.Error(err))
+	}
+}
+
+// Job возвращает состояние задачи пользователя. Чужие и неизвестные задачи не отличаются: ErrJobNotFound.
+func (q *Queue) Job(ctx context.Context, userID, id string) (models.DeletionJobDTO, error) {
+	if q.store != nil {
+		record, err := q.store.GetDeletionJob(ctx, id)
+		if errors.Is(err, ErrJobNotFound) || (err == nil && record.UserID != userID) {
+			return models.DeletionJobDTO{}, ErrJobNotFound
+		}
+		if err != nil {
+			return models.DeletionJobDTO{}, err
+		}
+		return record.DeletionJobDTO, nil
+	}
+	q.statusMx.Lock()
+	defer q.statusMx.Unlock()
+	j, ok := q.statuses[id]
+	if !ok || j.task.UserID != userID {
+		return models.DeletionJobDTO{}, ErrJobNotFound
+	}
+	return j.status, nil
+}
+
+// Close прекращает приём задач и дожидается выполнения уже поставленных в очередь.
+func (q *Queue) Close() {
+	q.mx.Lock()
+	if q.closed {
+		q.mx.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.jobs)
+	q.mx.Unlock()
+	q.wg.Wait()
+}
+
+// run собирает задачи в пачки и выполняет их по заполнении пачки или по таймеру.
+func (q *Queue) run() {
+	defer q.wg.Done()
+	ticker := time.NewTicker(q.flushInterval)
+	defer ticker.Stop()
+	var (
+		batch []*job
+		urls  int
+	)
+	for {
+		select {
+		case j, ok := <-q.jobs:
+			if !ok {
+				q.flush(batch)
+				return
+			}
+			batch = append(batch, j)
+			urls += len(j.task.ShortURLs)
+			if urls >= q.batchSize {
+				q.flush(batch)
+				batch, urls = nil, 0
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				q.flush(batch)
+				batch, urls = nil, 0
+			}
+		}
+	}
+}
+
+// flush удаляет ссылки пачки задач и обновляет их состояние.
+func (q *Queue) flush(batch []*job) {
+	if len(batch) == 0 {
+		return
+	}
+	tasks := make([]models.DeletionTask, 0, len(batch))
+	for _, j := range batch {
+		tasks = append(tasks, j.task)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), deleteTimeout)
+	defer cancel()
+	err := q.deleter.DeleteURLsBatch(ctx, tasks)
+	if err != nil {
+		logger.NewLogger().Log.Error("Error deleting urls", zap.Int("jobs", len(batch)), zap.Error(err))
+	}
+
+	now := time.Now()
+	if q.store != nil {
+		q.saveFinished(ctx, batch, now, err)
+		return
+	}
+	q.statusMx.Lock()
+	defer q.statusMx.Unlock()
+	for _, j := range batch {
+		finish(j, now, err)
+		q.finished = append(q.finished, j)
+	}
+	// Задачи завершаются по порядку, поэтому устаревшие всегда в начале списка.
+	for len(q.finished) > 0 && now.Sub(*q.finished[0].status.FinishedAt) > jobRetention {
+		delete(q.statuses, q.finished[0].status.ID)
+		q.finished = q.finished[1:]
+	}
+}
+
+// saveFinished сохраняет итог задач пачки в хранилище задач и удаляет из него задачи,
+// завершённые больше часа назад.
+func (q *Queue) saveFinished(ctx context.Context, batch []*job, now time.Time, deleteErr error) {
+	log := logger.NewLogger()
+	for _, j := range batch {
+		finish(j, now, deleteErr)
+		if err := q.store.SaveDeletionJob(ctx, j.record()); err != nil {
+			log.Log.Error("Error saving deletion job", zap.String("job_id", j.status.ID), zap.Error(err))
+		}
+	}
+	if _, err := q.store.PurgeDeletionJobs(ctx, now.Add(-jobRetention)); err != nil {
+		log.Log.Error("Error purging deletion jobs", zap.Error(err))
+	}
+}
+
+// finish отмечает задачу завершённой в момент now с ошибкой удаления err или без неё.
+func finish(j *job, now time.Time, err error) {
+	j.status.FinishedAt = &now
+	if err != nil {
+		j.status.Status = models.DeletionJobFailed
+		j.status.Error = err.Error()
+	} else {
+		j.status.Status = models.DeletionJobDone
+	}
+}
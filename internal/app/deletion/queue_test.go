@@ -0,0 +1,217 @@
+package deletion
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shekshuev/shortener/internal/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type deleterStub struct {
+	mx      sync.Mutex
+	batches [][]models.DeletionTask
+	err     error
+	block   chan struct{}
+}
+
+func (d *deleterStub) DeleteURLsBatch(_ context.Context, tasks []models.DeletionTask) error {
+	if d.block != nil {
+		<-d.block
+	}
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	d.batches = append(d.batches, tasks)
+	return d.err
+}
+
+func (d *deleterStub) calls() [][]models.DeletionTask {
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	return append([][]models.DeletionTask(nil), d.batches...)
+}
+
+func TestQueue_CoalescesUsers(t *testing.T) {
+	deleter := &deleterStub{}
+	q := NewQueue(deleter, 1, 10, 3, time.Hour)
+	defer q.Close()
+
+	first, err := q.Enqueue(context.Background(), "1", []string{"short1"})
+	assert.NoError(t, err)
+	second, err := q.Enqueue(context.Background(), "2", []string{"short2", "short3"})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return len(deleter.calls()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []models.DeletionTask{
+		{UserID: "1", ShortURLs: []string{"short1"}},
+		{UserID: "2", ShortURLs: []string{"short2", "short3"}},
+	}, deleter.calls()[0], "Tasks of different users should be deleted in one batch")
+
+	job, err := q.Job(context.Background(), "1", first)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DeletionJobDone, job.Status)
+	assert.Equal(t, 1, job.URLs)
+	assert.NotNil(t, job.FinishedAt)
+	job, err = q.Job(context.Background(), "2", second)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DeletionJobDone, job.Status)
+}
+
+func TestQueue_FlushesByInterval(t *testing.T) {
+	deleter := &deleterStub{}
+	q := NewQueue(deleter, 2, 10, 100, 10*time.Millisecond)
+	defer q.Close()
+
+	_, err := q.Enqueue(context.Background(), "1", []string{"short1"})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return len(deleter.calls()) == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestQueue_Job(t *testing.T) {
+	deleter := &deleterStub{err: assert.AnError, block: make(chan struct{})}
+	q := NewQueue(deleter, 1, 10, 1, time.Hour)
+	defer q.Close()
+
+	id, err := q.Enqueue(context.Background(), "1", []string{"short1"})
+	assert.NoError(t, err)
+
+	job, err := q.Job(context.Background(), "1", id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DeletionJobPending, job.Status)
+	assert.Nil(t, job.FinishedAt)
+
+	_, err = q.Job(context.Background(), "2", id)
+	assert.ErrorIs(t, err, ErrJobNotFound, "Job of another user should not be visible")
+	_, err = q.Job(context.Background(), "1", "missing")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	close(deleter.block)
+	assert.Eventually(t, func() bool {
+		job, _ := q.Job(context.Background(), "1", id)
+		return job.Status == models.DeletionJobFailed
+	}, time.Second, 5*time.Millisecond)
+	job, _ = q.Job(context.Background(), "1", id)
+	assert.Equal(t, assert.AnError.Error(), job.Error)
+}
+
+func TestQueue_Full(t *testing.T) {
+	deleter := &deleterStub{block: make(chan struct{})}
+	q := NewQueue(deleter, 1, 1, 1, time.Hour)
+	defer q.Close()
+	defer close(deleter.block)
+
+	_, err := q.Enqueue(context.Background(), "1", []string{"short1"})
+	assert.NoError(t, err)
+	// Обработчик забирает первую задачу и блокируется на ней, вторая занимает буфер.
+	assert.Eventually(t, func() bool {
+		_, err := q.Enqueue(context.Background(), "1", []string{"short2"})
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+	_, err = q.Enqueue(context.Background(), "1", []string{"short3"})
+	assert.ErrorIs(t, err, ErrQueueFull)
+}
+
+func TestQueue_CloseDrainsQueue(t *testing.T) {
+	deleter := &deleterStub{}
+	q := NewQueue(deleter, 2, 10, 100, time.Hour)
+	var ids []string
+	for i := 0; i < 5; i++ {
+		id, err := q.Enqueue(context.Background(), "1", []string{"short1"})
+		assert.NoError(t, err)
+		ids = append(ids, id)
+	}
+	q.Close()
+
+	total := 0
+	for _, batch := range deleter.calls() {
+		total += len(batch)
+	}
+	assert.Equal(t, 5, total)
+	for _, id := range ids {
+		job, err := q.Job(context.Background(), "1", id)
+		assert.NoError(t, err)
+		assert.Equal(t, models.DeletionJobDone, job.Status)
+	}
+	_, err := q.Enqueue(context.Background(), "1", []string{"short1"})
+	assert.ErrorIs(t, err, ErrQueueClosed, "Closed queue should reject jobs")
+}
+
+type jobStoreStub struct {
+	mx   sync.Mutex
+	jobs map[string]models.DeletionJob
+}
+
+func (s *jobStoreStub) SaveDeletionJob(_ context.Context, job models.DeletionJob) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.jobs[job.ID] = job
+	return nil
+}
+
+func (s *jobStoreStub) GetDeletionJob(_ context.Context, id string) (models.DeletionJob, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return models.DeletionJob{}, ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (s *jobStoreStub) PendingDeletionJobs(_ context.Context) ([]models.DeletionJob, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	var pending []models.DeletionJob
+	for _, job := range s.jobs {
+		if job.Status == models.DeletionJobPending {
+			pending = append(pending, job)
+		}
+	}
+	return pending, nil
+}
+
+func (s *jobStoreStub) PurgeDeletionJobs(_ context.Context, finishedBefore time.Time) (int, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	count := 0
+	for id, job := range s.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(finishedBefore) {
+			delete(s.jobs, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func TestQueue_JobStore_SurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	jobs := &jobStoreStub{jobs: make(map[string]models.DeletionJob)}
+	blocked := &deleterStub{block: make(chan struct{})}
+	q := NewQueue(blocked, 1, 10, 1, time.Hour, WithJobStore(jobs))
+	first, err := q.Enqueue(ctx, "1", []string{"short1"})
+	assert.NoError(t, err)
+	second, err := q.Enqueue(ctx, "2", []string{"short2"})
+	assert.NoError(t, err)
+	job, err := q.Job(ctx, "1", first)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DeletionJobPending, job.Status)
+	// Процесс «падает», пока обработчик занят первой задачей: очередь не закрывается.
+
+	deleter := &deleterStub{}
+	restarted := NewQueue(deleter, 1, 10, 100, time.Hour, WithJobStore(jobs))
+	restarted.Close()
+	assert.ElementsMatch(t, []models.DeletionTask{
+		{UserID: "1", ShortURLs: []string{"short1"}},
+		{UserID: "2", ShortURLs: []string{"short2"}},
+	}, deleter.calls()[0], "Pending jobs should be resumed after restart")
+	job, err = restarted.Job(ctx, "1", first)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DeletionJobDone, job.Status, "Job status should be read from the job store")
+	_, err = restarted.Job(ctx, "1", second)
+	assert.ErrorIs(t, err, ErrJobNotFound, "Job of another user should not be visible")
+	close(blocked.block)
+	q.Close()
+}
@@ -0,0 +1,137 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shekshuev/shortener/internal/app/apikey"
+	"github.com/shekshuev/shortener/internal/app/jwt"
+	"github.com/shekshuev/shortener/internal/app/models"
+	"github.com/shekshuev/shortener/internal/app/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authorizationKey - ключ метаданных вызова с токеном авторизации вида "Bearer <ключ API или JWT>".
+const authorizationKey = "authorization"
+
+// APIKeyAuthenticator - интерфейс проверки ключей API.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, error)
+}
+
+// publicMethods - методы, которые можно вызывать без аутентификации.
+// Если токен всё же передан, он проверяется так же, как для остальных методов.
+var publicMethods = map[string]bool{
+	proto.URLShortener_Ping_FullMethodName:           true,
+	proto.URLShortener_GetStats_FullMethodName:       true,
+	proto.URLShortener_GetOriginalURL_FullMethodName: true,
+}
+
+// methodScopes - разрешения, которые должны быть у ключа API для вызова метода.
+// Методы, которых нет ни в этом списке, ни в publicMethods, ключом API вызвать нельзя:
+// новый метод становится доступен по ключу только после того, как ему назначено разрешение.
+var methodScopes = map[string]string{
+	proto.URLShortener_Shorten_FullMethodName:             apikey.ScopeCreate,
+	proto.URLShortener_BatchShorten_FullMethodName:        apikey.ScopeCreate,
+	proto.URLShortener_UpdateURL_FullMethodName:           apikey.ScopeCreate,
+	proto.URLShortener_GetUserURLs_FullMethodName:         apikey.ScopeRead,
+	proto.URLShortener_LookupByOriginalURL_FullMethodName: apikey.ScopeRead,
+	proto.URLShortener_GetDeletionJob_FullMethodName:      apikey.ScopeRead,
+	proto.URLShortener_GetURLStats_FullMethodName:         apikey.ScopeRead,
+	proto.URLShortener_DeleteUserURLs_FullMethodName:      apikey.ScopeDelete,
+	proto.URLShortener_RestoreUserURLs_FullMethodName:     apikey.ScopeDelete,
+}
+
+// UnaryAuthInterceptor аутентифицирует унарные вызовы по ключу API или JWT-токену из метаданных authorization
+// и передаёт пользователя дальше через контекст (см. jwt.UserIDFromContext).
+// Вызовы без токена или с недействительным токеном отклоняются с кодом Unauthenticated,
+// вызовы ключом API без нужного разрешения - с кодом PermissionDenied.
+func UnaryAuthInterceptor(auth APIKeyAuthenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, auth, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuthInterceptor аутентифицирует потоковые вызовы так же, как UnaryAuthInterceptor.
+func StreamAuthInterceptor(auth APIKeyAuthenticator) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(stream.Context(), auth, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// authenticatedStream - поток вызова с контекстом, дополненным аутентифицированным пользователем.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context возвращает контекст потока с аутентифицированным пользователем.
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate проверяет токен из метаданных вызова метода fullMethod и возвращает контекст с пользователем,
+// а для ключа API - и с его разрешениями.
+func authenticate(ctx context.Context, auth APIKeyAuthenticator, fullMethod string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(authorizationKey)
+	if len(values) == 0 {
+		if publicMethods[fullMethod] {
+			return ctx, nil
+		}
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	token, ok := jwt.BearerToken(values[0])
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "authorization must be a bearer token")
+	}
+	if !apikey.IsAPIKey(token) {
+		userID, err := jwt.GetUserID(token)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return jwt.WithUserID(ctx, userID), nil
+	}
+	key, err := auth.AuthenticateAPIKey(ctx, token)
+	if errors.Is(err, apikey.ErrInvalidKey) {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !publicMethods[fullMethod] {
+		scope, ok := methodScopes[fullMethod]
+		if !ok {
+			return nil, status.Error(codes.PermissionDenied, "method is not available with an api key")
+		}
+		if !apikey.HasScope(key.Scopes, scope) {
+			return nil, status.Errorf(codes.PermissionDenied, "api key has no %s scope", scope)
+		}
+	}
+	return apikey.WithScopes(jwt.WithUserID(ctx, key.UserID), key.Scopes), nil
+}
+
+// requestUserID возвращает пользователя вызова, аутентифицированного перехватчиком.
+// Устаревшее поле user_id запроса больше не определяет пользователя: если оно задано,
+// то должно совпадать с аутентифицированным пользователем.
+func requestUserID(ctx context.Context, userID string) (string, error) {
+	authUserID, ok := jwt.UserIDFromContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "authentication required")
+	}
+	if userID != "" && userID != authUserID {
+		return "", status.Error(codes.PermissionDenied, "user_id does not match the token")
+	}
+	return authUserID, nil
+}
@@ -0,0 +1,214 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shekshuev/shortener/internal/app/apikey"
+	"github.com/shekshuev/shortener/internal/app/jwt"
+	"github.com/shekshuev/shortener/internal/app/models"
+	"github.com/shekshuev/shortener/internal/app/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestClient запускает srv в gRPC-сервере с перехватчиками аутентификации поверх соединения в памяти
+// и возвращает подключённого к нему клиента.
+func newTestClient(t *testing.T, srv *Server) proto.URLShortenerClient {
+	listener := bufconn.Listen(1024 * 1024)
+	grpcSrv := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryAuthInterceptor(srv.service)),
+		grpc.StreamInterceptor(StreamAuthInterceptor(srv.service)),
+	)
+	proto.RegisterURLShortenerServer(grpcSrv, srv)
+	go grpcSrv.Serve(listener)
+	t.Cleanup(grpcSrv.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return proto.NewURLShortenerClient(conn)
+}
+
+// withAuthorization возвращает исходящий контекст с метаданными authorization.
+func withAuthorization(value string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), authorizationKey, value)
+}
+
+func TestUnaryAuthInterceptor_JWT(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := jwt.NewKeyring("ed", []jwt.Key{{ID: "ed", PrivateKeyFile: keyPath}}, time.Hour)
+	assert.NoError(t, err)
+	jwt.SetKeyring(keyring)
+	defer func() {
+		random, _ := jwt.NewRandomKeyring()
+		jwt.SetKeyring(random)
+	}()
+
+	token, err := jwt.BuildJWTStringForUser("token-user")
+	assert.NoError(t, err)
+	client := newTestClient(t, setupTestServer())
+
+	t.Run("User from token", func(t *testing.T) {
+		_, err := client.Shorten(withAuthorization("Bearer "+token), &proto.ShortenRequest{Url: "https://example.com/token"})
+		assert.NoError(t, err)
+		resp, err := client.GetUserURLs(withAuthorization("bearer "+token), &proto.UserURLsRequest{})
+		assert.NoError(t, err)
+		assert.Len(t, resp.Urls, 1)
+	})
+
+	t.Run("Missing token", func(t *testing.T) {
+		_, err := client.GetUserURLs(context.Background(), &proto.UserURLsRequest{UserId: "token-user"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Public method without token", func(t *testing.T) {
+		_, err := client.GetOriginalURL(context.Background(), &proto.GetOriginalURLRequest{ShortUrl: "unknown"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("Mismatched user_id", func(t *testing.T) {
+		_, err := client.GetUserURLs(withAuthorization("Bearer "+token), &proto.UserURLsRequest{UserId: "other-user"})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("Invalid token", func(t *testing.T) {
+		for _, value := range []string{"Bearer invalid.token.string", "Basic dXNlcjpwYXNz", token} {
+			_, err := client.GetUserURLs(withAuthorization(value), &proto.UserURLsRequest{})
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+			_, err = client.GetOriginalURL(withAuthorization(value), &proto.GetOriginalURLRequest{ShortUrl: "unknown"})
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		}
+	})
+
+	t.Run("Token signed with unknown key", func(t *testing.T) {
+		other, err := jwt.NewSecretKeyring("other-secret")
+		assert.NoError(t, err)
+		jwt.SetKeyring(other)
+		foreign, err := jwt.BuildJWTStringForUser("token-user")
+		assert.NoError(t, err)
+		jwt.SetKeyring(keyring)
+
+		_, err = client.DeleteUserURLs(withAuthorization("Bearer "+foreign), &proto.DeleteURLsRequest{ShortUrls: []string{"abc"}})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+func TestUnaryAuthInterceptor_APIKey(t *testing.T) {
+	srv := setupTestServer()
+	client := newTestClient(t, srv)
+	created, err := srv.service.CreateAPIKey(context.Background(), "key-user", models.APIKeyCreateDTO{Name: "ci", Scopes: []string{apikey.ScopeCreate, apikey.ScopeRead}})
+	assert.NoError(t, err)
+	withKey := withAuthorization("Bearer " + created.Key)
+
+	t.Run("Scopes granted", func(t *testing.T) {
+		_, err := client.Shorten(withKey, &proto.ShortenRequest{Url: "https://example.com/key"})
+		assert.NoError(t, err)
+		resp, err := client.GetUserURLs(withKey, &proto.UserURLsRequest{})
+		assert.NoError(t, err)
+		assert.Len(t, resp.Urls, 1)
+	})
+
+	t.Run("Scope not granted", func(t *testing.T) {
+		_, err := client.DeleteUserURLs(withKey, &proto.DeleteURLsRequest{ShortUrls: []string{"abc"}})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("Unmapped method", func(t *testing.T) {
+		interceptor := UnaryAuthInterceptor(srv.service)
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(authorizationKey, "Bearer "+created.Key))
+		called := false
+		handler := func(context.Context, any) (any, error) {
+			called = true
+			return nil, nil
+		}
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/urlshortener.URLShortener/Unmapped"}, handler)
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		assert.False(t, called, "Handler should not be called for a method without a scope")
+
+		_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: proto.URLShortener_GetStats_FullMethodName}, handler)
+		assert.NoError(t, err)
+		assert.True(t, called, "Public methods should stay available with an api key")
+	})
+
+	t.Run("Mismatched user_id", func(t *testing.T) {
+		_, err := client.GetUserURLs(withKey, &proto.UserURLsRequest{UserId: "other-user"})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("Revoked key", func(t *testing.T) {
+		assert.NoError(t, srv.service.RevokeAPIKey(context.Background(), "key-user", created.ID))
+		_, err := client.GetUserURLs(withKey, &proto.UserURLsRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+// testServerStream - поток вызова с заданным контекстом для проверки потокового перехватчика.
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamAuthInterceptor(t *testing.T) {
+	srv := setupTestServer()
+	created, err := srv.service.CreateAPIKey(context.Background(), "key-user", models.APIKeyCreateDTO{Name: "ci", Scopes: []string{apikey.ScopeRead}})
+	assert.NoError(t, err)
+	interceptor := StreamAuthInterceptor(srv.service)
+	info := &grpc.StreamServerInfo{FullMethod: proto.URLShortener_GetUserURLs_FullMethodName, IsServerStream: true}
+
+	var userID string
+	var scopes []string
+	handler := func(_ any, stream grpc.ServerStream) error {
+		userID, _ = jwt.UserIDFromContext(stream.Context())
+		scopes, _ = apikey.ScopesFromContext(stream.Context())
+		return nil
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(authorizationKey, "Bearer "+created.Key))
+	err = interceptor(nil, &testServerStream{ctx: ctx}, info, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "key-user", userID)
+	assert.Equal(t, []string{apikey.ScopeRead}, scopes)
+
+	unmapped := &grpc.StreamServerInfo{FullMethod: "/urlshortener.URLShortener/Watch", IsServerStream: true}
+	err = interceptor(nil, &testServerStream{ctx: ctx}, unmapped, handler)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err), "Api key should not call a method without a scope")
+
+	err = interceptor(nil, &testServerStream{ctx: context.Background()}, info, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -4,13 +4,21 @@ import (
 	"context"
 	"errors"
 
+	"github.com/shekshuev/shortener/internal/app/deletion"
+	"github.com/shekshuev/shortener/internal/app/jwt"
 	"github.com/shekshuev/shortener/internal/app/models"
 	"github.com/shekshuev/shortener/internal/app/proto"
 	"github.com/shekshuev/shortener/internal/app/service"
 	"github.com/shekshuev/shortener/internal/app/store"
+	"github.com/shekshuev/shortener/internal/utils"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // Server реализует gRPC-сервис URLShortenerServer.
+// Пользователь вызова аутентифицируется перехватчиками UnaryAuthInterceptor и StreamAuthInterceptor
+// по ключу API или JWT-токену из метаданных authorization; устаревшее поле user_id запросов лишь сверяется с ним.
 type Server struct {
 	proto.UnimplementedURLShortenerServer
 	service service.Service
@@ -24,31 +32,47 @@ func NewServer(service service.Service) *Server {
 }
 
 // Shorten обрабатывает сокращение одного URL.
-// Запрос: ShortenRequest { url, user_id }.
-// Ответ: ShortenResponse { result: короткий URL } или ошибка.
+// Запрос: ShortenRequest { url, alias, expires_in, expires_at, redirect_type, forward_query, forward_path },
+// все поля, кроме url, необязательны.
+// Ответ: ShortenResponse { result: короткий URL } или ошибка, AlreadyExists — если алиас занят.
 func (s *Server) Shorten(ctx context.Context, req *proto.ShortenRequest) (*proto.ShortenResponse, error) {
-	shortURL, err := s.service.CreateShortURL(ctx, req.Url, req.UserId)
+	userID, err := requestUserID(ctx, req.UserId)
 	if err != nil {
 		return nil, err
 	}
+	createDTO := models.ShortURLCreateDTO{
+		URL:         req.Url,
+		Alias:       req.Alias,
+		LinkOptions: linkOptions(req),
+	}
+	shortURL, err := s.service.CreateShortURL(ctx, createDTO, userID)
+	if err != nil {
+		return nil, shortenError(err)
+	}
 	return &proto.ShortenResponse{Result: shortURL}, nil
 }
 
 // BatchShorten обрабатывает сокращение нескольких URL за один запрос.
-// Запрос: BatchShortenRequest с массивом URL.
+// Запрос: BatchShortenRequest с массивом URL и необязательными алиасами.
 // Ответ: BatchShortenResponse с массивом результатов или ошибка.
 func (s *Server) BatchShorten(ctx context.Context, req *proto.BatchShortenRequest) (*proto.BatchShortenResponse, error) {
+	userID, err := requestUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
 	createDTOs := make([]models.BatchShortURLCreateDTO, len(req.Items))
 	for i, item := range req.Items {
 		createDTOs[i] = models.BatchShortURLCreateDTO{
 			CorrelationID: item.CorrelationId,
 			OriginalURL:   item.OriginalUrl,
+			Alias:         item.Alias,
+			LinkOptions:   linkOptions(item),
 		}
 	}
-	readDTOs, err := s.service.BatchCreateShortURL(ctx, createDTOs, req.UserId)
+	readDTOs, err := s.service.BatchCreateShortURL(ctx, createDTOs, userID)
 	if err != nil {
 		if !errors.Is(err, store.ErrAlreadyExists) {
-			return nil, err
+			return nil, shortenError(err)
 		}
 	}
 	items := make([]*proto.BatchShortenResponseItem, len(readDTOs))
@@ -61,14 +85,60 @@ func (s *Server) BatchShorten(ctx context.Context, req *proto.BatchShortenReques
 	return &proto.BatchShortenResponse{Items: items}, nil
 }
 
-// GetUserURLs возвращает все сокращённые URL пользователя.
-// Запрос: UserURLsRequest { user_id }.
-// Ответ: UserURLsResponse с массивом ссылок или ошибка.
+// GetUserURLs возвращает страницу сокращённых URL пользователя.
+// Запрос: UserURLsRequest { limit, cursor, sort, order, q }, параметры выборки необязательны.
+// Ответ: UserURLsResponse с массивом ссылок и курсором следующей страницы, пустым на последней странице,
+// или InvalidArgument при некорректных параметрах выборки.
 func (s *Server) GetUserURLs(ctx context.Context, req *proto.UserURLsRequest) (*proto.UserURLsResponse, error) {
-	readDTO, err := s.service.GetUserURLs(ctx, req.UserId)
+	userID, err := requestUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	query := models.UserURLsQuery{
+		Limit:  int(req.Limit),
+		Cursor: req.Cursor,
+		Sort:   req.Sort,
+		Order:  req.Order,
+		Query:  req.Q,
+	}
+	page, err := s.service.GetUserURLs(ctx, userID, query)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return &proto.UserURLsResponse{}, nil
+	case errors.Is(err, store.ErrInvalidLimit), errors.Is(err, store.ErrInvalidSort),
+		errors.Is(err, store.ErrInvalidOrder), errors.Is(err, store.ErrInvalidCursor):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return nil, err
+	}
+	items := make([]*proto.UserURLItem, len(page.URLs))
+	for i, dto := range page.URLs {
+		items[i] = &proto.UserURLItem{
+			ShortUrl:    dto.ShortURL,
+			OriginalUrl: dto.OriginalURL,
+		}
+	}
+	return &proto.UserURLsResponse{Urls: items, NextCursor: page.NextCursor}, nil
+}
+
+// LookupByOriginalURL находит сокращённые URL пользователя, ведущие на заданный адрес с точностью до нормализации.
+// Запрос: LookupByOriginalURLRequest { url }.
+// Ответ: LookupByOriginalURLResponse с массивом ссылок, ошибка InvalidArgument, если url не является
+// абсолютным URL или не указан пользователь, либо NotFound, если таких ссылок нет.
+func (s *Server) LookupByOriginalURL(ctx context.Context, req *proto.LookupByOriginalURLRequest) (*proto.LookupByOriginalURLResponse, error) {
+	userID, err := requestUserID(ctx, req.UserId)
 	if err != nil {
 		return nil, err
 	}
+	readDTO, err := s.service.LookupURLs(ctx, userID, req.Url)
+	switch {
+	case errors.Is(err, utils.ErrInvalidURL), errors.Is(err, store.ErrEmptyUserID):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return nil, err
+	case len(readDTO) == 0:
+		return nil, status.Error(codes.NotFound, store.ErrNotFound.Error())
+	}
 	items := make([]*proto.UserURLItem, len(readDTO))
 	for i, dto := range readDTO {
 		items[i] = &proto.UserURLItem{
@@ -76,15 +146,103 @@ func (s *Server) GetUserURLs(ctx context.Context, req *proto.UserURLsRequest) (*
 			OriginalUrl: dto.OriginalURL,
 		}
 	}
-	return &proto.UserURLsResponse{Urls: items}, nil
+	return &proto.LookupByOriginalURLResponse{Urls: items}, nil
 }
 
-// DeleteUserURLs удаляет список URL пользователя асинхронно.
-// Запрос: DeleteURLsRequest { user_id, short_urls }.
-// Ответ: DeleteURLsResponse без тела.
+// DeleteUserURLs ставит удаление списка URL пользователя в очередь.
+// Запрос: DeleteURLsRequest { short_urls }.
+// Ответ: DeleteURLsResponse { job_id } для отслеживания через GetDeletionJob, ошибка InvalidArgument
+// при пустом списке или пользователе либо Unavailable, если очередь удаления переполнена или остановлена.
 func (s *Server) DeleteUserURLs(ctx context.Context, req *proto.DeleteURLsRequest) (*proto.DeleteURLsResponse, error) {
-	go s.service.DeleteURLs(ctx, req.UserId, req.ShortUrls)
-	return &proto.DeleteURLsResponse{}, nil
+	userID, err := requestUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	jobID, err := s.service.DeleteURLs(ctx, userID, req.ShortUrls)
+	switch {
+	case errors.Is(err, deletion.ErrQueueFull), errors.Is(err, deletion.ErrQueueClosed):
+		return nil, status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, store.ErrEmptyUserID), errors.Is(err, store.ErrEmptyURLs):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return nil, err
+	}
+	return &proto.DeleteURLsResponse{JobId: jobID}, nil
+}
+
+// GetDeletionJob возвращает состояние задачи удаления ссылок пользователя.
+// Запрос: DeletionJobRequest { job_id }.
+// Ответ: DeletionJobResponse со статусом задачи или ошибка NotFound, если задача не найдена,
+// устарела или поставлена другим пользователем.
+func (s *Server) GetDeletionJob(ctx context.Context, req *proto.DeletionJobRequest) (*proto.DeletionJobResponse, error) {
+	userID, err := requestUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	job, err := s.service.GetDeletionJob(ctx, userID, req.JobId)
+	if errors.Is(err, deletion.ErrJobNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp := &proto.DeletionJobResponse{
+		JobId:     job.ID,
+		Status:    job.Status,
+		Urls:      int64(job.URLs),
+		CreatedAt: timestamppb.New(job.CreatedAt),
+		Error:     job.Error,
+	}
+	if job.FinishedAt != nil {
+		resp.FinishedAt = timestamppb.New(*job.FinishedAt)
+	}
+	return resp, nil
+}
+
+// RestoreUserURLs восстанавливает удалённые URL пользователя в пределах срока восстановления.
+// Запрос: RestoreURLsRequest { short_urls }.
+// Ответ: RestoreURLsResponse { short_urls } с восстановленными ключами, ошибка InvalidArgument
+// при пустом списке или пользователе либо NotFound, если ни один URL не восстановлен.
+func (s *Server) RestoreUserURLs(ctx context.Context, req *proto.RestoreURLsRequest) (*proto.RestoreURLsResponse, error) {
+	userID, err := requestUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	restored, err := s.service.RestoreURLs(ctx, userID, req.ShortUrls)
+	switch {
+	case errors.Is(err, store.ErrEmptyUserID), errors.Is(err, store.ErrEmptyURLs):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return nil, err
+	case len(restored) == 0:
+		return nil, status.Error(codes.NotFound, store.ErrNotFound.Error())
+	}
+	return &proto.RestoreURLsResponse{ShortUrls: restored}, nil
+}
+
+// UpdateURL изменяет адрес, на который ведёт сокращённый URL пользователя, и код ответа при переходе по нему.
+// Запрос: UpdateURLRequest { short_url, original_url, redirect_type }, пустые поля не меняются.
+// Ответ: UpdateURLResponse с адресом и кодом ответа после изменения, ошибка NotFound, если ссылка не найдена
+// или принадлежит другому пользователю, либо AlreadyExists, если новый URL уже сокращён.
+func (s *Server) UpdateURL(ctx context.Context, req *proto.UpdateURLRequest) (*proto.UpdateURLResponse, error) {
+	userID, err := requestUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	updateDTO := models.ShortURLUpdateDTO{URL: req.OriginalUrl, RedirectType: int(req.RedirectType)}
+	readDTO, err := s.service.UpdateURL(ctx, userID, req.ShortUrl, updateDTO)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, store.ErrAlreadyExists):
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, store.ErrEmptyKey), errors.Is(err, store.ErrEmptyValue), errors.Is(err, store.ErrEmptyUserID),
+		errors.Is(err, utils.ErrInvalidRedirectType):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return nil, err
+	}
+	return &proto.UpdateURLResponse{ShortUrl: readDTO.ShortURL, OriginalUrl: readDTO.OriginalURL, RedirectType: int32(readDTO.RedirectType)}, nil
 }
 
 // Ping проверяет доступность базы данных.
@@ -112,16 +270,102 @@ func (s *Server) GetStats(ctx context.Context, _ *proto.StatsRequest) (*proto.St
 	}, nil
 }
 
-// GetOriginalURL возвращает оригинальный URL по его сокращённой форме.
-// Запрос: GetOriginalURLRequest { short_url }.
-// Ответ: GetOriginalURLResponse с оригинальной ссылкой или ошибка.
+// GetOriginalURL возвращает сведения о ссылке без перехода по ней.
+// Запрос: GetOriginalURLRequest { short_url }, момент создания возвращается только аутентифицированному владельцу.
+// Ответ: GetOriginalURLResponse { original_url, short_url, status, created_at, expires_at, clicks, redirect_type,
+// forward_query, forward_path },
+// ошибка NotFound для неизвестной ссылки либо FailedPrecondition для удалённой.
 func (s *Server) GetOriginalURL(ctx context.Context, req *proto.GetOriginalURLRequest) (*proto.GetOriginalURLResponse, error) {
-	longURL, err := s.service.GetLongURL(ctx, req.ShortUrl)
+	userID, _ := jwt.UserIDFromContext(ctx)
+	info, err := s.service.ExpandURL(ctx, userID, req.ShortUrl)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, store.ErrAlreadyDeleted):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case err != nil:
+		return nil, err
+	}
+	resp := &proto.GetOriginalURLResponse{
+		OriginalUrl:  info.OriginalURL,
+		ShortUrl:     info.ShortURL,
+		Status:       info.Status,
+		Clicks:       int64(info.Clicks),
+		RedirectType: int32(info.RedirectType),
+		ForwardQuery: info.ForwardQuery,
+		ForwardPath:  info.ForwardPath,
+	}
+	if info.CreatedAt != nil {
+		resp.CreatedAt = timestamppb.New(*info.CreatedAt)
+	}
+	if info.ExpiresAt != nil {
+		resp.ExpiresAt = timestamppb.New(*info.ExpiresAt)
+	}
+	return resp, nil
+}
+
+// GetURLStats возвращает статистику переходов по ссылке пользователя.
+// Запрос: URLStatsRequest { short_url }.
+// Ответ: URLStatsResponse с общим числом переходов, уникальными посетителями и разбивкой по дням
+// или ошибка NotFound, если ссылка не найдена или принадлежит другому пользователю.
+func (s *Server) GetURLStats(ctx context.Context, req *proto.URLStatsRequest) (*proto.URLStatsResponse, error) {
+	userID, err := requestUserID(ctx, req.UserId)
 	if err != nil {
-		if err == store.ErrAlreadyDeleted {
-			return nil, store.ErrAlreadyDeleted
-		}
 		return nil, err
 	}
-	return &proto.GetOriginalURLResponse{OriginalUrl: longURL}, nil
+	stats, err := s.service.GetURLStats(ctx, userID, req.ShortUrl)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	daily := make([]*proto.DailyClicks, len(stats.Daily))
+	for i, day := range stats.Daily {
+		daily[i] = &proto.DailyClicks{Date: day.Date, Clicks: int64(day.Clicks)}
+	}
+	return &proto.URLStatsResponse{
+		ShortUrl:       stats.ShortURL,
+		Clicks:         int64(stats.Clicks),
+		UniqueVisitors: int64(stats.UniqueVisitors),
+		Daily:          daily,
+	}, nil
+}
+
+// linkOptionsRequest - gRPC-запрос создания ссылки с её необязательными параметрами.
+type linkOptionsRequest interface {
+	GetExpiresIn() int64
+	GetExpiresAt() *timestamppb.Timestamp
+	GetRedirectType() int32
+	GetForwardQuery() string
+	GetForwardPath() bool
+}
+
+// linkOptions собирает параметры ссылки из полей gRPC-запроса.
+func linkOptions(req linkOptionsRequest) models.LinkOptions {
+	opts := models.LinkOptions{
+		ExpiresIn:    req.GetExpiresIn(),
+		RedirectType: int(req.GetRedirectType()),
+		ForwardQuery: req.GetForwardQuery(),
+		ForwardPath:  req.GetForwardPath(),
+	}
+	if expiresAt := req.GetExpiresAt(); expiresAt != nil {
+		t := expiresAt.AsTime()
+		opts.ExpiresAt = &t
+	}
+	return opts
+}
+
+// shortenError переводит ошибки создания ссылки, связанные с параметрами запроса, в gRPC-статусы.
+// Остальные ошибки возвращаются без изменений.
+func shortenError(err error) error {
+	switch {
+	case errors.Is(err, store.ErrKeyTaken):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, utils.ErrInvalidAlias), errors.Is(err, utils.ErrReservedAlias), errors.Is(err, service.ErrInvalidExpiry),
+		errors.Is(err, utils.ErrInvalidRedirectType), errors.Is(err, utils.ErrInvalidQueryForward):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return err
+	}
 }
@@ -5,12 +5,18 @@ import (
 	"database/sql"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/shekshuev/shortener/internal/app/config"
+	"github.com/shekshuev/shortener/internal/app/jwt"
 	"github.com/shekshuev/shortener/internal/app/mocks"
+	"github.com/shekshuev/shortener/internal/app/models"
 	"github.com/shekshuev/shortener/internal/app/proto"
 	"github.com/shekshuev/shortener/internal/app/service"
+	"github.com/shekshuev/shortener/internal/app/store"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func setupTestServer() *Server {
@@ -20,9 +26,14 @@ func setupTestServer() *Server {
 	return srv
 }
 
+// userContext возвращает контекст вызова, аутентифицированного перехватчиком как пользователь userID.
+func userContext(userID string) context.Context {
+	return jwt.WithUserID(context.Background(), userID)
+}
+
 func TestServer_Shorten(t *testing.T) {
 	srv := setupTestServer()
-	ctx := context.Background()
+	ctx := userContext("test-user-id")
 
 	testCases := []struct {
 		name         string
@@ -30,8 +41,12 @@ func TestServer_Shorten(t *testing.T) {
 		expectError  bool
 		expectResult bool
 	}{
-		{name: "Success", request: &proto.ShortenRequest{Url: "https://example.com", UserId: "test-user-id"}, expectError: false, expectResult: true},
-		{name: "Empty URL", request: &proto.ShortenRequest{Url: "", UserId: "test-user-id"}, expectError: true, expectResult: false},
+		{name: "Success", request: &proto.ShortenRequest{Url: "https://example.com"}, expectError: false, expectResult: true},
+		{name: "Empty URL", request: &proto.ShortenRequest{Url: ""}, expectError: true, expectResult: false},
+		{name: "Redirect type", request: &proto.ShortenRequest{Url: "https://example.org", RedirectType: 308}, expectError: false, expectResult: true},
+		{name: "Invalid redirect type", request: &proto.ShortenRequest{Url: "https://example.net", RedirectType: 200}, expectError: true, expectResult: false},
+		{name: "Forwarding", request: &proto.ShortenRequest{Url: "https://docs.example.com", ForwardQuery: "override", ForwardPath: true}, expectError: false, expectResult: true},
+		{name: "Invalid forward query", request: &proto.ShortenRequest{Url: "https://docs.example.org", ForwardQuery: "append"}, expectError: true, expectResult: false},
 	}
 
 	for _, tc := range testCases {
@@ -49,9 +64,34 @@ func TestServer_Shorten(t *testing.T) {
 	}
 }
 
+func TestServer_Shorten_Alias(t *testing.T) {
+	srv := setupTestServer()
+	ctx := userContext("test-user-id")
+
+	testCases := []struct {
+		name         string
+		request      *proto.ShortenRequest
+		expectedCode codes.Code
+	}{
+		{name: "Free alias", request: &proto.ShortenRequest{Url: "https://example.com", Alias: "my-link"}, expectedCode: codes.OK},
+		{name: "Taken alias", request: &proto.ShortenRequest{Url: "https://golang.org", Alias: "my-link"}, expectedCode: codes.AlreadyExists},
+		{name: "Reserved alias", request: &proto.ShortenRequest{Url: "https://golang.org", Alias: "ping"}, expectedCode: codes.InvalidArgument},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := srv.Shorten(ctx, tc.request)
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if tc.expectedCode == codes.OK {
+				assert.True(t, strings.HasSuffix(resp.Result, "/my-link"))
+			}
+		})
+	}
+}
+
 func TestServer_BatchShorten(t *testing.T) {
 	srv := setupTestServer()
-	ctx := context.Background()
+	ctx := userContext("test-user-id")
 
 	testCases := []struct {
 		name        string
@@ -66,13 +106,12 @@ func TestServer_BatchShorten(t *testing.T) {
 					{CorrelationId: "id1", OriginalUrl: "https://example.com"},
 					{CorrelationId: "id2", OriginalUrl: "https://golang.org"},
 				},
-				UserId: "test-user-id",
 			},
 			expectError: false, expectedLen: 2,
 		},
 		{
 			name:        "Empty Items",
-			request:     &proto.BatchShortenRequest{Items: []*proto.BatchShortenRequestItem{}, UserId: "test-user-id"},
+			request:     &proto.BatchShortenRequest{Items: []*proto.BatchShortenRequestItem{}},
 			expectError: false, expectedLen: 0,
 		},
 	}
@@ -88,27 +127,117 @@ func TestServer_BatchShorten(t *testing.T) {
 
 func TestServer_GetUserURLs(t *testing.T) {
 	srv := setupTestServer()
-	ctx := context.Background()
+	ctx := userContext("test-user-id")
 
 	t.Run("Get User URLs", func(t *testing.T) {
-		_, _ = srv.Shorten(ctx, &proto.ShortenRequest{Url: "https://example.com", UserId: "test-user-id"})
-		resp, err := srv.GetUserURLs(ctx, &proto.UserURLsRequest{UserId: "test-user-id"})
+		_, _ = srv.Shorten(ctx, &proto.ShortenRequest{Url: "https://example.com"})
+		resp, err := srv.GetUserURLs(ctx, &proto.UserURLsRequest{})
 		assert.NoError(t, err)
 		assert.NotEmpty(t, resp.Urls)
 	})
+
+	t.Run("Paginate User URLs", func(t *testing.T) {
+		_, _ = srv.Shorten(ctx, &proto.ShortenRequest{Url: "https://ya.ru"})
+		resp, err := srv.GetUserURLs(ctx, &proto.UserURLsRequest{Limit: 1})
+		assert.NoError(t, err)
+		assert.Len(t, resp.Urls, 1)
+		assert.NotEmpty(t, resp.NextCursor)
+		resp, err = srv.GetUserURLs(ctx, &proto.UserURLsRequest{Limit: 1, Cursor: resp.NextCursor})
+		assert.NoError(t, err)
+		assert.Len(t, resp.Urls, 1)
+		assert.Empty(t, resp.NextCursor)
+	})
+
+	t.Run("Invalid Limit", func(t *testing.T) {
+		_, err := srv.GetUserURLs(ctx, &proto.UserURLsRequest{Limit: -1})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestServer_LookupByOriginalURL(t *testing.T) {
+	srv := setupTestServer()
+	ctx := userContext("test-user-id")
+	_, _ = srv.Shorten(ctx, &proto.ShortenRequest{Url: "http://Example.com:80"})
+
+	t.Run("Found", func(t *testing.T) {
+		resp, err := srv.LookupByOriginalURL(ctx, &proto.LookupByOriginalURLRequest{Url: "http://example.com/"})
+		assert.NoError(t, err)
+		assert.Len(t, resp.Urls, 1)
+	})
+
+	t.Run("Another User", func(t *testing.T) {
+		_, err := srv.LookupByOriginalURL(userContext("other-user-id"), &proto.LookupByOriginalURLRequest{Url: "http://example.com/"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("Invalid URL", func(t *testing.T) {
+		_, err := srv.LookupByOriginalURL(ctx, &proto.LookupByOriginalURLRequest{Url: "not a url"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
 }
 
 func TestServer_DeleteUserURLs(t *testing.T) {
 	srv := setupTestServer()
-	ctx := context.Background()
+	ctx := userContext("test-user-id")
 
 	t.Run("Delete User URLs", func(t *testing.T) {
 		resp, err := srv.DeleteUserURLs(ctx, &proto.DeleteURLsRequest{ShortUrls: []string{"id1", "id2"}})
 		assert.NoError(t, err)
 		assert.NotNil(t, resp)
+		assert.NotEmpty(t, resp.JobId)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		_, err := srv.DeleteUserURLs(context.Background(), &proto.DeleteURLsRequest{ShortUrls: []string{"id1"}})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Mismatched user_id", func(t *testing.T) {
+		_, err := srv.DeleteUserURLs(ctx, &proto.DeleteURLsRequest{ShortUrls: []string{"id1"}, UserId: "other-user-id"})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
 	})
 }
 
+func TestServer_GetDeletionJob(t *testing.T) {
+	srv := setupTestServer()
+	ctx := userContext("test-user-id")
+	short, err := srv.Shorten(ctx, &proto.ShortenRequest{Url: "https://example.com"})
+	assert.NoError(t, err)
+	key := short.Result[strings.LastIndex(short.Result, "/")+1:]
+	deleted, err := srv.DeleteUserURLs(ctx, &proto.DeleteURLsRequest{ShortUrls: []string{key}})
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		resp, err := srv.GetDeletionJob(ctx, &proto.DeletionJobRequest{JobId: deleted.JobId})
+		return err == nil && resp.Status == models.DeletionJobDone && resp.FinishedAt != nil
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = srv.GetDeletionJob(userContext("other-user-id"), &proto.DeletionJobRequest{JobId: deleted.JobId})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServer_RestoreUserURLs(t *testing.T) {
+	srv := setupTestServer()
+	ctx := userContext("test-user-id")
+	short, err := srv.Shorten(ctx, &proto.ShortenRequest{Url: "https://example.com"})
+	assert.NoError(t, err)
+	key := short.Result[strings.LastIndex(short.Result, "/")+1:]
+	deleted, err := srv.DeleteUserURLs(ctx, &proto.DeleteURLsRequest{ShortUrls: []string{key}})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		resp, err := srv.GetDeletionJob(ctx, &proto.DeletionJobRequest{JobId: deleted.JobId})
+		return err == nil && resp.Status == models.DeletionJobDone
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = srv.RestoreUserURLs(userContext("other-user-id"), &proto.RestoreURLsRequest{ShortUrls: []string{key}})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	resp, err := srv.RestoreUserURLs(ctx, &proto.RestoreURLsRequest{ShortUrls: []string{key}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{key}, resp.ShortUrls)
+	_, err = srv.RestoreUserURLs(ctx, &proto.RestoreURLsRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
 func TestServer_Ping(t *testing.T) {
 	cfg := config.GetConfig()
 	mockStore := new(mocks.MockStore)
@@ -183,40 +312,127 @@ func TestServer_GetStats(t *testing.T) {
 
 func TestServer_GetOriginalURL(t *testing.T) {
 	srv := setupTestServer()
-	ctx := context.Background()
+	ctx := userContext("test-user-id")
 
-	shortResp, _ := srv.Shorten(ctx, &proto.ShortenRequest{Url: "https://example.com", UserId: "test-user-id"})
+	shortResp, _ := srv.Shorten(ctx, &proto.ShortenRequest{Url: "https://example.com"})
 	shortID := strings.TrimPrefix(shortResp.Result, config.GetConfig().BaseURL+"/")
+	deletedResp, _ := srv.Shorten(ctx, &proto.ShortenRequest{Url: "https://example.org"})
+	deletedID := strings.TrimPrefix(deletedResp.Result, config.GetConfig().BaseURL+"/")
+	_, err := srv.DeleteUserURLs(ctx, &proto.DeleteURLsRequest{ShortUrls: []string{deletedID}})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, err := srv.GetOriginalURL(ctx, &proto.GetOriginalURLRequest{ShortUrl: deletedID})
+		return status.Code(err) == codes.FailedPrecondition
+	}, time.Second, 5*time.Millisecond, "Deleted url should be reported as FailedPrecondition")
 
 	testCases := []struct {
-		name        string
-		shortURL    string
-		expectedURL string
-		expectError bool
+		name          string
+		shortURL      string
+		userID        string
+		expectedURL   string
+		expectedCode  codes.Code
+		seesCreatedAt bool
 	}{
 		{
-			name:        "Success",
-			shortURL:    shortID,
-			expectedURL: "https://example.com",
-			expectError: false,
+			name:          "Owner",
+			shortURL:      shortID,
+			userID:        "test-user-id",
+			expectedURL:   "https://example.com",
+			expectedCode:  codes.OK,
+			seesCreatedAt: true,
+		},
+		{
+			name:         "Another User",
+			shortURL:     shortID,
+			userID:       "other-user-id",
+			expectedURL:  "https://example.com",
+			expectedCode: codes.OK,
 		},
 		{
-			name:        "Failure",
-			shortURL:    "nonexistent",
-			expectError: true,
+			name:         "Failure",
+			shortURL:     "nonexistent",
+			expectedCode: codes.NotFound,
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			resp, err := srv.GetOriginalURL(ctx, &proto.GetOriginalURLRequest{ShortUrl: tc.shortURL})
-
-			if tc.expectError {
-				assert.Error(t, err)
+			resp, err := srv.GetOriginalURL(userContext(tc.userID), &proto.GetOriginalURLRequest{ShortUrl: tc.shortURL})
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if tc.expectedCode != codes.OK {
 				assert.Nil(t, resp)
-			} else {
-				assert.NoError(t, err)
-				assert.Equal(t, tc.expectedURL, resp.OriginalUrl)
+				return
+			}
+			assert.Equal(t, tc.expectedURL, resp.OriginalUrl)
+			assert.Equal(t, shortResp.Result, resp.ShortUrl)
+			assert.Equal(t, models.URLStatusActive, resp.Status)
+			assert.Equal(t, tc.seesCreatedAt, resp.CreatedAt != nil)
+		})
+	}
+}
+
+func TestServer_GetURLStats(t *testing.T) {
+	cfg := config.GetConfig()
+	mockStore := mocks.NewURLStore()
+	srv := NewServer(service.NewURLService(mockStore, &cfg))
+	ctx := userContext("test-user-id")
+
+	stats := models.URLStatsDTO{ShortURL: "short1", Clicks: 3, UniqueVisitors: 2, Daily: []models.DailyClicksDTO{{Date: "2024-05-01", Clicks: 3}}}
+	mockStore.On("GetURLStats", "test-user-id", "short1").Return(stats, nil)
+	mockStore.On("GetURLStats", "test-user-id", "unknown").Return(models.URLStatsDTO{}, store.ErrNotFound)
+
+	t.Run("Success", func(t *testing.T) {
+		resp, err := srv.GetURLStats(ctx, &proto.URLStatsRequest{ShortUrl: "short1"})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), resp.Clicks)
+		assert.Equal(t, int64(2), resp.UniqueVisitors)
+		assert.Len(t, resp.Daily, 1)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		_, err := srv.GetURLStats(ctx, &proto.URLStatsRequest{ShortUrl: "unknown"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+func TestServer_UpdateURL(t *testing.T) {
+	cfg := config.GetConfig()
+	mockStore := mocks.NewURLStore()
+	srv := NewServer(service.NewURLService(mockStore, &cfg))
+	ctx := userContext("test-user-id")
+
+	_, err := mockStore.SetURL(ctx, "short1", "https://ya.ru", "test-user-id", models.LinkOptions{})
+	assert.NoError(t, err)
+	_, err = mockStore.SetURL(ctx, "short2", "https://google.com", "test-user-id", models.LinkOptions{})
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		userID       string
+		req          *proto.UpdateURLRequest
+		expectedCode codes.Code
+	}{
+		{name: "Success", req: &proto.UpdateURLRequest{ShortUrl: "short1", OriginalUrl: "https://example.com"}, expectedCode: codes.OK},
+		{name: "Already exists", req: &proto.UpdateURLRequest{ShortUrl: "short1", OriginalUrl: "https://google.com"}, expectedCode: codes.AlreadyExists},
+		{name: "Another user", userID: "other-user-id", req: &proto.UpdateURLRequest{ShortUrl: "short1", OriginalUrl: "https://example.org"}, expectedCode: codes.NotFound},
+		{name: "Empty url", req: &proto.UpdateURLRequest{ShortUrl: "short1"}, expectedCode: codes.InvalidArgument},
+		{name: "Redirect type", req: &proto.UpdateURLRequest{ShortUrl: "short1", RedirectType: 301}, expectedCode: codes.OK},
+		{name: "Invalid redirect type", req: &proto.UpdateURLRequest{ShortUrl: "short1", RedirectType: 200}, expectedCode: codes.InvalidArgument},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			callCtx := ctx
+			if tc.userID != "" {
+				callCtx = userContext(tc.userID)
+			}
+			resp, err := srv.UpdateURL(callCtx, tc.req)
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if tc.expectedCode == codes.OK {
+				assert.Equal(t, "https://example.com", resp.OriginalUrl)
+				assert.Equal(t, cfg.BaseURL+"/short1", resp.ShortUrl)
+				if tc.req.RedirectType != 0 {
+					assert.Equal(t, tc.req.RedirectType, resp.RedirectType)
+				}
 			}
 		})
 	}
@@ -6,14 +6,19 @@ import (
 	"io"
 	"net"
 	"net/http"
-	"path"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/shekshuev/shortener/internal/app/apikey"
+	"github.com/shekshuev/shortener/internal/app/deletion"
 	"github.com/shekshuev/shortener/internal/app/jwt"
 	"github.com/shekshuev/shortener/internal/app/middleware"
 	"github.com/shekshuev/shortener/internal/app/models"
 	"github.com/shekshuev/shortener/internal/app/service"
 	"github.com/shekshuev/shortener/internal/app/store"
+	"github.com/shekshuev/shortener/internal/utils"
 )
 
 // URLHandler обрабатывает HTTP-запросы для управления сокращёнными URL.
@@ -26,17 +31,41 @@ type URLHandler struct {
 // NewURLHandler создаёт новый экземпляр URLHandler с зарегистрированными маршрутами.
 func NewURLHandler(service service.Service, trustedSubnet *net.IPNet) *URLHandler {
 	router := chi.NewRouter()
+	router.Use(middleware.BearerAuth(service))
 	router.Use(middleware.RequestAuth)
 	router.Use(middleware.RequestLogger)
 	router.Use(middleware.GzipCompressor)
 	h := &URLHandler{service: service, Router: router, trustedSubnet: trustedSubnet}
-	router.Post("/", h.createURLHandler)
-	router.Post("/api/shorten", h.createURLHandlerJSON)
-	router.Post("/api/shorten/batch", h.batchCreateURLHandlerJSON)
+	router.Group(func(r chi.Router) {
+		r.Use(middleware.RequireScope(apikey.ScopeCreate))
+		r.Post("/", h.createURLHandler)
+		r.Post("/api/shorten", h.createURLHandlerJSON)
+		r.Post("/api/shorten/batch", h.batchCreateURLHandlerJSON)
+		r.Patch("/api/user/urls/{short}", h.updateUserURLHandler)
+	})
+	router.Group(func(r chi.Router) {
+		r.Use(middleware.RequireScope(apikey.ScopeRead))
+		r.Get("/api/user/urls", h.getUserURLsHandler)
+		r.Get("/api/user/urls/lookup", h.lookupUserURLsHandler)
+		r.Get("/api/user/urls/{short}/stats", h.getURLStatsHandler)
+		r.Get("/api/jobs/{id}", h.getDeletionJobHandler)
+	})
+	router.Group(func(r chi.Router) {
+		r.Use(middleware.RequireScope(apikey.ScopeDelete))
+		r.Delete("/api/user/urls", h.deleteUserURLsHandler)
+		r.Post("/api/user/urls/restore", h.restoreUserURLsHandler)
+	})
+	router.Group(func(r chi.Router) {
+		r.Use(middleware.RejectAPIKeys)
+		r.Post("/api/user/keys", h.createAPIKeyHandler)
+		r.Get("/api/user/keys", h.getAPIKeysHandler)
+		r.Delete("/api/user/keys/{id}", h.revokeAPIKeyHandler)
+	})
 	router.Get("/{shorted}", h.getURLHandler)
-	router.Get("/api/user/urls", h.getUserURLsHandler)
-	router.Delete("/api/user/urls", h.deleteUserURLsHandler)
+	router.Get("/{shorted}/*", h.getURLHandler)
+	router.Get("/api/expand/{short}", h.expandURLHandler)
 	router.Get("/ping", h.pingURLHandler)
+	router.Get("/.well-known/jwks.json", h.jwksHandler)
 	router.Get("/api/internal/stats", h.getStatsHandler)
 	return h
 }
@@ -51,18 +80,18 @@ func (h *URLHandler) createURLHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	w.Header().Set("Content-Type", "text/plain")
-	cookie, err := jwt.GetAuthCookie(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-	}
-	userID, err := jwt.GetUserID(cookie)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	userID, ok := jwt.UserIDFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
 	}
-	shortURL, err := h.service.CreateShortURL(r.Context(), string(body), userID)
+	shortURL, err := h.service.CreateShortURL(r.Context(), models.ShortURLCreateDTO{URL: string(body)}, userID)
 	switch {
 	case errors.Is(err, store.ErrAlreadyExists):
 		w.WriteHeader(http.StatusConflict)
+	case errors.Is(err, store.ErrKeyTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
 	case err != nil:
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -76,8 +105,11 @@ func (h *URLHandler) createURLHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // createURLHandlerJSON обрабатывает создание короткого URL через JSON.
-// Запрос: `POST /api/shorten`, тело — JSON {"url": "http://example.com"}.
-// Ответ: 201 Created + JSON {"result": "short_url"}, либо 409 Conflict.
+// Запрос: `POST /api/shorten`, тело — JSON {"url": "http://example.com", "alias": "my-link", "expires_in": 3600, "redirect_type": 301},
+// алиас, срок действия (expires_in в секундах или expires_at в RFC 3339), код ответа при переходе
+// (301, 302, 307 или 308), передача параметров запроса посетителя (forward_query: merge или override)
+// и остатка пути после ключа (forward_path) необязательны.
+// Ответ: 201 Created + JSON {"result": "short_url"}, либо 409 Conflict, если URL уже существует или алиас занят.
 func (h *URLHandler) createURLHandlerJSON(w http.ResponseWriter, r *http.Request) {
 	var createDTO models.ShortURLCreateDTO
 	body, err := io.ReadAll(r.Body)
@@ -90,19 +122,19 @@ func (h *URLHandler) createURLHandlerJSON(w http.ResponseWriter, r *http.Request
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	cookie, err := jwt.GetAuthCookie(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-	}
-	userID, err := jwt.GetUserID(cookie)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	userID, ok := jwt.UserIDFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
 	}
-	shortURL, err := h.service.CreateShortURL(r.Context(), createDTO.URL, userID)
+	shortURL, err := h.service.CreateShortURL(r.Context(), createDTO, userID)
 
 	switch {
 	case errors.Is(err, store.ErrAlreadyExists):
 		w.WriteHeader(http.StatusConflict)
+	case errors.Is(err, store.ErrKeyTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
 	case err != nil:
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -122,65 +154,240 @@ func (h *URLHandler) createURLHandlerJSON(w http.ResponseWriter, r *http.Request
 }
 
 // getURLHandler обрабатывает редирект по сокращённому URL.
-// Запрос: `GET /{shorted}`.
-// Ответ: 307 Temporary Redirect на оригинальный URL или 410 Gone, если URL удалён.
+// Запрос: `GET /{shorted}` или `GET /{shorted}/*`. Остаток пути и параметры запроса переносятся
+// в адрес назначения, если это разрешено параметрами ссылки.
+// Каждый успешный переход асинхронно записывается в статистику ссылки.
+// Ответ: перенаправление на оригинальный URL с кодом, заданным для ссылки (по умолчанию - из конфигурации),
+// 404 Not Found для перехода с остатком пути по ссылке, которая не передаёт путь,
+// или 410 Gone, если URL удалён или истёк срок его действия.
 func (h *URLHandler) getURLHandler(w http.ResponseWriter, r *http.Request) {
-	urlPath := path.Base(r.URL.Path)
-	if longURL, err := h.service.GetLongURL(r.Context(), urlPath); err == nil {
-		http.Redirect(w, r, longURL, http.StatusTemporaryRedirect)
+	urlPath := chi.URLParam(r, "shorted")
+	extraPath := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/"+urlPath), "/")
+	if redirect, err := h.service.GetRedirect(r.Context(), urlPath, extraPath, r.URL.RawQuery); err == nil {
+		h.service.RecordClick(models.ClickEvent{
+			ShortURL:  urlPath,
+			Timestamp: time.Now(),
+			Referrer:  r.Referer(),
+			UserAgent: r.UserAgent(),
+			IP:        clientIP(r),
+		})
+		http.Redirect(w, r, redirect.URL, redirect.Type)
 	} else {
-		if err == store.ErrAlreadyDeleted {
+		switch {
+		case errors.Is(err, store.ErrAlreadyDeleted) || errors.Is(err, store.ErrExpired):
 			w.WriteHeader(http.StatusGone)
-		} else {
+		case errors.Is(err, service.ErrPathNotForwarded):
+			w.WriteHeader(http.StatusNotFound)
+		default:
 			w.WriteHeader(http.StatusBadRequest)
 		}
-
 	}
 }
 
-// getUserURLsHandler получает список всех сокращённых URL пользователя.
-// Запрос: `GET /api/user/urls`.
-// Ответ: 200 OK + JSON или 204 No Content, если URL нет.
-func (h *URLHandler) getUserURLsHandler(w http.ResponseWriter, r *http.Request) {
-	cookie, err := jwt.GetAuthCookie(r)
+// expandURLHandler возвращает сведения о сокращённой ссылке без перехода по ней.
+// Запрос: `GET /api/expand/{short}`.
+// Ответ: 200 OK + JSON с оригинальным URL, состоянием, сроком действия и количеством переходов,
+// момент создания включается только для владельца ссылки; 404 Not Found для неизвестной ссылки
+// и 410 Gone для удалённой.
+func (h *URLHandler) expandURLHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := jwt.UserIDFromContext(r.Context())
+	info, err := h.service.ExpandURL(r.Context(), userID, chi.URLParam(r, "short"))
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, store.ErrAlreadyDeleted) {
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
+		http.Error(w, "failed to expand url", http.StatusInternalServerError)
+		return
 	}
-	userID, err := jwt.GetUserID(cookie)
+	resp, err := json.Marshal(info)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	if readDTO, err := h.service.GetUserURLs(r.Context(), userID); err == nil {
-		if readDTO == nil {
-			w.WriteHeader(http.StatusNoContent)
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// getUserURLsHandler получает страницу сокращённых URL пользователя.
+// Запрос: `GET /api/user/urls?limit=&cursor=&sort=created_at&order=desc&q=`, все параметры необязательны:
+// limit - размер страницы (без него возвращается весь список), sort - created_at, short_url или original_url,
+// order - asc или desc, q - подстрока оригинального URL.
+// Ответ: 200 OK + JSON, курсор следующей страницы передаётся в заголовке X-Next-Cursor;
+// 204 No Content, если URL нет; 400 Bad Request при некорректных параметрах.
+func (h *URLHandler) getUserURLsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := jwt.UserIDFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	params := r.URL.Query()
+	query := models.UserURLsQuery{
+		Cursor: params.Get("cursor"),
+		Sort:   params.Get("sort"),
+		Order:  params.Get("order"),
+		Query:  params.Get("q"),
+	}
+	if limit := params.Get("limit"); len(limit) > 0 {
+		var err error
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			http.Error(w, store.ErrInvalidLimit.Error(), http.StatusBadRequest)
 			return
 		}
-		resp, err := json.Marshal(readDTO)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		}
-		_, err = w.Write(resp)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		}
-	} else {
+	}
+	page, err := h.service.GetUserURLs(r.Context(), userID, query)
+	switch {
+	case errors.Is(err, store.ErrInvalidLimit), errors.Is(err, store.ErrInvalidSort),
+		errors.Is(err, store.ErrInvalidOrder), errors.Is(err, store.ErrInvalidCursor):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
 		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	resp, err := json.Marshal(page.URLs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if len(page.NextCursor) > 0 {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+	_, err = w.Write(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 	}
+}
 
+// lookupUserURLsHandler находит сокращённые URL пользователя, ведущие на заданный адрес.
+// Адреса сравниваются после нормализации: регистр схемы и хоста и порт по умолчанию не важны.
+// Запрос: `GET /api/user/urls/lookup?url=https://example.com/x`.
+// Ответ: 200 OK + JSON-массив URL, 404 Not Found, если таких URL нет,
+// либо 400 Bad Request, если url не является абсолютным URL.
+func (h *URLHandler) lookupUserURLsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := jwt.UserIDFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	readDTO, err := h.service.LookupURLs(r.Context(), userID, r.URL.Query().Get("url"))
+	if errors.Is(err, utils.ErrInvalidURL) || errors.Is(err, store.ErrEmptyUserID) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to lookup urls", http.StatusInternalServerError)
+		return
+	}
+	if len(readDTO) == 0 {
+		http.Error(w, store.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	resp, err := json.Marshal(readDTO)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
 
 // deleteUserURLsHandler удаляет список URL пользователя.
 // Запрос: `DELETE /api/user/urls`, тело — JSON-массив сокращённых URL.
-// Ответ: 202 Accepted.
+// Ответ: 202 Accepted + JSON {"job_id": ...} для отслеживания через `GET /api/jobs/{id}`
+// либо 503 Service Unavailable, если очередь удаления переполнена или остановлена.
 func (h *URLHandler) deleteUserURLsHandler(w http.ResponseWriter, r *http.Request) {
-	cookie, err := jwt.GetAuthCookie(r)
-	if err != nil {
+	userID, ok := jwt.UserIDFromContext(r.Context())
+	if !ok {
 		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var urls []string
+	if err = json.Unmarshal(body, &urls); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	jobID, err := h.service.DeleteURLs(r.Context(), userID, urls)
+	if errors.Is(err, deletion.ErrQueueFull) || errors.Is(err, deletion.ErrQueueClosed) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
 	}
-	userID, err := jwt.GetUserID(cookie)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := json.Marshal(models.DeletionJobCreatedDTO{JobID: jobID})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_, err = w.Write(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// getDeletionJobHandler возвращает состояние задачи удаления ссылок пользователя.
+// Запрос: `GET /api/jobs/{id}`.
+// Ответ: 200 OK + JSON {"id": ..., "status": "pending|done|failed", "urls": ..., "created_at": ..., "finished_at": ..., "error": ...}
+// либо 404 Not Found, если задача не найдена, устарела или поставлена другим пользователем.
+func (h *URLHandler) getDeletionJobHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := jwt.UserIDFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	job, err := h.service.GetDeletionJob(r.Context(), userID, chi.URLParam(r, "id"))
+	if errors.Is(err, deletion.ErrJobNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to get deletion job", http.StatusInternalServerError)
+		return
+	}
+	resp, err := json.Marshal(job)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// restoreUserURLsHandler восстанавливает удалённые URL пользователя в пределах срока восстановления.
+// Запрос: `POST /api/user/urls/restore`, тело — JSON-массив сокращённых URL.
+// Ответ: 200 OK + JSON-массив восстановленных URL либо 404 Not Found, если ни один URL не восстановлен:
+// ссылки не удалены, принадлежат другому пользователю или срок восстановления истёк.
+func (h *URLHandler) restoreUserURLsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := jwt.UserIDFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
 	}
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -192,8 +399,205 @@ func (h *URLHandler) deleteUserURLsHandler(w http.ResponseWriter, r *http.Reques
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	go h.service.DeleteURLs(r.Context(), userID, urls)
-	w.WriteHeader(http.StatusAccepted)
+	restored, err := h.service.RestoreURLs(r.Context(), userID, urls)
+	if errors.Is(err, store.ErrEmptyURLs) || errors.Is(err, store.ErrEmptyUserID) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to restore urls", http.StatusInternalServerError)
+		return
+	}
+	if len(restored) == 0 {
+		http.Error(w, store.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	resp, err := json.Marshal(restored)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// updateUserURLHandler изменяет адрес, на который ведёт сокращённый URL пользователя, и код ответа при переходе.
+// Запрос: `PATCH /api/user/urls/{short}`, тело — JSON {"url": "http://example.com", "redirect_type": 301},
+// незаданные поля не меняются.
+// Ответ: 200 OK + JSON {"short_url": ..., "original_url": ..., "redirect_type": ...}, 404 Not Found, если ссылка не найдена
+// или принадлежит другому пользователю, либо 409 Conflict, если новый URL уже сокращён.
+func (h *URLHandler) updateUserURLHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := jwt.UserIDFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var updateDTO models.ShortURLUpdateDTO
+	if err = json.Unmarshal(body, &updateDTO); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	shortURL := chi.URLParam(r, "short")
+	readDTO, err := h.service.UpdateURL(r.Context(), userID, shortURL, updateDTO)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, store.ErrAlreadyExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := json.Marshal(readDTO)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// getURLStatsHandler возвращает статистику переходов по ссылке пользователя.
+// Запрос: `GET /api/user/urls/{short}/stats`.
+// Ответ: 200 OK + JSON {"short_url": ..., "clicks": ..., "unique_visitors": ..., "daily": [...]}
+// либо 404 Not Found, если ссылка не найдена или принадлежит другому пользователю.
+func (h *URLHandler) getURLStatsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := jwt.UserIDFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	stats, err := h.service.GetURLStats(r.Context(), userID, chi.URLParam(r, "short"))
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to get url stats", http.StatusInternalServerError)
+		return
+	}
+	resp, err := json.Marshal(stats)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// createAPIKeyHandler создаёт ключ API пользователя.
+// Запрос: `POST /api/user/keys`, тело — JSON {"name": "ci", "scopes": ["read", "create", "delete"]}.
+// Ответ: 201 Created + JSON {"id": ..., "name": ..., "prefix": ..., "scopes": [...], "created_at": ..., "key": ...};
+// сам ключ возвращается только в этом ответе. 400 Bad Request, если название пустое или разрешения неизвестны.
+func (h *URLHandler) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := jwt.UserIDFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var createDTO models.APIKeyCreateDTO
+	if err = json.Unmarshal(body, &createDTO); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	created, err := h.service.CreateAPIKey(r.Context(), userID, createDTO)
+	if errors.Is(err, apikey.ErrEmptyName) || errors.Is(err, apikey.ErrEmptyScopes) || errors.Is(err, apikey.ErrInvalidScope) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to create api key", http.StatusInternalServerError)
+		return
+	}
+	resp, err := json.Marshal(created)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// getAPIKeysHandler возвращает ключи API пользователя без самих ключей.
+// Запрос: `GET /api/user/keys`.
+// Ответ: 200 OK + JSON-массив [{"id": ..., "name": ..., "prefix": ..., "scopes": [...], "created_at": ..., "last_used_at": ...}]
+// либо 204 No Content, если ключей нет.
+func (h *URLHandler) getAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := jwt.UserIDFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	keys, err := h.service.GetAPIKeys(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "failed to get api keys", http.StatusInternalServerError)
+		return
+	}
+	if len(keys) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	resp, err := json.Marshal(keys)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// revokeAPIKeyHandler отзывает ключ API пользователя.
+// Запрос: `DELETE /api/user/keys/{id}`.
+// Ответ: 204 No Content либо 404 Not Found, если ключ не найден или принадлежит другому пользователю.
+func (h *URLHandler) revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := jwt.UserIDFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	err := h.service.RevokeAPIKey(r.Context(), userID, chi.URLParam(r, "id"))
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to revoke api key", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 // pingURLHandler проверяет доступность базы данных.
@@ -208,9 +612,32 @@ func (h *URLHandler) pingURLHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// jwksHandler публикует открытые ключи, которыми проверяются токены авторизации, выпущенные сервисом.
+// Запрос: `GET /.well-known/jwks.json`.
+// Ответ: 200 OK + JWK Set {"keys": [...]}; ключи HMAC не публикуются, поэтому при подписи секретом набор пуст.
+func (h *URLHandler) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	set, err := jwt.PublicKeys()
+	if err != nil {
+		http.Error(w, "failed to get public keys", http.StatusInternalServerError)
+		return
+	}
+	resp, err := json.Marshal(set)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 // batchCreateURLHandlerJSON создаёт несколько сокращённых URL за один запрос.
-// Запрос: `POST /api/shorten/batch`, тело — JSON-массив объектов { "url": "http://example.com" }.
-// Ответ: 201 Created + JSON-массив результатов, либо 409 Conflict.
+// Запрос: `POST /api/shorten/batch`, тело — JSON-массив объектов { "correlation_id": "1", "original_url": "http://example.com", "alias": "my-link" }.
+// Ответ: 201 Created + JSON-массив результатов, либо 409 Conflict, если URL уже существует или алиас занят.
 func (h *URLHandler) batchCreateURLHandlerJSON(w http.ResponseWriter, r *http.Request) {
 	var createDTO []models.BatchShortURLCreateDTO
 	body, err := io.ReadAll(r.Body)
@@ -223,18 +650,18 @@ func (h *URLHandler) batchCreateURLHandlerJSON(w http.ResponseWriter, r *http.Re
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	cookie, err := jwt.GetAuthCookie(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-	}
-	userID, err := jwt.GetUserID(cookie)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	userID, ok := jwt.UserIDFromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
 	}
 	readDTO, err := h.service.BatchCreateShortURL(r.Context(), createDTO, userID)
 	switch {
 	case errors.Is(err, store.ErrAlreadyExists):
 		w.WriteHeader(http.StatusConflict)
+	case errors.Is(err, store.ErrKeyTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
 	case err != nil:
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -293,3 +720,15 @@ func (h *URLHandler) getStatsHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
+
+// clientIP возвращает IP-адрес клиента из заголовка X-Real-IP, а при его отсутствии — из адреса соединения.
+func clientIP(r *http.Request) string {
+	if ip := r.Header.Get("X-Real-IP"); ip != "" {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
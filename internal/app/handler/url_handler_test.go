@@ -1,20 +1,33 @@
 package handler
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/shekshuev/shortener/internal/app/config"
+	"github.com/shekshuev/shortener/internal/app/jwt"
 	"github.com/shekshuev/shortener/internal/app/mocks"
 	"github.com/shekshuev/shortener/internal/app/models"
 	"github.com/shekshuev/shortener/internal/app/service"
+	"github.com/shekshuev/shortener/internal/app/store"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestNewURLHandler(t *testing.T) {
@@ -105,6 +118,43 @@ func TestURLHandler_createURLHandlerJSON(t *testing.T) {
 	}
 }
 
+func TestURLHandler_createURLHandlerJSON_Alias(t *testing.T) {
+	testCases := []struct {
+		name         string
+		body         string
+		expectedCode int
+	}{
+		{name: "Free alias", body: `{ "url": "https://ya.ru", "alias": "my-link" }`, expectedCode: http.StatusCreated},
+		{name: "Taken alias", body: `{ "url": "https://google.com", "alias": "my-link" }`, expectedCode: http.StatusConflict},
+		{name: "Invalid alias", body: `{ "url": "https://google.com", "alias": "my link" }`, expectedCode: http.StatusBadRequest},
+		{name: "Reserved alias", body: `{ "url": "https://google.com", "alias": "api" }`, expectedCode: http.StatusBadRequest},
+	}
+	cfg := config.GetConfig()
+	s := mocks.NewURLStore()
+	srv := service.NewURLService(s, &cfg)
+	handler := NewURLHandler(srv, nil)
+	httpSrv := httptest.NewServer(handler.Router)
+
+	defer httpSrv.Close()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := resty.New().R().
+				SetHeader("Content-Type", "application/json").
+				SetBody(tc.body).
+				Post(httpSrv.URL + "/api/shorten")
+			assert.NoError(t, err, "error making HTTP request")
+			assert.Equal(t, tc.expectedCode, resp.StatusCode(), "Response code didn't match expected")
+			if tc.expectedCode == http.StatusCreated {
+				var readDTO models.ShortURLReadDTO
+				err := json.Unmarshal(resp.Body(), &readDTO)
+				assert.NoError(t, err, "error unmarshal response body")
+				assert.Equal(t, cfg.BaseURL+"/my-link", readDTO.Result, "Wrong body")
+			}
+		})
+	}
+}
+
 func TestURLHandler_batchCreateURLHandlerJSON(t *testing.T) {
 	shortedLenWithSlash := 9
 	testCases := []struct {
@@ -197,6 +247,159 @@ func TestURLHandler_getURLHandler(t *testing.T) {
 	}
 }
 
+func TestURLHandler_getURLHandler_Expired(t *testing.T) {
+	cfg := config.GetConfig()
+	s := mocks.NewURLStore()
+	srv := service.NewURLService(s, &cfg)
+	handler := NewURLHandler(srv, nil)
+
+	expiresAt := time.Now().Add(-time.Minute)
+	_, err := s.SetURL(context.Background(), "expired", "https://ya.ru", "1", models.LinkOptions{ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/expired", nil)
+	rec := httptest.NewRecorder()
+	handler.Router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusGone, rec.Code, "Response code didn't match expected")
+}
+
+func TestURLHandler_getURLHandler_RedirectType(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.RedirectType = http.StatusFound
+	s := mocks.NewURLStore()
+	srv := service.NewURLService(s, &cfg)
+	defer srv.Close()
+	handler := NewURLHandler(srv, nil)
+	httpSrv := httptest.NewServer(handler.Router)
+	defer httpSrv.Close()
+
+	client := resty.New().SetRedirectPolicy(resty.NoRedirectPolicy())
+	testCases := []struct {
+		name         string
+		body         string
+		expectedCode int
+	}{
+		{name: "Server default", body: `{"url": "https://ya.ru", "alias": "default-link"}`, expectedCode: http.StatusFound},
+		{name: "Moved permanently", body: `{"url": "https://google.com", "alias": "moved-link", "redirect_type": 301}`, expectedCode: http.StatusMovedPermanently},
+		{name: "Permanent redirect", body: `{"url": "https://example.com", "alias": "perm-link", "redirect_type": 308}`, expectedCode: http.StatusPermanentRedirect},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := client.R().SetBody(tc.body).Post(httpSrv.URL + "/api/shorten")
+			assert.NoError(t, err, "error making HTTP request")
+			assert.Equal(t, http.StatusCreated, resp.StatusCode(), "Response code didn't match expected")
+			var readDTO models.ShortURLReadDTO
+			assert.NoError(t, json.Unmarshal(resp.Body(), &readDTO))
+
+			req := httptest.NewRequest(http.MethodGet, "/"+path.Base(readDTO.Result), nil)
+			rec := httptest.NewRecorder()
+			handler.Router.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedCode, rec.Code, "Response code didn't match expected")
+		})
+	}
+
+	resp, err := client.R().SetBody(`{"url": "https://ya.ru", "alias": "bad-link", "redirect_type": 200}`).Post(httpSrv.URL + "/api/shorten")
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode(), "Invalid redirect type should be rejected")
+}
+
+func TestURLHandler_getURLHandler_Passthrough(t *testing.T) {
+	cfg := config.GetConfig()
+	s := mocks.NewURLStore()
+	srv := service.NewURLService(s, &cfg)
+	defer srv.Close()
+	handler := NewURLHandler(srv, nil)
+
+	_, err := s.SetURL(context.Background(), "docs", "https://docs.example.com/v1?lang=en", "1", models.LinkOptions{ForwardQuery: "merge", ForwardPath: true})
+	assert.NoError(t, err)
+	_, err = s.SetURL(context.Background(), "plain", "https://ya.ru", "1", models.LinkOptions{})
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name             string
+		target           string
+		expectedCode     int
+		expectedLocation string
+	}{
+		{name: "Prefix link", target: "/docs/guide/install?lang=ru&page=2", expectedCode: http.StatusTemporaryRedirect, expectedLocation: "https://docs.example.com/v1/guide/install?lang=en&page=2"},
+		{name: "Prefix link without path", target: "/docs", expectedCode: http.StatusTemporaryRedirect, expectedLocation: "https://docs.example.com/v1?lang=en"},
+		{name: "Query dropped by default", target: "/plain?utm=1", expectedCode: http.StatusTemporaryRedirect, expectedLocation: "https://ya.ru"},
+		{name: "Path not forwarded", target: "/plain/guide", expectedCode: http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			rec := httptest.NewRecorder()
+			handler.Router.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedCode, rec.Code, "Response code didn't match expected")
+			assert.Equal(t, tc.expectedLocation, rec.Header().Get("Location"), "Location didn't match expected")
+		})
+	}
+}
+
+func TestURLHandler_getURLHandler_RecordsClick(t *testing.T) {
+	cfg := config.GetConfig()
+	s := mocks.NewURLStore()
+	srv := service.NewURLService(s, &cfg)
+	defer srv.Close()
+	handler := NewURLHandler(srv, nil)
+
+	_, err := s.SetURL(context.Background(), "short1", "https://ya.ru", "1", models.LinkOptions{})
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/short1", nil)
+	req.Header.Set("Referer", "https://google.com")
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set("X-Real-IP", "10.0.0.1")
+	rec := httptest.NewRecorder()
+	handler.Router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusTemporaryRedirect, rec.Code, "Response code didn't match expected")
+
+	srv.Close()
+	clicks := s.Clicks()
+	if assert.Len(t, clicks, 1, "Click was not recorded") {
+		assert.Equal(t, "short1", clicks[0].ShortURL)
+		assert.Equal(t, "https://google.com", clicks[0].Referrer)
+		assert.Equal(t, "test-agent", clicks[0].UserAgent)
+		assert.Equal(t, "10.0.0.1", clicks[0].IP)
+	}
+}
+
+func TestURLHandler_getURLStatsHandler(t *testing.T) {
+	cfg := config.GetConfig()
+	s := mocks.NewURLStore()
+	srv := service.NewURLService(s, &cfg)
+	handler := NewURLHandler(srv, nil)
+	httpSrv := httptest.NewServer(handler.Router)
+	defer httpSrv.Close()
+
+	stats := models.URLStatsDTO{ShortURL: "short1", Clicks: 3, UniqueVisitors: 2, Daily: []models.DailyClicksDTO{{Date: "2024-05-01", Clicks: 3}}}
+	s.On("GetURLStats", mock.Anything, "short1").Return(stats, nil)
+	s.On("GetURLStats", mock.Anything, "unknown").Return(models.URLStatsDTO{}, store.ErrNotFound)
+
+	testCases := []struct {
+		name         string
+		short        string
+		expectedCode int
+	}{
+		{name: "Existing url", short: "short1", expectedCode: http.StatusOK},
+		{name: "Unknown url", short: "unknown", expectedCode: http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := resty.New().R().Get(httpSrv.URL + "/api/user/urls/" + tc.short + "/stats")
+			assert.NoError(t, err, "error making HTTP request")
+			assert.Equal(t, tc.expectedCode, resp.StatusCode(), "Response code didn't match expected")
+			if tc.expectedCode == http.StatusOK {
+				var readDTO models.URLStatsDTO
+				err := json.Unmarshal(resp.Body(), &readDTO)
+				assert.NoError(t, err, "error unmarshal response body")
+				assert.Equal(t, stats, readDTO)
+			}
+		})
+	}
+}
+
 func TestURLHandler_getUserURLsHandler(t *testing.T) {
 	cfg := config.GetConfig()
 	s := mocks.NewURLStore()
@@ -234,6 +437,130 @@ func TestURLHandler_getUserURLsHandler(t *testing.T) {
 	}
 }
 
+func TestURLHandler_getUserURLsHandler_Pagination(t *testing.T) {
+	cfg := config.GetConfig()
+	s := mocks.NewURLStore()
+	srv := service.NewURLService(s, &cfg)
+	handler := NewURLHandler(srv, nil)
+	httpSrv := httptest.NewServer(handler.Router)
+	defer httpSrv.Close()
+
+	client := resty.New()
+	for _, u := range []string{"https://ya.ru", "https://google.com", "https://example.com"} {
+		_, err := client.R().SetBody(u).Post(httpSrv.URL)
+		assert.NoError(t, err, "error making HTTP request")
+	}
+
+	resp, err := client.R().Get(httpSrv.URL + "/api/user/urls?limit=2")
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	var readDTO []models.UserShortURLReadDTO
+	assert.NoError(t, json.Unmarshal(resp.Body(), &readDTO), "error unmarshal response body")
+	assert.Len(t, readDTO, 2)
+	cursor := resp.Header().Get("X-Next-Cursor")
+	assert.NotEmpty(t, cursor)
+
+	resp, err = client.R().SetQueryParams(map[string]string{"limit": "2", "cursor": cursor}).Get(httpSrv.URL + "/api/user/urls")
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.NoError(t, json.Unmarshal(resp.Body(), &readDTO), "error unmarshal response body")
+	assert.Len(t, readDTO, 1)
+	assert.Empty(t, resp.Header().Get("X-Next-Cursor"), "Last page should not have a cursor")
+
+	resp, err = client.R().Get(httpSrv.URL + "/api/user/urls?q=google")
+	assert.NoError(t, err, "error making HTTP request")
+	assert.NoError(t, json.Unmarshal(resp.Body(), &readDTO), "error unmarshal response body")
+	assert.Len(t, readDTO, 1)
+
+	resp, err = client.R().Get(httpSrv.URL + "/api/user/urls?limit=many")
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+}
+
+func TestURLHandler_lookupUserURLsHandler(t *testing.T) {
+	cfg := config.GetConfig()
+	s := mocks.NewURLStore()
+	srv := service.NewURLService(s, &cfg)
+	handler := NewURLHandler(srv, nil)
+	httpSrv := httptest.NewServer(handler.Router)
+	defer httpSrv.Close()
+
+	client := resty.New()
+	_, err := client.R().SetBody("https://Example.com/x").Post(httpSrv.URL)
+	assert.NoError(t, err, "error making HTTP request")
+
+	testCases := []struct {
+		name         string
+		url          string
+		expectedCode int
+	}{
+		{name: "Normalized match", url: "https://example.com:443/x", expectedCode: http.StatusOK},
+		{name: "No match", url: "https://example.com/y", expectedCode: http.StatusNotFound},
+		{name: "Relative url", url: "example.com/x", expectedCode: http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := client.R().SetQueryParam("url", tc.url).Get(httpSrv.URL + "/api/user/urls/lookup")
+			assert.NoError(t, err, "error making HTTP request")
+			assert.Equal(t, tc.expectedCode, resp.StatusCode(), "Response code didn't match expected")
+			if tc.expectedCode == http.StatusOK {
+				var readDTO []models.UserShortURLReadDTO
+				assert.NoError(t, json.Unmarshal(resp.Body(), &readDTO), "error unmarshal response body")
+				assert.Len(t, readDTO, 1)
+				assert.Equal(t, "https://Example.com/x", readDTO[0].OriginalURL)
+			}
+		})
+	}
+}
+
+func TestURLHandler_expandURLHandler(t *testing.T) {
+	cfg := config.GetConfig()
+	s := mocks.NewURLStore()
+	srv := service.NewURLService(s, &cfg)
+	handler := NewURLHandler(srv, nil)
+	httpSrv := httptest.NewServer(handler.Router)
+	defer httpSrv.Close()
+
+	owner := resty.New()
+	resp, err := owner.R().SetBody("https://ya.ru").Post(httpSrv.URL)
+	assert.NoError(t, err, "error making HTTP request")
+	key := path.Base(string(resp.Body()))
+	var userID string
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == jwt.CookieName {
+			userID, err = jwt.GetUserID(cookie.Value)
+			assert.NoError(t, err)
+		}
+	}
+	resp, err = owner.R().SetBody("https://google.com").Post(httpSrv.URL)
+	assert.NoError(t, err, "error making HTTP request")
+	deleted := path.Base(string(resp.Body()))
+	assert.NoError(t, s.DeleteURLs(context.Background(), userID, []string{deleted}))
+
+	resp, err = owner.R().Get(httpSrv.URL + "/api/expand/" + key)
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	var info models.URLInfoDTO
+	assert.NoError(t, json.Unmarshal(resp.Body(), &info), "error unmarshal response body")
+	assert.Equal(t, "https://ya.ru", info.OriginalURL)
+	assert.Equal(t, models.URLStatusActive, info.Status)
+	assert.NotNil(t, info.CreatedAt, "Owner should see creation time")
+
+	resp, err = resty.New().R().Get(httpSrv.URL + "/api/expand/" + key)
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusOK, resp.StatusCode(), "Expand should not redirect")
+	info = models.URLInfoDTO{}
+	assert.NoError(t, json.Unmarshal(resp.Body(), &info), "error unmarshal response body")
+	assert.Nil(t, info.CreatedAt, "Other users should not see creation time")
+
+	resp, err = owner.R().Get(httpSrv.URL + "/api/expand/unknown")
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+	resp, err = owner.R().Get(httpSrv.URL + "/api/expand/" + deleted)
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusGone, resp.StatusCode())
+}
+
 func TestURLHandler_deleteUserURLsHandler(t *testing.T) {
 	cfg := config.GetConfig()
 	s := mocks.NewURLStore()
@@ -273,6 +600,85 @@ func TestURLHandler_deleteUserURLsHandler(t *testing.T) {
 	}
 }
 
+func TestURLHandler_getDeletionJobHandler(t *testing.T) {
+	cfg := config.GetConfig()
+	s := mocks.NewURLStore()
+	srv := service.NewURLService(s, &cfg)
+	handler := NewURLHandler(srv, nil)
+	httpSrv := httptest.NewServer(handler.Router)
+	defer httpSrv.Close()
+
+	client := resty.New()
+	resp, err := client.R().SetBody("https://ya.ru").Post(httpSrv.URL)
+	assert.NoError(t, err, "error making HTTP request")
+	key := path.Base(string(resp.Body()))
+
+	resp, err = client.R().SetBody([]string{key}).Delete(httpSrv.URL + "/api/user/urls")
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode())
+	var created models.DeletionJobCreatedDTO
+	assert.NoError(t, json.Unmarshal(resp.Body(), &created), "error unmarshal response body")
+	assert.NotEmpty(t, created.JobID)
+
+	assert.Eventually(t, func() bool {
+		resp, err := client.R().Get(httpSrv.URL + "/api/jobs/" + created.JobID)
+		if err != nil || resp.StatusCode() != http.StatusOK {
+			return false
+		}
+		var job models.DeletionJobDTO
+		return json.Unmarshal(resp.Body(), &job) == nil && job.Status == models.DeletionJobDone
+	}, time.Second, 10*time.Millisecond, "Deletion job should be done")
+
+	resp, err = resty.New().R().Get(httpSrv.URL + "/api/jobs/" + created.JobID)
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode(), "Job of another user should not be visible")
+}
+
+func TestURLHandler_restoreUserURLsHandler(t *testing.T) {
+	cfg := config.GetConfig()
+	s := mocks.NewURLStore()
+	srv := service.NewURLService(s, &cfg)
+	handler := NewURLHandler(srv, nil)
+	httpSrv := httptest.NewServer(handler.Router)
+	defer httpSrv.Close()
+
+	client := resty.New()
+	resp, err := client.R().SetBody("https://ya.ru").Post(httpSrv.URL)
+	assert.NoError(t, err, "error making HTTP request")
+	key := path.Base(string(resp.Body()))
+	resp, err = client.R().SetBody([]string{key}).Delete(httpSrv.URL + "/api/user/urls")
+	assert.NoError(t, err, "error making HTTP request")
+	var created models.DeletionJobCreatedDTO
+	assert.NoError(t, json.Unmarshal(resp.Body(), &created), "error unmarshal response body")
+	assert.Eventually(t, func() bool {
+		resp, err := client.R().Get(httpSrv.URL + "/api/jobs/" + created.JobID)
+		var job models.DeletionJobDTO
+		return err == nil && json.Unmarshal(resp.Body(), &job) == nil && job.Status == models.DeletionJobDone
+	}, time.Second, 10*time.Millisecond, "Deletion job should be done")
+
+	testCases := []struct {
+		name         string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "Restore deleted url", body: `["` + key + `"]`, expectedCode: http.StatusOK, expectedBody: `["` + key + `"]`},
+		{name: "Nothing to restore", body: `["` + key + `"]`, expectedCode: http.StatusNotFound},
+		{name: "Empty list", body: `[]`, expectedCode: http.StatusBadRequest},
+		{name: "Invalid body", body: `{}`, expectedCode: http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := client.R().SetBody(tc.body).Post(httpSrv.URL + "/api/user/urls/restore")
+			assert.NoError(t, err, "error making HTTP request")
+			assert.Equal(t, tc.expectedCode, resp.StatusCode(), "Response code didn't match expected")
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, string(resp.Body()))
+			}
+		})
+	}
+}
+
 func TestURLHandler_pingURLHandler(t *testing.T) {
 	cfg := config.GetConfig()
 	mockStore := new(mocks.MockStore)
@@ -393,3 +799,187 @@ func parseCIDR(cidr string) *net.IPNet {
 	_, subnet, _ := net.ParseCIDR(cidr)
 	return subnet
 }
+
+func TestURLHandler_updateUserURLHandler(t *testing.T) {
+	cfg := config.GetConfig()
+	s := mocks.NewURLStore()
+	srv := service.NewURLService(s, &cfg)
+	handler := NewURLHandler(srv, nil)
+	httpSrv := httptest.NewServer(handler.Router)
+
+	defer httpSrv.Close()
+
+	owner := resty.New()
+	for alias, url := range map[string]string{"my-link": "https://ya.ru", "other-link": "https://google.com"} {
+		resp, err := owner.R().SetBody(`{"url": "` + url + `", "alias": "` + alias + `"}`).Post(httpSrv.URL + "/api/shorten")
+		assert.NoError(t, err, "error making HTTP request")
+		assert.Equal(t, http.StatusCreated, resp.StatusCode(), "Response code didn't match expected")
+	}
+
+	testCases := []struct {
+		name         string
+		client       *resty.Client
+		short        string
+		body         string
+		expectedCode int
+	}{
+		{name: "Owner updates url", client: owner, short: "my-link", body: `{"url": "https://example.com"}`, expectedCode: http.StatusOK},
+		{name: "Owner updates redirect type", client: owner, short: "my-link", body: `{"redirect_type": 301}`, expectedCode: http.StatusOK},
+		{name: "Invalid redirect type", client: owner, short: "my-link", body: `{"redirect_type": 303}`, expectedCode: http.StatusBadRequest},
+		{name: "Url already shortened", client: owner, short: "my-link", body: `{"url": "https://google.com"}`, expectedCode: http.StatusConflict},
+		{name: "Unknown short url", client: owner, short: "unknown", body: `{"url": "https://example.org"}`, expectedCode: http.StatusNotFound},
+		{name: "Another user", client: resty.New(), short: "my-link", body: `{"url": "https://example.org"}`, expectedCode: http.StatusNotFound},
+		{name: "Empty url", client: owner, short: "my-link", body: `{"url": ""}`, expectedCode: http.StatusBadRequest},
+		{name: "Invalid body", client: owner, short: "my-link", body: "", expectedCode: http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := tc.client.R().SetBody(tc.body).Patch(httpSrv.URL + "/api/user/urls/" + tc.short)
+			assert.NoError(t, err, "error making HTTP request")
+			assert.Equal(t, tc.expectedCode, resp.StatusCode(), "Response code didn't match expected")
+		})
+	}
+
+	redirect, err := srv.GetRedirect(context.Background(), "my-link", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.RedirectDTO{URL: "https://example.com", Type: http.StatusMovedPermanently}, redirect, "Url was not updated")
+
+	resp, err := owner.R().SetBody(`{"url": "https://example.org", "redirect_type": 308}`).Patch(httpSrv.URL + "/api/user/urls/my-link")
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusOK, resp.StatusCode(), "Response code didn't match expected")
+	var readDTO models.UserShortURLReadDTO
+	assert.NoError(t, json.Unmarshal(resp.Body(), &readDTO))
+	expected := models.UserShortURLReadDTO{ShortURL: cfg.BaseURL + "/my-link", OriginalURL: "https://example.org", RedirectType: http.StatusPermanentRedirect}
+	assert.Equal(t, expected, readDTO, "Response should contain the full short url")
+}
+
+func TestURLHandler_jwksHandler(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := jwt.NewKeyring("ed", []jwt.Key{{ID: "ed", PrivateKeyFile: keyPath}}, time.Hour)
+	assert.NoError(t, err)
+	jwt.SetKeyring(keyring)
+	defer func() {
+		random, _ := jwt.NewRandomKeyring()
+		jwt.SetKeyring(random)
+	}()
+
+	cfg := config.GetConfig()
+	srv := service.NewURLService(mocks.NewURLStore(), &cfg)
+	handler := NewURLHandler(srv, nil)
+	httpSrv := httptest.NewServer(handler.Router)
+	defer httpSrv.Close()
+
+	resp, err := resty.New().R().Get(httpSrv.URL + "/.well-known/jwks.json")
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+
+	var set jwt.JWKSet
+	assert.NoError(t, json.Unmarshal(resp.Body(), &set))
+	assert.Equal(t, []jwt.JWK{{KeyType: "OKP", KeyID: "ed", Use: "sig", Algorithm: "EdDSA", Curve: "Ed25519",
+		X: base64.RawURLEncoding.EncodeToString(public)}}, set.Keys)
+
+	// Токен из куки, выданной сервером, проверяется по опубликованному ключу.
+	var token string
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == jwt.CookieName {
+			token = cookie.Value
+		}
+	}
+	parts := strings.Split(token, ".")
+	if !assert.Len(t, parts, 3) {
+		return
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.NoError(t, err)
+	assert.True(t, ed25519.Verify(public, []byte(parts[0]+"."+parts[1]), signature))
+}
+
+func TestURLHandler_apiKeys(t *testing.T) {
+	cfg := config.GetConfig()
+	s := mocks.NewURLStore()
+	srv := service.NewURLService(s, &cfg)
+	handler := NewURLHandler(srv, nil)
+	httpSrv := httptest.NewServer(handler.Router)
+	defer httpSrv.Close()
+
+	owner := resty.New()
+	resp, err := owner.R().SetBody("https://ya.ru").Post(httpSrv.URL)
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusCreated, resp.StatusCode())
+
+	resp, err = owner.R().Get(httpSrv.URL + "/api/user/keys")
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+
+	resp, err = owner.R().SetBody(`{"name": "ci", "scopes": ["admin"]}`).Post(httpSrv.URL + "/api/user/keys")
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+
+	resp, err = owner.R().SetBody(`{"name": "ci", "scopes": ["read"]}`).Post(httpSrv.URL + "/api/user/keys")
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusCreated, resp.StatusCode())
+	var created models.APIKeyCreatedDTO
+	assert.NoError(t, json.Unmarshal(resp.Body(), &created), "error unmarshal response body")
+	assert.NotEmpty(t, created.Key)
+	assert.Equal(t, []string{"read"}, created.Scopes)
+
+	resp, err = owner.R().Get(httpSrv.URL + "/api/user/keys")
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.NotContains(t, string(resp.Body()), created.Key)
+	var keys []models.APIKeyReadDTO
+	assert.NoError(t, json.Unmarshal(resp.Body(), &keys), "error unmarshal response body")
+	if assert.Len(t, keys, 1) {
+		assert.Equal(t, created.ID, keys[0].ID)
+	}
+
+	withKey := func(key string) *resty.Request {
+		return resty.New().R().SetAuthToken(key)
+	}
+	testCases := []struct {
+		name         string
+		key          string
+		method       string
+		path         string
+		body         string
+		expectedCode int
+	}{
+		{name: "Read with read scope", key: created.Key, method: http.MethodGet, path: "/api/user/urls", expectedCode: http.StatusOK},
+		{name: "Create without create scope", key: created.Key, method: http.MethodPost, path: "/api/shorten", body: `{"url": "https://example.com"}`, expectedCode: http.StatusForbidden},
+		{name: "Delete without delete scope", key: created.Key, method: http.MethodDelete, path: "/api/user/urls", body: `["abc"]`, expectedCode: http.StatusForbidden},
+		{name: "Manage keys with a key", key: created.Key, method: http.MethodGet, path: "/api/user/keys", expectedCode: http.StatusForbidden},
+		{name: "Unknown key", key: created.Prefix + "unknown", method: http.MethodGet, path: "/api/user/urls", expectedCode: http.StatusUnauthorized},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := withKey(tc.key).SetBody(tc.body).Execute(tc.method, httpSrv.URL+tc.path)
+			assert.NoError(t, err, "error making HTTP request")
+			assert.Equal(t, tc.expectedCode, resp.StatusCode(), "Response code didn't match expected")
+			assert.Empty(t, resp.Cookies(), "API key requests should not get a cookie")
+		})
+	}
+
+	resp, err = resty.New().R().Delete(httpSrv.URL + "/api/user/keys/" + created.ID)
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode(), "other users cannot revoke the key")
+
+	resp, err = owner.R().Delete(httpSrv.URL + "/api/user/keys/" + created.ID)
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode())
+
+	resp, err = withKey(created.Key).Get(httpSrv.URL + "/api/user/urls")
+	assert.NoError(t, err, "error making HTTP request")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+}
@@ -1,7 +1,12 @@
 package jwt
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -11,17 +16,96 @@ import (
 // CookieName - имя куки, в котором хранится токен.
 const CookieName = "token"
 
+// Issuer - издатель токенов, ожидаемый в поле iss.
+const Issuer = "shortener"
+
+// Audience - получатель токенов, ожидаемый в поле aud.
+const Audience = "shortener"
+
+// TokenExp - время жизни токена.
+const TokenExp = time.Hour * 3
+
+// clockSkew - допустимое расхождение часов при проверке полей nbf и iat.
+const clockSkew = time.Minute
+
+// DefaultRefreshWindow - время после истечения токена, в течение которого он перевыпускается по умолчанию.
+const DefaultRefreshWindow = 24 * time.Hour
+
+// refreshWindow - время после истечения токена, в течение которого он перевыпускается для того же пользователя.
+var refreshWindow atomic.Int64
+
+func init() {
+	refreshWindow.Store(int64(DefaultRefreshWindow))
+}
+
+// SetRefreshWindow задаёт время после истечения токена, в течение которого он перевыпускается
+// для того же пользователя. Более старые токены отклоняются, как и любые другие недействительные.
+func SetRefreshWindow(window time.Duration) {
+	refreshWindow.Store(int64(window))
+}
+
+var (
+	ErrTokenExpired    = jwt.ErrTokenExpired                  // Ошибка: срок действия токена истёк
+	ErrInvalidIssuer   = fmt.Errorf("invalid token issuer")   // Ошибка: токен выпущен другим издателем
+	ErrInvalidAudience = fmt.Errorf("invalid token audience") // Ошибка: токен предназначен другому получателю
+	ErrMissingExpiry   = fmt.Errorf("token has no expiry")    // Ошибка: у токена не задан срок действия
+	ErrMissingUserID   = fmt.Errorf("token has no user id")   // Ошибка: в токене не указан пользователь
+)
+
 // Claims - структура, представляющая собой полезную нагрузку JWT-токена.
 type Claims struct {
 	jwt.RegisteredClaims
 	UserID string `json:"user_id"`
 }
 
-// TokenExp - время жизни токена.
-const TokenExp = time.Hour * 3
+// Valid проверяет срок действия, издателя, получателя и наличие пользователя в токене.
+// Ошибка имеет тип *jwt.ValidationError, поэтому по её флагам можно определить,
+// что токен только истёк, а в остальном корректен.
+func (c Claims) Valid() error {
+	now := time.Now()
+	vErr := &jwt.ValidationError{}
+	if c.ExpiresAt == nil {
+		vErr.Inner = ErrMissingExpiry
+		vErr.Errors |= jwt.ValidationErrorClaimsInvalid
+	} else if !c.VerifyExpiresAt(now, true) {
+		vErr.Inner = ErrTokenExpired
+		vErr.Errors |= jwt.ValidationErrorExpired
+	}
+	if !c.VerifyNotBefore(now.Add(clockSkew), false) {
+		vErr.Inner = jwt.ErrTokenNotValidYet
+		vErr.Errors |= jwt.ValidationErrorNotValidYet
+	}
+	if !c.VerifyIssuedAt(now.Add(clockSkew), false) {
+		vErr.Inner = jwt.ErrTokenUsedBeforeIssued
+		vErr.Errors |= jwt.ValidationErrorIssuedAt
+	}
+	if !c.VerifyIssuer(Issuer, true) {
+		vErr.Inner = ErrInvalidIssuer
+		vErr.Errors |= jwt.ValidationErrorIssuer
+	}
+	if !c.VerifyAudience(Audience, true) {
+		vErr.Inner = ErrInvalidAudience
+		vErr.Errors |= jwt.ValidationErrorAudience
+	}
+	if c.UserID == "" {
+		vErr.Inner = ErrMissingUserID
+		vErr.Errors |= jwt.ValidationErrorClaimsInvalid
+	}
+	if vErr.Errors == 0 {
+		return nil
+	}
+	return vErr
+}
 
-// SecretKey - секретный ключ для подписи токена.
-const SecretKey = "supersecretkey"
+// Refreshable проверяет, что истёкший токен с верной подписью ещё можно перевыпустить
+// для того же пользователя: он выпущен и истёк не раньше, чем окно перевыпуска назад.
+func (c Claims) Refreshable(now time.Time) bool {
+	if c.ExpiresAt == nil || c.IssuedAt == nil {
+		return false
+	}
+	window := time.Duration(refreshWindow.Load())
+	return now.Sub(c.ExpiresAt.Time) <= window && now.Sub(c.IssuedAt.Time) <= TokenExp+window
+}
 
 // GetAuthCookie извлекает значение куки с токеном из HTTP-запроса.
 func GetAuthCookie(req *http.Request) (string, error) {
@@ -32,61 +116,113 @@ func GetAuthCookie(req *http.Request) (string, error) {
 	return cookie.Value, nil
 }
 
-// BuildJWTString создаёт новый JWT-токен и возвращает его строковое представление.
+// bearerPrefix - схема заголовка Authorization, в которой передаётся токен.
+const bearerPrefix = "bearer "
+
+// BearerToken извлекает токен из значения заголовка Authorization вида "Bearer <token>".
+// Схема сравнивается без учёта регистра. Для другой схемы или пустого токена возвращается false.
+func BearerToken(value string) (string, bool) {
+	if len(value) < len(bearerPrefix) || !strings.EqualFold(value[:len(bearerPrefix)], bearerPrefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(value[len(bearerPrefix):])
+	return token, token != ""
+}
+
+// BuildJWTString создаёт новый JWT-токен для нового пользователя, подписанный активным ключом,
+// и возвращает его строковое представление.
 func BuildJWTString() (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+	return BuildJWTStringForUser(uuid.New().String())
+}
+
+// BuildJWTStringForUser создаёт новый JWT-токен для пользователя userID, подписанный активным ключом.
+func BuildJWTStringForUser(userID string) (string, error) {
+	now := time.Now()
+	return signClaims(Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExp)),
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(TokenExp)),
 		},
-		UserID: uuid.New().String(),
+		UserID: userID,
 	})
-	tokenString, err := token.SignedString([]byte(SecretKey))
+}
+
+// signClaims подписывает полезную нагрузку активным ключом и указывает его идентификатор в заголовке kid.
+func signClaims(claims Claims) (string, error) {
+	keyring, err := getKeyring()
+	if err != nil {
+		return "", err
+	}
+	kid, method, key := keyring.signingKey()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	tokenString, err := token.SignedString(key)
 	if err != nil {
 		return "", err
 	}
 	return tokenString, nil
 }
 
-// fromString парсит строку токена и извлекает из него данные.
-func fromString(tokenString string) (*Claims, error) {
-	claims := &Claims{}
-	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
-		return []byte(SecretKey), nil
-	}, jwt.WithoutClaimsValidation())
+// ParseToken проверяет подпись и поля токена и извлекает из него данные.
+// Принимаются только токены, подписанные HS256, RS256 или EdDSA ключом, указанным в заголовке kid,
+// причём алгоритм токена должен совпадать с алгоритмом ключа.
+// Если подпись верна и единственная причина отказа - истёкший срок действия,
+// вместе с ошибкой ErrTokenExpired возвращаются данные токена, чтобы его можно было перевыпустить.
+func ParseToken(tokenString string) (*Claims, error) {
+	keyring, err := getKeyring()
 	if err != nil {
 		return nil, err
 	}
-	return claims, nil
+	claims := &Claims{}
+	_, err = parser.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return keyring.verificationKey(kid, t.Method.Alg(), time.Now())
+	})
+	if err == nil {
+		return claims, nil
+	}
+	var vErr *jwt.ValidationError
+	if errors.As(err, &vErr) && vErr.Errors == jwt.ValidationErrorExpired {
+		return claims, err
+	}
+	return nil, err
 }
 
-// func fromString(tokenString string) (*Claims, error) {
-// 	claims := &Claims{}
-// 	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
-// 		return []byte(SecretKey), nil
-// 	})
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	return claims, nil
-// }
-
-// GetUserID извлекает UserID из токена.
+// GetUserID извлекает UserID из действительного токена.
 func GetUserID(tokenString string) (string, error) {
-	claims, err := fromString(tokenString)
+	claims, err := ParseToken(tokenString)
 	if err != nil {
 		return "", err
 	}
 	return claims.UserID, nil
 }
 
-// IsTokenExpired проверяет, истёк ли срок действия токена.
+// IsTokenExpired проверяет, истёк ли срок действия токена с верной подписью.
 func IsTokenExpired(tokenString string) bool {
-	claims, err := fromString(tokenString)
-	if err != nil {
-		return false
-	}
-	if claims.ExpiresAt == nil {
-		return false
-	}
-	return claims.ExpiresAt.Before(time.Now())
+	_, err := ParseToken(tokenString)
+	return errors.Is(err, ErrTokenExpired)
+}
+
+// parser - разборщик токенов, допускающий только алгоритмы, которыми подписываются токены сервиса.
+var parser = jwt.NewParser(jwt.WithValidMethods([]string{
+	jwt.SigningMethodHS256.Alg(),
+	jwt.SigningMethodRS256.Alg(),
+	jwt.SigningMethodEdDSA.Alg(),
+}))
+
+// userIDKey - ключ контекста запроса, под которым хранится идентификатор пользователя.
+type userIDKey struct{}
+
+// WithUserID возвращает контекст с идентификатором аутентифицированного пользователя.
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserIDFromContext возвращает идентификатор аутентифицированного пользователя из контекста.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(userIDKey{}).(string)
+	return userID, ok && userID != ""
 }
@@ -1,6 +1,7 @@
 package jwt
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -69,14 +70,140 @@ func TestIsTokenExpired_InvalidToken(t *testing.T) {
 	assert.False(t, expired, "Should return false on parse error")
 }
 
+func TestParseToken(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		name       string
+		method     jwt.SigningMethod
+		claims     func(c *Claims)
+		err        error
+		withClaims bool
+	}{
+		{name: "Valid", claims: func(c *Claims) {}, withClaims: true},
+		{name: "Expired", claims: func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Hour)) }, err: ErrTokenExpired, withClaims: true},
+		{name: "Expired with wrong issuer", claims: func(c *Claims) {
+			c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Hour))
+			c.Issuer = "other"
+		}, err: ErrTokenExpired},
+		{name: "Wrong issuer", claims: func(c *Claims) { c.Issuer = "other" }, err: ErrInvalidIssuer},
+		{name: "Wrong audience", claims: func(c *Claims) { c.Audience = jwt.ClaimStrings{"other"} }, err: ErrInvalidAudience},
+		{name: "Missing expiry", claims: func(c *Claims) { c.ExpiresAt = nil }, err: ErrMissingExpiry},
+		{name: "Missing user id", claims: func(c *Claims) { c.UserID = "" }, err: ErrMissingUserID},
+		{name: "Not valid yet", claims: func(c *Claims) { c.NotBefore = jwt.NewNumericDate(now.Add(time.Hour)) }, err: jwt.ErrTokenNotValidYet},
+		{name: "Other HMAC algorithm", method: jwt.SigningMethodHS512, claims: func(c *Claims) {}, err: jwt.ErrTokenSignatureInvalid},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := testClaims(now.Add(time.Hour))
+			tc.claims(&claims)
+			method := tc.method
+			if method == nil {
+				method = jwt.SigningMethodHS256
+			}
+			tokenString, err := signWithMethod(method, claims)
+			assert.NoError(t, err)
+
+			got, err := ParseToken(tokenString)
+			if tc.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tc.err)
+			}
+			if tc.withClaims {
+				assert.Equal(t, "test-user", got.UserID)
+			} else {
+				assert.Nil(t, got)
+			}
+		})
+	}
+}
+
+func TestBuildJWTStringForUser(t *testing.T) {
+	tokenStr, err := BuildJWTStringForUser("user-1")
+	assert.NoError(t, err)
+
+	claims, err := ParseToken(tokenStr)
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", claims.UserID)
+	assert.Equal(t, Issuer, claims.Issuer)
+	assert.Equal(t, jwt.ClaimStrings{Audience}, claims.Audience)
+}
+
+func TestUserIDFromContext(t *testing.T) {
+	_, ok := UserIDFromContext(context.Background())
+	assert.False(t, ok)
+
+	_, ok = UserIDFromContext(WithUserID(context.Background(), ""))
+	assert.False(t, ok)
+
+	userID, ok := UserIDFromContext(WithUserID(context.Background(), "user-1"))
+	assert.True(t, ok)
+	assert.Equal(t, "user-1", userID)
+}
+
+func TestBearerToken(t *testing.T) {
+	testCases := []struct {
+		value string
+		token string
+		ok    bool
+	}{
+		{value: "Bearer abc", token: "abc", ok: true},
+		{value: "bearer  abc ", token: "abc", ok: true},
+		{value: "Basic abc", ok: false},
+		{value: "Bearer ", ok: false},
+		{value: "", ok: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			token, ok := BearerToken(tc.value)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.token, token)
+		})
+	}
+}
+
 // 🔧 вспомогательная функция
 func buildJWTWithCustomExp(exp time.Time) (string, error) {
-	claims := Claims{
+	return signClaims(testClaims(exp))
+}
+
+func testClaims(exp time.Time) Claims {
+	return Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{Audience},
 			ExpiresAt: jwt.NewNumericDate(exp),
 		},
 		UserID: "test-user",
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(SecretKey))
+}
+
+// signWithMethod подписывает полезную нагрузку активным ключом заданным алгоритмом.
+func signWithMethod(method jwt.SigningMethod, claims Claims) (string, error) {
+	keyring, err := getKeyring()
+	if err != nil {
+		return "", err
+	}
+	kid, _, key := keyring.signingKey()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	return token.SignedString(key)
+}
+
+func TestClaims_Refreshable(t *testing.T) {
+	SetRefreshWindow(time.Hour)
+	defer SetRefreshWindow(DefaultRefreshWindow)
+	now := time.Now()
+	claimsExpiredAt := func(exp time.Time) Claims {
+		return Claims{RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(exp.Add(-TokenExp)),
+			ExpiresAt: jwt.NewNumericDate(exp),
+		}}
+	}
+	assert.True(t, claimsExpiredAt(now.Add(-time.Minute)).Refreshable(now), "Recently expired token should be refreshable")
+	assert.False(t, claimsExpiredAt(now.Add(-2*time.Hour)).Refreshable(now), "Token expired before the window should not be refreshable")
+	stale := claimsExpiredAt(now.Add(-time.Minute))
+	stale.IssuedAt = jwt.NewNumericDate(now.Add(-48 * time.Hour))
+	assert.False(t, stale.Refreshable(now), "Token issued too long ago should not be refreshable")
+	assert.False(t, Claims{}.Refreshable(now))
 }
@@ -0,0 +1,397 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// minRSAKeyBits - минимальный допустимый размер RSA-ключа.
+const minRSAKeyBits = 2048
+
+var (
+	ErrNoActiveKey          = fmt.Errorf("active signing key is not set")                                             // Ошибка: не задан активный ключ подписи
+	ErrUnknownActiveKey     = fmt.Errorf("active signing key is not in the key set")                                  // Ошибка: активный ключ отсутствует среди ключей
+	ErrActiveKeyRetired     = fmt.Errorf("active signing key is retired")                                             // Ошибка: активный ключ выведен из оборота
+	ErrNoPrivateKey         = fmt.Errorf("active signing key has no private key")                                     // Ошибка: для активного ключа задан только открытый ключ
+	ErrEmptyKeyID           = fmt.Errorf("key id is empty")                                                           // Ошибка: у ключа не задан идентификатор
+	ErrEmptySecret          = fmt.Errorf("key secret is empty")                                                       // Ошибка: у ключа не задан секрет
+	ErrKeyMaterial          = fmt.Errorf("key must have exactly one of secret, private_key_file and public_key_file") // Ошибка: ключевой материал не задан или задан неоднозначно
+	ErrInvalidPEM           = fmt.Errorf("no PEM block found")                                                        // Ошибка: файл ключа не содержит PEM-блока
+	ErrUnsupportedKeyType   = fmt.Errorf("unsupported key type, expected RSA or Ed25519")                             // Ошибка: тип ключа не поддерживается
+	ErrWeakRSAKey           = fmt.Errorf("RSA key is shorter than %d bits", minRSAKeyBits)                            // Ошибка: RSA-ключ слишком короткий
+	ErrDuplicateKeyID       = fmt.Errorf("duplicate key id")                                                          // Ошибка: идентификатор ключа повторяется
+	ErrConflictingKeySource = fmt.Errorf("only one of jwt secret, jwt key file and jwt private key file can be set")  // Ошибка: ключи заданы одновременно из нескольких источников
+	ErrUnknownKeyID         = fmt.Errorf("unknown key id")                                                            // Ошибка: токен подписан неизвестным ключом
+	ErrKeyExpired           = fmt.Errorf("key is retired and its grace period is over")                               // Ошибка: токен подписан ключом, срок приёма которого истёк
+	ErrUnexpectedAlgorithm  = fmt.Errorf("token algorithm does not match the key algorithm")                          // Ошибка: алгоритм токена не совпадает с алгоритмом ключа
+)
+
+// Key - ключ подписи токенов. Ключевой материал задаётся ровно одним из полей:
+// Secret - секрет HMAC (HS256), PrivateKeyFile - PEM-файл закрытого ключа RSA (RS256) или Ed25519 (EdDSA),
+// PublicKeyFile - PEM-файл открытого ключа, которым токены только проверяются.
+// Ключ с непустым RetiredAt больше не используется для подписи,
+// но токены, подписанные им, принимаются ещё в течение grace-периода связки ключей.
+type Key struct {
+	ID             string    `json:"kid"`
+	Secret         string    `json:"secret,omitempty"`
+	PrivateKeyFile string    `json:"private_key_file,omitempty"`
+	PublicKeyFile  string    `json:"public_key_file,omitempty"`
+	RetiredAt      time.Time `json:"retired_at,omitempty"`
+}
+
+// keyFile - формат JSON-файла с ключами подписи.
+type keyFile struct {
+	Active string `json:"active"`
+	Keys   []Key  `json:"keys"`
+}
+
+// keyEntry - ключ с разобранным ключевым материалом.
+type keyEntry struct {
+	Key
+	method    jwt.SigningMethod
+	signKey   interface{} // nil, если задан только открытый ключ
+	verifyKey interface{}
+}
+
+// Keyring - связка ключей: один активный ключ для подписи и набор ключей для проверки,
+// различаемых по заголовку kid токена.
+type Keyring struct {
+	active string
+	keys   map[string]keyEntry
+	grace  time.Duration
+}
+
+// NewKeyring создаёт связку ключей с активным ключом activeID, читая PEM-файлы ключей.
+func NewKeyring(activeID string, keys []Key, grace time.Duration) (*Keyring, error) {
+	if activeID == "" {
+		return nil, ErrNoActiveKey
+	}
+	keyring := &Keyring{active: activeID, keys: make(map[string]keyEntry, len(keys)), grace: grace}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, ErrEmptyKeyID
+		}
+		if _, ok := keyring.keys[key.ID]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateKeyID, key.ID)
+		}
+		entry, err := newKeyEntry(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key.ID, err)
+		}
+		keyring.keys[key.ID] = entry
+	}
+	active, ok := keyring.keys[activeID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownActiveKey, activeID)
+	}
+	if !active.RetiredAt.IsZero() {
+		return nil, fmt.Errorf("%w: %s", ErrActiveKeyRetired, activeID)
+	}
+	if active.signKey == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoPrivateKey, activeID)
+	}
+	return keyring, nil
+}
+
+// newKeyEntry разбирает ключевой материал ключа и определяет алгоритм подписи по его типу.
+func newKeyEntry(key Key) (keyEntry, error) {
+	entry := keyEntry{Key: key}
+	sources := 0
+	for _, source := range []string{key.Secret, key.PrivateKeyFile, key.PublicKeyFile} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return entry, ErrKeyMaterial
+	}
+	var err error
+	switch {
+	case key.Secret != "":
+		entry.method = jwt.SigningMethodHS256
+		entry.signKey = []byte(key.Secret)
+		entry.verifyKey = entry.signKey
+	case key.PrivateKeyFile != "":
+		var private crypto.Signer
+		if private, err = readPrivateKey(key.PrivateKeyFile); err == nil {
+			entry.signKey = private
+			entry.method, entry.verifyKey, err = publicKeyMethod(private.Public())
+		}
+	default:
+		var public crypto.PublicKey
+		if public, err = readPublicKey(key.PublicKeyFile); err == nil {
+			entry.method, entry.verifyKey, err = publicKeyMethod(public)
+		}
+	}
+	return entry, err
+}
+
+// publicKeyMethod возвращает алгоритм подписи для открытого ключа и сам ключ в виде, ожидаемом алгоритмом.
+func publicKeyMethod(public crypto.PublicKey) (jwt.SigningMethod, interface{}, error) {
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < minRSAKeyBits {
+			return nil, nil, ErrWeakRSAKey
+		}
+		return jwt.SigningMethodRS256, public, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, public, nil
+	}
+	return nil, nil, ErrUnsupportedKeyType
+}
+
+// readPEM читает первый PEM-блок из файла.
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPEM, path)
+	}
+	return block, nil
+}
+
+// readPrivateKey читает закрытый ключ в формате PKCS#8 или PKCS#1 (RSA) из PEM-файла.
+func readPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if rsaKey, rsaErr := x509.ParsePKCS1PrivateKey(block.Bytes); rsaErr == nil {
+			return rsaKey, nil
+		}
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, ErrUnsupportedKeyType
+	}
+	return signer, nil
+}
+
+// readPublicKey читает открытый ключ в формате PKIX или PKCS#1 (RSA) из PEM-файла.
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		if rsaKey, rsaErr := x509.ParsePKCS1PublicKey(block.Bytes); rsaErr == nil {
+			return rsaKey, nil
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// NewSecretKeyring создаёт связку из одного ключа с секретом secret.
+// Идентификатор ключа вычисляется из секрета, поэтому он не меняется между перезапусками.
+func NewSecretKeyring(secret string) (*Keyring, error) {
+	if secret == "" {
+		return nil, ErrEmptySecret
+	}
+	sum := sha256.Sum256([]byte(secret))
+	kid := hex.EncodeToString(sum[:8])
+	return NewKeyring(kid, []Key{{ID: kid, Secret: secret}}, 0)
+}
+
+// NewPrivateKeyKeyring создаёт связку из одного ключа RSA или Ed25519, прочитанного из PEM-файла.
+// Идентификатор ключа вычисляется из открытого ключа, поэтому он не меняется между перезапусками.
+func NewPrivateKeyKeyring(path string) (*Keyring, error) {
+	private, err := readPrivateKey(path)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+	kid := hex.EncodeToString(sum[:8])
+	return NewKeyring(kid, []Key{{ID: kid, PrivateKeyFile: path}}, 0)
+}
+
+// NewRandomKeyring создаёт связку из одного случайного ключа.
+// Токены, подписанные им, становятся недействительными после перезапуска.
+func NewRandomKeyring() (*Keyring, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return NewSecretKeyring(hex.EncodeToString(secret))
+}
+
+// LoadKeyFile читает связку ключей из JSON-файла вида
+// {"active": "kid", "keys": [{"kid": "...", "secret" | "private_key_file" | "public_key_file": "...", "retired_at": "RFC3339"}]}.
+func LoadKeyFile(path string, grace time.Duration) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse key file: %w", err)
+	}
+	return NewKeyring(file.Active, file.Keys, grace)
+}
+
+// LoadKeyring создаёт связку ключей из секрета, файла с ключами или PEM-файла закрытого ключа.
+// Если не задан ни один источник, возвращается nil без ошибки.
+func LoadKeyring(secret, keyFilePath, privateKeyPath string, grace time.Duration) (*Keyring, error) {
+	sources := 0
+	for _, source := range []string{secret, keyFilePath, privateKeyPath} {
+		if source != "" {
+			sources++
+		}
+	}
+	switch {
+	case sources > 1:
+		return nil, ErrConflictingKeySource
+	case keyFilePath != "":
+		return LoadKeyFile(keyFilePath, grace)
+	case privateKeyPath != "":
+		return NewPrivateKeyKeyring(privateKeyPath)
+	case secret != "":
+		return NewSecretKeyring(secret)
+	}
+	return nil, nil
+}
+
+// ActiveKeyID возвращает идентификатор ключа, которым подписываются новые токены.
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// signingKey возвращает идентификатор, алгоритм и ключ подписи активного ключа.
+func (k *Keyring) signingKey() (string, jwt.SigningMethod, interface{}) {
+	entry := k.keys[k.active]
+	return k.active, entry.method, entry.signKey
+}
+
+// accepted сообщает, принимаются ли на момент now токены, подписанные ключом.
+func (k *Keyring) accepted(entry keyEntry, now time.Time) bool {
+	return entry.RetiredAt.IsZero() || now.Before(entry.RetiredAt.Add(k.grace))
+}
+
+// verificationKey возвращает ключ проверки подписи kid, если токены, подписанные им алгоритмом alg,
+// ещё принимаются на момент now.
+func (k *Keyring) verificationKey(kid, alg string, now time.Time) (interface{}, error) {
+	entry, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKeyID, kid)
+	}
+	if entry.method.Alg() != alg {
+		return nil, fmt.Errorf("%w: %s is %s, token is %s", ErrUnexpectedAlgorithm, kid, entry.method.Alg(), alg)
+	}
+	if !k.accepted(entry, now) {
+		return nil, fmt.Errorf("%w: %s", ErrKeyExpired, kid)
+	}
+	return entry.verifyKey, nil
+}
+
+// JWK - открытый ключ в формате JSON Web Key (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKSet - набор открытых ключей в формате JWK Set.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS возвращает открытые ключи, токены которых принимаются на момент now, упорядоченные по kid.
+// Ключи HMAC не публикуются.
+func (k *Keyring) JWKS(now time.Time) JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for kid, entry := range k.keys {
+		if !k.accepted(entry, now) {
+			continue
+		}
+		jwk := JWK{KeyID: kid, Use: "sig", Algorithm: entry.method.Alg()}
+		switch public := entry.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
+
+// PublicKeys возвращает открытые ключи установленной связки для проверки токенов другими сервисами.
+func PublicKeys() (JWKSet, error) {
+	keyring, err := getKeyring()
+	if err != nil {
+		return JWKSet{}, err
+	}
+	return keyring.JWKS(time.Now()), nil
+}
+
+var (
+	current     atomic.Pointer[Keyring]
+	defaultOnce sync.Once
+)
+
+// SetKeyring устанавливает связку ключей, используемую для подписи и проверки токенов.
+func SetKeyring(keyring *Keyring) {
+	current.Store(keyring)
+}
+
+// getKeyring возвращает установленную связку ключей. Если связка не установлена,
+// создаётся случайный ключ, действующий до перезапуска процесса.
+func getKeyring() (*Keyring, error) {
+	if keyring := current.Load(); keyring != nil {
+		return keyring, nil
+	}
+	var err error
+	defaultOnce.Do(func() {
+		var keyring *Keyring
+		if keyring, err = NewRandomKeyring(); err == nil {
+			current.CompareAndSwap(nil, keyring)
+		}
+	})
+	if keyring := current.Load(); keyring != nil {
+		return keyring, nil
+	}
+	if err == nil {
+		err = ErrNoActiveKey
+	}
+	return nil, err
+}
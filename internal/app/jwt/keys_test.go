@@ -0,0 +1,343 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// useKeyring устанавливает связку ключей на время теста.
+func useKeyring(t *testing.T, keyring *Keyring) {
+	t.Helper()
+	prev := current.Load()
+	SetKeyring(keyring)
+	t.Cleanup(func() { current.Store(prev) })
+}
+
+func TestNewKeyring(t *testing.T) {
+	testCases := []struct {
+		name   string
+		active string
+		keys   []Key
+		err    error
+	}{
+		{name: "Valid", active: "k2", keys: []Key{{ID: "k1", Secret: "s1", RetiredAt: time.Now()}, {ID: "k2", Secret: "s2"}}},
+		{name: "No active key", active: "", keys: []Key{{ID: "k1", Secret: "s1"}}, err: ErrNoActiveKey},
+		{name: "Unknown active key", active: "k3", keys: []Key{{ID: "k1", Secret: "s1"}}, err: ErrUnknownActiveKey},
+		{name: "Active key retired", active: "k1", keys: []Key{{ID: "k1", Secret: "s1", RetiredAt: time.Now()}}, err: ErrActiveKeyRetired},
+		{name: "Empty key id", active: "k1", keys: []Key{{ID: "k1", Secret: "s1"}, {Secret: "s2"}}, err: ErrEmptyKeyID},
+		{name: "No key material", active: "k1", keys: []Key{{ID: "k1"}}, err: ErrKeyMaterial},
+		{name: "Ambiguous key material", active: "k1", keys: []Key{{ID: "k1", Secret: "s1", PublicKeyFile: "key.pem"}}, err: ErrKeyMaterial},
+		{name: "Duplicate key id", active: "k1", keys: []Key{{ID: "k1", Secret: "s1"}, {ID: "k1", Secret: "s2"}}, err: ErrDuplicateKeyID},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			keyring, err := NewKeyring(tc.active, tc.keys, time.Hour)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				assert.Nil(t, keyring)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.active, keyring.ActiveKeyID())
+		})
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	t.Run("Nothing configured", func(t *testing.T) {
+		keyring, err := LoadKeyring("", "", "", time.Hour)
+		assert.NoError(t, err)
+		assert.Nil(t, keyring)
+	})
+	t.Run("Conflicting sources", func(t *testing.T) {
+		_, err := LoadKeyring("secret", "keys.json", "", time.Hour)
+		assert.ErrorIs(t, err, ErrConflictingKeySource)
+		_, err = LoadKeyring("", "keys.json", "key.pem", time.Hour)
+		assert.ErrorIs(t, err, ErrConflictingKeySource)
+	})
+	t.Run("Secret", func(t *testing.T) {
+		first, err := LoadKeyring("secret", "", "", time.Hour)
+		assert.NoError(t, err)
+		second, err := LoadKeyring("secret", "", "", time.Hour)
+		assert.NoError(t, err)
+		other, err := LoadKeyring("other", "", "", time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, first.ActiveKeyID(), second.ActiveKeyID())
+		assert.NotEqual(t, first.ActiveKeyID(), other.ActiveKeyID())
+	})
+	t.Run("Key file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.json")
+		content := `{"active": "k2", "keys": [
+			{"kid": "k1", "secret": "s1", "retired_at": "2026-01-01T00:00:00Z"},
+			{"kid": "k2", "secret": "s2"}
+		]}`
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		keyring, err := LoadKeyring("", path, "", time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, "k2", keyring.ActiveKeyID())
+		assert.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), keyring.keys["k1"].RetiredAt)
+	})
+	t.Run("Invalid key file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.json")
+		if err := os.WriteFile(path, []byte("not json"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadKeyring("", path, "", time.Hour)
+		assert.Error(t, err)
+		_, err = LoadKeyring("", filepath.Join(t.TempDir(), "missing.json"), "", time.Hour)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKeyring, err := NewKeyring("k1", []Key{{ID: "k1", Secret: "s1"}}, time.Hour)
+	assert.NoError(t, err)
+	useKeyring(t, oldKeyring)
+	oldToken, err := BuildJWTString()
+	assert.NoError(t, err)
+	userID, err := GetUserID(oldToken)
+	assert.NoError(t, err)
+
+	retiredAt := time.Now().Add(-time.Minute)
+	rotated, err := NewKeyring("k2", []Key{{ID: "k1", Secret: "s1", RetiredAt: retiredAt}, {ID: "k2", Secret: "s2"}}, time.Hour)
+	assert.NoError(t, err)
+	SetKeyring(rotated)
+
+	t.Run("Old token is valid within grace period", func(t *testing.T) {
+		got, err := GetUserID(oldToken)
+		assert.NoError(t, err)
+		assert.Equal(t, userID, got)
+	})
+	t.Run("New token is signed with active key", func(t *testing.T) {
+		newToken, err := BuildJWTString()
+		assert.NoError(t, err)
+		token, _, err := jwt.NewParser().ParseUnverified(newToken, &Claims{})
+		assert.NoError(t, err)
+		assert.Equal(t, "k2", token.Header["kid"])
+		_, err = GetUserID(newToken)
+		assert.NoError(t, err)
+	})
+	t.Run("Old token is rejected after grace period", func(t *testing.T) {
+		expired, err := NewKeyring("k2", []Key{{ID: "k1", Secret: "s1", RetiredAt: retiredAt}, {ID: "k2", Secret: "s2"}}, 30*time.Second)
+		assert.NoError(t, err)
+		SetKeyring(expired)
+		_, err = GetUserID(oldToken)
+		assert.ErrorIs(t, err, ErrKeyExpired)
+	})
+	t.Run("Old token is rejected after key removal", func(t *testing.T) {
+		removed, err := NewKeyring("k2", []Key{{ID: "k2", Secret: "s2"}}, time.Hour)
+		assert.NoError(t, err)
+		SetKeyring(removed)
+		_, err = GetUserID(oldToken)
+		assert.ErrorIs(t, err, ErrUnknownKeyID)
+	})
+}
+
+func TestFromString_RejectsForgedTokens(t *testing.T) {
+	keyring, err := NewKeyring("k1", []Key{{ID: "k1", Secret: "s1"}}, time.Hour)
+	assert.NoError(t, err)
+	useKeyring(t, keyring)
+	claims := Claims{UserID: "victim"}
+
+	t.Run("Without kid", func(t *testing.T) {
+		tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("s1"))
+		assert.NoError(t, err)
+		_, err = GetUserID(tokenString)
+		assert.ErrorIs(t, err, ErrUnknownKeyID)
+	})
+	t.Run("Wrong secret", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		token.Header["kid"] = "k1"
+		tokenString, err := token.SignedString([]byte("supersecretkey"))
+		assert.NoError(t, err)
+		_, err = GetUserID(tokenString)
+		assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+	})
+	t.Run("Unsigned", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodNone, claims)
+		token.Header["kid"] = "k1"
+		tokenString, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+		assert.NoError(t, err)
+		_, err = GetUserID(tokenString)
+		assert.ErrorIs(t, err, jwt.ErrTokenSignatureInvalid)
+	})
+}
+
+// writePEM записывает DER-данные ключа в PEM-файл во временном каталоге теста.
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeKeyPair записывает закрытый ключ в формате PKCS#8 и открытый ключ в формате PKIX
+// и возвращает пути к файлам.
+func writeKeyPair(t *testing.T, private interface{}, public interface{}) (string, string) {
+	t.Helper()
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writePEM(t, "private.pem", "PRIVATE KEY", privateDER), writePEM(t, "public.pem", "PUBLIC KEY", publicDER)
+}
+
+func TestAsymmetricKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name    string
+		private interface{}
+		public  interface{}
+		alg     string
+	}{
+		{name: "RS256", private: rsaKey, public: &rsaKey.PublicKey, alg: "RS256"},
+		{name: "EdDSA", private: edPrivate, public: edPublic, alg: "EdDSA"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			privatePath, publicPath := writeKeyPair(t, tc.private, tc.public)
+			signer, err := NewKeyring("signer", []Key{{ID: "signer", PrivateKeyFile: privatePath}}, time.Hour)
+			assert.NoError(t, err)
+			useKeyring(t, signer)
+
+			tokenString, err := BuildJWTStringForUser("user-1")
+			assert.NoError(t, err)
+			token, _, err := jwt.NewParser().ParseUnverified(tokenString, &Claims{})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.alg, token.Header["alg"])
+			userID, err := GetUserID(tokenString)
+			assert.NoError(t, err)
+			assert.Equal(t, "user-1", userID)
+
+			// Другой сервис проверяет токены, имея только открытый ключ.
+			verifier, err := NewKeyring("own", []Key{{ID: "own", Secret: "s1"}, {ID: "signer", PublicKeyFile: publicPath}}, time.Hour)
+			assert.NoError(t, err)
+			SetKeyring(verifier)
+			userID, err = GetUserID(tokenString)
+			assert.NoError(t, err)
+			assert.Equal(t, "user-1", userID)
+
+			_, err = NewKeyring("signer", []Key{{ID: "signer", PublicKeyFile: publicPath}}, time.Hour)
+			assert.ErrorIs(t, err, ErrNoPrivateKey)
+		})
+	}
+
+	t.Run("PKCS1 RSA key", func(t *testing.T) {
+		path := writePEM(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+		keyring, err := LoadKeyring("", "", path, time.Hour)
+		assert.NoError(t, err)
+		again, err := LoadKeyring("", "", path, time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, keyring.ActiveKeyID(), again.ActiveKeyID())
+		_, method, _ := keyring.signingKey()
+		assert.Equal(t, jwt.SigningMethodRS256, method)
+	})
+
+	t.Run("Weak RSA key", func(t *testing.T) {
+		weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := writePEM(t, "weak.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(weakKey))
+		_, err = LoadKeyring("", "", path, time.Hour)
+		assert.ErrorIs(t, err, ErrWeakRSAKey)
+	})
+
+	t.Run("Not a PEM file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "key.pem")
+		if err := os.WriteFile(path, []byte("not a key"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadKeyring("", "", path, time.Hour)
+		assert.ErrorIs(t, err, ErrInvalidPEM)
+	})
+
+	t.Run("Algorithm confusion", func(t *testing.T) {
+		_, publicPath := writeKeyPair(t, rsaKey, &rsaKey.PublicKey)
+		keyring, err := NewKeyring("own", []Key{{ID: "own", Secret: "s1"}, {ID: "rsa", PublicKeyFile: publicPath}}, time.Hour)
+		assert.NoError(t, err)
+		useKeyring(t, keyring)
+		publicPEM, err := os.ReadFile(publicPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims(time.Now().Add(time.Hour)))
+		token.Header["kid"] = "rsa"
+		tokenString, err := token.SignedString(publicPEM)
+		assert.NoError(t, err)
+		_, err = GetUserID(tokenString)
+		assert.ErrorIs(t, err, ErrUnexpectedAlgorithm)
+	})
+}
+
+func TestKeyring_JWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPath, _ := writeKeyPair(t, rsaKey, &rsaKey.PublicKey)
+	edPath, edPublicPath := writeKeyPair(t, edPrivate, edPublic)
+	now := time.Now()
+	keyring, err := NewKeyring("b-rsa", []Key{
+		{ID: "b-rsa", PrivateKeyFile: rsaPath},
+		{ID: "a-ed", PublicKeyFile: edPublicPath, RetiredAt: now.Add(-time.Minute)},
+		{ID: "c-ed-expired", PrivateKeyFile: edPath, RetiredAt: now.Add(-2 * time.Hour)},
+		{ID: "d-hmac", Secret: "s1"},
+	}, time.Hour)
+	assert.NoError(t, err)
+
+	set := keyring.JWKS(now)
+	if !assert.Len(t, set.Keys, 2) {
+		return
+	}
+
+	ed := set.Keys[0]
+	assert.Equal(t, JWK{KeyType: "OKP", KeyID: "a-ed", Use: "sig", Algorithm: "EdDSA", Curve: "Ed25519",
+		X: base64.RawURLEncoding.EncodeToString(edPublic)}, ed)
+
+	rsaJWK := set.Keys[1]
+	assert.Equal(t, "RSA", rsaJWK.KeyType)
+	assert.Equal(t, "b-rsa", rsaJWK.KeyID)
+	assert.Equal(t, "RS256", rsaJWK.Algorithm)
+	n, err := base64.RawURLEncoding.DecodeString(rsaJWK.N)
+	assert.NoError(t, err)
+	e, err := base64.RawURLEncoding.DecodeString(rsaJWK.E)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, new(big.Int).SetBytes(n).Cmp(rsaKey.N))
+	assert.Equal(t, int64(rsaKey.E), new(big.Int).SetBytes(e).Int64())
+
+	hmacOnly, err := NewSecretKeyring("secret")
+	assert.NoError(t, err)
+	assert.Equal(t, JWKSet{Keys: []JWK{}}, hmacOnly.JWKS(now))
+}
@@ -0,0 +1,41 @@
+package keygen
+
+import (
+	"context"
+	"crypto/sha256"
+	"math/big"
+	"strconv"
+)
+
+// HashGenerator строит ключ из SHA-256 хеша URL, поэтому один и тот же URL получает один и тот же ключ.
+// При коллизии номер попытки подмешивается к хешу.
+type HashGenerator struct {
+	length  int
+	charset string
+}
+
+// NewHashGenerator создаёт генератор детерминированных ключей.
+func NewHashGenerator(length int, charset string) (*HashGenerator, error) {
+	if err := validate(length, charset); err != nil {
+		return nil, err
+	}
+	return &HashGenerator{length: length, charset: charset}, nil
+}
+
+// Generate возвращает ключ, вычисленный из URL и номера попытки.
+func (g *HashGenerator) Generate(_ context.Context, url string, attempt int) (string, error) {
+	input := url
+	if attempt > 0 {
+		input += "#" + strconv.Itoa(attempt)
+	}
+	sum := sha256.Sum256([]byte(input))
+	n := new(big.Int).SetBytes(sum[:])
+	base := big.NewInt(int64(len(g.charset)))
+	digit := new(big.Int)
+	key := make([]byte, g.length)
+	for i := range key {
+		n.DivMod(n, base, digit)
+		key[i] = g.charset[digit.Int64()]
+	}
+	return string(key), nil
+}
@@ -0,0 +1,124 @@
+// Package keygen содержит стратегии генерации коротких ключей ссылок.
+package keygen
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shekshuev/shortener/internal/app/config"
+)
+
+// Стратегии генерации коротких ключей.
+const (
+	StrategyRandom     = "random"     // Криптографически случайный ключ.
+	StrategySequence   = "sequence"   // Порядковый номер в системе счисления алфавита.
+	StrategyHash       = "hash"       // Детерминированный ключ из хеша URL.
+	StrategyObfuscated = "obfuscated" // Порядковый номер, перемешанный обратимым преобразованием.
+)
+
+// Ограничения на длину ключа.
+const (
+	MinLength = 1
+	MaxLength = 64
+)
+
+// Параметры генератора по умолчанию.
+const (
+	DefaultLength  = 8
+	DefaultCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// Ошибки генерации ключей.
+var (
+	ErrUnknownStrategy    = fmt.Errorf("key strategy must be one of: random, sequence, hash, obfuscated")                      // Ошибка: неизвестная стратегия
+	ErrInvalidLength      = fmt.Errorf("key length must be between %d and %d", MinLength, MaxLength)                           // Ошибка: недопустимая длина ключа
+	ErrInvalidCharset     = fmt.Errorf("key charset must contain at least 2 unique latin letters, digits, '-' or '_' symbols") // Ошибка: недопустимый алфавит
+	ErrKeySpaceExhausted  = fmt.Errorf("key space exhausted")                                                                  // Ошибка: исчерпано пространство ключей
+	ErrCounterUnavailable = fmt.Errorf("counter is required for sequence and obfuscated strategies")                           // Ошибка: не передан счётчик
+)
+
+// KeyGenerator - интерфейс генератора коротких ключей.
+// attempt - номер попытки, начиная с нуля: при коллизии сервис запрашивает ключ повторно с увеличенным номером,
+// чтобы детерминированные стратегии могли предложить другой ключ.
+type KeyGenerator interface {
+	Generate(ctx context.Context, url string, attempt int) (string, error)
+}
+
+// Counter - источник монотонно возрастающих порядковых номеров.
+type Counter interface {
+	NextSequence(ctx context.Context) (uint64, error)
+}
+
+// New создаёт генератор ключей по настройкам конфигурации.
+// counter используется стратегиями sequence и obfuscated.
+func New(cfg *config.Config, counter Counter) (KeyGenerator, error) {
+	switch cfg.KeyStrategy {
+	case StrategyRandom:
+		return NewRandomGenerator(cfg.KeyLength, cfg.KeyCharset)
+	case StrategySequence:
+		return NewSequenceGenerator(counter, cfg.KeyLength, cfg.KeyCharset)
+	case StrategyHash:
+		return NewHashGenerator(cfg.KeyLength, cfg.KeyCharset)
+	case StrategyObfuscated:
+		return NewObfuscatedGenerator(counter, cfg.KeyLength, cfg.KeyCharset)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStrategy, cfg.KeyStrategy)
+	}
+}
+
+// validate проверяет длину ключа и алфавит.
+func validate(length int, charset string) error {
+	if length < MinLength || length > MaxLength {
+		return ErrInvalidLength
+	}
+	if len(charset) < 2 {
+		return ErrInvalidCharset
+	}
+	seen := make(map[rune]struct{}, len(charset))
+	for _, r := range charset {
+		if !isKeyRune(r) {
+			return ErrInvalidCharset
+		}
+		if _, exists := seen[r]; exists {
+			return ErrInvalidCharset
+		}
+		seen[r] = struct{}{}
+	}
+	return nil
+}
+
+func isKeyRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_'
+}
+
+// encode записывает число n в системе счисления алфавита charset, дополняя результат
+// нулевым символом алфавита слева до длины width.
+func encode(n uint64, charset string, width int) string {
+	base := uint64(len(charset))
+	var buf []byte
+	for n > 0 {
+		buf = append(buf, charset[n%base])
+		n /= base
+	}
+	for len(buf) < width {
+		buf = append(buf, charset[0])
+	}
+	for i, j := 0, len(buf)-1; i < j; i, j = i+1, j-1 {
+		buf[i], buf[j] = buf[j], buf[i]
+	}
+	return string(buf)
+}
+
+// keySpace возвращает количество ключей длины length в алфавите charset
+// и false, если оно не помещается в uint64.
+func keySpace(length int, charset string) (uint64, bool) {
+	space := uint64(1)
+	base := uint64(len(charset))
+	for i := 0; i < length; i++ {
+		if space > ^uint64(0)/base {
+			return 0, false
+		}
+		space *= base
+	}
+	return space, true
+}
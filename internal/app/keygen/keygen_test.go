@@ -0,0 +1,117 @@
+package keygen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shekshuev/shortener/internal/app/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		name        string
+		strategy    string
+		length      int
+		charset     string
+		counter     Counter
+		expectedErr error
+	}{
+		{name: "Random", strategy: StrategyRandom, length: 8, charset: DefaultCharset},
+		{name: "Sequence", strategy: StrategySequence, length: 6, charset: DefaultCharset, counter: NewAtomicCounter(0)},
+		{name: "Hash", strategy: StrategyHash, length: 10, charset: "0123456789abcdef"},
+		{name: "Obfuscated", strategy: StrategyObfuscated, length: 7, charset: DefaultCharset, counter: NewAtomicCounter(0)},
+		{name: "Unknown strategy", strategy: "uuid", length: 8, charset: DefaultCharset, expectedErr: ErrUnknownStrategy},
+		{name: "Zero length", strategy: StrategyRandom, length: 0, charset: DefaultCharset, expectedErr: ErrInvalidLength},
+		{name: "Too long", strategy: StrategyHash, length: 65, charset: DefaultCharset, expectedErr: ErrInvalidLength},
+		{name: "Single character charset", strategy: StrategyRandom, length: 8, charset: "a", expectedErr: ErrInvalidCharset},
+		{name: "Duplicate characters", strategy: StrategyRandom, length: 8, charset: "abca", expectedErr: ErrInvalidCharset},
+		{name: "Unsafe characters", strategy: StrategyRandom, length: 8, charset: "ab/?", expectedErr: ErrInvalidCharset},
+		{name: "Sequence without counter", strategy: StrategySequence, length: 8, charset: DefaultCharset, expectedErr: ErrCounterUnavailable},
+		{name: "Obfuscated key space overflow", strategy: StrategyObfuscated, length: 11, charset: DefaultCharset, counter: NewAtomicCounter(0), expectedErr: ErrInvalidLength},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.GetConfig()
+			cfg.KeyStrategy = tc.strategy
+			cfg.KeyLength = tc.length
+			cfg.KeyCharset = tc.charset
+			gen, err := New(&cfg, tc.counter)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			key, err := gen.Generate(context.Background(), "https://ya.ru", 0)
+			assert.NoError(t, err)
+			assert.Len(t, key, tc.length)
+			for _, r := range key {
+				assert.Contains(t, tc.charset, string(r), "Key contains character outside of charset")
+			}
+		})
+	}
+}
+
+func TestRandomGenerator_Generate(t *testing.T) {
+	gen, err := NewRandomGenerator(DefaultLength, DefaultCharset)
+	assert.NoError(t, err)
+	keys := make(map[string]struct{})
+	for i := 0; i < 1000; i++ {
+		key, err := gen.Generate(context.Background(), "https://ya.ru", 0)
+		assert.NoError(t, err)
+		keys[key] = struct{}{}
+	}
+	assert.Len(t, keys, 1000, "Random keys should not repeat")
+}
+
+func TestSequenceGenerator_Generate(t *testing.T) {
+	gen, err := NewSequenceGenerator(NewAtomicCounter(0), 3, "0123456789")
+	assert.NoError(t, err)
+	for _, expected := range []string{"001", "002", "003"} {
+		key, err := gen.Generate(context.Background(), "https://ya.ru", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, key)
+	}
+
+	gen, err = NewSequenceGenerator(NewAtomicCounter(999), 3, "0123456789")
+	assert.NoError(t, err)
+	key, err := gen.Generate(context.Background(), "https://ya.ru", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "1000", key, "Key should grow when sequence outgrows the length")
+}
+
+func TestHashGenerator_Generate(t *testing.T) {
+	gen, err := NewHashGenerator(DefaultLength, DefaultCharset)
+	assert.NoError(t, err)
+	ctx := context.Background()
+	first, err := gen.Generate(ctx, "https://ya.ru", 0)
+	assert.NoError(t, err)
+	second, err := gen.Generate(ctx, "https://ya.ru", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, first, second, "Same url should give the same key")
+	retry, err := gen.Generate(ctx, "https://ya.ru", 1)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, retry, "Retry should give another key")
+	other, err := gen.Generate(ctx, "https://google.com", 0)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, other, "Different urls should give different keys")
+}
+
+func TestObfuscatedGenerator_Generate(t *testing.T) {
+	gen, err := NewObfuscatedGenerator(NewAtomicCounter(0), 3, "0123456789")
+	assert.NoError(t, err)
+	ctx := context.Background()
+	keys := make(map[string]struct{})
+	previous := ""
+	for i := 0; i < 999; i++ {
+		key, err := gen.Generate(ctx, "", 0)
+		assert.NoError(t, err)
+		assert.Len(t, key, 3)
+		assert.NotEqual(t, previous, key)
+		keys[key] = struct{}{}
+		previous = key
+	}
+	assert.Len(t, keys, 999, "Obfuscated keys should not collide")
+	_, err = gen.Generate(ctx, "", 0)
+	assert.ErrorIs(t, err, ErrKeySpaceExhausted)
+}
@@ -0,0 +1,70 @@
+package keygen
+
+import (
+	"context"
+	"math/bits"
+)
+
+// Множители обфускации: простые числа, одно из которых должно быть взаимно простым с размером пространства ключей.
+var obfuscationMultipliers = []uint64{1_580_030_173, 2_654_435_761, 1_000_000_007}
+
+// obfuscationOffset сдвигает результат, чтобы первый номер не давал предсказуемый ключ.
+const obfuscationOffset = 0x5bd1e995
+
+// ObfuscatedGenerator переводит порядковые номера счётчика в ключи фиксированной длины
+// с помощью обратимого преобразования n*M + C по модулю размера пространства ключей.
+// Соседние номера дают непохожие ключи, а коллизии невозможны, пока пространство не исчерпано.
+type ObfuscatedGenerator struct {
+	counter    Counter
+	length     int
+	charset    string
+	space      uint64
+	multiplier uint64
+}
+
+// NewObfuscatedGenerator создаёт генератор обфусцированных ключей.
+func NewObfuscatedGenerator(counter Counter, length int, charset string) (*ObfuscatedGenerator, error) {
+	if counter == nil {
+		return nil, ErrCounterUnavailable
+	}
+	if err := validate(length, charset); err != nil {
+		return nil, err
+	}
+	space, ok := keySpace(length, charset)
+	if !ok {
+		return nil, ErrInvalidLength
+	}
+	g := &ObfuscatedGenerator{counter: counter, length: length, charset: charset, space: space}
+	for _, m := range obfuscationMultipliers {
+		if gcd(m, space) == 1 {
+			g.multiplier = m
+			break
+		}
+	}
+	if g.multiplier == 0 {
+		return nil, ErrInvalidCharset
+	}
+	return g, nil
+}
+
+// Generate возвращает обфусцированный ключ для следующего номера счётчика.
+func (g *ObfuscatedGenerator) Generate(ctx context.Context, _ string, _ int) (string, error) {
+	n, err := g.counter.NextSequence(ctx)
+	if err != nil {
+		return "", err
+	}
+	if n >= g.space {
+		return "", ErrKeySpaceExhausted
+	}
+	hi, lo := bits.Mul64(n, g.multiplier)
+	lo, carry := bits.Add64(lo, obfuscationOffset, 0)
+	hi += carry
+	return encode(bits.Rem64(hi, lo, g.space), g.charset, g.length), nil
+}
+
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
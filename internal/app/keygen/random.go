@@ -0,0 +1,48 @@
+package keygen
+
+import (
+	"context"
+	"crypto/rand"
+)
+
+// RandomGenerator генерирует криптографически случайные ключи фиксированной длины.
+type RandomGenerator struct {
+	length  int
+	charset string
+}
+
+// NewRandomGenerator создаёт генератор случайных ключей.
+func NewRandomGenerator(length int, charset string) (*RandomGenerator, error) {
+	if err := validate(length, charset); err != nil {
+		return nil, err
+	}
+	return &RandomGenerator{length: length, charset: charset}, nil
+}
+
+// NewDefaultGenerator создаёт генератор случайных ключей длины DefaultLength в алфавите DefaultCharset.
+func NewDefaultGenerator() *RandomGenerator {
+	return &RandomGenerator{length: DefaultLength, charset: DefaultCharset}
+}
+
+// Generate возвращает случайный ключ. URL и номер попытки не используются.
+func (g *RandomGenerator) Generate(_ context.Context, _ string, _ int) (string, error) {
+	// Байты не меньше limit отбрасываются, чтобы все символы алфавита были равновероятны.
+	limit := 256 - 256%len(g.charset)
+	key := make([]byte, 0, g.length)
+	buf := make([]byte, g.length*2)
+	for len(key) < g.length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			key = append(key, g.charset[int(b)%len(g.charset)])
+			if len(key) == g.length {
+				break
+			}
+		}
+	}
+	return string(key), nil
+}
@@ -0,0 +1,52 @@
+package keygen
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// AtomicCounter - счётчик в памяти процесса. Значения не сохраняются между перезапусками,
+// поэтому его стоит инициализировать количеством уже существующих ссылок.
+type AtomicCounter struct {
+	value atomic.Uint64
+}
+
+// NewAtomicCounter создаёт счётчик, первое значение которого будет start+1.
+func NewAtomicCounter(start uint64) *AtomicCounter {
+	c := &AtomicCounter{}
+	c.value.Store(start)
+	return c
+}
+
+// NextSequence возвращает следующее значение счётчика.
+func (c *AtomicCounter) NextSequence(_ context.Context) (uint64, error) {
+	return c.value.Add(1), nil
+}
+
+// SequenceGenerator кодирует порядковые номера счётчика в алфавите ключей.
+// Ключи короче length дополняются слева, длиннее - получаются, когда номера перестают помещаться в length символов.
+type SequenceGenerator struct {
+	counter Counter
+	length  int
+	charset string
+}
+
+// NewSequenceGenerator создаёт генератор последовательных ключей.
+func NewSequenceGenerator(counter Counter, length int, charset string) (*SequenceGenerator, error) {
+	if counter == nil {
+		return nil, ErrCounterUnavailable
+	}
+	if err := validate(length, charset); err != nil {
+		return nil, err
+	}
+	return &SequenceGenerator{counter: counter, length: length, charset: charset}, nil
+}
+
+// Generate возвращает ключ для следующего номера счётчика.
+func (g *SequenceGenerator) Generate(ctx context.Context, _ string, _ int) (string, error) {
+	n, err := g.counter.NextSequence(ctx)
+	if err != nil {
+		return "", err
+	}
+	return encode(n, g.charset, g.length), nil
+}
@@ -1,47 +1,135 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/shekshuev/shortener/internal/app/apikey"
 	"github.com/shekshuev/shortener/internal/app/jwt"
 	"github.com/shekshuev/shortener/internal/app/logger"
+	"github.com/shekshuev/shortener/internal/app/models"
+	"go.uber.org/zap"
 )
 
-// RequestAuth проверяет, авторизован ли пользователь или нет, и выставляет куки
-func RequestAuth(h http.Handler) http.Handler {
+// APIKeyAuthenticator - интерфейс проверки ключей API.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, error)
+}
+
+// BearerAuth аутентифицирует запрос по заголовку Authorization вида "Bearer <ключ API или JWT>".
+// Для ключа API в контекст кроме пользователя кладутся его разрешения (см. apikey.ScopesFromContext),
+// JWT-токен даёт те же права, что и куки. На недействительный токен отвечает 401 Unauthorized.
+// Запросы без заголовка или с другой схемой передаются дальше без изменений.
+func BearerAuth(auth APIKeyAuthenticator) func(http.Handler) http.Handler {
 	log := logger.NewLogger()
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		jwtToken, err := jwt.GetAuthCookie(r)
-		if err != nil {
-			value, err := jwt.BuildJWTString()
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := jwt.BearerToken(r.Header.Get("Authorization"))
+			if !ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if !apikey.IsAPIKey(token) {
+				userID, err := jwt.GetUserID(token)
+				if err != nil {
+					unauthorized(w, err)
+					return
+				}
+				h.ServeHTTP(w, r.WithContext(jwt.WithUserID(r.Context(), userID)))
+				return
+			}
+			key, err := auth.AuthenticateAPIKey(r.Context(), token)
+			if errors.Is(err, apikey.ErrInvalidKey) {
+				unauthorized(w, err)
+				return
+			}
 			if err != nil {
-				log.Log.Error(err.Error())
-				w.WriteHeader(http.StatusBadRequest)
+				log.Log.Error("Error authenticating api key", zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
-			cookie := &http.Cookie{
-				Name:     jwt.CookieName,
-				Value:    value,
-				Path:     "/",
-				HttpOnly: true,
+			ctx := apikey.WithScopes(jwt.WithUserID(r.Context(), key.UserID), key.Scopes)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// unauthorized отвечает 401 Unauthorized с заголовком WWW-Authenticate для схемы Bearer.
+func unauthorized(w http.ResponseWriter, err error) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	http.Error(w, err.Error(), http.StatusUnauthorized)
+}
+
+// RequireScope пропускает запрос, только если он аутентифицирован не ключом API
+// или у ключа есть разрешение scope, иначе отвечает 403 Forbidden.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !apikey.Allowed(r.Context(), scope) {
+				http.Error(w, "api key has no "+scope+" scope", http.StatusForbidden)
+				return
 			}
-			r.AddCookie(cookie)
-			http.SetCookie(w, cookie)
 			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RejectAPIKeys отвечает 403 Forbidden на запросы, аутентифицированные ключом API.
+// Используется для управления самими ключами, чтобы утёкший ключ нельзя было перевыпустить.
+func RejectAPIKeys(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := apikey.ScopesFromContext(r.Context()); ok {
+			http.Error(w, "api keys cannot manage api keys", http.StatusForbidden)
 			return
 		}
-		if jwt.IsTokenExpired(jwtToken) {
-			http.SetCookie(w, &http.Cookie{
-				Name:     jwt.CookieName,
-				Value:    "",
-				Path:     "/",
-				MaxAge:   -1,
-				HttpOnly: true,
-			})
-			w.WriteHeader(http.StatusUnauthorized)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// RequestAuth аутентифицирует пользователя по куки с токеном и передаёт его идентификатор
+// дальше через контекст запроса (см. jwt.UserIDFromContext).
+// Пользователю без куки или с недействительным токеном выдаётся токен с новым идентификатором.
+// Токен с верной подписью, истёкший не раньше окна перевыпуска (см. jwt.SetRefreshWindow),
+// перевыпускается с тем же идентификатором, более старый считается недействительным.
+// Запросы, уже аутентифицированные BearerAuth, передаются дальше без куки.
+func RequestAuth(h http.Handler) http.Handler {
+	log := logger.NewLogger()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := jwt.UserIDFromContext(r.Context()); ok {
 			h.ServeHTTP(w, r)
 			return
 		}
-		h.ServeHTTP(w, r)
+		var claims *jwt.Claims
+		jwtToken, err := jwt.GetAuthCookie(r)
+		if err == nil {
+			claims, err = jwt.ParseToken(jwtToken)
+		}
+		if err == nil {
+			h.ServeHTTP(w, r.WithContext(jwt.WithUserID(r.Context(), claims.UserID)))
+			return
+		}
+
+		userID := uuid.New().String()
+		if errors.Is(err, jwt.ErrTokenExpired) && claims != nil && claims.Refreshable(time.Now()) {
+			userID = claims.UserID
+		} else if !errors.Is(err, http.ErrNoCookie) {
+			log.Log.Debug("Rejected auth token", zap.Error(err))
+		}
+		value, err := jwt.BuildJWTStringForUser(userID)
+		if err != nil {
+			log.Log.Error(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     jwt.CookieName,
+			Value:    value,
+			Path:     "/",
+			HttpOnly: true,
+		})
+		h.ServeHTTP(w, r.WithContext(jwt.WithUserID(r.Context(), userID)))
 	})
 }
@@ -3,16 +3,24 @@ package mocks
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/shekshuev/shortener/internal/app/models"
 	"github.com/shekshuev/shortener/internal/app/store"
+	"github.com/shekshuev/shortener/internal/utils"
 	"github.com/stretchr/testify/mock"
 )
 
 // MockStore - моковая реализация хранилища URL.
 type MockStore struct {
 	mock.Mock
-	urls map[string]store.UserURL
+	urls    map[string]store.UserURL
+	mx      sync.Mutex
+	clicks  []models.ClickEvent
+	apiKeys []models.APIKey
 }
 
 // ErrNotFound - ошибка, возникающая при отсутствии запрашиваемого URL.
@@ -25,7 +33,9 @@ func NewURLStore() *MockStore {
 }
 
 // SetURL сохраняет URL в хранилище.
-func (m *MockStore) SetURL(_ context.Context, key, value, userID string) (string, error) {
+func (m *MockStore) SetURL(_ context.Context, key, value, userID string, opts models.LinkOptions) (string, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
 	if len(key) == 0 {
 		return "", store.ErrEmptyKey
 	}
@@ -35,12 +45,17 @@ func (m *MockStore) SetURL(_ context.Context, key, value, userID string) (string
 	if len(userID) == 0 {
 		return "", store.ErrEmptyUserID
 	}
-	m.urls[key] = store.UserURL{UserID: userID, URL: value}
+	if _, exists := m.urls[key]; exists {
+		return "", store.ErrKeyTaken
+	}
+	m.urls[key] = store.UserURL{UserID: userID, URL: value, ExpiresAt: opts.ExpiresAt, CreatedAt: time.Now(), RedirectType: opts.RedirectType, ForwardQuery: opts.ForwardQuery, ForwardPath: opts.ForwardPath}
 	return value, nil
 }
 
 // SetBatchURL сохраняет пакет URL в хранилище.
 func (m *MockStore) SetBatchURL(_ context.Context, createDTO []models.BatchShortURLCreateDTO, userID string) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
 	if len(userID) == 0 {
 		return store.ErrEmptyUserID
 	}
@@ -54,22 +69,38 @@ func (m *MockStore) SetBatchURL(_ context.Context, createDTO []models.BatchShort
 		if len(dto.OriginalURL) == 0 {
 			return store.ErrEmptyValue
 		}
-		m.urls[dto.ShortURL] = store.UserURL{UserID: userID, URL: dto.OriginalURL}
+		if _, exists := m.urls[dto.ShortURL]; exists {
+			return store.ErrKeyTaken
+		}
+		m.urls[dto.ShortURL] = store.UserURL{UserID: userID, URL: dto.OriginalURL, ExpiresAt: dto.ExpiresAt, RedirectType: dto.RedirectType, ForwardQuery: dto.ForwardQuery, ForwardPath: dto.ForwardPath}
 	}
 	return nil
 }
 
 // GetURL возвращает оригинальный URL по короткому ключу.
-func (m *MockStore) GetURL(_ context.Context, key string) (string, error) {
+func (m *MockStore) GetURL(ctx context.Context, key string) (string, error) {
+	redirect, err := m.GetRedirect(ctx, key)
+	return redirect.URL, err
+}
+
+// GetRedirect возвращает оригинальный URL и код ответа при переходе по короткому ключу.
+func (m *MockStore) GetRedirect(_ context.Context, key string) (models.RedirectDTO, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
 	value, exists := m.urls[key]
 	if !exists {
-		return "", ErrNotFound
+		return models.RedirectDTO{}, ErrNotFound
+	}
+	if value.IsExpired(time.Now()) {
+		return models.RedirectDTO{}, store.ErrExpired
 	}
-	return value.URL, nil
+	return models.RedirectDTO{URL: value.URL, Type: value.RedirectType, ForwardQuery: value.ForwardQuery, ForwardPath: value.ForwardPath, ExpiresAt: value.ExpiresAt}, nil
 }
 
 // GetUserURLs возвращает все URL, принадлежащие пользователю.
 func (m *MockStore) GetUserURLs(_ context.Context, userID string) ([]models.UserShortURLReadDTO, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
 	var readDTO []models.UserShortURLReadDTO
 	for key, value := range m.urls {
 		if value.UserID == userID {
@@ -82,8 +113,49 @@ func (m *MockStore) GetUserURLs(_ context.Context, userID string) ([]models.User
 	return readDTO, nil
 }
 
+// GetUserURLsPage возвращает неудалённые URL пользователя, отсортированные по ключу.
+// Поле и направление сортировки игнорируются, курсором служит последний ключ страницы.
+func (m *MockStore) GetUserURLsPage(_ context.Context, userID string, query models.UserURLsQuery) (models.UserURLsPageDTO, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if query.Limit < 0 || query.Limit > store.MaxPageLimit {
+		return models.UserURLsPageDTO{}, store.ErrInvalidLimit
+	}
+	var keys []string
+	for key, value := range m.urls {
+		if value.UserID == userID && !value.IsDeleted && key > query.Cursor && strings.Contains(value.URL, query.Query) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	var page models.UserURLsPageDTO
+	if query.Limit > 0 && len(keys) > query.Limit {
+		keys = keys[:query.Limit]
+		page.NextCursor = keys[len(keys)-1]
+	}
+	for _, key := range keys {
+		page.URLs = append(page.URLs, models.UserShortURLReadDTO{ShortURL: key, OriginalURL: m.urls[key].URL})
+	}
+	return page, nil
+}
+
+// LookupUserURLs возвращает неудалённые URL пользователя, нормализованный вид которых совпадает с normalizedURL.
+func (m *MockStore) LookupUserURLs(_ context.Context, userID, normalizedURL string) ([]models.UserShortURLReadDTO, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	var readDTO []models.UserShortURLReadDTO
+	for key, value := range m.urls {
+		if normalized, err := utils.NormalizeURL(value.URL); err == nil && normalized == normalizedURL && value.UserID == userID && !value.IsDeleted {
+			readDTO = append(readDTO, models.UserShortURLReadDTO{ShortURL: key, OriginalURL: value.URL})
+		}
+	}
+	return readDTO, nil
+}
+
 // DeleteURLs помечает список URL как удалённые.
 func (m *MockStore) DeleteURLs(_ context.Context, userID string, urls []string) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
 	if m.urls == nil {
 		return store.ErrNotInitialized
 	}
@@ -106,12 +178,109 @@ func (m *MockStore) DeleteURLs(_ context.Context, userID string, urls []string)
 	return nil
 }
 
+// DeleteURLsBatch помечает удалёнными URL нескольких пользователей.
+func (m *MockStore) DeleteURLsBatch(ctx context.Context, tasks []models.DeletionTask) error {
+	for _, task := range tasks {
+		if err := m.DeleteURLs(ctx, task.UserID, task.ShortURLs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreURLs снимает пометку удаления с URL пользователя. Время удаления в моке не учитывается.
+func (m *MockStore) RestoreURLs(_ context.Context, userID string, urls []string, _ time.Time) ([]string, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if len(userID) == 0 {
+		return nil, store.ErrEmptyUserID
+	}
+	if len(urls) == 0 {
+		return nil, store.ErrEmptyURLs
+	}
+	restored := make([]string, 0, len(urls))
+	for _, shortURL := range urls {
+		if value, exists := m.urls[shortURL]; exists && value.UserID == userID && value.IsDeleted {
+			value.IsDeleted = false
+			m.urls[shortURL] = value
+			restored = append(restored, shortURL)
+		}
+	}
+	return restored, nil
+}
+
+// PurgeDeleted окончательно удаляет все помеченные удалёнными URL.
+func (m *MockStore) PurgeDeleted(_ context.Context, _ time.Time) (int, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	count := 0
+	for key, value := range m.urls {
+		if value.IsDeleted {
+			delete(m.urls, key)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// UpdateURL изменяет оригинальный URL и код ответа при переходе по ссылке пользователя.
+func (m *MockStore) UpdateURL(_ context.Context, userID, key string, update models.ShortURLUpdateDTO) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if len(key) == 0 {
+		return store.ErrEmptyKey
+	}
+	if len(update.URL) == 0 && update.RedirectType == 0 {
+		return store.ErrEmptyValue
+	}
+	if len(userID) == 0 {
+		return store.ErrEmptyUserID
+	}
+	current, exists := m.urls[key]
+	if !exists || current.IsDeleted || current.UserID != userID {
+		return store.ErrNotFound
+	}
+	if len(update.URL) > 0 {
+		for otherKey, other := range m.urls {
+			if otherKey != key && other.UserID == userID && other.URL == update.URL {
+				return store.ErrAlreadyExists
+			}
+		}
+		current.URL = update.URL
+	}
+	if update.RedirectType != 0 {
+		current.RedirectType = update.RedirectType
+	}
+	m.urls[key] = current
+	return nil
+}
+
 // CheckDBConnection проверяет подключение к базе данных (мокается для тестов).
 func (m *MockStore) CheckDBConnection(_ context.Context) error {
 	args := m.Called()
 	return args.Error(0)
 }
 
+// GetURLInfo возвращает сведения о ссылке по короткому ключу.
+func (m *MockStore) GetURLInfo(_ context.Context, key string) (models.URLInfoDTO, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	value, exists := m.urls[key]
+	if !exists {
+		return models.URLInfoDTO{}, store.ErrNotFound
+	}
+	if value.IsDeleted {
+		return models.URLInfoDTO{}, store.ErrAlreadyDeleted
+	}
+	clicks := 0
+	for _, event := range m.clicks {
+		if event.ShortURL == key {
+			clicks++
+		}
+	}
+	return models.URLInfoDTO{ShortURL: key, OriginalURL: value.URL, UserID: value.UserID, CreatedAt: &value.CreatedAt, ExpiresAt: value.ExpiresAt, Clicks: clicks, RedirectType: value.RedirectType, ForwardQuery: value.ForwardQuery, ForwardPath: value.ForwardPath}, nil
+}
+
 // Close закрывает подключение к хранилищу (мокается для тестов).
 func (m *MockStore) Close() error {
 	args := m.Called()
@@ -129,3 +298,98 @@ func (m *MockStore) CountUsers(_ context.Context) (int, error) {
 	args := m.Called()
 	return args.Int(0), args.Error(1)
 }
+
+// DeleteExpired удаляет из мока ссылки с истёкшим сроком действия.
+func (m *MockStore) DeleteExpired(_ context.Context) (int, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	now := time.Now()
+	count := 0
+	for key, value := range m.urls {
+		if value.IsExpired(now) {
+			delete(m.urls, key)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// SaveClicks сохраняет события переходов в моке.
+func (m *MockStore) SaveClicks(_ context.Context, events []models.ClickEvent) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.clicks = append(m.clicks, events...)
+	return nil
+}
+
+// Clicks возвращает сохранённые в моке события переходов.
+func (m *MockStore) Clicks() []models.ClickEvent {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	return append([]models.ClickEvent(nil), m.clicks...)
+}
+
+// GetURLStats возвращает статистику переходов по ссылке (мокается для тестов).
+func (m *MockStore) GetURLStats(_ context.Context, userID, key string) (models.URLStatsDTO, error) {
+	args := m.Called(userID, key)
+	return args.Get(0).(models.URLStatsDTO), args.Error(1)
+}
+
+// CreateAPIKey сохраняет ключ API в моке.
+func (m *MockStore) CreateAPIKey(_ context.Context, key models.APIKey) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.apiKeys = append(m.apiKeys, key)
+	return nil
+}
+
+// GetUserAPIKeys возвращает ключи API пользователя в порядке создания.
+func (m *MockStore) GetUserAPIKeys(_ context.Context, userID string) ([]models.APIKey, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	var keys []models.APIKey
+	for _, key := range m.apiKeys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// GetAPIKeyByHash возвращает ключ API по его хэшу.
+func (m *MockStore) GetAPIKeyByHash(_ context.Context, hash string) (models.APIKey, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	for _, key := range m.apiKeys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return models.APIKey{}, store.ErrNotFound
+}
+
+// RevokeAPIKey удаляет ключ API пользователя.
+func (m *MockStore) RevokeAPIKey(_ context.Context, userID, id string) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	for i, key := range m.apiKeys {
+		if key.ID == id && key.UserID == userID {
+			m.apiKeys = append(m.apiKeys[:i], m.apiKeys[i+1:]...)
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+// TouchAPIKey запоминает момент последнего использования ключа API.
+func (m *MockStore) TouchAPIKey(_ context.Context, id string, usedAt time.Time) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	for i, key := range m.apiKeys {
+		if key.ID == id {
+			m.apiKeys[i].LastUsedAt = &usedAt
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
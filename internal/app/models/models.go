@@ -1,8 +1,21 @@
 package models
 
+import "time"
+
+// LinkOptions содержит необязательные параметры сокращённой ссылки.
+type LinkOptions struct {
+	ExpiresIn    int64      `json:"expires_in,omitempty"`    // Время жизни ссылки в секундах.
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`    // Момент, после которого ссылка перестаёт работать.
+	RedirectType int        `json:"redirect_type,omitempty"` // Код ответа при переходе: 301, 302, 307 или 308, 0 - значение по умолчанию сервера.
+	ForwardQuery string     `json:"forward_query,omitempty"` // Передача параметров запроса посетителя: merge или override, пусто - не передавать.
+	ForwardPath  bool       `json:"forward_path,omitempty"`  // Дописывать к адресу назначения остаток пути после короткого ключа.
+}
+
 // ShortURLCreateDTO представляет структуру запроса на создание сокращённого URL.
 type ShortURLCreateDTO struct {
-	URL string `json:"url"` // Исходный URL, который нужно сократить.
+	URL   string `json:"url"`             // Исходный URL, который нужно сократить.
+	Alias string `json:"alias,omitempty"` // Пользовательский алиас (необязательный).
+	LinkOptions
 }
 
 // ShortURLReadDTO содержит результат успешного создания сокращённого URL.
@@ -10,18 +23,42 @@ type ShortURLReadDTO struct {
 	Result string `json:"result"` // Сокращённый URL.
 }
 
+// ShortURLUpdateDTO представляет структуру запроса на изменение адреса назначения сокращённого URL.
+type ShortURLUpdateDTO struct {
+	URL          string `json:"url,omitempty"`           // Новый исходный URL, пустой - не изменять.
+	RedirectType int    `json:"redirect_type,omitempty"` // Новый код ответа при переходе, 0 - не изменять.
+}
+
 // SerializeData представляет структуру данных для сериализации URL пользователя.
 type SerializeData struct {
-	UserID      string `json:"user_id"`      // Уникальный идентификатор пользователя.
-	ShortURL    string `json:"short_url"`    // Сокращённый URL.
-	OriginalURL string `json:"original_url"` // Исходный URL.
+	UserID       string       `json:"user_id"`                 // Уникальный идентификатор пользователя.
+	ShortURL     string       `json:"short_url"`               // Сокращённый URL.
+	OriginalURL  string       `json:"original_url"`            // Исходный URL.
+	ExpiresAt    *time.Time   `json:"expires_at,omitempty"`    // Момент истечения срока действия ссылки.
+	IsDeleted    bool         `json:"is_deleted,omitempty"`    // Признак удалённой ссылки.
+	DeletedAt    *time.Time   `json:"deleted_at,omitempty"`    // Момент удаления ссылки.
+	CreatedAt    *time.Time   `json:"created_at,omitempty"`    // Момент создания ссылки.
+	RedirectType int          `json:"redirect_type,omitempty"` // Код ответа при переходе, 0 - значение по умолчанию сервера.
+	ForwardQuery string       `json:"forward_query,omitempty"` // Режим передачи параметров запроса посетителя.
+	ForwardPath  bool         `json:"forward_path,omitempty"`  // Признак передачи остатка пути.
+	Op           string       `json:"op,omitempty"`            // Операция записи журнала: set, delete, restore, purge, sequence, job или job_purge (в снапшоте не заполняется).
+	Sequence     uint64       `json:"sequence,omitempty"`      // Выданное значение счётчика ключей для операции sequence.
+	Job          *DeletionJob `json:"job,omitempty"`           // Задача удаления для операции job и строк задач в снапшоте.
+}
+
+// SnapshotHeader представляет заголовок файла снапшота с версией формата.
+type SnapshotHeader struct {
+	Version  int    `json:"version"`            // Версия формата снапшота.
+	Sequence uint64 `json:"sequence,omitempty"` // Последнее выданное значение счётчика ключей.
 }
 
 // BatchShortURLCreateDTO представляет структуру для пакетного создания сокращённых URL.
 type BatchShortURLCreateDTO struct {
-	CorrelationID string `json:"correlation_id"` // Уникальный идентификатор запроса (используется клиентом для сопоставления).
-	OriginalURL   string `json:"original_url"`   // Исходный URL.
+	CorrelationID string `json:"correlation_id"`  // Уникальный идентификатор запроса (используется клиентом для сопоставления).
+	OriginalURL   string `json:"original_url"`    // Исходный URL.
+	Alias         string `json:"alias,omitempty"` // Пользовательский алиас (необязательный).
 	ShortURL      string // Сокращённый URL (не сериализуется в JSON).
+	LinkOptions
 }
 
 // BatchShortURLReadDTO содержит результат пакетного создания сокращённых URL.
@@ -32,12 +69,158 @@ type BatchShortURLReadDTO struct {
 
 // UserShortURLReadDTO содержит данные о сокращённом URL, привязанном к пользователю.
 type UserShortURLReadDTO struct {
-	ShortURL    string `json:"short_url"`    // Сокращённый URL.
-	OriginalURL string `json:"original_url"` // Исходный URL.
+	ShortURL     string `json:"short_url"`               // Сокращённый URL.
+	OriginalURL  string `json:"original_url"`            // Исходный URL.
+	RedirectType int    `json:"redirect_type,omitempty"` // Код ответа при переходе, заполняется только в ответе на изменение ссылки.
 }
 
 // StatsDTO представляет статистику по сервису.
 type StatsDTO struct {
-	URLs  int `json:"urls"`  // Количество сокращённых URL
-	Users int `json:"users"` // Количество пользователей
+	URLs  int            `json:"urls"`            // Количество сокращённых URL
+	Users int            `json:"users"`           // Количество пользователей
+	Cache *CacheStatsDTO `json:"cache,omitempty"` // Статистика кэша ссылок, если он включён
+}
+
+// CacheStatsDTO представляет статистику кэша ссылок.
+type CacheStatsDTO struct {
+	Hits   int64 `json:"hits"`   // Количество попаданий
+	Misses int64 `json:"misses"` // Количество промахов
+	Size   int   `json:"size"`   // Текущее количество записей
+}
+
+// ClickEvent описывает один переход по сокращённой ссылке.
+type ClickEvent struct {
+	ShortURL  string    // Короткий ключ ссылки.
+	Timestamp time.Time // Время перехода.
+	Referrer  string    // Значение заголовка Referer.
+	UserAgent string    // Значение заголовка User-Agent.
+	IP        string    // IP-адрес клиента.
+}
+
+// DailyClicksDTO содержит количество переходов по ссылке за один день.
+type DailyClicksDTO struct {
+	Date   string `json:"date"`   // Дата в формате YYYY-MM-DD (UTC).
+	Clicks int    `json:"clicks"` // Количество переходов за день.
+}
+
+// URLStatsDTO содержит статистику переходов по сокращённой ссылке.
+type URLStatsDTO struct {
+	ShortURL       string           `json:"short_url"`       // Короткий ключ ссылки.
+	Clicks         int              `json:"clicks"`          // Общее количество переходов.
+	UniqueVisitors int              `json:"unique_visitors"` // Количество уникальных посетителей (по IP-адресу).
+	Daily          []DailyClicksDTO `json:"daily"`           // Количество переходов по дням.
+}
+
+// RedirectDTO содержит всё необходимое для перенаправления по сокращённой ссылке.
+type RedirectDTO struct {
+	URL          string     // Оригинальный URL.
+	Type         int        // Код ответа при переходе, 0 - значение по умолчанию сервера.
+	ForwardQuery string     // Режим передачи параметров запроса посетителя.
+	ForwardPath  bool       // Признак передачи остатка пути после короткого ключа.
+	ExpiresAt    *time.Time // Момент истечения срока действия ссылки, nil - ссылка бессрочная.
+}
+
+// DeletionTask описывает удаление списка ссылок одного пользователя.
+type DeletionTask struct {
+	UserID    string   // Уникальный идентификатор пользователя.
+	ShortURLs []string // Короткие ключи удаляемых ссылок.
+}
+
+// Статусы задачи удаления ссылок.
+const (
+	DeletionJobPending = "pending" // Задача ожидает выполнения в очереди.
+	DeletionJobDone    = "done"    // Ссылки удалены.
+	DeletionJobFailed  = "failed"  // Удаление завершилось ошибкой.
+)
+
+// DeletionJobDTO описывает состояние задачи асинхронного удаления ссылок.
+type DeletionJobDTO struct {
+	ID         string     `json:"id"`                    // Идентификатор задачи.
+	Status     string     `json:"status"`                // Статус задачи: pending, done или failed.
+	URLs       int        `json:"urls"`                  // Количество ссылок в задаче.
+	CreatedAt  time.Time  `json:"created_at"`            // Момент постановки задачи в очередь.
+	FinishedAt *time.Time `json:"finished_at,omitempty"` // Момент завершения задачи.
+	Error      string     `json:"error,omitempty"`       // Текст ошибки для задачи со статусом failed.
+}
+
+// DeletionJob - задача удаления ссылок вместе с её состоянием в том виде, в котором она сохраняется в хранилище.
+type DeletionJob struct {
+	DeletionJobDTO
+	UserID    string   `json:"user_id"`    // Уникальный идентификатор пользователя.
+	ShortURLs []string `json:"short_urls"` // Короткие ключи удаляемых ссылок.
+}
+
+// DeletionJobCreatedDTO - ответ на запрос удаления ссылок с идентификатором поставленной задачи.
+type DeletionJobCreatedDTO struct {
+	JobID string `json:"job_id"` // Идентификатор задачи удаления.
+}
+
+// UserURLsQuery описывает параметры выборки ссылок пользователя.
+type UserURLsQuery struct {
+	Limit  int    // Максимальное количество ссылок на странице, 0 - без ограничения.
+	Cursor string // Непрозрачный курсор, полученный вместе с предыдущей страницей.
+	Sort   string // Поле сортировки: created_at, short_url или original_url.
+	Order  string // Направление сортировки: asc или desc.
+	Query  string // Подстрока оригинального URL без учёта регистра.
+}
+
+// UserURLsPageDTO содержит страницу ссылок пользователя.
+type UserURLsPageDTO struct {
+	URLs       []UserShortURLReadDTO // Ссылки страницы.
+	NextCursor string                // Курсор следующей страницы, пустой на последней странице.
+}
+
+// Состояния ссылки в сведениях о ней.
+const (
+	URLStatusActive  = "active"  // Ссылка работает.
+	URLStatusExpired = "expired" // Срок действия ссылки истёк.
+)
+
+// URLInfoDTO содержит сведения о сокращённой ссылке, возвращаемые без перехода по ней.
+type URLInfoDTO struct {
+	ShortURL     string     `json:"short_url"`               // Сокращённый URL.
+	OriginalURL  string     `json:"original_url"`            // Оригинальный URL.
+	Status       string     `json:"status"`                  // Состояние ссылки: active или expired.
+	CreatedAt    *time.Time `json:"created_at,omitempty"`    // Момент создания ссылки, виден только владельцу.
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`    // Момент истечения срока действия ссылки.
+	Clicks       int        `json:"clicks"`                  // Общее количество переходов.
+	RedirectType int        `json:"redirect_type"`           // Код ответа при переходе по ссылке.
+	ForwardQuery string     `json:"forward_query,omitempty"` // Режим передачи параметров запроса посетителя.
+	ForwardPath  bool       `json:"forward_path,omitempty"`  // Признак передачи остатка пути.
+	UserID       string     `json:"-"`                       // Владелец ссылки.
+}
+
+// APIKey описывает ключ API, с которым машинные клиенты обращаются к сервису без куки.
+// Сам ключ не хранится: запись находится по его хэшу.
+type APIKey struct {
+	ID         string     `json:"id"`                     // Идентификатор ключа.
+	UserID     string     `json:"user_id"`                // Владелец ключа.
+	Name       string     `json:"name"`                   // Название ключа, заданное пользователем.
+	Prefix     string     `json:"prefix"`                 // Начало ключа, по которому его можно узнать в списке.
+	Hash       string     `json:"hash"`                   // Хэш ключа.
+	Scopes     []string   `json:"scopes"`                 // Разрешённые действия: read, create, delete.
+	CreatedAt  time.Time  `json:"created_at"`             // Момент создания ключа.
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"` // Момент последнего использования ключа.
+}
+
+// APIKeyCreateDTO представляет структуру запроса на создание ключа API.
+type APIKeyCreateDTO struct {
+	Name   string   `json:"name"`   // Название ключа.
+	Scopes []string `json:"scopes"` // Разрешённые действия.
+}
+
+// APIKeyReadDTO содержит сведения о ключе API без самого ключа и его хэша.
+type APIKeyReadDTO struct {
+	ID         string     `json:"id"`                     // Идентификатор ключа.
+	Name       string     `json:"name"`                   // Название ключа.
+	Prefix     string     `json:"prefix"`                 // Начало ключа.
+	Scopes     []string   `json:"scopes"`                 // Разрешённые действия.
+	CreatedAt  time.Time  `json:"created_at"`             // Момент создания ключа.
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"` // Момент последнего использования ключа.
+}
+
+// APIKeyCreatedDTO содержит созданный ключ API. Сам ключ показывается только в этом ответе.
+type APIKeyCreatedDTO struct {
+	APIKeyReadDTO
+	Key string `json:"key"` // Ключ, который клиент передаёт в заголовке Authorization.
 }
@@ -19,13 +19,18 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	URLShortener_Shorten_FullMethodName        = "/urlshortener.URLShortener/Shorten"
-	URLShortener_BatchShorten_FullMethodName   = "/urlshortener.URLShortener/BatchShorten"
-	URLShortener_GetUserURLs_FullMethodName    = "/urlshortener.URLShortener/GetUserURLs"
-	URLShortener_DeleteUserURLs_FullMethodName = "/urlshortener.URLShortener/DeleteUserURLs"
-	URLShortener_Ping_FullMethodName           = "/urlshortener.URLShortener/Ping"
-	URLShortener_GetStats_FullMethodName       = "/urlshortener.URLShortener/GetStats"
-	URLShortener_GetOriginalURL_FullMethodName = "/urlshortener.URLShortener/GetOriginalURL"
+	URLShortener_Shorten_FullMethodName             = "/urlshortener.URLShortener/Shorten"
+	URLShortener_BatchShorten_FullMethodName        = "/urlshortener.URLShortener/BatchShorten"
+	URLShortener_GetUserURLs_FullMethodName         = "/urlshortener.URLShortener/GetUserURLs"
+	URLShortener_LookupByOriginalURL_FullMethodName = "/urlshortener.URLShortener/LookupByOriginalURL"
+	URLShortener_DeleteUserURLs_FullMethodName      = "/urlshortener.URLShortener/DeleteUserURLs"
+	URLShortener_GetDeletionJob_FullMethodName      = "/urlshortener.URLShortener/GetDeletionJob"
+	URLShortener_RestoreUserURLs_FullMethodName     = "/urlshortener.URLShortener/RestoreUserURLs"
+	URLShortener_UpdateURL_FullMethodName           = "/urlshortener.URLShortener/UpdateURL"
+	URLShortener_Ping_FullMethodName                = "/urlshortener.URLShortener/Ping"
+	URLShortener_GetStats_FullMethodName            = "/urlshortener.URLShortener/GetStats"
+	URLShortener_GetOriginalURL_FullMethodName      = "/urlshortener.URLShortener/GetOriginalURL"
+	URLShortener_GetURLStats_FullMethodName         = "/urlshortener.URLShortener/GetURLStats"
 )
 
 // URLShortenerClient is the client API for URLShortener service.
@@ -35,10 +40,15 @@ type URLShortenerClient interface {
 	Shorten(ctx context.Context, in *ShortenRequest, opts ...grpc.CallOption) (*ShortenResponse, error)
 	BatchShorten(ctx context.Context, in *BatchShortenRequest, opts ...grpc.CallOption) (*BatchShortenResponse, error)
 	GetUserURLs(ctx context.Context, in *UserURLsRequest, opts ...grpc.CallOption) (*UserURLsResponse, error)
+	LookupByOriginalURL(ctx context.Context, in *LookupByOriginalURLRequest, opts ...grpc.CallOption) (*LookupByOriginalURLResponse, error)
 	DeleteUserURLs(ctx context.Context, in *DeleteURLsRequest, opts ...grpc.CallOption) (*DeleteURLsResponse, error)
+	GetDeletionJob(ctx context.Context, in *DeletionJobRequest, opts ...grpc.CallOption) (*DeletionJobResponse, error)
+	RestoreUserURLs(ctx context.Context, in *RestoreURLsRequest, opts ...grpc.CallOption) (*RestoreURLsResponse, error)
+	UpdateURL(ctx context.Context, in *UpdateURLRequest, opts ...grpc.CallOption) (*UpdateURLResponse, error)
 	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
 	GetStats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
 	GetOriginalURL(ctx context.Context, in *GetOriginalURLRequest, opts ...grpc.CallOption) (*GetOriginalURLResponse, error)
+	GetURLStats(ctx context.Context, in *URLStatsRequest, opts ...grpc.CallOption) (*URLStatsResponse, error)
 }
 
 type uRLShortenerClient struct {
@@ -76,6 +86,15 @@ func (c *uRLShortenerClient) GetUserURLs(ctx context.Context, in *UserURLsReques
 	return out, nil
 }
 
+func (c *uRLShortenerClient) LookupByOriginalURL(ctx context.Context, in *LookupByOriginalURLRequest, opts ...grpc.CallOption) (*LookupByOriginalURLResponse, error) {
+	out := new(LookupByOriginalURLResponse)
+	err := c.cc.Invoke(ctx, URLShortener_LookupByOriginalURL_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *uRLShortenerClient) DeleteUserURLs(ctx context.Context, in *DeleteURLsRequest, opts ...grpc.CallOption) (*DeleteURLsResponse, error) {
 	out := new(DeleteURLsResponse)
 	err := c.cc.Invoke(ctx, URLShortener_DeleteUserURLs_FullMethodName, in, out, opts...)
@@ -85,6 +104,33 @@ func (c *uRLShortenerClient) DeleteUserURLs(ctx context.Context, in *DeleteURLsR
 	return out, nil
 }
 
+func (c *uRLShortenerClient) GetDeletionJob(ctx context.Context, in *DeletionJobRequest, opts ...grpc.CallOption) (*DeletionJobResponse, error) {
+	out := new(DeletionJobResponse)
+	err := c.cc.Invoke(ctx, URLShortener_GetDeletionJob_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) RestoreUserURLs(ctx context.Context, in *RestoreURLsRequest, opts ...grpc.CallOption) (*RestoreURLsResponse, error) {
+	out := new(RestoreURLsResponse)
+	err := c.cc.Invoke(ctx, URLShortener_RestoreUserURLs_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) UpdateURL(ctx context.Context, in *UpdateURLRequest, opts ...grpc.CallOption) (*UpdateURLResponse, error) {
+	out := new(UpdateURLResponse)
+	err := c.cc.Invoke(ctx, URLShortener_UpdateURL_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *uRLShortenerClient) Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error) {
 	out := new(PingResponse)
 	err := c.cc.Invoke(ctx, URLShortener_Ping_FullMethodName, in, out, opts...)
@@ -112,6 +158,15 @@ func (c *uRLShortenerClient) GetOriginalURL(ctx context.Context, in *GetOriginal
 	return out, nil
 }
 
+func (c *uRLShortenerClient) GetURLStats(ctx context.Context, in *URLStatsRequest, opts ...grpc.CallOption) (*URLStatsResponse, error) {
+	out := new(URLStatsResponse)
+	err := c.cc.Invoke(ctx, URLShortener_GetURLStats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // URLShortenerServer is the server API for URLShortener service.
 // All implementations must embed UnimplementedURLShortenerServer
 // for forward compatibility
@@ -119,10 +174,15 @@ type URLShortenerServer interface {
 	Shorten(context.Context, *ShortenRequest) (*ShortenResponse, error)
 	BatchShorten(context.Context, *BatchShortenRequest) (*BatchShortenResponse, error)
 	GetUserURLs(context.Context, *UserURLsRequest) (*UserURLsResponse, error)
+	LookupByOriginalURL(context.Context, *LookupByOriginalURLRequest) (*LookupByOriginalURLResponse, error)
 	DeleteUserURLs(context.Context, *DeleteURLsRequest) (*DeleteURLsResponse, error)
+	GetDeletionJob(context.Context, *DeletionJobRequest) (*DeletionJobResponse, error)
+	RestoreUserURLs(context.Context, *RestoreURLsRequest) (*RestoreURLsResponse, error)
+	UpdateURL(context.Context, *UpdateURLRequest) (*UpdateURLResponse, error)
 	Ping(context.Context, *PingRequest) (*PingResponse, error)
 	GetStats(context.Context, *StatsRequest) (*StatsResponse, error)
 	GetOriginalURL(context.Context, *GetOriginalURLRequest) (*GetOriginalURLResponse, error)
+	GetURLStats(context.Context, *URLStatsRequest) (*URLStatsResponse, error)
 	mustEmbedUnimplementedURLShortenerServer()
 }
 
@@ -139,9 +199,21 @@ func (UnimplementedURLShortenerServer) BatchShorten(context.Context, *BatchShort
 func (UnimplementedURLShortenerServer) GetUserURLs(context.Context, *UserURLsRequest) (*UserURLsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUserURLs not implemented")
 }
+func (UnimplementedURLShortenerServer) LookupByOriginalURL(context.Context, *LookupByOriginalURLRequest) (*LookupByOriginalURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LookupByOriginalURL not implemented")
+}
 func (UnimplementedURLShortenerServer) DeleteUserURLs(context.Context, *DeleteURLsRequest) (*DeleteURLsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteUserURLs not implemented")
 }
+func (UnimplementedURLShortenerServer) GetDeletionJob(context.Context, *DeletionJobRequest) (*DeletionJobResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDeletionJob not implemented")
+}
+func (UnimplementedURLShortenerServer) RestoreUserURLs(context.Context, *RestoreURLsRequest) (*RestoreURLsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreUserURLs not implemented")
+}
+func (UnimplementedURLShortenerServer) UpdateURL(context.Context, *UpdateURLRequest) (*UpdateURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateURL not implemented")
+}
 func (UnimplementedURLShortenerServer) Ping(context.Context, *PingRequest) (*PingResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Ping not implemented")
 }
@@ -151,6 +223,9 @@ func (UnimplementedURLShortenerServer) GetStats(context.Context, *StatsRequest)
 func (UnimplementedURLShortenerServer) GetOriginalURL(context.Context, *GetOriginalURLRequest) (*GetOriginalURLResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetOriginalURL not implemented")
 }
+func (UnimplementedURLShortenerServer) GetURLStats(context.Context, *URLStatsRequest) (*URLStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetURLStats not implemented")
+}
 func (UnimplementedURLShortenerServer) mustEmbedUnimplementedURLShortenerServer() {}
 
 // UnsafeURLShortenerServer may be embedded to opt out of forward compatibility for this service.
@@ -218,6 +293,24 @@ func _URLShortener_GetUserURLs_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _URLShortener_LookupByOriginalURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LookupByOriginalURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).LookupByOriginalURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_LookupByOriginalURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).LookupByOriginalURL(ctx, req.(*LookupByOriginalURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _URLShortener_DeleteUserURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(DeleteURLsRequest)
 	if err := dec(in); err != nil {
@@ -236,6 +329,60 @@ func _URLShortener_DeleteUserURLs_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _URLShortener_GetDeletionJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletionJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).GetDeletionJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_GetDeletionJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).GetDeletionJob(ctx, req.(*DeletionJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_RestoreUserURLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreURLsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).RestoreUserURLs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_RestoreUserURLs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).RestoreUserURLs(ctx, req.(*RestoreURLsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_UpdateURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).UpdateURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_UpdateURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).UpdateURL(ctx, req.(*UpdateURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _URLShortener_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(PingRequest)
 	if err := dec(in); err != nil {
@@ -290,6 +437,24 @@ func _URLShortener_GetOriginalURL_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _URLShortener_GetURLStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(URLStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).GetURLStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_GetURLStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).GetURLStats(ctx, req.(*URLStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // URLShortener_ServiceDesc is the grpc.ServiceDesc for URLShortener service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -309,10 +474,26 @@ var URLShortener_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetUserURLs",
 			Handler:    _URLShortener_GetUserURLs_Handler,
 		},
+		{
+			MethodName: "LookupByOriginalURL",
+			Handler:    _URLShortener_LookupByOriginalURL_Handler,
+		},
 		{
 			MethodName: "DeleteUserURLs",
 			Handler:    _URLShortener_DeleteUserURLs_Handler,
 		},
+		{
+			MethodName: "GetDeletionJob",
+			Handler:    _URLShortener_GetDeletionJob_Handler,
+		},
+		{
+			MethodName: "RestoreUserURLs",
+			Handler:    _URLShortener_RestoreUserURLs_Handler,
+		},
+		{
+			MethodName: "UpdateURL",
+			Handler:    _URLShortener_UpdateURL_Handler,
+		},
 		{
 			MethodName: "Ping",
 			Handler:    _URLShortener_Ping_Handler,
@@ -325,6 +506,10 @@ var URLShortener_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetOriginalURL",
 			Handler:    _URLShortener_GetOriginalURL_Handler,
 		},
+		{
+			MethodName: "GetURLStats",
+			Handler:    _URLShortener_GetURLStats_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "internal/app/proto/urlshortener.proto",
@@ -4,8 +4,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/shekshuev/shortener/internal/app/apikey"
+	"github.com/shekshuev/shortener/internal/app/clicks"
 	"github.com/shekshuev/shortener/internal/app/config"
+	"github.com/shekshuev/shortener/internal/app/deletion"
+	"github.com/shekshuev/shortener/internal/app/keygen"
+	"github.com/shekshuev/shortener/internal/app/logger"
 	"github.com/shekshuev/shortener/internal/app/models"
 	"github.com/shekshuev/shortener/internal/app/store"
 	"github.com/shekshuev/shortener/internal/utils"
@@ -13,59 +24,165 @@ import (
 
 // Service - интерфейс для работы с URL.
 type Service interface {
-	CreateShortURL(ctx context.Context, longURL, userID string) (string, error)
+	CreateShortURL(ctx context.Context, createDTO models.ShortURLCreateDTO, userID string) (string, error)
 	BatchCreateShortURL(ctx context.Context, createDTO []models.BatchShortURLCreateDTO, userID string) ([]models.BatchShortURLReadDTO, error)
 	GetLongURL(ctx context.Context, shortURL string) (string, error)
-	GetUserURLs(ctx context.Context, userID string) ([]models.UserShortURLReadDTO, error)
-	DeleteURLs(ctx context.Context, userID string, urls []string)
+	GetRedirect(ctx context.Context, shortURL, extraPath, rawQuery string) (models.RedirectDTO, error)
+	ExpandURL(ctx context.Context, userID, shortURL string) (models.URLInfoDTO, error)
+	GetUserURLs(ctx context.Context, userID string, query models.UserURLsQuery) (models.UserURLsPageDTO, error)
+	LookupURLs(ctx context.Context, userID, originalURL string) ([]models.UserShortURLReadDTO, error)
+	DeleteURLs(ctx context.Context, userID string, urls []string) (string, error)
+	GetDeletionJob(ctx context.Context, userID, jobID string) (models.DeletionJobDTO, error)
+	RestoreURLs(ctx context.Context, userID string, urls []string) ([]string, error)
+	UpdateURL(ctx context.Context, userID, shortURL string, updateDTO models.ShortURLUpdateDTO) (models.UserShortURLReadDTO, error)
 	CheckDBConnection(ctx context.Context) error
 	GetStats(ctx context.Context) (models.StatsDTO, error)
+	RecordClick(event models.ClickEvent)
+	GetURLStats(ctx context.Context, userID, shortURL string) (models.URLStatsDTO, error)
+	CreateAPIKey(ctx context.Context, userID string, createDTO models.APIKeyCreateDTO) (models.APIKeyCreatedDTO, error)
+	GetAPIKeys(ctx context.Context, userID string) ([]models.APIKeyReadDTO, error)
+	RevokeAPIKey(ctx context.Context, userID, id string) error
+	AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, error)
 }
 
 // URLService - реализация сервиса для управления URL.
 type URLService struct {
-	store store.URLStore
-	cfg   *config.Config
+	store     store.URLStore
+	cfg       *config.Config
+	recorder  *clicks.Recorder
+	deletions *deletion.Queue
+	jobs      deletion.JobStore
+	keygen    keygen.KeyGenerator
+}
+
+// Option - необязательная настройка URLService.
+type Option func(*URLService)
+
+// WithKeyGenerator задаёт генератор коротких ключей вместо генератора случайных ключей по умолчанию.
+func WithKeyGenerator(gen keygen.KeyGenerator) Option {
+	return func(s *URLService) {
+		s.keygen = gen
+	}
+}
+
+// WithDeletionJobStore задаёт хранилище задач удаления, чтобы задачи и их состояние переживали перезапуск.
+func WithDeletionJobStore(jobs deletion.JobStore) Option {
+	return func(s *URLService) {
+		s.jobs = jobs
+	}
 }
 
+// maxKeyAttempts - количество попыток сгенерировать свободный ключ при коллизиях.
+const maxKeyAttempts = 5
+
 // ErrNotPostgresStore - ошибка, указывающая на использование in-memory хранилища вместо Postgres.
 var ErrNotPostgresStore = fmt.Errorf("app using in-memory store, not postgres")
 
 // NewURLService создаёт новый экземпляр URLService.
-func NewURLService(store store.URLStore, cfg *config.Config) *URLService {
-	return &URLService{store: store, cfg: cfg}
+func NewURLService(store store.URLStore, cfg *config.Config, opts ...Option) *URLService {
+	recorder := clicks.NewRecorder(store, clicks.DefaultBufferSize, clicks.DefaultBatchSize, clicks.DefaultFlushInterval)
+	s := &URLService{store: store, cfg: cfg, recorder: recorder, keygen: keygen.NewDefaultGenerator()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	var queueOpts []deletion.Option
+	if s.jobs != nil {
+		queueOpts = append(queueOpts, deletion.WithJobStore(s.jobs))
+	}
+	s.deletions = deletion.NewQueue(store, deletion.DefaultWorkers, deletion.DefaultBufferSize, deletion.DefaultBatchSize, deletion.DefaultFlushInterval, queueOpts...)
+	return s
 }
 
 // ErrFailedToShorten - ошибка при создании короткого URL.
 var ErrFailedToShorten = fmt.Errorf("failed to create short url")
 
-// CreateShortURL создаёт короткий URL.
-func (s *URLService) CreateShortURL(ctx context.Context, longURL, userID string) (string, error) {
-	shorted, err := utils.Shorten(longURL)
-	if err != nil {
-		return "", ErrFailedToShorten
-	}
-	shortURL, err := s.store.SetURL(ctx, shorted, longURL, userID)
-	if err != nil {
-		if errors.Is(err, store.ErrAlreadyExists) {
-			return fmt.Sprintf("%s/%s", s.cfg.BaseURL, shortURL), err
+// ErrInvalidExpiry - ошибка, указывающая на некорректный срок действия ссылки.
+var ErrInvalidExpiry = fmt.Errorf("expiry must be either a positive expires_in or a future expires_at")
+
+// ErrPathNotForwarded - ошибка, указывающая на переход с остатком пути по ссылке, которая не передаёт путь.
+var ErrPathNotForwarded = fmt.Errorf("short url does not forward paths")
+
+// CreateShortURL создаёт короткий URL. Если в запросе указан алиас, он используется в качестве ключа.
+// Параметры ссылки проверяются до генерации ключа, чтобы некорректный запрос не расходовал ключи.
+// При коллизии сгенерированного ключа или совпадении его с зарезервированным словом запрашивается
+// новый ключ, но не более maxKeyAttempts раз.
+func (s *URLService) CreateShortURL(ctx context.Context, createDTO models.ShortURLCreateDTO, userID string) (string, error) {
+	if len(createDTO.Alias) > 0 {
+		if err := utils.ValidateAlias(createDTO.Alias); err != nil {
+			return "", err
 		}
+	}
+	if err := resolveExpiry(&createDTO.LinkOptions, time.Now()); err != nil {
+		return "", err
+	}
+	if err := validateLinkOptions(createDTO.LinkOptions); err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("%s/%s", s.cfg.BaseURL, shorted), nil
+	for attempt := 0; ; attempt++ {
+		shorted, err := s.makeKey(ctx, createDTO.URL, createDTO.Alias, attempt)
+		if err != nil {
+			return "", err
+		}
+		var shortURL string
+		if utils.IsReservedAlias(shorted) {
+			err = store.ErrKeyTaken
+		} else {
+			shortURL, err = s.store.SetURL(ctx, shorted, createDTO.URL, userID, createDTO.LinkOptions)
+		}
+		if errors.Is(err, store.ErrKeyTaken) && len(createDTO.Alias) == 0 && attempt+1 < maxKeyAttempts {
+			continue
+		}
+		if err != nil {
+			if errors.Is(err, store.ErrAlreadyExists) {
+				return fmt.Sprintf("%s/%s", s.cfg.BaseURL, shortURL), err
+			}
+			return "", err
+		}
+		return fmt.Sprintf("%s/%s", s.cfg.BaseURL, shorted), nil
+	}
 }
 
 // BatchCreateShortURL создаёт несколько коротких URL в пакете.
+// При коллизии сгенерированных ключей или совпадении их с зарезервированными словами пакет повторяется
+// с новыми ключами, но не более maxKeyAttempts раз.
 func (s *URLService) BatchCreateShortURL(ctx context.Context, createDTO []models.BatchShortURLCreateDTO, userID string) ([]models.BatchShortURLReadDTO, error) {
+	hasGenerated := false
 	for i := 0; i < len(createDTO); i++ {
-		shorted, err := utils.Shorten(createDTO[i].OriginalURL)
-		if err != nil {
-			return nil, ErrFailedToShorten
+		if err := resolveExpiry(&createDTO[i].LinkOptions, time.Now()); err != nil {
+			return nil, err
+		}
+		if err := validateLinkOptions(createDTO[i].LinkOptions); err != nil {
+			return nil, err
+		}
+		if len(createDTO[i].Alias) > 0 {
+			if err := utils.ValidateAlias(createDTO[i].Alias); err != nil {
+				return nil, err
+			}
+		}
+		hasGenerated = hasGenerated || len(createDTO[i].Alias) == 0
+	}
+
+	var err error
+	for attempt := 0; attempt < maxKeyAttempts; attempt++ {
+		reserved := false
+		for i := 0; i < len(createDTO); i++ {
+			shorted, keyErr := s.makeKey(ctx, createDTO[i].OriginalURL, createDTO[i].Alias, attempt)
+			if keyErr != nil {
+				return nil, keyErr
+			}
+			createDTO[i].ShortURL = shorted
+			reserved = reserved || utils.IsReservedAlias(shorted)
+		}
+		if reserved {
+			err = store.ErrKeyTaken
+			continue
+		}
+		err = s.store.SetBatchURL(ctx, createDTO, userID)
+		if !errors.Is(err, store.ErrKeyTaken) || !hasGenerated {
+			break
 		}
-		createDTO[i].ShortURL = shorted
 	}
 
-	err := s.store.SetBatchURL(ctx, createDTO, userID)
 	readDTO := make([]models.BatchShortURLReadDTO, 0, len(createDTO))
 	for _, dto := range createDTO {
 		readDTO = append(readDTO, models.BatchShortURLReadDTO{
@@ -83,6 +200,63 @@ func (s *URLService) BatchCreateShortURL(ctx context.Context, createDTO []models
 	return readDTO, nil
 }
 
+// makeKey возвращает ключ для сокращённого URL: алиас, если он задан, иначе ключ от генератора.
+// Алиас должен быть проверен вызывающим кодом.
+func (s *URLService) makeKey(ctx context.Context, longURL, alias string, attempt int) (string, error) {
+	if len(alias) > 0 {
+		return alias, nil
+	}
+	shorted, err := s.keygen.Generate(ctx, longURL, attempt)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrFailedToShorten, err)
+	}
+	return shorted, nil
+}
+
+// resolveExpiry переводит относительный срок действия ссылки в абсолютный момент времени.
+// Допускается указать либо expires_in, либо expires_at, но не оба сразу.
+func resolveExpiry(opts *models.LinkOptions, now time.Time) error {
+	if opts.ExpiresIn < 0 || (opts.ExpiresIn > 0 && opts.ExpiresAt != nil) {
+		return ErrInvalidExpiry
+	}
+	if opts.ExpiresIn > 0 {
+		expiresAt := now.Add(time.Duration(opts.ExpiresIn) * time.Second)
+		opts.ExpiresAt = &expiresAt
+		opts.ExpiresIn = 0
+	}
+	if opts.ExpiresAt != nil && !opts.ExpiresAt.After(now) {
+		return ErrInvalidExpiry
+	}
+	return nil
+}
+
+// validateRedirectType проверяет код ответа при переходе, заданный для ссылки. 0 означает значение по умолчанию сервера.
+func validateRedirectType(redirectType int) error {
+	if redirectType == 0 {
+		return nil
+	}
+	return utils.ValidateRedirectType(redirectType)
+}
+
+// validateLinkOptions проверяет параметры перенаправления по создаваемой ссылке.
+func validateLinkOptions(opts models.LinkOptions) error {
+	if err := validateRedirectType(opts.RedirectType); err != nil {
+		return err
+	}
+	return utils.ValidateQueryForward(opts.ForwardQuery)
+}
+
+// resolveRedirectType возвращает код ответа при переходе по ссылке с учётом значения по умолчанию сервера.
+func (s *URLService) resolveRedirectType(redirectType int) int {
+	if redirectType != 0 {
+		return redirectType
+	}
+	if s.cfg.RedirectType != 0 {
+		return s.cfg.RedirectType
+	}
+	return http.StatusTemporaryRedirect
+}
+
 // GetLongURL возвращает оригинальный URL по короткому.
 func (s *URLService) GetLongURL(ctx context.Context, shortURL string) (string, error) {
 	longURL, err := s.store.GetURL(ctx, shortURL)
@@ -92,18 +266,113 @@ func (s *URLService) GetLongURL(ctx context.Context, shortURL string) (string, e
 	return longURL, nil
 }
 
-// GetUserURLs возвращает список URL пользователя.
-func (s *URLService) GetUserURLs(ctx context.Context, userID string) ([]models.UserShortURLReadDTO, error) {
-	readDTO, err := s.store.GetUserURLs(ctx, userID)
+// GetRedirect возвращает адрес и код ответа для перехода по короткой ссылке.
+// Остаток пути после ключа extraPath и параметры запроса rawQuery переносятся в адрес назначения,
+// если это разрешено параметрами ссылки; переход с остатком пути по ссылке, которая его не передаёт,
+// завершается ошибкой ErrPathNotForwarded. Для ссылки без собственного типа перенаправления
+// подставляется значение из конфигурации.
+func (s *URLService) GetRedirect(ctx context.Context, shortURL, extraPath, rawQuery string) (models.RedirectDTO, error) {
+	redirect, err := s.store.GetRedirect(ctx, shortURL)
+	if err != nil {
+		return models.RedirectDTO{}, err
+	}
+	if len(extraPath) > 0 && !redirect.ForwardPath {
+		return models.RedirectDTO{}, ErrPathNotForwarded
+	}
+	redirect.URL, err = utils.BuildRedirectURL(redirect.URL, extraPath, rawQuery, redirect.ForwardQuery)
+	if err != nil {
+		return models.RedirectDTO{}, err
+	}
+	redirect.Type = s.resolveRedirectType(redirect.Type)
+	return redirect, nil
+}
+
+// ExpandURL возвращает сведения о ссылке без перехода по ней. Момент создания виден только владельцу ссылки.
+// Для неизвестной ссылки возвращается store.ErrNotFound, для удалённой - store.ErrAlreadyDeleted.
+func (s *URLService) ExpandURL(ctx context.Context, userID, shortURL string) (models.URLInfoDTO, error) {
+	info, err := s.store.GetURLInfo(ctx, shortURL)
+	if err != nil {
+		return models.URLInfoDTO{}, err
+	}
+	info.ShortURL = fmt.Sprintf("%s/%s", s.cfg.BaseURL, shortURL)
+	info.Status = models.URLStatusActive
+	if info.ExpiresAt != nil && !info.ExpiresAt.After(time.Now()) {
+		info.Status = models.URLStatusExpired
+	}
+	if len(userID) == 0 || info.UserID != userID {
+		info.CreatedAt = nil
+	}
+	info.RedirectType = s.resolveRedirectType(info.RedirectType)
+	return info, nil
+}
+
+// GetUserURLs возвращает страницу URL пользователя согласно параметрам выборки.
+// Если на странице нет ни одного URL, возвращается store.ErrNotFound.
+func (s *URLService) GetUserURLs(ctx context.Context, userID string, query models.UserURLsQuery) (models.UserURLsPageDTO, error) {
+	page, err := s.store.GetUserURLsPage(ctx, userID, query)
+	if err != nil {
+		return models.UserURLsPageDTO{}, err
+	}
+	if len(page.URLs) == 0 {
+		return models.UserURLsPageDTO{}, store.ErrNotFound
+	}
+	return page, nil
+}
+
+// LookupURLs возвращает ссылки пользователя, ведущие на originalURL с точностью до нормализации URL.
+// Возвращает utils.ErrInvalidURL, если originalURL не является абсолютным URL.
+func (s *URLService) LookupURLs(ctx context.Context, userID, originalURL string) ([]models.UserShortURLReadDTO, error) {
+	if len(userID) == 0 {
+		return nil, store.ErrEmptyUserID
+	}
+	normalized, err := utils.NormalizeURL(strings.TrimSpace(originalURL))
 	if err != nil {
 		return nil, err
 	}
-	return readDTO, nil
+	return s.store.LookupUserURLs(ctx, userID, normalized)
+}
+
+// DeleteURLs ставит удаление списка URL пользователя в очередь и возвращает идентификатор задачи.
+// Удаление выполняется в фоне и не зависит от контекста запроса.
+func (s *URLService) DeleteURLs(ctx context.Context, userID string, urls []string) (string, error) {
+	if len(userID) == 0 {
+		return "", store.ErrEmptyUserID
+	}
+	if len(urls) == 0 {
+		return "", store.ErrEmptyURLs
+	}
+	return s.deletions.Enqueue(ctx, userID, urls)
 }
 
-// DeleteURLs удаляет список URL пользователя.
-func (s *URLService) DeleteURLs(ctx context.Context, userID string, urls []string) {
-	s.store.DeleteURLs(ctx, userID, urls)
+// GetDeletionJob возвращает состояние задачи удаления, поставленной пользователем.
+func (s *URLService) GetDeletionJob(ctx context.Context, userID, jobID string) (models.DeletionJobDTO, error) {
+	return s.deletions.Job(ctx, userID, jobID)
+}
+
+// RestoreURLs восстанавливает ссылки пользователя, удалённые не раньше, чем RestoreGracePeriod назад,
+// и возвращает ключи восстановленных ссылок.
+func (s *URLService) RestoreURLs(ctx context.Context, userID string, urls []string) ([]string, error) {
+	return s.store.RestoreURLs(ctx, userID, urls, time.Now().Add(-s.cfg.RestoreGracePeriod))
+}
+
+// UpdateURL изменяет адрес, на который ведёт короткая ссылка пользователя, и код ответа при переходе по ней.
+// Незаполненные поля updateDTO не меняются, но хотя бы одно из них должно быть задано.
+// Возвращает ссылку после изменения.
+func (s *URLService) UpdateURL(ctx context.Context, userID, shortURL string, updateDTO models.ShortURLUpdateDTO) (models.UserShortURLReadDTO, error) {
+	if len(updateDTO.URL) == 0 && updateDTO.RedirectType == 0 {
+		return models.UserShortURLReadDTO{}, store.ErrEmptyValue
+	}
+	if err := validateRedirectType(updateDTO.RedirectType); err != nil {
+		return models.UserShortURLReadDTO{}, err
+	}
+	if err := s.store.UpdateURL(ctx, userID, shortURL, updateDTO); err != nil {
+		return models.UserShortURLReadDTO{}, err
+	}
+	info, err := s.store.GetURLInfo(ctx, shortURL)
+	if err != nil {
+		return models.UserShortURLReadDTO{}, err
+	}
+	return models.UserShortURLReadDTO{ShortURL: fmt.Sprintf("%s/%s", s.cfg.BaseURL, shortURL), OriginalURL: info.OriginalURL, RedirectType: s.resolveRedirectType(info.RedirectType)}, nil
 }
 
 // CheckDBConnection проверяет соединение с базой данных.
@@ -114,7 +383,7 @@ func (s *URLService) CheckDBConnection(ctx context.Context) error {
 	return ErrNotPostgresStore
 }
 
-// GetStats возвращает статистику: количество URL и пользователей.
+// GetStats возвращает статистику: количество URL и пользователей, а также статистику кэша, если он включён.
 func (s *URLService) GetStats(ctx context.Context) (models.StatsDTO, error) {
 	urlsCount, err := s.store.CountURLs(ctx)
 	if err != nil {
@@ -126,8 +395,117 @@ func (s *URLService) GetStats(ctx context.Context) (models.StatsDTO, error) {
 		return models.StatsDTO{}, err
 	}
 
-	return models.StatsDTO{
+	stats := models.StatsDTO{
 		URLs:  urlsCount,
 		Users: usersCount,
-	}, nil
+	}
+	if provider, ok := s.store.(store.CacheStatsProvider); ok {
+		cacheStats := provider.CacheStats()
+		stats.Cache = &cacheStats
+	}
+	return stats, nil
+}
+
+// RecordClick асинхронно записывает событие перехода по ссылке.
+func (s *URLService) RecordClick(event models.ClickEvent) {
+	s.recorder.Record(event)
+}
+
+// GetURLStats возвращает статистику переходов по ссылке пользователя.
+func (s *URLService) GetURLStats(ctx context.Context, userID, shortURL string) (models.URLStatsDTO, error) {
+	return s.store.GetURLStats(ctx, userID, shortURL)
+}
+
+// apiKeyTouchInterval - как часто обновляется момент последнего использования ключа API.
+// Запись при каждом запросе нагружала бы хранилище, а точность до минуты достаточна.
+const apiKeyTouchInterval = time.Minute
+
+// CreateAPIKey создаёт ключ API пользователя с заданными названием и разрешениями.
+// Сам ключ возвращается только здесь, в хранилище попадает лишь его хэш.
+func (s *URLService) CreateAPIKey(ctx context.Context, userID string, createDTO models.APIKeyCreateDTO) (models.APIKeyCreatedDTO, error) {
+	name := strings.TrimSpace(createDTO.Name)
+	if len(name) == 0 {
+		return models.APIKeyCreatedDTO{}, apikey.ErrEmptyName
+	}
+	scopes, err := apikey.ValidateScopes(createDTO.Scopes)
+	if err != nil {
+		return models.APIKeyCreatedDTO{}, err
+	}
+	key, prefix, hash, err := apikey.Generate()
+	if err != nil {
+		return models.APIKeyCreatedDTO{}, err
+	}
+	record := models.APIKey{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		Hash:      hash,
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.store.CreateAPIKey(ctx, record); err != nil {
+		return models.APIKeyCreatedDTO{}, err
+	}
+	return models.APIKeyCreatedDTO{APIKeyReadDTO: apiKeyReadDTO(record), Key: key}, nil
+}
+
+// GetAPIKeys возвращает ключи API пользователя без самих ключей.
+func (s *URLService) GetAPIKeys(ctx context.Context, userID string) ([]models.APIKeyReadDTO, error) {
+	keys, err := s.store.GetUserAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	readDTO := make([]models.APIKeyReadDTO, 0, len(keys))
+	for _, key := range keys {
+		readDTO = append(readDTO, apiKeyReadDTO(key))
+	}
+	return readDTO, nil
+}
+
+// RevokeAPIKey отзывает ключ API пользователя. Отозванным ключом больше нельзя аутентифицироваться.
+func (s *URLService) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	return s.store.RevokeAPIKey(ctx, userID, id)
+}
+
+// AuthenticateAPIKey находит ключ API по его значению и отмечает его использование.
+// Для неизвестного ключа возвращается apikey.ErrInvalidKey.
+func (s *URLService) AuthenticateAPIKey(ctx context.Context, key string) (models.APIKey, error) {
+	if !apikey.IsAPIKey(key) {
+		return models.APIKey{}, apikey.ErrInvalidKey
+	}
+	record, err := s.store.GetAPIKeyByHash(ctx, apikey.Hash(key))
+	if errors.Is(err, store.ErrNotFound) {
+		return models.APIKey{}, apikey.ErrInvalidKey
+	}
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	now := time.Now().UTC()
+	if record.LastUsedAt == nil || now.Sub(*record.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.store.TouchAPIKey(ctx, record.ID, now); err != nil {
+			logger.NewLogger().Log.Error("Error updating api key last use", zap.String("id", record.ID), zap.Error(err))
+		} else {
+			record.LastUsedAt = &now
+		}
+	}
+	return record, nil
+}
+
+// apiKeyReadDTO возвращает сведения о ключе API, которые можно показать пользователю.
+func apiKeyReadDTO(key models.APIKey) models.APIKeyReadDTO {
+	return models.APIKeyReadDTO{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+	}
+}
+
+// Close дожидается выполнения поставленных в очередь удалений и записи накопленных событий переходов.
+func (s *URLService) Close() {
+	s.deletions.Close()
+	s.recorder.Close()
 }
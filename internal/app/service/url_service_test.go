@@ -4,11 +4,17 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/shekshuev/shortener/internal/app/apikey"
 	"github.com/shekshuev/shortener/internal/app/config"
 	"github.com/shekshuev/shortener/internal/app/mocks"
 	"github.com/shekshuev/shortener/internal/app/models"
+	"github.com/shekshuev/shortener/internal/app/store"
+	"github.com/shekshuev/shortener/internal/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -38,7 +44,7 @@ func TestURLService_CreateShortURL(t *testing.T) {
 	service := NewURLService(s, &cfg)
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			shortURL, err := service.CreateShortURL(context.Background(), tc.longURL, tc.userID)
+			shortURL, err := service.CreateShortURL(context.Background(), models.ShortURLCreateDTO{URL: tc.longURL}, tc.userID)
 			if tc.hasError {
 				assert.NotNil(t, err, "Error is nil")
 			} else {
@@ -49,6 +55,59 @@ func TestURLService_CreateShortURL(t *testing.T) {
 	}
 }
 
+func TestURLService_CreateShortURL_Alias(t *testing.T) {
+	testCases := []struct {
+		name        string
+		alias       string
+		expectedErr error
+	}{
+		{name: "Free alias", alias: "my-link", expectedErr: nil},
+		{name: "Taken alias", alias: "my-link", expectedErr: store.ErrKeyTaken},
+		{name: "Invalid alias", alias: "my link", expectedErr: utils.ErrInvalidAlias},
+		{name: "Reserved alias", alias: "api", expectedErr: utils.ErrReservedAlias},
+	}
+	cfg := config.GetConfig()
+	s := mocks.NewURLStore()
+	service := NewURLService(s, &cfg)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			createDTO := models.ShortURLCreateDTO{URL: "https://example.com", Alias: tc.alias}
+			shortURL, err := service.CreateShortURL(context.Background(), createDTO, "1")
+			assert.ErrorIs(t, err, tc.expectedErr)
+			if tc.expectedErr == nil {
+				assert.Equal(t, fmt.Sprintf("%s/%s", cfg.BaseURL, tc.alias), shortURL)
+			}
+		})
+	}
+}
+
+func TestURLService_CreateShortURL_Expiry(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	testCases := []struct {
+		name        string
+		opts        models.LinkOptions
+		expectedErr error
+	}{
+		{name: "Without expiry", opts: models.LinkOptions{}, expectedErr: nil},
+		{name: "Expires in", opts: models.LinkOptions{ExpiresIn: 60}, expectedErr: nil},
+		{name: "Expires at", opts: models.LinkOptions{ExpiresAt: &future}, expectedErr: nil},
+		{name: "Negative expires in", opts: models.LinkOptions{ExpiresIn: -1}, expectedErr: ErrInvalidExpiry},
+		{name: "Expires at in the past", opts: models.LinkOptions{ExpiresAt: &past}, expectedErr: ErrInvalidExpiry},
+		{name: "Both expires in and expires at", opts: models.LinkOptions{ExpiresIn: 60, ExpiresAt: &future}, expectedErr: ErrInvalidExpiry},
+	}
+	cfg := config.GetConfig()
+	s := mocks.NewURLStore()
+	service := NewURLService(s, &cfg)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			createDTO := models.ShortURLCreateDTO{URL: "https://example.com", LinkOptions: tc.opts}
+			_, err := service.CreateShortURL(context.Background(), createDTO, "1")
+			assert.ErrorIs(t, err, tc.expectedErr)
+		})
+	}
+}
+
 func TestURLService_BatchCreateShortURL(t *testing.T) {
 	shorted := "12345678"
 	testCases := []struct {
@@ -106,7 +165,7 @@ func TestURLService_GetLongURL(t *testing.T) {
 	cfg := config.GetConfig()
 	s := mocks.NewURLStore()
 	service := NewURLService(s, &cfg)
-	_, err := s.SetURL(context.Background(), shorted, longURL, userID)
+	_, err := s.SetURL(context.Background(), shorted, longURL, userID, models.LinkOptions{})
 	assert.Nil(t, err, "Set url store error is not nil")
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -121,6 +180,42 @@ func TestURLService_GetLongURL(t *testing.T) {
 	}
 }
 
+func TestURLService_RedirectType(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.RedirectType = http.StatusFound
+	s := mocks.NewURLStore()
+	service := NewURLService(s, &cfg)
+	ctx := context.Background()
+
+	_, err := service.CreateShortURL(ctx, models.ShortURLCreateDTO{URL: "https://ya.ru", Alias: "permanent", LinkOptions: models.LinkOptions{RedirectType: http.StatusMovedPermanently}}, "1")
+	assert.NoError(t, err)
+	_, err = service.CreateShortURL(ctx, models.ShortURLCreateDTO{URL: "https://google.com", Alias: "default"}, "1")
+	assert.NoError(t, err)
+	_, err = service.CreateShortURL(ctx, models.ShortURLCreateDTO{URL: "https://example.com", LinkOptions: models.LinkOptions{RedirectType: http.StatusOK}}, "1")
+	assert.ErrorIs(t, err, utils.ErrInvalidRedirectType)
+	_, err = service.BatchCreateShortURL(ctx, []models.BatchShortURLCreateDTO{{CorrelationID: "1", OriginalURL: "https://example.com", LinkOptions: models.LinkOptions{RedirectType: http.StatusSeeOther}}}, "1")
+	assert.ErrorIs(t, err, utils.ErrInvalidRedirectType)
+	_, err = service.CreateShortURL(ctx, models.ShortURLCreateDTO{URL: "https://example.com", LinkOptions: models.LinkOptions{ForwardQuery: "append"}}, "1")
+	assert.ErrorIs(t, err, utils.ErrInvalidQueryForward)
+
+	redirect, err := service.GetRedirect(ctx, "permanent", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.RedirectDTO{URL: "https://ya.ru", Type: http.StatusMovedPermanently}, redirect)
+	redirect, err = service.GetRedirect(ctx, "default", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusFound, redirect.Type, "Link without redirect type should use config default")
+	_, err = service.GetRedirect(ctx, "default", "guide", "")
+	assert.ErrorIs(t, err, ErrPathNotForwarded)
+
+	readDTO, err := service.UpdateURL(ctx, "1", "default", models.ShortURLUpdateDTO{RedirectType: http.StatusPermanentRedirect})
+	assert.NoError(t, err)
+	assert.Equal(t, models.UserShortURLReadDTO{ShortURL: cfg.BaseURL + "/default", OriginalURL: "https://google.com", RedirectType: http.StatusPermanentRedirect}, readDTO)
+	_, err = service.UpdateURL(ctx, "1", "default", models.ShortURLUpdateDTO{RedirectType: http.StatusOK})
+	assert.ErrorIs(t, err, utils.ErrInvalidRedirectType)
+	_, err = service.UpdateURL(ctx, "1", "default", models.ShortURLUpdateDTO{})
+	assert.ErrorIs(t, err, store.ErrEmptyValue)
+}
+
 func TestURLService_GetUserURLs(t *testing.T) {
 	longURL := "https://example.com"
 	shorted := "12345678"
@@ -137,21 +232,63 @@ func TestURLService_GetUserURLs(t *testing.T) {
 	cfg := config.GetConfig()
 	s := mocks.NewURLStore()
 	service := NewURLService(s, &cfg)
-	_, err := s.SetURL(context.Background(), shorted, longURL, userID)
+	_, err := s.SetURL(context.Background(), shorted, longURL, userID, models.LinkOptions{})
 	assert.Nil(t, err, "Set url store error is not nil")
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			readDTO, err := service.GetUserURLs(context.Background(), tc.getUserID)
+			page, err := service.GetUserURLs(context.Background(), tc.getUserID, models.UserURLsQuery{})
 			if tc.shorted == "non-existing" {
 				assert.NotNil(t, err, "Error is nil")
 			} else {
 				assert.Nil(t, err, "Error is not nil")
-				assert.Len(t, readDTO, 1)
+				assert.Len(t, page.URLs, 1)
 			}
 		})
 	}
 }
 
+func TestURLService_DeleteURLs(t *testing.T) {
+	shorted := "12345678"
+	userID := "1"
+	testCases := []struct {
+		name    string
+		userID  string
+		urls    []string
+		wantErr error
+	}{
+		{name: "Delete own url", userID: userID, urls: []string{shorted}},
+		{name: "Empty user", urls: []string{shorted}, wantErr: store.ErrEmptyUserID},
+		{name: "Empty urls", userID: userID, wantErr: store.ErrEmptyURLs},
+	}
+	cfg := config.GetConfig()
+	s := mocks.NewURLStore()
+	service := NewURLService(s, &cfg)
+	_, err := s.SetURL(context.Background(), shorted, "https://example.com", userID, models.LinkOptions{})
+	assert.Nil(t, err, "Set url store error is not nil")
+	var jobIDs []string
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jobID, err := service.DeleteURLs(context.Background(), tc.userID, tc.urls)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			job, err := service.GetDeletionJob(context.Background(), tc.userID, jobID)
+			assert.NoError(t, err)
+			assert.Equal(t, len(tc.urls), job.URLs)
+			jobIDs = append(jobIDs, jobID)
+		})
+	}
+
+	service.Close()
+	for _, jobID := range jobIDs {
+		job, err := service.GetDeletionJob(context.Background(), userID, jobID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.DeletionJobDone, job.Status, "Close should wait for queued deletions")
+	}
+}
+
 func TestURLService_CheckDBConnection(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -249,3 +386,158 @@ func TestURLService_GetStats(t *testing.T) {
 		})
 	}
 }
+
+// stubKeyGenerator возвращает ключи по номеру попытки и считает обращения к генератору.
+type stubKeyGenerator struct {
+	keys  []string
+	calls int
+}
+
+func (g *stubKeyGenerator) Generate(_ context.Context, _ string, attempt int) (string, error) {
+	g.calls++
+	if attempt >= len(g.keys) {
+		return g.keys[len(g.keys)-1], nil
+	}
+	return g.keys[attempt], nil
+}
+
+func TestURLService_CreateShortURL_RetryOnCollision(t *testing.T) {
+	testCases := []struct {
+		name        string
+		keys        []string
+		alias       string
+		expectedKey string
+		expectedErr error
+	}{
+		{name: "Free key after collision", keys: []string{"taken", "free"}, expectedKey: "free"},
+		{name: "Collisions on every attempt", keys: []string{"taken"}, expectedErr: store.ErrKeyTaken},
+		{name: "Alias is not retried", keys: []string{"free"}, alias: "taken", expectedErr: store.ErrKeyTaken},
+		{name: "Reserved key is skipped", keys: []string{"api", "free"}, expectedKey: "free"},
+		{name: "Reserved key in upper case is skipped", keys: []string{"PING", "taken", "free"}, expectedKey: "free"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.GetConfig()
+			s := mocks.NewURLStore()
+			_, err := s.SetURL(context.Background(), "taken", "https://google.com", "2", models.LinkOptions{})
+			assert.Nil(t, err)
+			service := NewURLService(s, &cfg, WithKeyGenerator(&stubKeyGenerator{keys: tc.keys}))
+			shortURL, err := service.CreateShortURL(context.Background(), models.ShortURLCreateDTO{URL: "https://ya.ru", Alias: tc.alias}, "1")
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, fmt.Sprintf("%s/%s", cfg.BaseURL, tc.expectedKey), shortURL)
+		})
+	}
+}
+
+func TestURLService_CreateShortURL_InvalidOptionsDoNotGenerateKeys(t *testing.T) {
+	cfg := config.GetConfig()
+	gen := &stubKeyGenerator{keys: []string{"free"}}
+	service := NewURLService(mocks.NewURLStore(), &cfg, WithKeyGenerator(gen))
+	ctx := context.Background()
+
+	_, err := service.CreateShortURL(ctx, models.ShortURLCreateDTO{URL: "https://ya.ru", LinkOptions: models.LinkOptions{RedirectType: http.StatusOK}}, "1")
+	assert.ErrorIs(t, err, utils.ErrInvalidRedirectType)
+	_, err = service.CreateShortURL(ctx, models.ShortURLCreateDTO{URL: "https://ya.ru", LinkOptions: models.LinkOptions{ExpiresIn: -1}}, "1")
+	assert.ErrorIs(t, err, ErrInvalidExpiry)
+	_, err = service.BatchCreateShortURL(ctx, []models.BatchShortURLCreateDTO{
+		{CorrelationID: "1", OriginalURL: "https://ya.ru"},
+		{CorrelationID: "2", OriginalURL: "https://google.com", Alias: "api"},
+	}, "1")
+	assert.ErrorIs(t, err, utils.ErrReservedAlias)
+	assert.Equal(t, 0, gen.calls, "Invalid requests should not consume keys")
+}
+
+func TestURLService_BatchCreateShortURL_RetryOnCollision(t *testing.T) {
+	cfg := config.GetConfig()
+	s := mocks.NewURLStore()
+	_, err := s.SetURL(context.Background(), "taken", "https://google.com", "2", models.LinkOptions{})
+	assert.Nil(t, err)
+	service := NewURLService(s, &cfg, WithKeyGenerator(&stubKeyGenerator{keys: []string{"taken", "api", "free"}}))
+	readDTO, err := service.BatchCreateShortURL(context.Background(), []models.BatchShortURLCreateDTO{
+		{CorrelationID: "1", OriginalURL: "https://ya.ru"},
+	}, "1")
+	assert.Nil(t, err)
+	if assert.Len(t, readDTO, 1) {
+		assert.Equal(t, fmt.Sprintf("%s/%s", cfg.BaseURL, "free"), readDTO[0].ShortURL)
+	}
+}
+
+func TestURLService_GetStats_Cache(t *testing.T) {
+	cfg := config.GetConfig()
+	mockStore := mocks.NewURLStore()
+	mockStore.On("CountURLs").Return(1, nil)
+	mockStore.On("CountUsers").Return(1, nil)
+	_, err := mockStore.SetURL(context.Background(), "short1", "https://ya.ru", "1", models.LinkOptions{})
+	assert.Nil(t, err)
+	cached := store.NewCachedURLStore(mockStore, 10, time.Minute)
+	service := NewURLService(cached, &cfg)
+
+	_, _ = service.GetLongURL(context.Background(), "short1")
+	_, _ = service.GetLongURL(context.Background(), "short1")
+
+	stats, err := service.GetStats(context.Background())
+	assert.Nil(t, err)
+	if assert.NotNil(t, stats.Cache, "Cache stats should be present") {
+		assert.Equal(t, models.CacheStatsDTO{Hits: 1, Misses: 1, Size: 1}, *stats.Cache)
+	}
+}
+
+func TestURLService_APIKeys(t *testing.T) {
+	cfg := config.GetConfig()
+	s := mocks.NewURLStore()
+	service := NewURLService(s, &cfg)
+	ctx := context.Background()
+
+	t.Run("Invalid create request", func(t *testing.T) {
+		_, err := service.CreateAPIKey(ctx, "1", models.APIKeyCreateDTO{Name: " ", Scopes: []string{apikey.ScopeRead}})
+		assert.ErrorIs(t, err, apikey.ErrEmptyName)
+		_, err = service.CreateAPIKey(ctx, "1", models.APIKeyCreateDTO{Name: "ci"})
+		assert.ErrorIs(t, err, apikey.ErrEmptyScopes)
+		_, err = service.CreateAPIKey(ctx, "1", models.APIKeyCreateDTO{Name: "ci", Scopes: []string{"admin"}})
+		assert.ErrorIs(t, err, apikey.ErrInvalidScope)
+	})
+
+	created, err := service.CreateAPIKey(ctx, "1", models.APIKeyCreateDTO{Name: " ci ", Scopes: []string{apikey.ScopeRead, apikey.ScopeCreate, apikey.ScopeRead}})
+	assert.NoError(t, err)
+	assert.Equal(t, "ci", created.Name)
+	assert.Equal(t, []string{apikey.ScopeCreate, apikey.ScopeRead}, created.Scopes)
+	assert.True(t, apikey.IsAPIKey(created.Key))
+	assert.True(t, strings.HasPrefix(created.Key, created.Prefix))
+
+	t.Run("List keys", func(t *testing.T) {
+		keys, err := service.GetAPIKeys(ctx, "1")
+		assert.NoError(t, err)
+		if assert.Len(t, keys, 1) {
+			assert.Equal(t, created.ID, keys[0].ID)
+		}
+		keys, err = service.GetAPIKeys(ctx, "2")
+		assert.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("Authenticate", func(t *testing.T) {
+		key, err := service.AuthenticateAPIKey(ctx, created.Key)
+		assert.NoError(t, err)
+		assert.Equal(t, "1", key.UserID)
+		assert.NotNil(t, key.LastUsedAt)
+		keys, err := service.GetAPIKeys(ctx, "1")
+		assert.NoError(t, err)
+		assert.NotNil(t, keys[0].LastUsedAt)
+
+		_, err = service.AuthenticateAPIKey(ctx, created.Key+"x")
+		assert.ErrorIs(t, err, apikey.ErrInvalidKey)
+		_, err = service.AuthenticateAPIKey(ctx, "not-a-key")
+		assert.ErrorIs(t, err, apikey.ErrInvalidKey)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		assert.ErrorIs(t, service.RevokeAPIKey(ctx, "2", created.ID), store.ErrNotFound)
+		assert.NoError(t, service.RevokeAPIKey(ctx, "1", created.ID))
+		_, err := service.AuthenticateAPIKey(ctx, created.Key)
+		assert.ErrorIs(t, err, apikey.ErrInvalidKey)
+	})
+}
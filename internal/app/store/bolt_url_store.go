@@ -0,0 +1,848 @@
+package store
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/shekshuev/shortener/internal/app/config"
+	"github.com/shekshuev/shortener/internal/app/deletion"
+	"github.com/shekshuev/shortener/internal/app/models"
+)
+
+// Бакеты файла BoltURLStore.
+var (
+	boltURLsBucket          = []byte("urls")           // Ссылки по короткому ключу.
+	boltUserKeysBucket      = []byte("user_keys")      // Индекс коротких ключей пользователя: вложенный бакет на каждого пользователя.
+	boltUserOriginalsBucket = []byte("user_originals") // Индекс оригинальных URL пользователя для дедупликации.
+	boltClicksBucket        = []byte("clicks")         // События переходов: вложенный бакет на каждую ссылку.
+	boltKeySequenceBucket   = []byte("key_sequence")   // Счётчик для последовательных стратегий генерации ключей.
+	boltAPIKeysBucket       = []byte("api_keys")       // Ключи API по идентификатору.
+	boltAPIKeyHashesBucket  = []byte("api_key_hashes") // Индекс идентификаторов ключей API по хэшу.
+	boltDeletionJobsBucket  = []byte("deletion_jobs")  // Задачи удаления ссылок по идентификатору.
+)
+
+// BoltURLStore - хранилище URL во встраиваемой базе bbolt в одном файле.
+// Каждая изменяющая операция выполняется в отдельной транзакции, которая сбрасывается на диск при фиксации.
+type BoltURLStore struct {
+	cfg *config.Config
+	db  *bolt.DB
+}
+
+// NewBoltURLStore открывает (или создаёт) файл базы по пути cfg.FileStoragePath и подготавливает бакеты.
+func NewBoltURLStore(cfg *config.Config) (*BoltURLStore, error) {
+	db, err := bolt.Open(cfg.FileStoragePath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltURLsBucket, boltUserKeysBucket, boltUserOriginalsBucket, boltClicksBucket, boltKeySequenceBucket, boltAPIKeysBucket, boltAPIKeyHashesBucket, boltDeletionJobsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltURLStore{cfg: cfg, db: db}, nil
+}
+
+// SetURL сохраняет новый URL в базе.
+// Если пользователь уже сокращал этот URL, возвращается существующий ключ и ErrAlreadyExists.
+func (s *BoltURLStore) SetURL(_ context.Context, key, value, userID string, opts models.LinkOptions) (string, error) {
+	if len(key) == 0 {
+		return "", ErrEmptyKey
+	}
+	if len(value) == 0 {
+		return "", ErrEmptyValue
+	}
+	if len(userID) == 0 {
+		return "", ErrEmptyUserID
+	}
+	var result string
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if existing, exists := boltFindUserURL(tx, userID, value); exists {
+			result = existing
+			return ErrAlreadyExists
+		}
+		if tx.Bucket(boltURLsBucket).Get([]byte(key)) != nil {
+			return ErrKeyTaken
+		}
+		result = key
+		now := time.Now()
+		return boltPutURL(tx, models.SerializeData{UserID: userID, ShortURL: key, OriginalURL: value, ExpiresAt: opts.ExpiresAt, CreatedAt: &now, RedirectType: opts.RedirectType, ForwardQuery: opts.ForwardQuery, ForwardPath: opts.ForwardPath})
+	})
+	if err == ErrAlreadyExists {
+		return result, err
+	}
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// SetBatchURL сохраняет пакет URL в одной транзакции.
+// Для URL, которые пользователь уже сокращал, в createDTO подставляется существующий ключ,
+// остальные URL сохраняются, а в конце возвращается ErrAlreadyExists.
+func (s *BoltURLStore) SetBatchURL(_ context.Context, createDTO []models.BatchShortURLCreateDTO, userID string) error {
+	if len(userID) == 0 {
+		return ErrEmptyUserID
+	}
+	if createDTO == nil {
+		return ErrEmptyValue
+	}
+	for _, dto := range createDTO {
+		if len(dto.ShortURL) == 0 {
+			return ErrEmptyKey
+		}
+		if len(dto.OriginalURL) == 0 {
+			return ErrEmptyValue
+		}
+	}
+	keys := make([]string, len(createDTO))
+	hasSameURL := false
+	now := time.Now()
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		urls := tx.Bucket(boltURLsBucket)
+		for i, dto := range createDTO {
+			if existing, exists := boltFindUserURL(tx, userID, dto.OriginalURL); exists {
+				keys[i] = existing
+				hasSameURL = true
+				continue
+			}
+			if urls.Get([]byte(dto.ShortURL)) != nil {
+				return ErrKeyTaken
+			}
+			keys[i] = dto.ShortURL
+			record := models.SerializeData{UserID: userID, ShortURL: dto.ShortURL, OriginalURL: dto.OriginalURL, ExpiresAt: dto.ExpiresAt, CreatedAt: &now, RedirectType: dto.RedirectType, ForwardQuery: dto.ForwardQuery, ForwardPath: dto.ForwardPath}
+			if err := boltPutURL(tx, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := range createDTO {
+		createDTO[i].ShortURL = keys[i]
+	}
+	if hasSameURL {
+		return ErrAlreadyExists
+	}
+	return nil
+}
+
+// GetURL возвращает оригинальный URL по короткому ключу.
+func (s *BoltURLStore) GetURL(ctx context.Context, key string) (string, error) {
+	redirect, err := s.GetRedirect(ctx, key)
+	return redirect.URL, err
+}
+
+// GetRedirect возвращает оригинальный URL и код ответа при переходе по короткому ключу.
+func (s *BoltURLStore) GetRedirect(_ context.Context, key string) (models.RedirectDTO, error) {
+	var record models.SerializeData
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		record, err = boltGetURL(tx, key)
+		return err
+	})
+	if err != nil {
+		return models.RedirectDTO{}, err
+	}
+	if record.IsDeleted {
+		return models.RedirectDTO{}, ErrAlreadyDeleted
+	}
+	if record.ExpiresAt != nil && !record.ExpiresAt.After(time.Now()) {
+		return models.RedirectDTO{}, ErrExpired
+	}
+	return models.RedirectDTO{URL: record.OriginalURL, Type: record.RedirectType, ForwardQuery: record.ForwardQuery, ForwardPath: record.ForwardPath, ExpiresAt: record.ExpiresAt}, nil
+}
+
+// GetUserURLs возвращает список URL пользователя.
+func (s *BoltURLStore) GetUserURLs(_ context.Context, userID string) ([]models.UserShortURLReadDTO, error) {
+	var readDTO []models.UserShortURLReadDTO
+	err := s.db.View(func(tx *bolt.Tx) error {
+		userKeys := tx.Bucket(boltUserKeysBucket).Bucket([]byte(userID))
+		if userKeys == nil {
+			return nil
+		}
+		return userKeys.ForEach(func(k, _ []byte) error {
+			record, err := boltGetURL(tx, string(k))
+			if err != nil {
+				return err
+			}
+			if !record.IsDeleted {
+				readDTO = append(readDTO, models.UserShortURLReadDTO{ShortURL: fmt.Sprintf("%s/%s", s.cfg.BaseURL, record.ShortURL), OriginalURL: record.OriginalURL})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(readDTO) == 0 {
+		return nil, ErrNotFound
+	}
+	return readDTO, nil
+}
+
+// GetUserURLsPage возвращает страницу ссылок пользователя, отфильтрованных и отсортированных согласно query.
+// Читаются только ссылки пользователя из его индекса ключей.
+func (s *BoltURLStore) GetUserURLsPage(_ context.Context, userID string, query models.UserURLsQuery) (models.UserURLsPageDTO, error) {
+	cursor, err := prepareUserURLsQuery(&query)
+	if err != nil {
+		return models.UserURLsPageDTO{}, err
+	}
+	var entries []userURLEntry
+	err = s.db.View(func(tx *bolt.Tx) error {
+		userKeys := tx.Bucket(boltUserKeysBucket).Bucket([]byte(userID))
+		if userKeys == nil {
+			return nil
+		}
+		return userKeys.ForEach(func(k, _ []byte) error {
+			record, err := boltGetURL(tx, string(k))
+			if err != nil {
+				return err
+			}
+			if !record.IsDeleted && matchesURLQuery(record.OriginalURL, query.Query) {
+				entries = append(entries, userURLEntry{key: record.ShortURL, url: record.OriginalURL, createdAt: timeOrZero(record.CreatedAt)})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return models.UserURLsPageDTO{}, err
+	}
+	return paginateUserURLs(entries, query, cursor, s.cfg.BaseURL), nil
+}
+
+// LookupUserURLs возвращает неудалённые ссылки пользователя, ведущие на URL с нормализованным видом normalizedURL.
+// Перебираются только оригинальные URL пользователя из его индекса.
+func (s *BoltURLStore) LookupUserURLs(_ context.Context, userID, normalized string) ([]models.UserShortURLReadDTO, error) {
+	var readDTO []models.UserShortURLReadDTO
+	err := s.db.View(func(tx *bolt.Tx) error {
+		userOriginals := tx.Bucket(boltUserOriginalsBucket).Bucket([]byte(userID))
+		if userOriginals == nil {
+			return nil
+		}
+		return userOriginals.ForEach(func(original, key []byte) error {
+			if normalizedURL(string(original)) != normalized {
+				return nil
+			}
+			record, err := boltGetURL(tx, string(key))
+			if err != nil {
+				return err
+			}
+			if !record.IsDeleted {
+				readDTO = append(readDTO, models.UserShortURLReadDTO{ShortURL: fmt.Sprintf("%s/%s", s.cfg.BaseURL, record.ShortURL), OriginalURL: record.OriginalURL})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return readDTO, nil
+}
+
+// DeleteURLs помечает список URL пользователя как удалённые.
+func (s *BoltURLStore) DeleteURLs(ctx context.Context, userID string, urls []string) error {
+	return s.DeleteURLsBatch(ctx, []models.DeletionTask{{UserID: userID, ShortURLs: urls}})
+}
+
+// DeleteURLsBatch помечает удалёнными ссылки нескольких пользователей в одной транзакции.
+func (s *BoltURLStore) DeleteURLsBatch(_ context.Context, tasks []models.DeletionTask) error {
+	for _, task := range tasks {
+		if len(task.UserID) == 0 {
+			return ErrEmptyUserID
+		}
+		if len(task.ShortURLs) == 0 {
+			return ErrEmptyURLs
+		}
+	}
+	now := time.Now()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, task := range tasks {
+			for _, key := range task.ShortURLs {
+				record, err := boltGetURL(tx, key)
+				if err == ErrNotFound || (err == nil && (record.UserID != task.UserID || record.IsDeleted)) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				record.IsDeleted = true
+				record.DeletedAt = &now
+				if err := boltPutURL(tx, record); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateURL изменяет оригинальный URL и код ответа при переходе по ссылке пользователя в одной транзакции.
+// Незаполненные поля update не меняются.
+// Возвращает ErrNotFound, если ссылка не найдена, удалена или принадлежит другому пользователю,
+// и ErrAlreadyExists, если пользователь уже сократил новый URL под другим ключом.
+func (s *BoltURLStore) UpdateURL(_ context.Context, userID, key string, update models.ShortURLUpdateDTO) error {
+	if len(key) == 0 {
+		return ErrEmptyKey
+	}
+	if len(update.URL) == 0 && update.RedirectType == 0 {
+		return ErrEmptyValue
+	}
+	if len(userID) == 0 {
+		return ErrEmptyUserID
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		record, err := boltGetURL(tx, key)
+		if err != nil {
+			return err
+		}
+		if record.IsDeleted || record.UserID != userID {
+			return ErrNotFound
+		}
+		if len(update.URL) > 0 {
+			if existing, exists := boltFindUserURL(tx, userID, update.URL); exists && existing != key {
+				return ErrAlreadyExists
+			}
+			if err := boltDeleteUserOriginal(tx.Bucket(boltUserOriginalsBucket).Bucket([]byte(userID)), record); err != nil {
+				return err
+			}
+			record.OriginalURL = update.URL
+		}
+		if update.RedirectType != 0 {
+			record.RedirectType = update.RedirectType
+		}
+		return boltPutURL(tx, record)
+	})
+}
+
+// DeleteExpired удаляет из базы ссылки с истёкшим сроком действия вместе с их индексами и переходами
+// и возвращает их количество.
+func (s *BoltURLStore) DeleteExpired(_ context.Context) (int, error) {
+	now := time.Now()
+	return s.purgeWhere(func(record models.SerializeData) bool {
+		return record.ExpiresAt != nil && !record.ExpiresAt.After(now)
+	})
+}
+
+// RestoreURLs снимает пометку удаления со ссылок пользователя, удалённых не раньше deletedSince,
+// и возвращает ключи восстановленных ссылок.
+func (s *BoltURLStore) RestoreURLs(_ context.Context, userID string, urls []string, deletedSince time.Time) ([]string, error) {
+	if len(userID) == 0 {
+		return nil, ErrEmptyUserID
+	}
+	if len(urls) == 0 {
+		return nil, ErrEmptyURLs
+	}
+	var restored []string
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, key := range urls {
+			record, err := boltGetURL(tx, key)
+			if err == ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if record.UserID != userID || !record.IsDeleted || record.DeletedAt == nil || record.DeletedAt.Before(deletedSince) {
+				continue
+			}
+			// Ссылка не восстанавливается, если пользователь уже заново сократил тот же URL.
+			if _, exists := boltFindUserURL(tx, userID, record.OriginalURL); exists {
+				continue
+			}
+			record.IsDeleted = false
+			record.DeletedAt = nil
+			if err := boltPutURL(tx, record); err != nil {
+				return err
+			}
+			restored = append(restored, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// PurgeDeleted окончательно удаляет ссылки, удалённые раньше deletedBefore, и возвращает их количество.
+func (s *BoltURLStore) PurgeDeleted(_ context.Context, deletedBefore time.Time) (int, error) {
+	return s.purgeWhere(func(record models.SerializeData) bool {
+		return record.IsDeleted && (record.DeletedAt == nil || record.DeletedAt.Before(deletedBefore))
+	})
+}
+
+// purgeWhere окончательно удаляет в одной транзакции ссылки, подходящие под условие, и возвращает их количество.
+func (s *BoltURLStore) purgeWhere(match func(record models.SerializeData) bool) (int, error) {
+	count := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var matched []models.SerializeData
+		err := tx.Bucket(boltURLsBucket).ForEach(func(_, v []byte) error {
+			var record models.SerializeData
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			if match(record) {
+				matched = append(matched, record)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, record := range matched {
+			if err := boltPurgeURL(tx, record); err != nil {
+				return err
+			}
+		}
+		count = len(matched)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// SaveClicks сохраняет пачку событий переходов в одной транзакции.
+func (s *BoltURLStore) SaveClicks(_ context.Context, events []models.ClickEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		clicks := tx.Bucket(boltClicksBucket)
+		for _, event := range events {
+			bucket, err := clicks.CreateBucketIfNotExists([]byte(event.ShortURL))
+			if err != nil {
+				return err
+			}
+			id, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(boltSequenceKey(id), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetURLStats возвращает статистику переходов по ссылке, принадлежащей пользователю.
+func (s *BoltURLStore) GetURLStats(_ context.Context, userID, key string) (models.URLStatsDTO, error) {
+	var events []models.ClickEvent
+	err := s.db.View(func(tx *bolt.Tx) error {
+		record, err := boltGetURL(tx, key)
+		if err != nil {
+			return err
+		}
+		if record.UserID != userID {
+			return ErrNotFound
+		}
+		bucket := tx.Bucket(boltClicksBucket).Bucket([]byte(key))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(_, v []byte) error {
+			var event models.ClickEvent
+			if err := json.Unmarshal(v, &event); err != nil {
+				return err
+			}
+			events = append(events, event)
+			return nil
+		})
+	})
+	if err != nil {
+		return models.URLStatsDTO{}, err
+	}
+	return buildURLStats(key, events), nil
+}
+
+// GetURLInfo возвращает сведения о ссылке по короткому ключу: владельца, оригинальный URL,
+// момент создания, срок действия и количество переходов. Для удалённой ссылки возвращается ErrAlreadyDeleted.
+func (s *BoltURLStore) GetURLInfo(_ context.Context, key string) (models.URLInfoDTO, error) {
+	var info models.URLInfoDTO
+	err := s.db.View(func(tx *bolt.Tx) error {
+		record, err := boltGetURL(tx, key)
+		if err != nil {
+			return err
+		}
+		if record.IsDeleted {
+			return ErrAlreadyDeleted
+		}
+		info = models.URLInfoDTO{
+			ShortURL:     key,
+			OriginalURL:  record.OriginalURL,
+			UserID:       record.UserID,
+			CreatedAt:    record.CreatedAt,
+			ExpiresAt:    record.ExpiresAt,
+			RedirectType: record.RedirectType,
+			ForwardQuery: record.ForwardQuery,
+			ForwardPath:  record.ForwardPath,
+		}
+		if bucket := tx.Bucket(boltClicksBucket).Bucket([]byte(key)); bucket != nil {
+			info.Clicks = bucket.Stats().KeyN
+		}
+		return nil
+	})
+	if err != nil {
+		return models.URLInfoDTO{}, err
+	}
+	return info, nil
+}
+
+// CreateAPIKey сохраняет ключ API и индекс по его хэшу.
+func (s *BoltURLStore) CreateAPIKey(_ context.Context, key models.APIKey) error {
+	if len(key.UserID) == 0 {
+		return ErrEmptyUserID
+	}
+	if len(key.Hash) == 0 {
+		return ErrEmptyKey
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		hashes := tx.Bucket(boltAPIKeyHashesBucket)
+		if hashes.Get([]byte(key.Hash)) != nil || tx.Bucket(boltAPIKeysBucket).Get([]byte(key.ID)) != nil {
+			return ErrAlreadyExists
+		}
+		if err := boltPutAPIKey(tx, key); err != nil {
+			return err
+		}
+		return hashes.Put([]byte(key.Hash), []byte(key.ID))
+	})
+}
+
+// GetUserAPIKeys возвращает ключи API пользователя в порядке создания.
+// Ключей у пользователей немного, поэтому отдельного индекса по пользователю нет.
+func (s *BoltURLStore) GetUserAPIKeys(_ context.Context, userID string) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltAPIKeysBucket).ForEach(func(_, v []byte) error {
+			var key models.APIKey
+			if err := json.Unmarshal(v, &key); err != nil {
+				return err
+			}
+			if key.UserID == userID {
+				keys = append(keys, key)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortAPIKeys(keys)
+	return keys, nil
+}
+
+// GetAPIKeyByHash возвращает ключ API по его хэшу.
+func (s *BoltURLStore) GetAPIKeyByHash(_ context.Context, hash string) (models.APIKey, error) {
+	var key models.APIKey
+	err := s.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(boltAPIKeyHashesBucket).Get([]byte(hash))
+		if id == nil {
+			return ErrNotFound
+		}
+		var err error
+		key, err = boltGetAPIKey(tx, string(id))
+		return err
+	})
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+// RevokeAPIKey удаляет ключ API пользователя и его индекс.
+// Возвращает ErrNotFound, если ключ не найден или принадлежит другому пользователю.
+func (s *BoltURLStore) RevokeAPIKey(_ context.Context, userID, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		key, err := boltGetAPIKey(tx, id)
+		if err != nil {
+			return err
+		}
+		if key.UserID != userID {
+			return ErrNotFound
+		}
+		if err := tx.Bucket(boltAPIKeysBucket).Delete([]byte(id)); err != nil {
+			return err
+		}
+		return tx.Bucket(boltAPIKeyHashesBucket).Delete([]byte(key.Hash))
+	})
+}
+
+// TouchAPIKey запоминает момент последнего использования ключа API.
+func (s *BoltURLStore) TouchAPIKey(_ context.Context, id string, usedAt time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		key, err := boltGetAPIKey(tx, id)
+		if err != nil {
+			return err
+		}
+		key.LastUsedAt = &usedAt
+		return boltPutAPIKey(tx, key)
+	})
+}
+
+// SaveDeletionJob сохраняет задачу удаления или обновляет состояние уже сохранённой задачи.
+func (s *BoltURLStore) SaveDeletionJob(_ context.Context, job models.DeletionJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltDeletionJobsBucket).Put([]byte(job.ID), data)
+	})
+}
+
+// GetDeletionJob возвращает задачу удаления по идентификатору или deletion.ErrJobNotFound.
+func (s *BoltURLStore) GetDeletionJob(_ context.Context, id string) (models.DeletionJob, error) {
+	var job models.DeletionJob
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltDeletionJobsBucket).Get([]byte(id))
+		if data == nil {
+			return deletion.ErrJobNotFound
+		}
+		return json.Unmarshal(data, &job)
+	})
+	return job, err
+}
+
+// PendingDeletionJobs возвращает невыполненные задачи удаления в порядке постановки в очередь.
+// Завершённые задачи хранятся недолго, поэтому отдельного индекса по статусу нет.
+func (s *BoltURLStore) PendingDeletionJobs(_ context.Context) ([]models.DeletionJob, error) {
+	var jobs []models.DeletionJob
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltDeletionJobsBucket).ForEach(func(_, v []byte) error {
+			var job models.DeletionJob
+			if err := json.Unmarshal(v, &job); err != nil {
+				return err
+			}
+			if job.Status == models.DeletionJobPending {
+				jobs = append(jobs, job)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortDeletionJobs(jobs)
+	return jobs, nil
+}
+
+// PurgeDeletionJobs удаляет задачи, завершённые раньше finishedBefore, и возвращает их количество.
+func (s *BoltURLStore) PurgeDeletionJobs(_ context.Context, finishedBefore time.Time) (int, error) {
+	count := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltDeletionJobsBucket)
+		var ids [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			var job models.DeletionJob
+			if err := json.Unmarshal(v, &job); err != nil {
+				return err
+			}
+			if job.FinishedAt != nil && job.FinishedAt.Before(finishedBefore) {
+				ids = append(ids, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := bucket.Delete(id); err != nil {
+				return err
+			}
+		}
+		count = len(ids)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Close закрывает файл базы.
+func (s *BoltURLStore) Close() error {
+	return s.db.Close()
+}
+
+// CountURLs возвращает количество неудалённых сокращённых URL.
+func (s *BoltURLStore) CountURLs(_ context.Context) (int, error) {
+	count := 0
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltURLsBucket).ForEach(func(_, v []byte) error {
+			var record models.SerializeData
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			if !record.IsDeleted {
+				count++
+			}
+			return nil
+		})
+	})
+	return count, err
+}
+
+// CountUsers возвращает количество пользователей, сокращавших URL.
+func (s *BoltURLStore) CountUsers(_ context.Context) (int, error) {
+	count := 0
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltUserKeysBucket).ForEach(func(_, v []byte) error {
+			// Вложенные бакеты пользователей обходятся с пустым значением.
+			if v == nil {
+				count++
+			}
+			return nil
+		})
+	})
+	return count, err
+}
+
+// NextSequence возвращает следующее значение счётчика, сохраняемого в файле базы.
+func (s *BoltURLStore) NextSequence(_ context.Context) (uint64, error) {
+	var n uint64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.Bucket(boltKeySequenceBucket).NextSequence()
+		return err
+	})
+	return n, err
+}
+
+// boltGetURL читает ссылку по короткому ключу.
+func boltGetURL(tx *bolt.Tx, key string) (models.SerializeData, error) {
+	var record models.SerializeData
+	data := tx.Bucket(boltURLsBucket).Get([]byte(key))
+	if data == nil {
+		return record, ErrNotFound
+	}
+	err := json.Unmarshal(data, &record)
+	return record, err
+}
+
+// boltPutURL сохраняет ссылку и обновляет индексы пользователя.
+// В индексе оригинальных URL остаются только неудалённые ссылки.
+func boltPutURL(tx *bolt.Tx, record models.SerializeData) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(boltURLsBucket).Put([]byte(record.ShortURL), data); err != nil {
+		return err
+	}
+	userKeys, err := tx.Bucket(boltUserKeysBucket).CreateBucketIfNotExists([]byte(record.UserID))
+	if err != nil {
+		return err
+	}
+	if err := userKeys.Put([]byte(record.ShortURL), nil); err != nil {
+		return err
+	}
+	userOriginals, err := tx.Bucket(boltUserOriginalsBucket).CreateBucketIfNotExists([]byte(record.UserID))
+	if err != nil {
+		return err
+	}
+	if record.IsDeleted {
+		return boltDeleteUserOriginal(userOriginals, record)
+	}
+	return userOriginals.Put([]byte(record.OriginalURL), []byte(record.ShortURL))
+}
+
+// boltDeleteUserOriginal убирает оригинальный URL ссылки из индекса пользователя,
+// если индекс указывает именно на эту ссылку.
+func boltDeleteUserOriginal(userOriginals *bolt.Bucket, record models.SerializeData) error {
+	if string(userOriginals.Get([]byte(record.OriginalURL))) != record.ShortURL {
+		return nil
+	}
+	return userOriginals.Delete([]byte(record.OriginalURL))
+}
+
+// boltPurgeURL окончательно удаляет ссылку, её индексы и переходы.
+func boltPurgeURL(tx *bolt.Tx, record models.SerializeData) error {
+	if err := tx.Bucket(boltURLsBucket).Delete([]byte(record.ShortURL)); err != nil {
+		return err
+	}
+	if userKeys := tx.Bucket(boltUserKeysBucket).Bucket([]byte(record.UserID)); userKeys != nil {
+		if err := userKeys.Delete([]byte(record.ShortURL)); err != nil {
+			return err
+		}
+		if k, _ := userKeys.Cursor().First(); k == nil {
+			if err := tx.Bucket(boltUserKeysBucket).DeleteBucket([]byte(record.UserID)); err != nil {
+				return err
+			}
+		}
+	}
+	if userOriginals := tx.Bucket(boltUserOriginalsBucket).Bucket([]byte(record.UserID)); userOriginals != nil {
+		if err := boltDeleteUserOriginal(userOriginals, record); err != nil {
+			return err
+		}
+	}
+	err := tx.Bucket(boltClicksBucket).DeleteBucket([]byte(record.ShortURL))
+	if err != nil && err != bolt.ErrBucketNotFound {
+		return err
+	}
+	return nil
+}
+
+// boltGetAPIKey читает ключ API по идентификатору.
+func boltGetAPIKey(tx *bolt.Tx, id string) (models.APIKey, error) {
+	var key models.APIKey
+	data := tx.Bucket(boltAPIKeysBucket).Get([]byte(id))
+	if data == nil {
+		return key, ErrNotFound
+	}
+	err := json.Unmarshal(data, &key)
+	return key, err
+}
+
+// boltPutAPIKey сохраняет ключ API по идентификатору.
+func boltPutAPIKey(tx *bolt.Tx, key models.APIKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(boltAPIKeysBucket).Put([]byte(key.ID), data)
+}
+
+// boltFindUserURL ищет ключ, под которым пользователь уже сократил URL.
+// Удалённые ссылки и ссылки с истёкшим сроком действия повтором не считаются.
+func boltFindUserURL(tx *bolt.Tx, userID, value string) (string, bool) {
+	userOriginals := tx.Bucket(boltUserOriginalsBucket).Bucket([]byte(userID))
+	if userOriginals == nil {
+		return "", false
+	}
+	key := userOriginals.Get([]byte(value))
+	if key == nil {
+		return "", false
+	}
+	record, err := boltGetURL(tx, string(key))
+	if err != nil || record.IsDeleted || (record.ExpiresAt != nil && !record.ExpiresAt.After(time.Now())) {
+		return "", false
+	}
+	return string(key), true
+}
+
+// boltSequenceKey кодирует порядковый номер в ключ, сохраняющий порядок при побайтовом сравнении.
+func boltSequenceKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
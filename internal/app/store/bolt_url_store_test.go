@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/shekshuev/shortener/internal/app/config"
+	"github.com/shekshuev/shortener/internal/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoltURLStore_PersistsAcrossReopen(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.FileStoragePath = filepath.Join(t.TempDir(), "storage.db")
+	ctx := context.Background()
+
+	s, err := NewBoltURLStore(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{})
+	assert.NoError(t, err)
+	_, err = s.SetURL(ctx, "short2", "https://google.com", "2", models.LinkOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, s.DeleteURLs(ctx, "2", []string{"short2"}))
+	assert.NoError(t, s.Close())
+
+	s, err = NewBoltURLStore(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	value, err := s.GetURL(ctx, "short1")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", value)
+	_, err = s.GetURL(ctx, "short2")
+	assert.ErrorIs(t, err, ErrAlreadyDeleted, "Deleted url should stay deleted after reopen")
+
+	urls, err := s.CountURLs(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, urls)
+	users, err := s.CountUsers(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, users)
+}
+
+func TestNewBoltURLStore_FileLocked(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.FileStoragePath = filepath.Join(t.TempDir(), "storage.db")
+
+	s, err := NewBoltURLStore(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	_, err = NewBoltURLStore(&cfg)
+	assert.Error(t, err, "Second instance should not open a locked file")
+}
+
+func TestBoltURLStore_NextSequence(t *testing.T) {
+	cfg := config.GetConfig()
+	cfg.FileStoragePath = filepath.Join(t.TempDir(), "storage.db")
+	ctx := context.Background()
+
+	s, err := NewBoltURLStore(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []uint64{1, 2} {
+		n, err := s.NextSequence(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, n)
+	}
+	assert.NoError(t, s.Close())
+
+	s, err = NewBoltURLStore(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	n, err := s.NextSequence(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), n, "Sequence should survive reopen")
+}
@@ -0,0 +1,233 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shekshuev/shortener/internal/app/models"
+)
+
+// ErrNotDatabaseStore - ошибка, указывающая, что хранилище под кэшем не работает с базой данных.
+var ErrNotDatabaseStore = fmt.Errorf("store is not backed by a database")
+
+// CacheStatsProvider - хранилище, которое ведёт статистику попаданий в кэш.
+type CacheStatsProvider interface {
+	CacheStats() models.CacheStatsDTO
+}
+
+// cacheEntry - запись LRU-кэша. Пустой redirect с notFound=true означает закэшированный промах.
+type cacheEntry struct {
+	key       string
+	redirect  models.RedirectDTO
+	notFound  bool
+	expiresAt time.Time
+}
+
+// CachedURLStore - декоратор URLStore со сквозным чтением через LRU-кэш для GetURL и GetRedirect.
+// Кэшируются как найденные ссылки, так и ErrNotFound. Записи сбрасываются при изменении
+// и удалении ссылок через декоратор и по истечении ttl, но не позже срока действия самой ссылки,
+// поэтому истёкшая ссылка из кэша не обслуживается.
+type CachedURLStore struct {
+	URLStore
+	mx      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List
+	epoch   uint64
+	hits    atomic.Int64
+	misses  atomic.Int64
+}
+
+// NewCachedURLStore оборачивает хранилище кэшем на size записей с временем жизни ttl.
+func NewCachedURLStore(next URLStore, size int, ttl time.Duration) *CachedURLStore {
+	return &CachedURLStore{
+		URLStore: next,
+		size:     size,
+		ttl:      ttl,
+		entries:  make(map[string]*list.Element, size),
+		order:    list.New(),
+	}
+}
+
+// GetURL возвращает оригинальный URL из кэша или из хранилища, сохраняя результат в кэш.
+func (s *CachedURLStore) GetURL(ctx context.Context, key string) (string, error) {
+	redirect, err := s.GetRedirect(ctx, key)
+	return redirect.URL, err
+}
+
+// GetRedirect возвращает оригинальный URL и код ответа при переходе из кэша или из хранилища,
+// сохраняя результат в кэш.
+func (s *CachedURLStore) GetRedirect(ctx context.Context, key string) (models.RedirectDTO, error) {
+	s.mx.Lock()
+	if entry, ok := s.lookup(key, time.Now()); ok {
+		s.mx.Unlock()
+		s.hits.Add(1)
+		if entry.notFound {
+			return models.RedirectDTO{}, ErrNotFound
+		}
+		return entry.redirect, nil
+	}
+	epoch := s.epoch
+	s.mx.Unlock()
+	s.misses.Add(1)
+
+	redirect, err := s.URLStore.GetRedirect(ctx, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return models.RedirectDTO{}, err
+	}
+
+	s.mx.Lock()
+	// Если за время чтения кэш инвалидировали, результат мог устареть и не сохраняется.
+	if epoch == s.epoch {
+		expiresAt := time.Now().Add(s.ttl)
+		if redirect.ExpiresAt != nil && redirect.ExpiresAt.Before(expiresAt) {
+			expiresAt = *redirect.ExpiresAt
+		}
+		s.add(&cacheEntry{key: key, redirect: redirect, notFound: err != nil, expiresAt: expiresAt})
+	}
+	s.mx.Unlock()
+	return redirect, err
+}
+
+// SetURL сохраняет URL и сбрасывает закэшированный промах по ключу.
+func (s *CachedURLStore) SetURL(ctx context.Context, key, value, userID string, opts models.LinkOptions) (string, error) {
+	shortURL, err := s.URLStore.SetURL(ctx, key, value, userID, opts)
+	s.Invalidate(key)
+	return shortURL, err
+}
+
+// SetBatchURL сохраняет пакет URL и сбрасывает закэшированные промахи по их ключам.
+func (s *CachedURLStore) SetBatchURL(ctx context.Context, createDTO []models.BatchShortURLCreateDTO, userID string) error {
+	keys := make([]string, 0, len(createDTO))
+	for _, dto := range createDTO {
+		keys = append(keys, dto.ShortURL)
+	}
+	err := s.URLStore.SetBatchURL(ctx, createDTO, userID)
+	s.Invalidate(keys...)
+	return err
+}
+
+// DeleteURLs удаляет URL пользователя и сбрасывает их из кэша.
+func (s *CachedURLStore) DeleteURLs(ctx context.Context, userID string, urls []string) error {
+	err := s.URLStore.DeleteURLs(ctx, userID, urls)
+	s.Invalidate(urls...)
+	return err
+}
+
+// DeleteURLsBatch удаляет URL нескольких пользователей и сбрасывает их из кэша.
+func (s *CachedURLStore) DeleteURLsBatch(ctx context.Context, tasks []models.DeletionTask) error {
+	err := s.URLStore.DeleteURLsBatch(ctx, tasks)
+	for _, task := range tasks {
+		s.Invalidate(task.ShortURLs...)
+	}
+	return err
+}
+
+// UpdateURL изменяет оригинальный URL и код ответа при переходе по ссылке и сбрасывает её из кэша.
+func (s *CachedURLStore) UpdateURL(ctx context.Context, userID, key string, update models.ShortURLUpdateDTO) error {
+	err := s.URLStore.UpdateURL(ctx, userID, key, update)
+	s.Invalidate(key)
+	return err
+}
+
+// DeleteExpired удаляет ссылки с истёкшим сроком действия и, если такие были, очищает кэш.
+func (s *CachedURLStore) DeleteExpired(ctx context.Context) (int, error) {
+	count, err := s.URLStore.DeleteExpired(ctx)
+	if count > 0 {
+		s.Purge()
+	}
+	return count, err
+}
+
+// RestoreURLs восстанавливает удалённые URL пользователя и сбрасывает их из кэша.
+func (s *CachedURLStore) RestoreURLs(ctx context.Context, userID string, urls []string, deletedSince time.Time) ([]string, error) {
+	restored, err := s.URLStore.RestoreURLs(ctx, userID, urls, deletedSince)
+	s.Invalidate(restored...)
+	return restored, err
+}
+
+// PurgeDeleted окончательно удаляет давно удалённые ссылки и, если такие были, очищает кэш.
+func (s *CachedURLStore) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int, error) {
+	count, err := s.URLStore.PurgeDeleted(ctx, deletedBefore)
+	if count > 0 {
+		s.Purge()
+	}
+	return count, err
+}
+
+// CheckDBConnection проверяет соединение с базой данных хранилища под кэшем.
+func (s *CachedURLStore) CheckDBConnection(ctx context.Context) error {
+	if checker, ok := s.URLStore.(DatabaseChecker); ok {
+		return checker.CheckDBConnection(ctx)
+	}
+	return ErrNotDatabaseStore
+}
+
+// Invalidate удаляет ключи из кэша.
+func (s *CachedURLStore) Invalidate(keys ...string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.epoch++
+	for _, key := range keys {
+		if element, ok := s.entries[key]; ok {
+			s.remove(element)
+		}
+	}
+}
+
+// Purge полностью очищает кэш.
+func (s *CachedURLStore) Purge() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.epoch++
+	s.entries = make(map[string]*list.Element, s.size)
+	s.order.Init()
+}
+
+// CacheStats возвращает количество попаданий и промахов кэша и его текущий размер.
+func (s *CachedURLStore) CacheStats() models.CacheStatsDTO {
+	s.mx.Lock()
+	size := s.order.Len()
+	s.mx.Unlock()
+	return models.CacheStatsDTO{Hits: s.hits.Load(), Misses: s.misses.Load(), Size: size}
+}
+
+// lookup ищет неистёкшую запись и переносит её в начало очереди. Вызывается под блокировкой.
+func (s *CachedURLStore) lookup(key string, now time.Time) (*cacheEntry, bool) {
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if !now.Before(entry.expiresAt) {
+		s.remove(element)
+		return nil, false
+	}
+	s.order.MoveToFront(element)
+	return entry, true
+}
+
+// add добавляет запись и вытесняет самые давние, если кэш переполнен. Вызывается под блокировкой.
+func (s *CachedURLStore) add(entry *cacheEntry) {
+	if element, ok := s.entries[entry.key]; ok {
+		element.Value = entry
+		s.order.MoveToFront(element)
+		return
+	}
+	s.entries[entry.key] = s.order.PushFront(entry)
+	for s.order.Len() > s.size {
+		s.remove(s.order.Back())
+	}
+}
+
+// remove удаляет запись из кэша. Вызывается под блокировкой.
+func (s *CachedURLStore) remove(element *list.Element) {
+	s.order.Remove(element)
+	delete(s.entries, element.Value.(*cacheEntry).key)
+}
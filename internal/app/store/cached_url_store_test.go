@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shekshuev/shortener/internal/app/config"
+	"github.com/shekshuev/shortener/internal/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingStore считает обращения к GetRedirect хранилища под кэшем.
+type countingStore struct {
+	URLStore
+	gets int
+}
+
+func (s *countingStore) GetRedirect(ctx context.Context, key string) (models.RedirectDTO, error) {
+	s.gets++
+	return s.URLStore.GetRedirect(ctx, key)
+}
+
+func newCountingStore() *countingStore {
+	cfg := config.GetConfig()
+	return &countingStore{URLStore: &MemoryURLStore{urls: make(map[string]UserURL), clicks: make(map[string][]models.ClickEvent), cfg: &cfg}}
+}
+
+func TestCachedURLStore_GetURL(t *testing.T) {
+	ctx := context.Background()
+	next := newCountingStore()
+	s := NewCachedURLStore(next, 10, time.Minute)
+	_, err := s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{})
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		value, err := s.GetURL(ctx, "short1")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://ya.ru", value)
+	}
+	for i := 0; i < 2; i++ {
+		_, err = s.GetURL(ctx, "missing")
+		assert.ErrorIs(t, err, ErrNotFound)
+	}
+	assert.Equal(t, 2, next.gets, "Store should be called once per key")
+	assert.Equal(t, models.CacheStatsDTO{Hits: 3, Misses: 2, Size: 2}, s.CacheStats())
+}
+
+func TestCachedURLStore_Invalidation(t *testing.T) {
+	ctx := context.Background()
+	testCases := []struct {
+		name     string
+		key      string
+		mutate   func(s *CachedURLStore) error
+		expected string
+		wantErr  error
+	}{
+		{
+			name: "Update",
+			key:  "short1",
+			mutate: func(s *CachedURLStore) error {
+				return s.UpdateURL(ctx, "1", "short1", models.ShortURLUpdateDTO{URL: "https://example.com"})
+			},
+			expected: "https://example.com",
+		},
+		{
+			name: "Delete",
+			key:  "short1",
+			mutate: func(s *CachedURLStore) error {
+				return s.DeleteURLs(ctx, "1", []string{"short1"})
+			},
+			wantErr: ErrAlreadyDeleted,
+		},
+		{
+			name: "Create after cached miss",
+			key:  "short2",
+			mutate: func(s *CachedURLStore) error {
+				_, err := s.SetURL(ctx, "short2", "https://google.com", "1", models.LinkOptions{})
+				return err
+			},
+			expected: "https://google.com",
+		},
+		{
+			name: "Batch create after cached miss",
+			key:  "short3",
+			mutate: func(s *CachedURLStore) error {
+				return s.SetBatchURL(ctx, []models.BatchShortURLCreateDTO{{CorrelationID: "1", OriginalURL: "https://example.org", ShortURL: "short3"}}, "1")
+			},
+			expected: "https://example.org",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewCachedURLStore(newCountingStore(), 10, time.Minute)
+			_, err := s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{})
+			assert.NoError(t, err)
+			_, _ = s.GetURL(ctx, tc.key)
+
+			assert.NoError(t, tc.mutate(s))
+			value, err := s.GetURL(ctx, tc.key)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, value)
+		})
+	}
+}
+
+func TestCachedURLStore_RedirectType(t *testing.T) {
+	ctx := context.Background()
+	next := newCountingStore()
+	s := NewCachedURLStore(next, 10, time.Minute)
+	_, err := s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{RedirectType: 302})
+	assert.NoError(t, err)
+
+	redirect, err := s.GetRedirect(ctx, "short1")
+	assert.NoError(t, err)
+	assert.Equal(t, models.RedirectDTO{URL: "https://ya.ru", Type: 302}, redirect)
+	redirect, err = s.GetRedirect(ctx, "short1")
+	assert.NoError(t, err)
+	assert.Equal(t, models.RedirectDTO{URL: "https://ya.ru", Type: 302}, redirect, "Redirect type should be served from cache")
+	assert.Equal(t, 1, next.gets)
+
+	assert.NoError(t, s.UpdateURL(ctx, "1", "short1", models.ShortURLUpdateDTO{RedirectType: 308}))
+	redirect, err = s.GetRedirect(ctx, "short1")
+	assert.NoError(t, err)
+	assert.Equal(t, 308, redirect.Type, "Changed redirect type should not be served from cache")
+}
+
+func TestCachedURLStore_Eviction(t *testing.T) {
+	ctx := context.Background()
+	next := newCountingStore()
+	s := NewCachedURLStore(next, 2, time.Minute)
+	for _, key := range []string{"short1", "short2", "short3"} {
+		_, err := s.SetURL(ctx, key, "https://"+key+".ru", "1", models.LinkOptions{})
+		assert.NoError(t, err)
+	}
+	_, _ = s.GetURL(ctx, "short1")
+	_, _ = s.GetURL(ctx, "short2")
+	_, _ = s.GetURL(ctx, "short1")
+	_, _ = s.GetURL(ctx, "short3")
+	assert.Equal(t, 2, s.CacheStats().Size)
+
+	next.gets = 0
+	_, _ = s.GetURL(ctx, "short1")
+	assert.Equal(t, 0, next.gets, "Recently used key should stay in cache")
+	_, _ = s.GetURL(ctx, "short2")
+	assert.Equal(t, 1, next.gets, "Least recently used key should be evicted")
+}
+
+func TestCachedURLStore_TTL(t *testing.T) {
+	ctx := context.Background()
+	next := newCountingStore()
+	s := NewCachedURLStore(next, 10, 10*time.Millisecond)
+	_, _ = s.GetURL(ctx, "short1")
+	_, err := next.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{})
+	assert.NoError(t, err)
+
+	_, err = s.GetURL(ctx, "short1")
+	assert.ErrorIs(t, err, ErrNotFound, "Miss should be served from cache before ttl")
+	time.Sleep(20 * time.Millisecond)
+	value, err := s.GetURL(ctx, "short1")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", value)
+}
+
+func TestCachedURLStore_LinkExpiration(t *testing.T) {
+	ctx := context.Background()
+	next := newCountingStore()
+	s := NewCachedURLStore(next, 10, time.Minute)
+	expiresAt := time.Now().Add(20 * time.Millisecond)
+	_, err := s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{ExpiresAt: &expiresAt})
+	assert.NoError(t, err)
+
+	value, err := s.GetURL(ctx, "short1")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", value)
+	time.Sleep(30 * time.Millisecond)
+	_, err = s.GetURL(ctx, "short1")
+	assert.ErrorIs(t, err, ErrExpired, "Expired link should not be served from cache before ttl")
+	assert.Equal(t, 2, next.gets)
+}
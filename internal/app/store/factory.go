@@ -0,0 +1,60 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/shekshuev/shortener/internal/app/config"
+)
+
+// Ошибки выбора хранилища.
+var (
+	ErrUnknownBackend     = fmt.Errorf("unknown store backend, must be one of: memory, file, postgres") // Ошибка: неизвестный тип хранилища
+	ErrEmptyDatabaseDSN   = fmt.Errorf("database DSN is required for postgres store backend")           // Ошибка: не задана строка подключения к БД
+	ErrEmptyFileStorePath = fmt.Errorf("file storage path is required for file store backend")          // Ошибка: не задан путь к файлу хранилища
+)
+
+// Backend возвращает тип хранилища из конфигурации.
+// Если тип не задан явно, выбирается postgres при заданной строке подключения к БД и memory в остальных случаях.
+func Backend(cfg *config.Config) string {
+	if len(cfg.StoreBackend) > 0 {
+		return cfg.StoreBackend
+	}
+	if cfg.DatabaseDSN != cfg.DefaultDatabaseDSN {
+		return BackendPostgres
+	}
+	return BackendMemory
+}
+
+// New создаёт хранилище выбранного в конфигурации типа.
+// Возвращает ошибку, если тип неизвестен или хранилище не удалось инициализировать.
+func New(cfg *config.Config) (URLStore, error) {
+	backend := Backend(cfg)
+	switch backend {
+	case BackendMemory:
+		s, err := NewMemoryURLStore(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("memory store: %w", err)
+		}
+		return s, nil
+	case BackendFile:
+		if len(cfg.FileStoragePath) == 0 {
+			return nil, ErrEmptyFileStorePath
+		}
+		s, err := NewBoltURLStore(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("file store: %w", err)
+		}
+		return s, nil
+	case BackendPostgres:
+		if len(cfg.DatabaseDSN) == 0 {
+			return nil, ErrEmptyDatabaseDSN
+		}
+		s, err := NewPostgresURLStore(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("postgres store: %w", err)
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBackend, backend)
+	}
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/shekshuev/shortener/internal/app/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackend(t *testing.T) {
+	testCases := []struct {
+		name    string
+		backend string
+		dsn     string
+		want    string
+	}{
+		{name: "Explicit backend", backend: BackendFile, dsn: "host=localhost", want: BackendFile},
+		{name: "Database DSN without backend", dsn: "host=localhost", want: BackendPostgres},
+		{name: "Nothing set", want: BackendMemory},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.GetConfig()
+			cfg.StoreBackend = tc.backend
+			cfg.DatabaseDSN = tc.dsn
+			assert.Equal(t, tc.want, Backend(&cfg))
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		name     string
+		backend  string
+		dsn      string
+		path     string
+		policy   string
+		wantType URLStore
+		wantErr  error
+		hasError bool
+	}{
+		{name: "Memory store", backend: BackendMemory, path: "storage.txt", policy: WALSyncNever, wantType: &MemoryURLStore{}},
+		{name: "File store", backend: BackendFile, path: "storage.db", wantType: &BoltURLStore{}},
+		{name: "Unknown backend", backend: "redis", wantErr: ErrUnknownBackend, hasError: true},
+		{name: "Postgres without DSN", backend: BackendPostgres, wantErr: ErrEmptyDatabaseDSN, hasError: true},
+		{name: "File store without path", backend: BackendFile, wantErr: ErrEmptyFileStorePath, hasError: true},
+		{name: "Memory store with invalid WAL policy", backend: BackendMemory, path: "storage.txt", policy: "sometimes", wantErr: ErrInvalidWALSyncPolicy, hasError: true},
+		{name: "Unreachable database", backend: BackendPostgres, dsn: "host=127.0.0.1 port=1 user=test dbname=test sslmode=disable connect_timeout=1", hasError: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.GetConfig()
+			cfg.StoreBackend = tc.backend
+			cfg.DatabaseDSN = tc.dsn
+			cfg.FileStoragePath = ""
+			if len(tc.path) > 0 {
+				cfg.FileStoragePath = filepath.Join(t.TempDir(), tc.path)
+			}
+			if len(tc.policy) > 0 {
+				cfg.WALSyncPolicy = tc.policy
+			}
+			s, err := New(&cfg)
+			if tc.hasError {
+				assert.Error(t, err)
+				if tc.wantErr != nil {
+					assert.ErrorIs(t, err, tc.wantErr)
+				}
+				assert.Nil(t, s, "Store should be nil on error")
+				return
+			}
+			assert.NoError(t, err)
+			assert.IsType(t, tc.wantType, s)
+			assert.NoError(t, s.Close())
+		})
+	}
+}
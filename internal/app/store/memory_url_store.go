@@ -3,42 +3,96 @@ package store
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sort"
 	"sync"
-
-	"go.uber.org/zap"
+	"time"
 
 	"github.com/shekshuev/shortener/internal/app/config"
-	"github.com/shekshuev/shortener/internal/app/logger"
+	"github.com/shekshuev/shortener/internal/app/deletion"
 	"github.com/shekshuev/shortener/internal/app/models"
 )
 
 // UserURL представляет структуру для хранения информации о сокращённом URL.
 type UserURL struct {
-	UserID    string
-	URL       string
-	IsDeleted bool
+	UserID       string
+	URL          string
+	IsDeleted    bool
+	DeletedAt    *time.Time
+	ExpiresAt    *time.Time
+	CreatedAt    time.Time
+	RedirectType int
+	ForwardQuery string
+	ForwardPath  bool
+}
+
+// IsExpired сообщает, истёк ли срок действия ссылки к моменту now.
+func (u UserURL) IsExpired(now time.Time) bool {
+	return u.ExpiresAt != nil && !u.ExpiresAt.After(now)
+}
+
+// optionalTime возвращает указатель на момент времени или nil для нулевого значения.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// timeOrZero возвращает момент времени по указателю или нулевое значение для nil.
+func timeOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
 }
 
 // MemoryURLStore - хранилище URL в оперативной памяти.
+// Изменения дописываются в журнал упреждающей записи, чтобы переживать аварийное завершение процесса.
 type MemoryURLStore struct {
-	mx   sync.RWMutex
-	urls map[string]UserURL
+	mx    sync.RWMutex
+	urls  map[string]UserURL
+	byURL map[string]map[string]struct{}
+	// byUserURL хранит ключ ссылки по пользователю и точному оригинальному URL для поиска повторных сокращений.
+	byUserURL map[string]string
+	// byUser хранит ключи ссылок каждого пользователя, упорядоченные по моменту создания и ключу.
+	byUser map[string][]userURLRef
+	clicks map[string][]models.ClickEvent
+	// apiKeys хранит ключи API по хэшу. Как и переходы, ключи не попадают в снапшот и журнал.
+	apiKeys map[string]models.APIKey
+	// sequence - последнее выданное значение счётчика ключей. Хранится в снапшоте и журнале,
+	// чтобы после удаления ссылок стратегии sequence и obfuscated не выдавали их ключи повторно.
+	sequence uint64
+	// jobs хранит задачи удаления по идентификатору. В отличие от ключей API задачи попадают в снапшот и журнал,
+	// чтобы невыполненные задачи возобновлялись после перезапуска.
+	jobs map[string]models.DeletionJob
 	cfg  *config.Config
+	wal  *writeAheadLog
 }
 
-// NewMemoryURLStore создаёт новый экземпляр MemoryURLStore.
-func NewMemoryURLStore(cfg *config.Config) *MemoryURLStore {
-	store := &MemoryURLStore{urls: make(map[string]UserURL), cfg: cfg}
-	log := logger.NewLogger()
-	err := store.LoadSnapshot()
-	if err != nil {
-		log.Log.Error("Error loading snapshot", zap.Error(err))
+// NewMemoryURLStore создаёт новый экземпляр MemoryURLStore, восстанавливает данные из снапшота и журнала
+// и открывает журнал упреждающей записи.
+func NewMemoryURLStore(cfg *config.Config) (*MemoryURLStore, error) {
+	store := &MemoryURLStore{urls: make(map[string]UserURL), clicks: make(map[string][]models.ClickEvent), cfg: cfg}
+	if err := store.LoadSnapshot(); err != nil {
+		return nil, fmt.Errorf("load snapshot: %w", err)
+	}
+	// Данные, сохранённые до появления счётчика, не содержат его значения:
+	// продолжаем его с количества ссылок, как это делалось раньше.
+	store.sequence = max(store.sequence, uint64(len(store.urls)))
+	if len(cfg.FileStoragePath) > 0 {
+		wal, err := openWriteAheadLog(walPath(cfg.FileStoragePath), cfg.WALSyncPolicy, cfg.WALSyncInterval)
+		if err != nil {
+			return nil, fmt.Errorf("open write-ahead log: %w", err)
+		}
+		store.wal = wal
 	}
-	return store
+	return store, nil
 }
 
-// SetURL сохраняет новый URL в хранилище.
-func (s *MemoryURLStore) SetURL(_ context.Context, key, value, userID string) (string, error) {
+// SetURL сохраняет новый URL в хранилище и возвращает его короткий ключ.
+// Если пользователь уже сокращал этот URL, возвращается существующий ключ и ErrAlreadyExists.
+func (s *MemoryURLStore) SetURL(_ context.Context, key, value, userID string, opts models.LinkOptions) (string, error) {
 	s.mx.Lock()
 	defer s.mx.Unlock()
 	if s.urls == nil {
@@ -53,11 +107,23 @@ func (s *MemoryURLStore) SetURL(_ context.Context, key, value, userID string) (s
 	if len(userID) == 0 {
 		return "", ErrEmptyUserID
 	}
-	s.urls[key] = UserURL{UserID: userID, URL: value}
-	return value, nil
+	if existing, exists := s.findUserURL(userID, value); exists {
+		return existing, ErrAlreadyExists
+	}
+	if _, exists := s.urls[key]; exists {
+		return "", ErrKeyTaken
+	}
+	userURL := UserURL{UserID: userID, URL: value, ExpiresAt: opts.ExpiresAt, CreatedAt: time.Now(), RedirectType: opts.RedirectType, ForwardQuery: opts.ForwardQuery, ForwardPath: opts.ForwardPath}
+	if err := s.wal.Append(walRecord(walOpSet, key, userURL)); err != nil {
+		return "", err
+	}
+	s.putURL(key, userURL)
+	return key, nil
 }
 
 // SetBatchURL сохраняет пакет URL в хранилище.
+// Для URL, которые пользователь уже сокращал, в createDTO подставляется существующий ключ,
+// остальные URL сохраняются, а в конце возвращается ErrAlreadyExists.
 func (s *MemoryURLStore) SetBatchURL(_ context.Context, createDTO []models.BatchShortURLCreateDTO, userID string) error {
 	s.mx.Lock()
 	defer s.mx.Unlock()
@@ -78,27 +144,76 @@ func (s *MemoryURLStore) SetBatchURL(_ context.Context, createDTO []models.Batch
 			return ErrEmptyValue
 		}
 	}
-	for _, dto := range createDTO {
-		s.urls[dto.ShortURL] = UserURL{UserID: userID, URL: dto.OriginalURL}
+	keys := make(map[string]struct{}, len(createDTO))
+	batchURLs := make(map[string]string, len(createDTO))
+	isNew := make([]bool, len(createDTO))
+	hasSameURL := false
+	for i, dto := range createDTO {
+		if existing, exists := s.findUserURL(userID, dto.OriginalURL); exists {
+			createDTO[i].ShortURL = existing
+			hasSameURL = true
+			continue
+		}
+		if existing, exists := batchURLs[dto.OriginalURL]; exists {
+			createDTO[i].ShortURL = existing
+			hasSameURL = true
+			continue
+		}
+		if _, exists := s.urls[dto.ShortURL]; exists {
+			return ErrKeyTaken
+		}
+		if _, exists := keys[dto.ShortURL]; exists {
+			return ErrKeyTaken
+		}
+		keys[dto.ShortURL] = struct{}{}
+		batchURLs[dto.OriginalURL] = dto.ShortURL
+		isNew[i] = true
+	}
+	now := time.Now()
+	records := make([]models.SerializeData, 0, len(createDTO))
+	for i, dto := range createDTO {
+		if isNew[i] {
+			records = append(records, walRecord(walOpSet, dto.ShortURL, UserURL{UserID: userID, URL: dto.OriginalURL, ExpiresAt: dto.ExpiresAt, CreatedAt: now, RedirectType: dto.RedirectType, ForwardQuery: dto.ForwardQuery, ForwardPath: dto.ForwardPath}))
+		}
+	}
+	if err := s.wal.Append(records...); err != nil {
+		return err
+	}
+	for i, dto := range createDTO {
+		if isNew[i] {
+			s.putURL(dto.ShortURL, UserURL{UserID: userID, URL: dto.OriginalURL, ExpiresAt: dto.ExpiresAt, CreatedAt: now, RedirectType: dto.RedirectType, ForwardQuery: dto.ForwardQuery, ForwardPath: dto.ForwardPath})
+		}
+	}
+	if hasSameURL {
+		return ErrAlreadyExists
 	}
 	return nil
 }
 
 // GetURL возвращает оригинальный URL по короткому ключу.
-func (s *MemoryURLStore) GetURL(_ context.Context, key string) (string, error) {
+func (s *MemoryURLStore) GetURL(ctx context.Context, key string) (string, error) {
+	redirect, err := s.GetRedirect(ctx, key)
+	return redirect.URL, err
+}
+
+// GetRedirect возвращает оригинальный URL и код ответа при переходе по короткому ключу.
+func (s *MemoryURLStore) GetRedirect(_ context.Context, key string) (models.RedirectDTO, error) {
 	s.mx.RLock()
 	defer s.mx.RUnlock()
 	if s.urls == nil {
-		return "", ErrNotInitialized
+		return models.RedirectDTO{}, ErrNotInitialized
 	}
 	value, exists := s.urls[key]
 	if !exists {
-		return "", ErrNotFound
+		return models.RedirectDTO{}, ErrNotFound
 	}
 	if value.IsDeleted {
-		return "", ErrAlreadyDeleted
+		return models.RedirectDTO{}, ErrAlreadyDeleted
 	}
-	return value.URL, nil
+	if value.IsExpired(time.Now()) {
+		return models.RedirectDTO{}, ErrExpired
+	}
+	return models.RedirectDTO{URL: value.URL, Type: value.RedirectType, ForwardQuery: value.ForwardQuery, ForwardPath: value.ForwardPath, ExpiresAt: value.ExpiresAt}, nil
 }
 
 // GetUserURLs возвращает список URL пользователя.
@@ -109,9 +224,9 @@ func (s *MemoryURLStore) GetUserURLs(_ context.Context, userID string) ([]models
 		return nil, ErrNotInitialized
 	}
 	var readDTO []models.UserShortURLReadDTO
-	for key, value := range s.urls {
-		if value.UserID == userID && !value.IsDeleted {
-			readDTO = append(readDTO, models.UserShortURLReadDTO{ShortURL: fmt.Sprintf("%s/%s", s.cfg.BaseURL, key), OriginalURL: value.URL})
+	for _, ref := range s.byUser[userID] {
+		if value := s.urls[ref.key]; !value.IsDeleted {
+			readDTO = append(readDTO, models.UserShortURLReadDTO{ShortURL: fmt.Sprintf("%s/%s", s.cfg.BaseURL, ref.key), OriginalURL: value.URL})
 		}
 	}
 	if len(readDTO) == 0 {
@@ -120,36 +235,476 @@ func (s *MemoryURLStore) GetUserURLs(_ context.Context, userID string) ([]models
 	return readDTO, nil
 }
 
+// GetUserURLsPage возвращает страницу ссылок пользователя, отфильтрованных и отсортированных согласно query.
+func (s *MemoryURLStore) GetUserURLsPage(_ context.Context, userID string, query models.UserURLsQuery) (models.UserURLsPageDTO, error) {
+	cursor, err := prepareUserURLsQuery(&query)
+	if err != nil {
+		return models.UserURLsPageDTO{}, err
+	}
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	if s.urls == nil {
+		return models.UserURLsPageDTO{}, ErrNotInitialized
+	}
+	refs := s.byUser[userID]
+	if query.Sort == SortCreatedAt {
+		return buildUserURLsPage(s.seekUserURLs(refs, query, cursor), query, s.cfg.BaseURL), nil
+	}
+	var entries []userURLEntry
+	for _, ref := range refs {
+		if value := s.urls[ref.key]; !value.IsDeleted && matchesURLQuery(value.URL, query.Query) {
+			entries = append(entries, userURLEntry{key: ref.key, url: value.URL, createdAt: value.CreatedAt})
+		}
+	}
+	return paginateUserURLs(entries, query, cursor, s.cfg.BaseURL), nil
+}
+
+// seekUserURLs находит в индексе ссылок пользователя позицию курсора и возвращает
+// не больше query.Limit+1 следующих за ним неудалённых ссылок, подходящих под фильтр, а при нулевом query.Limit - все.
+// Лишняя ссылка показывает buildUserURLsPage, что есть следующая страница.
+// Вызывается под блокировкой хранилища.
+func (s *MemoryURLStore) seekUserURLs(refs []userURLRef, query models.UserURLsQuery, cursor *pageCursor) []userURLEntry {
+	next := func(i int) int { return i + 1 }
+	i := 0
+	if query.Order == OrderDesc {
+		next = func(i int) int { return i - 1 }
+		i = len(refs) - 1
+	}
+	if cursor != nil {
+		after := userURLRef{created: cursor.Value, key: cursor.Key}
+		if query.Order == OrderDesc {
+			i = sort.Search(len(refs), func(i int) bool { return !refs[i].less(after) }) - 1
+		} else {
+			i = sort.Search(len(refs), func(i int) bool { return after.less(refs[i]) })
+		}
+	}
+	var entries []userURLEntry
+	for ; i >= 0 && i < len(refs) && (query.Limit == 0 || len(entries) <= query.Limit); i = next(i) {
+		value := s.urls[refs[i].key]
+		if !value.IsDeleted && matchesURLQuery(value.URL, query.Query) {
+			entries = append(entries, userURLEntry{key: refs[i].key, url: value.URL, createdAt: value.CreatedAt})
+		}
+	}
+	return entries
+}
+
+// LookupUserURLs возвращает неудалённые ссылки пользователя, ведущие на URL с нормализованным видом normalizedURL.
+// Ссылки ищутся по вторичному индексу без перебора всего хранилища.
+func (s *MemoryURLStore) LookupUserURLs(_ context.Context, userID, normalizedURL string) ([]models.UserShortURLReadDTO, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	if s.urls == nil {
+		return nil, ErrNotInitialized
+	}
+	keys := make([]string, 0, len(s.byURL[urlIndexKey(userID, normalizedURL)]))
+	for key := range s.byURL[urlIndexKey(userID, normalizedURL)] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var readDTO []models.UserShortURLReadDTO
+	for _, key := range keys {
+		if value := s.urls[key]; !value.IsDeleted {
+			readDTO = append(readDTO, models.UserShortURLReadDTO{ShortURL: fmt.Sprintf("%s/%s", s.cfg.BaseURL, key), OriginalURL: value.URL})
+		}
+	}
+	return readDTO, nil
+}
+
 // DeleteURLs помечает список URL как удалённые.
-func (s *MemoryURLStore) DeleteURLs(_ context.Context, userID string, urls []string) error {
+func (s *MemoryURLStore) DeleteURLs(ctx context.Context, userID string, urls []string) error {
+	return s.DeleteURLsBatch(ctx, []models.DeletionTask{{UserID: userID, ShortURLs: urls}})
+}
+
+// DeleteURLsBatch помечает удалёнными ссылки нескольких пользователей одной записью в журнал.
+func (s *MemoryURLStore) DeleteURLsBatch(_ context.Context, tasks []models.DeletionTask) error {
 	s.mx.Lock()
 	defer s.mx.Unlock()
 
 	if s.urls == nil {
 		return ErrNotInitialized
 	}
+
+	now := time.Now()
+	var records []models.SerializeData
+	for _, task := range tasks {
+		if len(task.UserID) == 0 {
+			return ErrEmptyUserID
+		}
+		if len(task.ShortURLs) == 0 {
+			return ErrEmptyURLs
+		}
+		for _, shortURL := range task.ShortURLs {
+			if value, exists := s.urls[shortURL]; exists && value.UserID == task.UserID && !value.IsDeleted {
+				value.DeletedAt = &now
+				records = append(records, walRecord(walOpDelete, shortURL, value))
+			}
+		}
+	}
+	if err := s.wal.Append(records...); err != nil {
+		return err
+	}
+	for _, record := range records {
+		value := s.urls[record.ShortURL]
+		value.IsDeleted = true
+		value.DeletedAt = &now
+		s.putURL(record.ShortURL, value)
+	}
+
+	return nil
+}
+
+// RestoreURLs снимает пометку удаления со ссылок пользователя, удалённых не раньше deletedSince,
+// и возвращает ключи восстановленных ссылок.
+func (s *MemoryURLStore) RestoreURLs(_ context.Context, userID string, urls []string, deletedSince time.Time) ([]string, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.urls == nil {
+		return nil, ErrNotInitialized
+	}
 	if len(userID) == 0 {
-		return ErrEmptyUserID
+		return nil, ErrEmptyUserID
 	}
 	if len(urls) == 0 {
-		return ErrEmptyURLs
+		return nil, ErrEmptyURLs
 	}
-
+	var records []models.SerializeData
+	restoring := make(map[string]struct{}, len(urls))
 	for _, shortURL := range urls {
-		if value, exists := s.urls[shortURL]; exists {
-			if value.UserID == userID {
-				value.IsDeleted = true
-				s.urls[shortURL] = value
-			}
+		value, exists := s.urls[shortURL]
+		if !exists || value.UserID != userID || !value.IsDeleted || value.DeletedAt == nil || value.DeletedAt.Before(deletedSince) {
+			continue
+		}
+		// Ссылка не восстанавливается, если пользователь уже заново сократил тот же URL.
+		if _, exists := s.findUserURL(userID, value.URL); exists {
+			continue
+		}
+		if _, exists := restoring[value.URL]; exists {
+			continue
+		}
+		restoring[value.URL] = struct{}{}
+		records = append(records, walRecord(walOpRestore, shortURL, value))
+	}
+	if err := s.wal.Append(records...); err != nil {
+		return nil, err
+	}
+	restored := make([]string, 0, len(records))
+	for _, record := range records {
+		value := s.urls[record.ShortURL]
+		value.IsDeleted = false
+		value.DeletedAt = nil
+		s.putURL(record.ShortURL, value)
+		restored = append(restored, record.ShortURL)
+	}
+	return restored, nil
+}
+
+// PurgeDeleted окончательно удаляет ссылки, удалённые раньше deletedBefore, и возвращает их количество.
+// Ссылки, удалённые до появления отметки времени удаления, удаляются при первом вызове.
+func (s *MemoryURLStore) PurgeDeleted(_ context.Context, deletedBefore time.Time) (int, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.urls == nil {
+		return 0, ErrNotInitialized
+	}
+	var records []models.SerializeData
+	for key, value := range s.urls {
+		if value.IsDeleted && (value.DeletedAt == nil || value.DeletedAt.Before(deletedBefore)) {
+			records = append(records, walRecord(walOpPurge, key, value))
+		}
+	}
+	if err := s.wal.Append(records...); err != nil {
+		return 0, err
+	}
+	for _, record := range records {
+		s.removeURL(record.ShortURL)
+		delete(s.clicks, record.ShortURL)
+	}
+	return len(records), nil
+}
+
+// UpdateURL изменяет оригинальный URL и код ответа при переходе по ссылке пользователя одной записью в журнал.
+// Незаполненные поля update не меняются.
+// Возвращает ErrNotFound, если ссылка не найдена, удалена или принадлежит другому пользователю,
+// и ErrAlreadyExists, если пользователь уже сократил новый URL под другим ключом.
+func (s *MemoryURLStore) UpdateURL(_ context.Context, userID, key string, update models.ShortURLUpdateDTO) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.urls == nil {
+		return ErrNotInitialized
+	}
+	if len(key) == 0 {
+		return ErrEmptyKey
+	}
+	if len(update.URL) == 0 && update.RedirectType == 0 {
+		return ErrEmptyValue
+	}
+	if len(userID) == 0 {
+		return ErrEmptyUserID
+	}
+	current, exists := s.urls[key]
+	if !exists || current.IsDeleted || current.UserID != userID {
+		return ErrNotFound
+	}
+	if len(update.URL) > 0 {
+		if existing, exists := s.findUserURL(userID, update.URL); exists && existing != key {
+			return ErrAlreadyExists
+		}
+		current.URL = update.URL
+	}
+	if update.RedirectType != 0 {
+		current.RedirectType = update.RedirectType
+	}
+	if err := s.wal.Append(walRecord(walOpSet, key, current)); err != nil {
+		return err
+	}
+	s.putURL(key, current)
+	return nil
+}
+
+// DeleteExpired удаляет из памяти ссылки с истёкшим сроком действия и возвращает их количество.
+func (s *MemoryURLStore) DeleteExpired(_ context.Context) (int, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.urls == nil {
+		return 0, ErrNotInitialized
+	}
+	now := time.Now()
+	var records []models.SerializeData
+	for key, value := range s.urls {
+		if value.IsExpired(now) {
+			records = append(records, walRecord(walOpPurge, key, value))
+		}
+	}
+	if err := s.wal.Append(records...); err != nil {
+		return 0, err
+	}
+	for _, record := range records {
+		s.removeURL(record.ShortURL)
+		delete(s.clicks, record.ShortURL)
+	}
+	return len(records), nil
+}
+
+// SaveClicks сохраняет события переходов в памяти.
+func (s *MemoryURLStore) SaveClicks(_ context.Context, events []models.ClickEvent) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.urls == nil {
+		return ErrNotInitialized
+	}
+	if s.clicks == nil {
+		s.clicks = make(map[string][]models.ClickEvent)
+	}
+	for _, event := range events {
+		s.clicks[event.ShortURL] = append(s.clicks[event.ShortURL], event)
+	}
+	return nil
+}
+
+// GetURLStats возвращает статистику переходов по ссылке, принадлежащей пользователю.
+func (s *MemoryURLStore) GetURLStats(_ context.Context, userID, key string) (models.URLStatsDTO, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	if s.urls == nil {
+		return models.URLStatsDTO{}, ErrNotInitialized
+	}
+	value, exists := s.urls[key]
+	if !exists || value.UserID != userID {
+		return models.URLStatsDTO{}, ErrNotFound
+	}
+	return buildURLStats(key, s.clicks[key]), nil
+}
+
+// GetURLInfo возвращает сведения о ссылке по короткому ключу: владельца, оригинальный URL,
+// момент создания, срок действия и количество переходов. Для удалённой ссылки возвращается ErrAlreadyDeleted.
+func (s *MemoryURLStore) GetURLInfo(_ context.Context, key string) (models.URLInfoDTO, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	if s.urls == nil {
+		return models.URLInfoDTO{}, ErrNotInitialized
+	}
+	value, exists := s.urls[key]
+	if !exists {
+		return models.URLInfoDTO{}, ErrNotFound
+	}
+	if value.IsDeleted {
+		return models.URLInfoDTO{}, ErrAlreadyDeleted
+	}
+	return models.URLInfoDTO{
+		ShortURL:     key,
+		OriginalURL:  value.URL,
+		UserID:       value.UserID,
+		CreatedAt:    optionalTime(value.CreatedAt),
+		ExpiresAt:    value.ExpiresAt,
+		Clicks:       len(s.clicks[key]),
+		RedirectType: value.RedirectType,
+		ForwardQuery: value.ForwardQuery,
+		ForwardPath:  value.ForwardPath,
+	}, nil
+}
+
+// CreateAPIKey сохраняет ключ API в памяти.
+func (s *MemoryURLStore) CreateAPIKey(_ context.Context, key models.APIKey) error {
+	if len(key.UserID) == 0 {
+		return ErrEmptyUserID
+	}
+	if len(key.Hash) == 0 {
+		return ErrEmptyKey
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.urls == nil {
+		return ErrNotInitialized
+	}
+	if s.apiKeys == nil {
+		s.apiKeys = make(map[string]models.APIKey)
+	}
+	if _, exists := s.apiKeys[key.Hash]; exists {
+		return ErrAlreadyExists
+	}
+	s.apiKeys[key.Hash] = key
+	return nil
+}
+
+// GetUserAPIKeys возвращает ключи API пользователя в порядке создания.
+func (s *MemoryURLStore) GetUserAPIKeys(_ context.Context, userID string) ([]models.APIKey, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	if s.urls == nil {
+		return nil, ErrNotInitialized
+	}
+	var keys []models.APIKey
+	for _, key := range s.apiKeys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	sortAPIKeys(keys)
+	return keys, nil
+}
+
+// GetAPIKeyByHash возвращает ключ API по его хэшу.
+func (s *MemoryURLStore) GetAPIKeyByHash(_ context.Context, hash string) (models.APIKey, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	if s.urls == nil {
+		return models.APIKey{}, ErrNotInitialized
+	}
+	key, exists := s.apiKeys[hash]
+	if !exists {
+		return models.APIKey{}, ErrNotFound
+	}
+	return key, nil
+}
+
+// RevokeAPIKey удаляет ключ API пользователя.
+// Возвращает ErrNotFound, если ключ не найден или принадлежит другому пользователю.
+func (s *MemoryURLStore) RevokeAPIKey(_ context.Context, userID, id string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.urls == nil {
+		return ErrNotInitialized
+	}
+	for hash, key := range s.apiKeys {
+		if key.ID == id && key.UserID == userID {
+			delete(s.apiKeys, hash)
+			return nil
 		}
 	}
+	return ErrNotFound
+}
 
+// TouchAPIKey запоминает момент последнего использования ключа API.
+func (s *MemoryURLStore) TouchAPIKey(_ context.Context, id string, usedAt time.Time) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.urls == nil {
+		return ErrNotInitialized
+	}
+	for hash, key := range s.apiKeys {
+		if key.ID == id {
+			key.LastUsedAt = &usedAt
+			s.apiKeys[hash] = key
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// SaveDeletionJob сохраняет задачу удаления или обновляет состояние уже сохранённой задачи.
+func (s *MemoryURLStore) SaveDeletionJob(_ context.Context, job models.DeletionJob) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.urls == nil {
+		return ErrNotInitialized
+	}
+	if err := s.wal.Append(models.SerializeData{Op: walOpJob, Job: &job}); err != nil {
+		return err
+	}
+	s.putDeletionJob(job)
 	return nil
 }
 
-// Close завершает работу хранилища, создавая снапшот.
+// GetDeletionJob возвращает задачу удаления по идентификатору или deletion.ErrJobNotFound.
+func (s *MemoryURLStore) GetDeletionJob(_ context.Context, id string) (models.DeletionJob, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	job, exists := s.jobs[id]
+	if !exists {
+		return models.DeletionJob{}, deletion.ErrJobNotFound
+	}
+	return job, nil
+}
+
+// PendingDeletionJobs возвращает невыполненные задачи удаления в порядке постановки в очередь.
+func (s *MemoryURLStore) PendingDeletionJobs(_ context.Context) ([]models.DeletionJob, error) {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	var jobs []models.DeletionJob
+	for _, job := range s.jobs {
+		if job.Status == models.DeletionJobPending {
+			jobs = append(jobs, job)
+		}
+	}
+	sortDeletionJobs(jobs)
+	return jobs, nil
+}
+
+// PurgeDeletionJobs удаляет задачи, завершённые раньше finishedBefore, и возвращает их количество.
+func (s *MemoryURLStore) PurgeDeletionJobs(_ context.Context, finishedBefore time.Time) (int, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	var records []models.SerializeData
+	for _, job := range s.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(finishedBefore) {
+			records = append(records, models.SerializeData{Op: walOpJobPurge, Job: &models.DeletionJob{DeletionJobDTO: models.DeletionJobDTO{ID: job.ID}}})
+		}
+	}
+	if err := s.wal.Append(records...); err != nil {
+		return 0, err
+	}
+	for _, record := range records {
+		delete(s.jobs, record.Job.ID)
+	}
+	return len(records), nil
+}
+
+// putDeletionJob сохраняет задачу удаления в памяти. Вызывается под блокировкой хранилища.
+func (s *MemoryURLStore) putDeletionJob(job models.DeletionJob) {
+	if s.jobs == nil {
+		s.jobs = make(map[string]models.DeletionJob)
+	}
+	s.jobs[job.ID] = job
+}
+
+// Close завершает работу хранилища: создаёт снапшот, очищая вошедший в него журнал, и закрывает журнал.
+// Если снапшот создать не удалось, журнал сохраняется и будет применён при следующем запуске.
 func (s *MemoryURLStore) Close() error {
-	return s.CreateSnapshot()
+	if err := s.CreateSnapshot(); err != nil {
+		s.wal.Close()
+		return err
+	}
+	return s.wal.Close()
 }
 
 // CountURLs возвращает количество всех сокращённых URL в памяти.
@@ -169,3 +724,123 @@ func (s *MemoryURLStore) CountUsers(_ context.Context) (int, error) {
 	}
 	return len(users), nil
 }
+
+// NextSequence возвращает следующее значение счётчика ключей и сохраняет его в журнале.
+func (s *MemoryURLStore) NextSequence(_ context.Context) (uint64, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	n := s.sequence + 1
+	if err := s.wal.Append(models.SerializeData{Op: walOpSequence, Sequence: n}); err != nil {
+		return 0, err
+	}
+	s.sequence = n
+	return n, nil
+}
+
+// putURL сохраняет ссылку и обновляет индексы ссылок по нормализованному и точному оригинальному URL и по пользователю.
+// В индекс точных URL попадают только неудалённые ссылки. Вызывается под блокировкой хранилища.
+func (s *MemoryURLStore) putURL(key string, value UserURL) {
+	s.removeURL(key)
+	s.urls[key] = value
+	if s.byURL == nil {
+		s.byURL = make(map[string]map[string]struct{})
+	}
+	indexKey := urlIndexKey(value.UserID, normalizedURL(value.URL))
+	if s.byURL[indexKey] == nil {
+		s.byURL[indexKey] = make(map[string]struct{})
+	}
+	s.byURL[indexKey][key] = struct{}{}
+	if s.byUserURL == nil {
+		s.byUserURL = make(map[string]string)
+	}
+	if !value.IsDeleted {
+		s.byUserURL[urlIndexKey(value.UserID, value.URL)] = key
+	}
+	if s.byUser == nil {
+		s.byUser = make(map[string][]userURLRef)
+	}
+	ref := userURLRef{created: cursorTime(value.CreatedAt), key: key}
+	refs := s.byUser[value.UserID]
+	i := sort.Search(len(refs), func(i int) bool { return ref.less(refs[i]) })
+	s.byUser[value.UserID] = slices.Insert(refs, i, ref)
+}
+
+// removeURL удаляет ссылку вместе с её записями в индексах по оригинальному URL и по пользователю.
+// Вызывается под блокировкой хранилища.
+func (s *MemoryURLStore) removeURL(key string) {
+	value, exists := s.urls[key]
+	if !exists {
+		return
+	}
+	delete(s.urls, key)
+	indexKey := urlIndexKey(value.UserID, normalizedURL(value.URL))
+	delete(s.byURL[indexKey], key)
+	if len(s.byURL[indexKey]) == 0 {
+		delete(s.byURL, indexKey)
+	}
+	if exactKey := urlIndexKey(value.UserID, value.URL); s.byUserURL[exactKey] == key {
+		delete(s.byUserURL, exactKey)
+	}
+	ref := userURLRef{created: cursorTime(value.CreatedAt), key: key}
+	refs := s.byUser[value.UserID]
+	if i := sort.Search(len(refs), func(i int) bool { return !refs[i].less(ref) }); i < len(refs) && refs[i] == ref {
+		refs = slices.Delete(refs, i, i+1)
+	}
+	if len(refs) == 0 {
+		delete(s.byUser, value.UserID)
+	} else {
+		s.byUser[value.UserID] = refs
+	}
+}
+
+// userURLRef - запись индекса ссылок пользователя. Момент создания хранится в том же виде,
+// что и в курсоре, чтобы позицию курсора можно было найти двоичным поиском.
+type userURLRef struct {
+	created string
+	key     string
+}
+
+// less сравнивает записи индекса по моменту создания, а при равенстве - по ключу.
+func (r userURLRef) less(other userURLRef) bool {
+	if r.created != other.created {
+		return r.created < other.created
+	}
+	return r.key < other.key
+}
+
+// urlIndexKey возвращает ключ индекса ссылок пользователя по нормализованному оригинальному URL.
+func urlIndexKey(userID, normalizedURL string) string {
+	return userID + "\x00" + normalizedURL
+}
+
+// findUserURL ищет ключ, под которым пользователь уже сократил URL, по индексу точных URL.
+// Ссылка с истёкшим сроком действия убирается из индекса и не считается повтором.
+// Вызывается под блокировкой хранилища.
+func (s *MemoryURLStore) findUserURL(userID, value string) (string, bool) {
+	indexKey := urlIndexKey(userID, value)
+	key, exists := s.byUserURL[indexKey]
+	if exists && s.urls[key].IsExpired(time.Now()) {
+		delete(s.byUserURL, indexKey)
+		return "", false
+	}
+	return key, exists
+}
+
+// buildURLStats подсчитывает общее количество переходов, уникальных посетителей и переходы по дням.
+func buildURLStats(key string, events []models.ClickEvent) models.URLStatsDTO {
+	stats := models.URLStatsDTO{ShortURL: key, Clicks: len(events), Daily: []models.DailyClicksDTO{}}
+	visitors := make(map[string]struct{})
+	daily := make(map[string]int)
+	for _, event := range events {
+		visitors[event.IP] = struct{}{}
+		daily[event.Timestamp.UTC().Format(time.DateOnly)]++
+	}
+	stats.UniqueVisitors = len(visitors)
+	for date, clicks := range daily {
+		stats.Daily = append(stats.Daily, models.DailyClicksDTO{Date: date, Clicks: clicks})
+	}
+	sort.Slice(stats.Daily, func(i, j int) bool {
+		return stats.Daily[i].Date < stats.Daily[j].Date
+	})
+	return stats
+}
@@ -2,42 +2,134 @@ package store
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
+	"go.uber.org/zap"
+
+	"github.com/shekshuev/shortener/internal/app/logger"
 	"github.com/shekshuev/shortener/internal/app/models"
 )
 
-// CreateSnapshot создаёт снапшот хранилища в файл.
+// snapshotVersion - текущая версия формата снапшота.
+// Версия 1 - исходный формат без заголовка и без признака удаления.
+const snapshotVersion = 2
+
+// ErrUnsupportedSnapshotVersion - ошибка, указывающая на снапшот неизвестной версии формата.
+var ErrUnsupportedSnapshotVersion = fmt.Errorf("unsupported snapshot version")
+
+// Snapshotter - хранилище, умеющее сохранять своё состояние в снапшот.
+type Snapshotter interface {
+	CreateSnapshot() error
+}
+
+// CreateSnapshot атомарно создаёт снапшот хранилища в файл и очищает журнал упреждающей записи.
+// Данные пишутся во временный файл рядом с целевым, сбрасываются на диск и переименовываются,
+// поэтому при аварии на диске остаётся либо старый, либо новый снапшот целиком.
 func (s *MemoryURLStore) CreateSnapshot() error {
-	file, err := os.OpenFile(s.cfg.FileStoragePath, os.O_CREATE|os.O_WRONLY, 0644)
+	if len(s.cfg.FileStoragePath) == 0 {
+		return nil
+	}
+	// Блокировка на чтение не даёт писателям дописать журнал между снапшотом и его очисткой.
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	if err := s.writeSnapshotFile(); err != nil {
+		return err
+	}
+	return s.wal.Truncate()
+}
+
+// writeSnapshotFile записывает содержимое хранилища во временный файл и атомарно подменяет им снапшот.
+// Вызывается под блокировкой хранилища.
+func (s *MemoryURLStore) writeSnapshotFile() error {
+	dir, name := filepath.Split(s.cfg.FileStoragePath)
+	if len(dir) == 0 {
+		dir = "."
+	}
+	file, err := os.CreateTemp(dir, name+".tmp*")
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	tmpPath := file.Name()
+	defer os.Remove(tmpPath)
+
+	if err := s.encodeSnapshot(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, s.cfg.FileStoragePath); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
 
+// encodeSnapshot пишет заголовок и записи снапшота в формате JSON Lines.
+func (s *MemoryURLStore) encodeSnapshot(file *os.File) error {
+	w := bufio.NewWriter(file)
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(models.SnapshotHeader{Version: snapshotVersion, Sequence: s.sequence}); err != nil {
+		return err
+	}
 	for key, value := range s.urls {
 		urlData := models.SerializeData{
-			UserID:      value.UserID,
-			ShortURL:    key,
-			OriginalURL: value.URL,
+			UserID:       value.UserID,
+			ShortURL:     key,
+			OriginalURL:  value.URL,
+			ExpiresAt:    value.ExpiresAt,
+			IsDeleted:    value.IsDeleted,
+			DeletedAt:    value.DeletedAt,
+			CreatedAt:    optionalTime(value.CreatedAt),
+			RedirectType: value.RedirectType,
+			ForwardQuery: value.ForwardQuery,
+			ForwardPath:  value.ForwardPath,
 		}
-
-		data, err := json.Marshal(urlData)
-		if err != nil {
+		if err := encoder.Encode(urlData); err != nil {
 			return err
 		}
-
-		_, err = file.Write(append(data, '\n'))
-		if err != nil {
+	}
+	// Строки задач удаления не содержат короткого ключа, поэтому прежние версии их пропускают.
+	for _, job := range s.jobs {
+		if err := encoder.Encode(models.SerializeData{Job: &job}); err != nil {
 			return err
 		}
 	}
-	return nil
+	return w.Flush()
+}
+
+// syncDir сбрасывает на диск запись каталога, чтобы переименование файла пережило сбой питания.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
 }
 
-// LoadSnapshot загружает данные из файла снапшота в хранилище.
+// LoadSnapshot загружает данные из файла снапшота в хранилище
+// и применяет поверх них журнал упреждающей записи.
 func (s *MemoryURLStore) LoadSnapshot() error {
+	if err := s.loadSnapshotFile(); err != nil {
+		return err
+	}
+	return s.replayWAL(walPath(s.cfg.FileStoragePath))
+}
+
+// loadSnapshotFile загружает данные из файла снапшота, если он существует.
+// Файлы без заголовка читаются как снапшоты версии 1. Повреждённая запись не в конце файла
+// возвращается как ErrCorruptedRecord.
+func (s *MemoryURLStore) loadSnapshotFile() error {
 	if _, err := os.Stat(s.cfg.FileStoragePath); os.IsNotExist(err) {
 		return nil
 	}
@@ -48,19 +140,62 @@ func (s *MemoryURLStore) LoadSnapshot() error {
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
+	first := true
+	return readJSONLines(file, func(line []byte) error {
+		if first {
+			first = false
+			var header models.SnapshotHeader
+			if err := json.Unmarshal(line, &header); err == nil && header.Version > 0 {
+				if header.Version > snapshotVersion {
+					return fmt.Errorf("%w: %d", ErrUnsupportedSnapshotVersion, header.Version)
+				}
+				s.sequence = header.Sequence
+				return nil
+			}
+		}
 		var urlData models.SerializeData
-		err := json.Unmarshal(scanner.Bytes(), &urlData)
-		if err != nil {
-			continue
+		if err := json.Unmarshal(line, &urlData); err != nil {
+			return fmt.Errorf("%w: %v", ErrCorruptedRecord, err)
 		}
-		s.urls[urlData.ShortURL] = UserURL{UserID: urlData.UserID, URL: urlData.OriginalURL}
-	}
+		if urlData.Job != nil {
+			s.putDeletionJob(*urlData.Job)
+			return nil
+		}
+		if len(urlData.ShortURL) == 0 {
+			return nil
+		}
+		s.putURL(urlData.ShortURL, UserURL{
+			UserID:       urlData.UserID,
+			URL:          urlData.OriginalURL,
+			ExpiresAt:    urlData.ExpiresAt,
+			IsDeleted:    urlData.IsDeleted,
+			DeletedAt:    urlData.DeletedAt,
+			CreatedAt:    timeOrZero(urlData.CreatedAt),
+			RedirectType: urlData.RedirectType,
+			ForwardQuery: urlData.ForwardQuery,
+			ForwardPath:  urlData.ForwardPath,
+		})
+		return nil
+	})
+}
 
-	if err := scanner.Err(); err != nil {
-		return err
+// RunSnapshotter периодически создаёт снапшот хранилища.
+// Работает до отмены контекста; при неположительном интервале сразу завершается.
+func RunSnapshotter(ctx context.Context, s Snapshotter, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	log := logger.NewLogger()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.CreateSnapshot(); err != nil {
+				log.Log.Error("Error creating snapshot", zap.Error(err))
+			}
+		}
 	}
-
-	return nil
 }
@@ -1,8 +1,12 @@
 package store
 
 import (
+	"context"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/shekshuev/shortener/internal/app/config"
 	"github.com/stretchr/testify/assert"
@@ -59,3 +63,133 @@ func TestLoadSnapshot_FileDoesNotExist(t *testing.T) {
 	assert.Nil(t, err, "Error should be nil when loading snapshot from non-existent file")
 	assert.Empty(t, store.urls, "URL map should be empty when loading from a non-existent file")
 }
+
+func newSnapshotTestStore(t *testing.T) *MemoryURLStore {
+	cfg := config.GetConfig()
+	cfg.FileStoragePath = filepath.Join(t.TempDir(), "storage.txt")
+	return &MemoryURLStore{urls: make(map[string]UserURL), cfg: &cfg}
+}
+
+func TestCreateSnapshot_PreservesDeletedState(t *testing.T) {
+	store := newSnapshotTestStore(t)
+	store.urls["short1"] = UserURL{UserID: "1", URL: "https://ya.ru"}
+	store.urls["short2"] = UserURL{UserID: "1", URL: "https://google.com", IsDeleted: true}
+	err := store.CreateSnapshot()
+	assert.Nil(t, err, "Error should be nil when creating snapshot")
+
+	store2 := &MemoryURLStore{urls: make(map[string]UserURL), cfg: store.cfg}
+	err = store2.LoadSnapshot()
+	assert.Nil(t, err, "Error should be nil when loading snapshot")
+	_, err = store2.GetURL(context.Background(), "short2")
+	assert.ErrorIs(t, err, ErrAlreadyDeleted, "Deleted url should stay deleted after restart")
+}
+
+func TestCreateSnapshot_Compacts(t *testing.T) {
+	store := newSnapshotTestStore(t)
+	store.urls["short1"] = UserURL{UserID: "1", URL: "https://ya.ru"}
+	store.urls["short2"] = UserURL{UserID: "1", URL: "https://google.com"}
+	assert.Nil(t, store.CreateSnapshot())
+
+	delete(store.urls, "short2")
+	assert.Nil(t, store.CreateSnapshot())
+
+	data, err := os.ReadFile(store.cfg.FileStoragePath)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 2, "Snapshot should contain header and one record")
+	assert.JSONEq(t, `{"version":2}`, lines[0])
+
+	matches, err := filepath.Glob(store.cfg.FileStoragePath + ".tmp*")
+	assert.Nil(t, err)
+	assert.Empty(t, matches, "Temporary files should be removed")
+}
+
+func TestLoadSnapshot_Versions(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		want    map[string]UserURL
+		wantErr error
+	}{
+		{
+			name:    "Legacy snapshot without header",
+			content: `{"user_id":"1","short_url":"short1","original_url":"https://ya.ru"}` + "\n",
+			want:    map[string]UserURL{"short1": {UserID: "1", URL: "https://ya.ru"}},
+		},
+		{
+			name: "Current snapshot",
+			content: `{"version":2}` + "\n" +
+				`{"user_id":"1","short_url":"short1","original_url":"https://ya.ru","is_deleted":true}` + "\n",
+			want: map[string]UserURL{"short1": {UserID: "1", URL: "https://ya.ru", IsDeleted: true}},
+		},
+		{
+			name: "Torn last record",
+			content: `{"version":2}` + "\n" +
+				`{"user_id":"1","short_url":"short1","original_url":"https://ya.ru"}` + "\n" +
+				`{"user_id":"1","short_url":"sho`,
+			want: map[string]UserURL{"short1": {UserID: "1", URL: "https://ya.ru"}},
+		},
+		{
+			name: "Corrupted record in the middle",
+			content: `{"version":2}` + "\n" +
+				`{"user_id":"1","short_url":"sho` + "\n" +
+				`{"user_id":"1","short_url":"short1","original_url":"https://ya.ru"}` + "\n",
+			want:    map[string]UserURL{},
+			wantErr: ErrCorruptedRecord,
+		},
+		{
+			name:    "Unsupported version",
+			content: `{"version":99}` + "\n",
+			want:    map[string]UserURL{},
+			wantErr: ErrUnsupportedSnapshotVersion,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newSnapshotTestStore(t)
+			err := os.WriteFile(store.cfg.FileStoragePath, []byte(tc.content), 0644)
+			assert.Nil(t, err)
+			err = store.LoadSnapshot()
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tc.want, store.urls)
+		})
+	}
+}
+
+func TestLoadSnapshot_LongRecord(t *testing.T) {
+	store := newSnapshotTestStore(t)
+	long := "https://ya.ru/?q=" + strings.Repeat("a", 100*1024)
+	store.urls["short1"] = UserURL{UserID: "1", URL: long}
+	store.urls["short2"] = UserURL{UserID: "1", URL: "https://google.com"}
+	assert.Nil(t, store.CreateSnapshot())
+
+	store2 := &MemoryURLStore{urls: make(map[string]UserURL), cfg: store.cfg}
+	err := store2.LoadSnapshot()
+	assert.Nil(t, err, "Record longer than 64 KiB should be loaded")
+	assert.Equal(t, long, store2.urls["short1"].URL)
+	assert.Equal(t, "https://google.com", store2.urls["short2"].URL)
+}
+
+func TestRunSnapshotter(t *testing.T) {
+	store := newSnapshotTestStore(t)
+	store.urls["short1"] = UserURL{UserID: "1", URL: "https://ya.ru"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunSnapshotter(ctx, store, 10*time.Millisecond)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(store.cfg.FileStoragePath)
+		return err == nil
+	}, time.Second, 10*time.Millisecond, "Snapshot was not created")
+
+	cancel()
+	<-done
+}
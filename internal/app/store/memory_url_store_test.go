@@ -2,7 +2,9 @@ package store
 
 import (
 	"context"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/shekshuev/shortener/internal/app/config"
 	"github.com/shekshuev/shortener/internal/app/models"
@@ -26,7 +28,7 @@ func TestMemoryURLStore_SetURL(t *testing.T) {
 	s := &MemoryURLStore{urls: make(map[string]UserURL), cfg: &cfg}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := s.SetURL(context.Background(), tc.key, tc.value, tc.userID)
+			_, err := s.SetURL(context.Background(), tc.key, tc.value, tc.userID, models.LinkOptions{})
 			if tc.hasError {
 				assert.NotNil(t, err, "Error is nil")
 			} else {
@@ -36,6 +38,72 @@ func TestMemoryURLStore_SetURL(t *testing.T) {
 	}
 }
 
+func TestMemoryURLStore_SetURL_KeyTaken(t *testing.T) {
+	cfg := config.GetConfig()
+	s := &MemoryURLStore{urls: make(map[string]UserURL), cfg: &cfg}
+	_, err := s.SetURL(context.Background(), "my-link", "https://ya.ru", "1", models.LinkOptions{})
+	assert.Nil(t, err, "Error is not nil")
+	_, err = s.SetURL(context.Background(), "my-link", "https://google.com", "2", models.LinkOptions{})
+	assert.ErrorIs(t, err, ErrKeyTaken)
+	err = s.SetBatchURL(context.Background(), []models.BatchShortURLCreateDTO{
+		{CorrelationID: "test1", OriginalURL: "https://example.com", ShortURL: "my-link"},
+	}, "2")
+	assert.ErrorIs(t, err, ErrKeyTaken)
+	err = s.SetBatchURL(context.Background(), []models.BatchShortURLCreateDTO{
+		{CorrelationID: "test1", OriginalURL: "https://example.com", ShortURL: "other"},
+		{CorrelationID: "test2", OriginalURL: "https://example.org", ShortURL: "other"},
+	}, "2")
+	assert.ErrorIs(t, err, ErrKeyTaken)
+	value, err := s.GetURL(context.Background(), "my-link")
+	assert.Nil(t, err, "Error is not nil")
+	assert.Equal(t, "https://ya.ru", value, "Existing link was overwritten")
+}
+
+func TestMemoryURLStore_SetURL_PerUserDeduplication(t *testing.T) {
+	cfg := config.GetConfig()
+	s := &MemoryURLStore{urls: make(map[string]UserURL), cfg: &cfg}
+	key, err := s.SetURL(context.Background(), "short1", "https://ya.ru", "1", models.LinkOptions{})
+	assert.Nil(t, err, "Error is not nil")
+	assert.Equal(t, "short1", key)
+
+	key, err = s.SetURL(context.Background(), "short2", "https://ya.ru", "1", models.LinkOptions{})
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+	assert.Equal(t, "short1", key, "Existing key of the user should be returned")
+
+	key, err = s.SetURL(context.Background(), "short3", "https://ya.ru", "2", models.LinkOptions{})
+	assert.Nil(t, err, "Another user should get a separate link")
+	assert.Equal(t, "short3", key)
+
+	createDTO := []models.BatchShortURLCreateDTO{
+		{CorrelationID: "test1", OriginalURL: "https://ya.ru", ShortURL: "short4"},
+		{CorrelationID: "test2", OriginalURL: "https://google.com", ShortURL: "short5"},
+		{CorrelationID: "test3", OriginalURL: "https://google.com", ShortURL: "short6"},
+	}
+	err = s.SetBatchURL(context.Background(), createDTO, "2")
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+	assert.Equal(t, "short3", createDTO[0].ShortURL)
+	assert.Equal(t, "short5", createDTO[1].ShortURL)
+	assert.Equal(t, "short5", createDTO[2].ShortURL)
+	readDTO, err := s.GetUserURLs(context.Background(), "2")
+	assert.Nil(t, err, "Error is not nil")
+	assert.Len(t, readDTO, 2, "New urls from the batch should be saved")
+
+	assert.Nil(t, s.UpdateURL(context.Background(), "1", "short1", models.ShortURLUpdateDTO{URL: "https://example.com"}))
+	key, err = s.SetURL(context.Background(), "short7", "https://example.com", "1", models.LinkOptions{})
+	assert.ErrorIs(t, err, ErrAlreadyExists, "Updated url should be found by its new value")
+	assert.Equal(t, "short1", key)
+	key, err = s.SetURL(context.Background(), "short7", "https://ya.ru", "1", models.LinkOptions{})
+	assert.Nil(t, err, "Previous value of the updated url should be free")
+	assert.Equal(t, "short7", key)
+
+	assert.Nil(t, s.DeleteURLs(context.Background(), "1", []string{"short7"}))
+	_, err = s.PurgeDeleted(context.Background(), time.Now().Add(time.Hour))
+	assert.Nil(t, err)
+	key, err = s.SetURL(context.Background(), "short8", "https://ya.ru", "1", models.LinkOptions{})
+	assert.Nil(t, err, "Purged url should be free")
+	assert.Equal(t, "short8", key)
+}
+
 func TestMemoryURLStore_SetBatchURL(t *testing.T) {
 	testCases := []struct {
 		name      string
@@ -88,10 +156,10 @@ func TestMemoryURLStore_GetURL(t *testing.T) {
 		{name: "Get not existing value", key: "test", getKey: "not exists", value: "test", userID: "1"},
 	}
 	cfg := config.GetConfig()
-	s := &MemoryURLStore{urls: make(map[string]UserURL), cfg: &cfg}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := s.SetURL(context.Background(), tc.key, tc.value, tc.userID)
+			s := &MemoryURLStore{urls: make(map[string]UserURL), cfg: &cfg}
+			_, err := s.SetURL(context.Background(), tc.key, tc.value, tc.userID, models.LinkOptions{})
 			assert.Nil(t, err, "Set error is not nil")
 			res, err := s.GetURL(context.Background(), tc.getKey)
 			if tc.key == tc.getKey {
@@ -118,10 +186,10 @@ func TestMemoryURLStore_GetUserURLs(t *testing.T) {
 		{name: "Get with wrong userID", getUserID: "2", hasError: true, originalURL: "https://ya.ru", shortURL: "test1"},
 	}
 	cfg := config.GetConfig()
-	s := &MemoryURLStore{urls: make(map[string]UserURL), cfg: &cfg}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := s.SetURL(context.Background(), tc.shortURL, tc.originalURL, userID)
+			s := &MemoryURLStore{urls: make(map[string]UserURL), cfg: &cfg}
+			_, err := s.SetURL(context.Background(), tc.shortURL, tc.originalURL, userID, models.LinkOptions{})
 			assert.Nil(t, err, "Set error is not nil")
 			res, err := s.GetUserURLs(context.Background(), tc.getUserID)
 			if !tc.hasError {
@@ -135,6 +203,48 @@ func TestMemoryURLStore_GetUserURLs(t *testing.T) {
 	}
 }
 
+func TestMemoryURLStore_GetUserURLsPage_CreatedAt(t *testing.T) {
+	cfg := config.GetConfig()
+	s := &MemoryURLStore{urls: make(map[string]UserURL), cfg: &cfg}
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	s.putURL("e", UserURL{UserID: "1", URL: "https://example.com/e", CreatedAt: base.Add(2 * time.Second)})
+	s.putURL("a", UserURL{UserID: "1", URL: "https://example.com/a", CreatedAt: base})
+	s.putURL("c", UserURL{UserID: "1", URL: "https://example.com/c", CreatedAt: base.Add(time.Second)})
+	s.putURL("b", UserURL{UserID: "1", URL: "https://example.com/b", CreatedAt: base.Add(time.Second)})
+	s.putURL("d", UserURL{UserID: "1", URL: "https://example.com/d", CreatedAt: base.Add(time.Second), IsDeleted: true})
+	s.putURL("f", UserURL{UserID: "1", URL: "https://ya.ru", CreatedAt: base.Add(3 * time.Second)})
+	s.putURL("g", UserURL{UserID: "2", URL: "https://example.com/g", CreatedAt: base.Add(time.Second)})
+	s.removeURL("a")
+	s.putURL("a", UserURL{UserID: "1", URL: "https://example.com/a", CreatedAt: base})
+
+	testCases := []struct {
+		name  string
+		order string
+		want  []string
+	}{
+		{name: "Ascending", order: OrderAsc, want: []string{"a", "b", "c", "e"}},
+		{name: "Descending", order: OrderDesc, want: []string{"e", "c", "b", "a"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var keys []string
+			query := models.UserURLsQuery{Limit: 2, Order: tc.order, Query: "example"}
+			for pages := 0; pages < 5; pages++ {
+				page, err := s.GetUserURLsPage(context.Background(), "1", query)
+				assert.Nil(t, err)
+				for _, u := range page.URLs {
+					keys = append(keys, filepath.Base(u.ShortURL))
+				}
+				if len(page.NextCursor) == 0 {
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+			assert.Equal(t, tc.want, keys)
+		})
+	}
+}
+
 func TestMemoryURLStore_DeleteURLs(t *testing.T) {
 	userID := "1"
 	testCases := []struct {
@@ -217,8 +327,8 @@ func TestMemoryURLStore_CountURLs(t *testing.T) {
 	count, err := s.CountURLs(context.Background())
 	assert.Nil(t, err)
 	assert.Equal(t, 0, count)
-	_, _ = s.SetURL(context.Background(), "short1", "https://ya.ru", "user1")
-	_, _ = s.SetURL(context.Background(), "short2", "https://google.com", "user2")
+	_, _ = s.SetURL(context.Background(), "short1", "https://ya.ru", "user1", models.LinkOptions{})
+	_, _ = s.SetURL(context.Background(), "short2", "https://google.com", "user2", models.LinkOptions{})
 	count, err = s.CountURLs(context.Background())
 	assert.Nil(t, err)
 	assert.Equal(t, 2, count)
@@ -230,11 +340,99 @@ func TestMemoryURLStore_CountUsers(t *testing.T) {
 	count, err := s.CountUsers(context.Background())
 	assert.Nil(t, err)
 	assert.Equal(t, 0, count)
-	_, _ = s.SetURL(context.Background(), "short1", "https://ya.ru", "user1")
-	_, _ = s.SetURL(context.Background(), "short2", "https://google.com", "user2")
-	_, _ = s.SetURL(context.Background(), "short3", "https://example.com", "user1")
+	_, _ = s.SetURL(context.Background(), "short1", "https://ya.ru", "user1", models.LinkOptions{})
+	_, _ = s.SetURL(context.Background(), "short2", "https://google.com", "user2", models.LinkOptions{})
+	_, _ = s.SetURL(context.Background(), "short3", "https://example.com", "user1", models.LinkOptions{})
 
 	count, err = s.CountUsers(context.Background())
 	assert.Nil(t, err)
 	assert.Equal(t, 2, count)
 }
+
+func TestMemoryURLStore_Expiration(t *testing.T) {
+	cfg := config.GetConfig()
+	s := &MemoryURLStore{urls: make(map[string]UserURL), cfg: &cfg}
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	_, err := s.SetURL(context.Background(), "expired", "https://ya.ru", "1", models.LinkOptions{ExpiresAt: &past})
+	assert.Nil(t, err)
+	_, err = s.SetURL(context.Background(), "alive", "https://google.com", "1", models.LinkOptions{ExpiresAt: &future})
+	assert.Nil(t, err)
+
+	_, err = s.GetURL(context.Background(), "expired")
+	assert.ErrorIs(t, err, ErrExpired)
+	value, err := s.GetURL(context.Background(), "alive")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://google.com", value)
+
+	count, err := s.DeleteExpired(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	_, err = s.GetURL(context.Background(), "expired")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryURLStore_GetURLStats(t *testing.T) {
+	cfg := config.GetConfig()
+	s := &MemoryURLStore{urls: make(map[string]UserURL), cfg: &cfg}
+	_, err := s.SetURL(context.Background(), "short1", "https://ya.ru", "1", models.LinkOptions{})
+	assert.Nil(t, err)
+
+	day1 := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	day2 := time.Date(2024, 5, 2, 10, 0, 0, 0, time.UTC)
+	err = s.SaveClicks(context.Background(), []models.ClickEvent{
+		{ShortURL: "short1", Timestamp: day2, IP: "10.0.0.1"},
+		{ShortURL: "short1", Timestamp: day1, IP: "10.0.0.1"},
+		{ShortURL: "short1", Timestamp: day1, IP: "10.0.0.2"},
+	})
+	assert.Nil(t, err)
+
+	stats, err := s.GetURLStats(context.Background(), "1", "short1")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, stats.Clicks)
+	assert.Equal(t, 2, stats.UniqueVisitors)
+	assert.Equal(t, []models.DailyClicksDTO{{Date: "2024-05-01", Clicks: 2}, {Date: "2024-05-02", Clicks: 1}}, stats.Daily)
+
+	_, err = s.GetURLStats(context.Background(), "2", "short1")
+	assert.ErrorIs(t, err, ErrNotFound, "Stats of another user's url should not be available")
+	_, err = s.GetURLStats(context.Background(), "1", "unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryURLStore_UpdateURL(t *testing.T) {
+	testCases := []struct {
+		name        string
+		userID      string
+		key         string
+		value       string
+		expectedErr error
+	}{
+		{name: "Owner updates url", userID: "1", key: "short1", value: "https://example.com", expectedErr: nil},
+		{name: "Same url is allowed", userID: "1", key: "short1", value: "https://ya.ru", expectedErr: nil},
+		{name: "Url already shortened by the user", userID: "1", key: "short1", value: "https://example.org", expectedErr: ErrAlreadyExists},
+		{name: "Url shortened by another user", userID: "1", key: "short1", value: "https://google.com", expectedErr: nil},
+		{name: "Another user", userID: "2", key: "short1", value: "https://example.com", expectedErr: ErrNotFound},
+		{name: "Unknown key", userID: "1", key: "unknown", value: "https://example.com", expectedErr: ErrNotFound},
+		{name: "Deleted url", userID: "1", key: "deleted", value: "https://example.com", expectedErr: ErrNotFound},
+		{name: "Empty value", userID: "1", key: "short1", value: "", expectedErr: ErrEmptyValue},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.GetConfig()
+			s := &MemoryURLStore{urls: make(map[string]UserURL), cfg: &cfg}
+			for key, value := range map[string]UserURL{
+				"short1":  {UserID: "1", URL: "https://ya.ru"},
+				"short2":  {UserID: "2", URL: "https://google.com"},
+				"short3":  {UserID: "1", URL: "https://example.org"},
+				"deleted": {UserID: "1", URL: "https://deleted.com", IsDeleted: true},
+			} {
+				s.putURL(key, value)
+			}
+			err := s.UpdateURL(context.Background(), tc.userID, tc.key, models.ShortURLUpdateDTO{URL: tc.value})
+			assert.Equal(t, tc.expectedErr, err, "Unexpected update result")
+			if tc.expectedErr == nil {
+				assert.Equal(t, tc.value, s.urls[tc.key].URL, "Url was not updated")
+			}
+		})
+	}
+}
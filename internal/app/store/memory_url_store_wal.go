@@ -0,0 +1,299 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shekshuev/shortener/internal/app/logger"
+	"github.com/shekshuev/shortener/internal/app/models"
+)
+
+// Политики сброса журнала упреждающей записи на диск.
+const (
+	WALSyncAlways   = "always"   // fsync после каждой записи.
+	WALSyncInterval = "interval" // fsync по таймеру.
+	WALSyncNever    = "never"    // сброс на диск остаётся на усмотрение ОС.
+)
+
+// Операции, записываемые в журнал упреждающей записи.
+const (
+	walOpSet      = "set"       // Создание или изменение ссылки.
+	walOpDelete   = "delete"    // Пометка ссылки удалённой.
+	walOpRestore  = "restore"   // Снятие пометки удаления.
+	walOpPurge    = "purge"     // Окончательное удаление ссылки.
+	walOpSequence = "sequence"  // Выдача значения счётчика ключей.
+	walOpJob      = "job"       // Сохранение задачи удаления или её состояния.
+	walOpJobPurge = "job_purge" // Удаление завершённой задачи удаления.
+)
+
+// Ошибки журнала упреждающей записи и снапшота.
+var (
+	ErrInvalidWALSyncPolicy = fmt.Errorf("wal sync policy must be one of: always, interval, never") // Ошибка: неизвестная политика сброса журнала
+	ErrCorruptedRecord      = fmt.Errorf("corrupted record")                                        // Ошибка: повреждённая запись не в конце файла
+)
+
+// walPath возвращает путь к журналу упреждающей записи для файла снапшота.
+func walPath(snapshotPath string) string {
+	return snapshotPath + ".wal"
+}
+
+// writeAheadLog - журнал упреждающей записи в формате JSON Lines.
+// Каждая изменяющая операция MemoryURLStore дописывается в конец файла до применения в памяти.
+type writeAheadLog struct {
+	mx     sync.Mutex
+	file   *os.File
+	policy string
+	dirty  bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// openWriteAheadLog открывает журнал на дозапись, отрезав недописанную последнюю строку,
+// и при политике interval запускает периодический fsync.
+func openWriteAheadLog(path, policy string, interval time.Duration) (*writeAheadLog, error) {
+	switch policy {
+	case WALSyncAlways, WALSyncNever:
+	case WALSyncInterval:
+		if interval <= 0 {
+			return nil, ErrInvalidWALSyncPolicy
+		}
+	default:
+		return nil, ErrInvalidWALSyncPolicy
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := trimTornTail(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	wal := &writeAheadLog{file: file, policy: policy}
+	if policy == WALSyncInterval {
+		wal.stop = make(chan struct{})
+		wal.done = make(chan struct{})
+		go wal.syncLoop(interval)
+	}
+	return wal, nil
+}
+
+// trimTornTail отрезает от журнала недописанную при аварии последнюю строку.
+// Иначе следующая запись приклеится к ней и при очередном восстановлении будет пропущена вместе с ней.
+func trimTornTail(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	buf := make([]byte, 4096)
+	for end := size; end > 0; {
+		start := end - int64(len(buf))
+		if start < 0 {
+			start = 0
+		}
+		chunk := buf[:end-start]
+		if _, err := file.ReadAt(chunk, start); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			return truncateTail(file, size, start+int64(i)+1)
+		}
+		end = start
+	}
+	return truncateTail(file, size, 0)
+}
+
+// truncateTail обрезает файл размера size до length, если в нём есть лишние байты.
+func truncateTail(file *os.File, size, length int64) error {
+	if length == size {
+		return nil
+	}
+	if err := file.Truncate(length); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// Append дописывает записи в журнал одной операцией записи.
+func (w *writeAheadLog) Append(records ...models.SerializeData) error {
+	if w == nil || len(records) == 0 {
+		return nil
+	}
+	var buf []byte
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, data...)
+		buf = append(buf, '\n')
+	}
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if _, err := w.file.Write(buf); err != nil {
+		return err
+	}
+	if w.policy == WALSyncAlways {
+		return w.file.Sync()
+	}
+	w.dirty = true
+	return nil
+}
+
+// Truncate очищает журнал. Вызывается после того, как все записи попали в снапшот.
+func (w *writeAheadLog) Truncate() error {
+	if w == nil {
+		return nil
+	}
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	w.dirty = false
+	return w.file.Sync()
+}
+
+// Close сбрасывает журнал на диск и закрывает файл.
+func (w *writeAheadLog) Close() error {
+	if w == nil {
+		return nil
+	}
+	if w.stop != nil {
+		close(w.stop)
+		<-w.done
+	}
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if err := w.file.Sync(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// syncLoop периодически сбрасывает журнал на диск, если в него были записи.
+func (w *writeAheadLog) syncLoop(interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.mx.Lock()
+			if w.dirty {
+				if err := w.file.Sync(); err != nil {
+					logger.NewLogger().Log.Error("Error syncing write-ahead log", zap.Error(err))
+				} else {
+					w.dirty = false
+				}
+			}
+			w.mx.Unlock()
+		}
+	}
+}
+
+// replayWAL применяет к хранилищу записи журнала, сделанные после последнего снапшота.
+// Пропускается только недописанная при аварии последняя запись, повреждённая запись в середине журнала
+// возвращается как ErrCorruptedRecord.
+func (s *MemoryURLStore) replayWAL(path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return readJSONLines(file, func(line []byte) error {
+		var record models.SerializeData
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("%w: %v", ErrCorruptedRecord, err)
+		}
+		switch record.Op {
+		case walOpSet:
+			s.putURL(record.ShortURL, UserURL{UserID: record.UserID, URL: record.OriginalURL, ExpiresAt: record.ExpiresAt, CreatedAt: timeOrZero(record.CreatedAt), RedirectType: record.RedirectType, ForwardQuery: record.ForwardQuery, ForwardPath: record.ForwardPath})
+		case walOpDelete:
+			if value, exists := s.urls[record.ShortURL]; exists && value.UserID == record.UserID {
+				value.IsDeleted = true
+				value.DeletedAt = record.DeletedAt
+				s.putURL(record.ShortURL, value)
+			}
+		case walOpRestore:
+			if value, exists := s.urls[record.ShortURL]; exists && value.UserID == record.UserID {
+				value.IsDeleted = false
+				value.DeletedAt = nil
+				s.putURL(record.ShortURL, value)
+			}
+		case walOpPurge:
+			s.removeURL(record.ShortURL)
+			delete(s.clicks, record.ShortURL)
+		case walOpSequence:
+			s.sequence = max(s.sequence, record.Sequence)
+		case walOpJob:
+			if record.Job != nil {
+				s.putDeletionJob(*record.Job)
+			}
+		case walOpJobPurge:
+			if record.Job != nil {
+				delete(s.jobs, record.Job.ID)
+			}
+		}
+		return nil
+	})
+}
+
+// readJSONLines построчно передаёт содержимое файла в формате JSON Lines в apply без ограничения длины строки.
+// Пустые строки пропускаются. Строка без завершающего перевода строки считается недописанной при аварии
+// последней записью: если apply не смог её разобрать (ErrCorruptedRecord), она пропускается.
+// Остальные ошибки apply возвращаются с номером строки.
+func readJSONLines(r io.Reader, apply func(line []byte) error) error {
+	reader := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		torn := readErr == io.EOF
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			err := apply(line)
+			if torn && errors.Is(err, ErrCorruptedRecord) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("line %d: %w", n, err)
+			}
+		}
+		if torn {
+			return nil
+		}
+	}
+}
+
+// walRecord собирает запись журнала для операции над ссылкой.
+func walRecord(op, key string, value UserURL) models.SerializeData {
+	return models.SerializeData{
+		Op:           op,
+		UserID:       value.UserID,
+		ShortURL:     key,
+		OriginalURL:  value.URL,
+		ExpiresAt:    value.ExpiresAt,
+		DeletedAt:    value.DeletedAt,
+		CreatedAt:    optionalTime(value.CreatedAt),
+		RedirectType: value.RedirectType,
+		ForwardQuery: value.ForwardQuery,
+		ForwardPath:  value.ForwardPath,
+	}
+}
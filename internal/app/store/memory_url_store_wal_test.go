@@ -0,0 +1,291 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shekshuev/shortener/internal/app/config"
+	"github.com/shekshuev/shortener/internal/app/deletion"
+	"github.com/shekshuev/shortener/internal/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func newWALTestConfig(t *testing.T, policy string) *config.Config {
+	cfg := config.GetConfig()
+	cfg.FileStoragePath = filepath.Join(t.TempDir(), "storage.txt")
+	cfg.WALSyncPolicy = policy
+	cfg.WALSyncInterval = 10 * time.Millisecond
+	return &cfg
+}
+
+func TestMemoryURLStore_WAL_ReplayAfterCrash(t *testing.T) {
+	for _, policy := range []string{WALSyncAlways, WALSyncInterval, WALSyncNever} {
+		t.Run(policy, func(t *testing.T) {
+			cfg := newWALTestConfig(t, policy)
+			s, err := NewMemoryURLStore(cfg)
+			assert.Nil(t, err)
+			ctx := context.Background()
+
+			_, err = s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{RedirectType: 301, ForwardQuery: "merge", ForwardPath: true})
+			assert.Nil(t, err)
+			err = s.SetBatchURL(ctx, []models.BatchShortURLCreateDTO{
+				{CorrelationID: "1", OriginalURL: "https://google.com", ShortURL: "short2"},
+				{CorrelationID: "2", OriginalURL: "https://example.com", ShortURL: "short3"},
+			}, "1")
+			assert.Nil(t, err)
+			err = s.DeleteURLs(ctx, "1", []string{"short2"})
+			assert.Nil(t, err)
+			err = s.UpdateURL(ctx, "1", "short3", models.ShortURLUpdateDTO{URL: "https://example.org", RedirectType: 308})
+			assert.Nil(t, err)
+
+			// Процесс «падает» без Close: снапшот не создаётся, остаётся только журнал.
+			restored, err := NewMemoryURLStore(cfg)
+			assert.Nil(t, err)
+			value, err := restored.GetURL(ctx, "short1")
+			assert.Nil(t, err)
+			assert.Equal(t, "https://ya.ru", value)
+			_, err = restored.GetURL(ctx, "short2")
+			assert.ErrorIs(t, err, ErrAlreadyDeleted)
+			value, err = restored.GetURL(ctx, "short3")
+			assert.Nil(t, err)
+			assert.Equal(t, "https://example.org", value)
+			assert.Equal(t, s.urls["short3"].CreatedAt.UnixNano(), restored.urls["short3"].CreatedAt.UnixNano(), "Creation time should survive replay")
+			assert.False(t, restored.urls["short3"].CreatedAt.IsZero())
+			assert.Equal(t, 301, restored.urls["short1"].RedirectType, "Redirect type should survive replay")
+			assert.Equal(t, "merge", restored.urls["short1"].ForwardQuery, "Forwarding options should survive replay")
+			assert.True(t, restored.urls["short1"].ForwardPath)
+			assert.Equal(t, 308, restored.urls["short3"].RedirectType)
+			found, err := restored.LookupUserURLs(ctx, "1", "https://example.org/")
+			assert.Nil(t, err)
+			assert.Len(t, found, 1, "Lookup index should be rebuilt on replay")
+			found, err = restored.LookupUserURLs(ctx, "1", "https://example.com/")
+			assert.Nil(t, err)
+			assert.Empty(t, found, "Lookup index should follow updates on replay")
+
+			assert.Nil(t, s.wal.Close())
+			assert.Nil(t, restored.wal.Close())
+		})
+	}
+}
+
+func TestMemoryURLStore_WAL_PurgeExpired(t *testing.T) {
+	cfg := newWALTestConfig(t, WALSyncAlways)
+	s, err := NewMemoryURLStore(cfg)
+	assert.Nil(t, err)
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	s.urls["expired"] = UserURL{UserID: "1", URL: "https://ya.ru", ExpiresAt: &past}
+	assert.Nil(t, s.wal.Append(walRecord(walOpSet, "expired", s.urls["expired"])))
+
+	count, err := s.DeleteExpired(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+
+	restored, err := NewMemoryURLStore(cfg)
+	assert.Nil(t, err)
+	_, err = restored.GetURL(ctx, "expired")
+	assert.ErrorIs(t, err, ErrNotFound, "Purged url should not be restored from the log")
+	assert.Nil(t, s.wal.Close())
+	assert.Nil(t, restored.wal.Close())
+}
+
+func TestMemoryURLStore_WAL_RestoreAndPurgeDeleted(t *testing.T) {
+	cfg := newWALTestConfig(t, WALSyncAlways)
+	s, err := NewMemoryURLStore(cfg)
+	assert.Nil(t, err)
+	ctx := context.Background()
+	_, err = s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{})
+	assert.Nil(t, err)
+	_, err = s.SetURL(ctx, "short2", "https://google.com", "1", models.LinkOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, s.DeleteURLs(ctx, "1", []string{"short1", "short2"}))
+	restoredKeys, err := s.RestoreURLs(ctx, "1", []string{"short1"}, time.Now().Add(-time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"short1"}, restoredKeys)
+	count, err := s.PurgeDeleted(ctx, time.Now().Add(time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+
+	restored, err := NewMemoryURLStore(cfg)
+	assert.Nil(t, err)
+	value, err := restored.GetURL(ctx, "short1")
+	assert.Nil(t, err, "Restored url should be replayed from the log")
+	assert.Equal(t, "https://ya.ru", value)
+	_, err = restored.GetURL(ctx, "short2")
+	assert.ErrorIs(t, err, ErrNotFound, "Purged url should not be restored from the log")
+	assert.Nil(t, s.wal.Close())
+	assert.Nil(t, restored.wal.Close())
+}
+
+func TestMemoryURLStore_WAL_TruncatedOnClose(t *testing.T) {
+	cfg := newWALTestConfig(t, WALSyncAlways)
+	s, err := NewMemoryURLStore(cfg)
+	assert.Nil(t, err)
+	ctx := context.Background()
+	_, err = s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{})
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.Close())
+	info, err := os.Stat(walPath(cfg.FileStoragePath))
+	assert.Nil(t, err)
+	assert.Zero(t, info.Size(), "Log should be empty after snapshot")
+
+	restored, err := NewMemoryURLStore(cfg)
+	assert.Nil(t, err)
+	value, err := restored.GetURL(ctx, "short1")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://ya.ru", value)
+	assert.Nil(t, restored.wal.Close())
+}
+
+func TestMemoryURLStore_WAL_SkipsCorruptedRecord(t *testing.T) {
+	cfg := newWALTestConfig(t, WALSyncNever)
+	content := `{"op":"set","user_id":"1","short_url":"short1","original_url":"https://ya.ru"}` + "\n" +
+		`{"op":"set","user_id":"1","short_url":"sho`
+	err := os.WriteFile(walPath(cfg.FileStoragePath), []byte(content), 0644)
+	assert.Nil(t, err)
+
+	s, err := NewMemoryURLStore(cfg)
+	assert.Nil(t, err)
+	assert.Len(t, s.urls, 1, "Only complete records should be replayed")
+	assert.Nil(t, s.wal.Close())
+}
+
+func TestMemoryURLStore_WAL_AppendAfterTornRecord(t *testing.T) {
+	cfg := newWALTestConfig(t, WALSyncAlways)
+	content := `{"op":"set","user_id":"1","short_url":"short1","original_url":"https://ya.ru"}` + "\n" +
+		`{"op":"set","user_id":"1","short_url":"sho`
+	err := os.WriteFile(walPath(cfg.FileStoragePath), []byte(content), 0644)
+	assert.Nil(t, err)
+	ctx := context.Background()
+
+	s, err := NewMemoryURLStore(cfg)
+	assert.Nil(t, err)
+	_, err = s.SetURL(ctx, "short2", "https://google.com", "1", models.LinkOptions{})
+	assert.Nil(t, err)
+	// Процесс снова «падает» без Close.
+	assert.Nil(t, s.wal.Close())
+
+	restored, err := NewMemoryURLStore(cfg)
+	assert.Nil(t, err)
+	value, err := restored.GetURL(ctx, "short2")
+	assert.Nil(t, err, "Record appended after a torn one should survive replay")
+	assert.Equal(t, "https://google.com", value)
+	assert.Len(t, restored.urls, 2)
+	assert.Nil(t, restored.wal.Close())
+}
+
+func TestMemoryURLStore_WAL_CorruptedRecordInTheMiddle(t *testing.T) {
+	cfg := newWALTestConfig(t, WALSyncNever)
+	content := `{"op":"set","user_id":"1","short_url":"short1","original_url":"https://ya.ru"}` + "\n" +
+		`{"op":"set","user_id":"1","short_url":"sho` + "\n" +
+		`{"op":"set","user_id":"1","short_url":"short2","original_url":"https://google.com"}` + "\n"
+	err := os.WriteFile(walPath(cfg.FileStoragePath), []byte(content), 0644)
+	assert.Nil(t, err)
+
+	_, err = NewMemoryURLStore(cfg)
+	assert.ErrorIs(t, err, ErrCorruptedRecord, "Only a torn last record may be skipped")
+}
+
+func TestMemoryURLStore_WAL_LongRecord(t *testing.T) {
+	cfg := newWALTestConfig(t, WALSyncAlways)
+	s, err := NewMemoryURLStore(cfg)
+	assert.Nil(t, err)
+	ctx := context.Background()
+	long := "https://ya.ru/?q=" + strings.Repeat("a", 100*1024)
+	_, err = s.SetURL(ctx, "short1", long, "1", models.LinkOptions{})
+	assert.Nil(t, err)
+	_, err = s.SetURL(ctx, "short2", "https://google.com", "1", models.LinkOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, s.wal.Close())
+
+	restored, err := NewMemoryURLStore(cfg)
+	assert.Nil(t, err)
+	value, err := restored.GetURL(ctx, "short1")
+	assert.Nil(t, err, "Record longer than 64 KiB should be replayed")
+	assert.Equal(t, long, value)
+	_, err = restored.GetURL(ctx, "short2")
+	assert.Nil(t, err, "Records after a long one should be replayed")
+	assert.Nil(t, restored.wal.Close())
+}
+
+func TestMemoryURLStore_WAL_DeletionJobs(t *testing.T) {
+	cfg := newWALTestConfig(t, WALSyncAlways)
+	s, err := NewMemoryURLStore(cfg)
+	assert.Nil(t, err)
+	ctx := context.Background()
+	now := time.Now()
+	pending := models.DeletionJob{DeletionJobDTO: models.DeletionJobDTO{ID: "job1", Status: models.DeletionJobPending, URLs: 1, CreatedAt: now}, UserID: "1", ShortURLs: []string{"short1"}}
+	done := models.DeletionJob{DeletionJobDTO: models.DeletionJobDTO{ID: "job2", Status: models.DeletionJobDone, URLs: 1, CreatedAt: now, FinishedAt: &now}, UserID: "1", ShortURLs: []string{"short2"}}
+	assert.Nil(t, s.SaveDeletionJob(ctx, pending))
+	assert.Nil(t, s.SaveDeletionJob(ctx, done))
+	count, err := s.PurgeDeletionJobs(ctx, now.Add(time.Second))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+
+	// Процесс «падает» без Close: задачи восстанавливаются из журнала.
+	restored, err := NewMemoryURLStore(cfg)
+	assert.Nil(t, err)
+	jobs, err := restored.PendingDeletionJobs(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, jobs, 1, "Pending job should be replayed from the log")
+	_, err = restored.GetDeletionJob(ctx, "job2")
+	assert.ErrorIs(t, err, deletion.ErrJobNotFound, "Purged job should not be replayed")
+
+	// После снапшота задачи читаются из файла снапшота.
+	assert.Nil(t, s.wal.Close())
+	assert.Nil(t, restored.Close())
+	reloaded, err := NewMemoryURLStore(cfg)
+	assert.Nil(t, err)
+	job, err := reloaded.GetDeletionJob(ctx, "job1")
+	assert.Nil(t, err, "Pending job should survive the snapshot")
+	assert.Equal(t, []string{"short1"}, job.ShortURLs)
+	assert.Nil(t, reloaded.wal.Close())
+}
+
+func TestOpenWriteAheadLog_InvalidPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.txt.wal")
+	_, err := openWriteAheadLog(path, "sometimes", time.Second)
+	assert.ErrorIs(t, err, ErrInvalidWALSyncPolicy)
+	_, err = openWriteAheadLog(path, WALSyncInterval, 0)
+	assert.ErrorIs(t, err, ErrInvalidWALSyncPolicy)
+}
+
+func TestMemoryURLStore_NextSequence_SurvivesRestart(t *testing.T) {
+	cfg := newWALTestConfig(t, WALSyncAlways)
+	s, err := NewMemoryURLStore(cfg)
+	assert.Nil(t, err)
+	ctx := context.Background()
+	for i := 1; i <= 3; i++ {
+		n, err := s.NextSequence(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(i), n)
+	}
+	_, err = s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, s.DeleteURLs(ctx, "1", []string{"short1"}))
+	_, err = s.PurgeDeleted(ctx, time.Now().Add(time.Hour))
+	assert.Nil(t, err)
+
+	// Процесс «падает» без Close: значение счётчика восстанавливается из журнала.
+	restored, err := NewMemoryURLStore(cfg)
+	assert.Nil(t, err)
+	n, err := restored.NextSequence(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(4), n, "Counter should not restart from the number of urls")
+
+	// После снапшота журнал очищается, и значение счётчика берётся из заголовка снапшота.
+	assert.Nil(t, restored.CreateSnapshot())
+	assert.Nil(t, s.wal.Close())
+	assert.Nil(t, restored.wal.Close())
+	reloaded, err := NewMemoryURLStore(cfg)
+	assert.Nil(t, err)
+	n, err = reloaded.NextSequence(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(5), n)
+	assert.Nil(t, reloaded.wal.Close())
+}
@@ -0,0 +1,257 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// sqlFS содержит SQL-файлы миграций вида 0001_name.up.sql и 0001_name.down.sql.
+//
+//go:embed sql/*.sql
+var sqlFS embed.FS
+
+// lockKey - ключ advisory-блокировки, под которой выполняются миграции.
+const lockKey int64 = 7_341_086_120_523
+
+// Ошибки, возникающие при работе с миграциями.
+var (
+	ErrInvalidMigrationName = fmt.Errorf("invalid migration file name")        // Ошибка: имя файла миграции не соответствует формату
+	ErrDuplicateMigration   = fmt.Errorf("duplicate migration version")        // Ошибка: две миграции с одной версией
+	ErrMissingUpMigration   = fmt.Errorf("migration has no up script")         // Ошибка: у миграции нет up-скрипта
+	ErrNoAppliedMigrations  = fmt.Errorf("no applied migrations to roll back") // Ошибка: нет применённых миграций для отката
+)
+
+var fileNameRegexp = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration описывает одну версию схемы базы данных.
+type Migration struct {
+	Version int64  // Номер версии, определяет порядок применения.
+	Name    string // Название миграции.
+	Up      string // SQL для применения миграции.
+	Down    string // SQL для отката миграции.
+}
+
+// Status описывает состояние миграции в базе данных.
+type Status struct {
+	Version   int64      // Номер версии.
+	Name      string     // Название миграции.
+	AppliedAt *time.Time // Время применения, nil — если миграция ещё не применена.
+}
+
+// Migrator применяет и откатывает миграции схемы PostgreSQL.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator создаёт Migrator со встроенными в бинарный файл миграциями.
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	return newMigrator(db, sqlFS, "sql")
+}
+
+func newMigrator(db *sql.DB, fsys fs.FS, dir string) (*Migrator, error) {
+	migrations, err := load(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Migrations возвращает все известные миграции в порядке применения.
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Up применяет все ещё не применённые миграции и возвращает их количество.
+// Каждая миграция выполняется в отдельной транзакции.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			query := `insert into schema_migrations (version, name) values ($1, $2);`
+			if err := runInTx(ctx, conn, migration.Up, query, migration.Version, migration.Name); err != nil {
+				return fmt.Errorf("apply migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down откатывает последнюю применённую миграцию и возвращает её.
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	var rolledBack Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			query := `delete from schema_migrations where version = $1;`
+			if err := runInTx(ctx, conn, migration.Down, query, migration.Version); err != nil {
+				return fmt.Errorf("roll back migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			rolledBack = migration
+			return nil
+		}
+		return ErrNoAppliedMigrations
+	})
+	return rolledBack, err
+}
+
+// Status возвращает состояние всех известных миграций.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		statuses = make([]Status, 0, len(m.migrations))
+		for _, migration := range m.migrations {
+			status := Status{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := applied[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// withLock выполняет fn на выделенном соединении под advisory-блокировкой,
+// чтобы несколько экземпляров сервиса не применяли миграции одновременно.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err = conn.ExecContext(ctx, `select pg_advisory_lock($1);`, lockKey); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), `select pg_advisory_unlock($1);`, lockKey)
+	query := `
+		create table if not exists schema_migrations (
+			version bigint not null,
+			name text not null,
+			applied_at timestamptz not null default now(),
+			constraint schema_migrations_pk primary key(version)
+		);
+	`
+	if _, err = conn.ExecContext(ctx, query); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// appliedVersions возвращает версии применённых миграций и время их применения.
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `select version, applied_at from schema_migrations;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			version   int64
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// runInTx выполняет скрипт миграции и запись в schema_migrations в одной транзакции.
+func runInTx(ctx context.Context, conn *sql.Conn, script, query string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// load читает миграции из каталога dir и сортирует их по версии.
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		parts := fileNameRegexp.FindStringSubmatch(entry.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigrationName, entry.Name())
+		}
+		version, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigrationName, entry.Name())
+		}
+		script, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[2]}
+			byVersion[version] = migration
+		} else if migration.Name != parts[2] {
+			return nil, fmt.Errorf("%w: %d", ErrDuplicateMigration, version)
+		}
+		if parts[3] == "up" {
+			migration.Up = string(script)
+		} else {
+			migration.Down = string(script)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("%w: %d_%s", ErrMissingUpMigration, migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
@@ -0,0 +1,189 @@
+package migrations
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var testFS = fstest.MapFS{
+	"sql/0001_create_urls.up.sql":     {Data: []byte("create table urls (id serial);")},
+	"sql/0001_create_urls.down.sql":   {Data: []byte("drop table urls;")},
+	"sql/0002_add_column.up.sql":      {Data: []byte("alter table urls add column name text;")},
+	"sql/0002_add_column.down.sql":    {Data: []byte("alter table urls drop column name;")},
+	"sql/0003_create_clicks.up.sql":   {Data: []byte("create table clicks (id serial);")},
+	"sql/0003_create_clicks.down.sql": {Data: []byte("drop table clicks;")},
+}
+
+func expectLock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`select pg_advisory_lock\(\$1\);`).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`create table if not exists schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`select pg_advisory_unlock\(\$1\);`).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestNewMigrator_EmbeddedMigrations(t *testing.T) {
+	m, err := NewMigrator(nil)
+	assert.NoError(t, err, "Embedded migrations should be valid")
+	migrations := m.Migrations()
+	assert.NotEmpty(t, migrations)
+	for i, migration := range migrations {
+		assert.NotEmpty(t, migration.Up, "Migration %d has no up script", migration.Version)
+		assert.NotEmpty(t, migration.Down, "Migration %d has no down script", migration.Version)
+		if i > 0 {
+			assert.Greater(t, migration.Version, migrations[i-1].Version, "Migrations are not ordered")
+		}
+	}
+}
+
+func TestLoad_InvalidMigrations(t *testing.T) {
+	testCases := []struct {
+		name        string
+		fsys        fstest.MapFS
+		expectedErr error
+	}{
+		{name: "Invalid file name", fsys: fstest.MapFS{"sql/create_urls.up.sql": {}}, expectedErr: ErrInvalidMigrationName},
+		{name: "Missing up script", fsys: fstest.MapFS{"sql/0001_create_urls.down.sql": {Data: []byte("drop table urls;")}}, expectedErr: ErrMissingUpMigration},
+		{name: "Duplicate version", fsys: fstest.MapFS{
+			"sql/0001_create_urls.up.sql":  {Data: []byte("create table urls (id serial);")},
+			"sql/0001_create_users.up.sql": {Data: []byte("create table users (id serial);")},
+		}, expectedErr: ErrDuplicateMigration},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := load(tc.fsys, "sql")
+			assert.ErrorIs(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestMigrator_Up(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	m, err := newMigrator(db, testFS, "sql")
+	assert.NoError(t, err)
+
+	expectLock(mock)
+	mock.ExpectQuery(`select version, applied_at from schema_migrations;`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+	for _, migration := range m.Migrations()[1:] {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(migration.Up)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`insert into schema_migrations \(version, name\) values \(\$1, \$2\);`).
+			WithArgs(migration.Version, migration.Name).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+	expectUnlock(mock)
+
+	count, err := m.Up(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count, "Only pending migrations should be applied")
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
+
+func TestMigrator_Up_RollsBackFailedMigration(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	m, err := newMigrator(db, testFS, "sql")
+	assert.NoError(t, err)
+
+	expectLock(mock)
+	mock.ExpectQuery(`select version, applied_at from schema_migrations;`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(`create table urls`).WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+	expectUnlock(mock)
+
+	count, err := m.Up(context.Background())
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Equal(t, 0, count)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
+
+func TestMigrator_Down(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	m, err := newMigrator(db, testFS, "sql")
+	assert.NoError(t, err)
+
+	t.Run("Rolls back latest applied migration", func(t *testing.T) {
+		expectLock(mock)
+		mock.ExpectQuery(`select version, applied_at from schema_migrations;`).
+			WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()).AddRow(2, time.Now()))
+		mock.ExpectBegin()
+		mock.ExpectExec(`alter table urls drop column name;`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`delete from schema_migrations where version = \$1;`).WithArgs(int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		expectUnlock(mock)
+
+		migration, err := m.Down(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), migration.Version)
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Not all expectations were met: %v", err)
+		}
+	})
+
+	t.Run("Nothing to roll back", func(t *testing.T) {
+		expectLock(mock)
+		mock.ExpectQuery(`select version, applied_at from schema_migrations;`).
+			WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}))
+		expectUnlock(mock)
+
+		_, err := m.Down(context.Background())
+		assert.ErrorIs(t, err, ErrNoAppliedMigrations)
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Not all expectations were met: %v", err)
+		}
+	})
+}
+
+func TestMigrator_Status(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	m, err := newMigrator(db, testFS, "sql")
+	assert.NoError(t, err)
+
+	appliedAt := time.Now()
+	expectLock(mock)
+	mock.ExpectQuery(`select version, applied_at from schema_migrations;`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, appliedAt))
+	expectUnlock(mock)
+
+	statuses, err := m.Status(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, statuses, 3) {
+		assert.Equal(t, "create_urls", statuses[0].Name)
+		assert.NotNil(t, statuses[0].AppliedAt, "First migration should be applied")
+		assert.Nil(t, statuses[1].AppliedAt, "Second migration should be pending")
+		assert.Nil(t, statuses[2].AppliedAt, "Third migration should be pending")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
@@ -0,0 +1,11 @@
+create table if not exists urls (
+    id serial,
+    user_id text not null,
+    original_url text not null,
+    shorted_url text not null,
+    created_at timestamp not null default now(),
+    updated_at timestamp not null default now(),
+    deleted_at timestamp,
+    constraint urls_id_pk primary key(id),
+    constraint ulrs_original_url_uk unique (original_url)
+);
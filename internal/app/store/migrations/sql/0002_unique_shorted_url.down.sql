@@ -0,0 +1,2 @@
+alter table urls drop constraint if exists urls_shorted_url_uk;
+drop index if exists urls_shorted_url_uk;
@@ -0,0 +1 @@
+create unique index if not exists urls_shorted_url_uk on urls (shorted_url);
@@ -0,0 +1 @@
+alter table urls drop column if exists expires_at;
@@ -0,0 +1 @@
+alter table urls add column if not exists expires_at timestamptz;
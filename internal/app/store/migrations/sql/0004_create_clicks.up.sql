@@ -0,0 +1,10 @@
+create table if not exists clicks (
+    id bigserial,
+    shorted_url text not null,
+    clicked_at timestamptz not null,
+    referrer text not null default '',
+    user_agent text not null default '',
+    ip text not null default '',
+    constraint clicks_id_pk primary key(id)
+);
+create index if not exists clicks_shorted_url_clicked_at_idx on clicks (shorted_url, clicked_at);
@@ -0,0 +1,3 @@
+alter table urls drop constraint if exists urls_user_id_original_url_uk;
+drop index if exists urls_user_id_original_url_uk;
+alter table urls add constraint ulrs_original_url_uk unique (original_url);
@@ -0,0 +1,2 @@
+create unique index if not exists urls_user_id_original_url_uk on urls (user_id, original_url);
+alter table urls drop constraint if exists ulrs_original_url_uk;
@@ -0,0 +1 @@
+drop sequence if exists short_key_seq;
@@ -0,0 +1 @@
+create sequence if not exists short_key_seq;
@@ -0,0 +1,2 @@
+drop trigger if exists urls_notify_changes on urls;
+drop function if exists notify_url_changes();
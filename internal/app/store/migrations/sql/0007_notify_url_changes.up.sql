@@ -0,0 +1,13 @@
+create or replace function notify_url_changes() returns trigger as $$
+begin
+    if tg_op = 'DELETE' then
+        perform pg_notify('url_changes', old.shorted_url);
+    else
+        perform pg_notify('url_changes', new.shorted_url);
+    end if;
+    return null;
+end;
+$$ language plpgsql;
+drop trigger if exists urls_notify_changes on urls;
+create trigger urls_notify_changes after insert or delete or update of original_url, deleted_at, expires_at on urls
+    for each row execute procedure notify_url_changes();
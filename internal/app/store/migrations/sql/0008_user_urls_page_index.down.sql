@@ -0,0 +1 @@
+drop index if exists urls_user_id_created_at_idx;
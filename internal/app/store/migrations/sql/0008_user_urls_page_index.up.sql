@@ -0,0 +1 @@
+create index if not exists urls_user_id_created_at_idx on urls (user_id, created_at, shorted_url) where deleted_at is null;
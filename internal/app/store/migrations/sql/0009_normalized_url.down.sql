@@ -0,0 +1,3 @@
+drop index if exists urls_user_id_normalized_url_idx;
+alter table urls drop column if exists normalized_url;
+drop function if exists normalize_url(text);
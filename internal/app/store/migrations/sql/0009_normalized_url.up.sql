@@ -0,0 +1,29 @@
+create or replace function normalize_url(url text) returns text as $$
+declare
+    parts text[];
+    scheme text;
+    userinfo text;
+    host text;
+    rest text;
+begin
+    parts := regexp_match(url, '^([A-Za-z][A-Za-z0-9+.\-]*)://([^/?#]*)(.*)$');
+    if parts is null then
+        return url;
+    end if;
+    scheme := lower(parts[1]);
+    userinfo := coalesce(substring(parts[2] from '^(.*@)'), '');
+    host := lower(substring(parts[2] from '([^@]*)$'));
+    rest := parts[3];
+    if scheme = 'http' and right(host, 3) = ':80' then
+        host := left(host, -3);
+    elsif scheme = 'https' and right(host, 4) = ':443' then
+        host := left(host, -4);
+    end if;
+    if rest = '' or left(rest, 1) in ('?', '#') then
+        rest := '/' || rest;
+    end if;
+    return scheme || '://' || userinfo || host || rest;
+end;
+$$ language plpgsql immutable;
+alter table urls add column if not exists normalized_url text generated always as (normalize_url(original_url)) stored;
+create index if not exists urls_user_id_normalized_url_idx on urls (user_id, normalized_url);
@@ -0,0 +1,4 @@
+alter table urls add column if not exists redirect_type smallint not null default 0;
+drop trigger if exists urls_notify_changes on urls;
+create trigger urls_notify_changes after insert or delete or update of original_url, deleted_at, expires_at, redirect_type on urls
+    for each row execute procedure notify_url_changes();
@@ -0,0 +1,5 @@
+drop trigger if exists urls_notify_changes on urls;
+create trigger urls_notify_changes after insert or delete or update of original_url, deleted_at, expires_at, redirect_type on urls
+    for each row execute procedure notify_url_changes();
+alter table urls drop column if exists forward_path;
+alter table urls drop column if exists forward_query;
@@ -0,0 +1,5 @@
+alter table urls add column if not exists forward_query text not null default '';
+alter table urls add column if not exists forward_path boolean not null default false;
+drop trigger if exists urls_notify_changes on urls;
+create trigger urls_notify_changes after insert or delete or update of original_url, deleted_at, expires_at, redirect_type, forward_query, forward_path on urls
+    for each row execute procedure notify_url_changes();
@@ -0,0 +1,13 @@
+create table if not exists api_keys (
+    id text not null,
+    user_id text not null,
+    name text not null,
+    prefix text not null,
+    key_hash text not null,
+    scopes text[] not null,
+    created_at timestamptz not null default now(),
+    last_used_at timestamptz,
+    constraint api_keys_id_pk primary key(id),
+    constraint api_keys_key_hash_unique unique(key_hash)
+);
+create index if not exists api_keys_user_id_idx on api_keys (user_id, created_at);
@@ -0,0 +1,2 @@
+-- Удалённые переходы не восстанавливаются.
+select 1;
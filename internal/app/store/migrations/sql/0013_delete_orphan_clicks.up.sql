@@ -0,0 +1 @@
+delete from clicks where not exists (select 1 from urls where urls.shorted_url = clicks.shorted_url);
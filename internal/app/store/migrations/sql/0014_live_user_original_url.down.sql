@@ -0,0 +1,9 @@
+-- Удалённые ссылки, которые пользователь сократил заново, не переживают возврат к полному индексу.
+delete from urls d where d.deleted_at is not null and exists (
+    select 1 from urls l
+    where l.user_id = d.user_id and l.original_url = d.original_url and l.id <> d.id
+        and (l.deleted_at is null or l.id > d.id)
+);
+delete from clicks where not exists (select 1 from urls where urls.shorted_url = clicks.shorted_url);
+drop index if exists urls_user_id_original_url_uk;
+create unique index if not exists urls_user_id_original_url_uk on urls (user_id, original_url);
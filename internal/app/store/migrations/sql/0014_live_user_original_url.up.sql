@@ -0,0 +1,2 @@
+drop index if exists urls_user_id_original_url_uk;
+create unique index if not exists urls_user_id_original_url_uk on urls (user_id, original_url) where deleted_at is null;
@@ -0,0 +1,12 @@
+create table if not exists deletion_jobs (
+    id text not null,
+    user_id text not null,
+    short_urls text[] not null,
+    status text not null,
+    error text not null default '',
+    created_at timestamptz not null default now(),
+    finished_at timestamptz,
+    constraint deletion_jobs_id_pk primary key(id)
+);
+create index if not exists deletion_jobs_pending_idx on deletion_jobs (created_at) where status = 'pending';
+create index if not exists deletion_jobs_finished_at_idx on deletion_jobs (finished_at);
@@ -3,71 +3,70 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/jackc/pgx"
 	_ "github.com/jackc/pgx/stdlib"
 	"github.com/lib/pq"
 	"go.uber.org/zap"
 
 	"github.com/shekshuev/shortener/internal/app/config"
+	"github.com/shekshuev/shortener/internal/app/deletion"
 	"github.com/shekshuev/shortener/internal/app/logger"
 	"github.com/shekshuev/shortener/internal/app/models"
+	"github.com/shekshuev/shortener/internal/app/store/migrations"
 )
 
+// shortedURLConstraint - имя ограничения уникальности короткого ключа.
+const shortedURLConstraint = "urls_shorted_url_uk"
+
+// userOriginalURLConstraint - имя ограничения уникальности оригинального URL в пределах пользователя.
+const userOriginalURLConstraint = "urls_user_id_original_url_uk"
+
+// apiKeyHashConstraint - имя ограничения уникальности хэша ключа API.
+const apiKeyHashConstraint = "api_keys_key_hash_unique"
+
+// apiKeyIDConstraint - имя первичного ключа таблицы ключей API.
+const apiKeyIDConstraint = "api_keys_id_pk"
+
+// uniqueViolationCode - код ошибки PostgreSQL при нарушении ограничения уникальности.
+const uniqueViolationCode = "23505"
+
 // PostgresURLStore - хранилище URL в PostgreSQL.
 type PostgresURLStore struct {
 	cfg *config.Config
 	db  *sql.DB
 }
 
-// NewPostgresURLStore создаёт экземпляр хранилища и инициализирует БД.
-func NewPostgresURLStore(cfg *config.Config) *PostgresURLStore {
-	log := logger.NewLogger()
+// NewPostgresURLStore создаёт экземпляр хранилища и применяет к БД недостающие миграции схемы.
+func NewPostgresURLStore(cfg *config.Config) (*PostgresURLStore, error) {
 	db, err := sql.Open("pgx", cfg.DatabaseDSN)
 	if err != nil {
-		log.Log.Error("Error connecting to database", zap.Error(err))
-		return nil
+		return nil, fmt.Errorf("connect to database: %w", err)
 	}
-	query := `
-		select exists (
-			select from information_schema.tables 
-			where table_schema = 'public' and table_name = 'urls'
-		);
-	`
-	var exists bool
-	err = db.QueryRow(query).Scan(&exists)
+	migrator, err := migrations.NewMigrator(db)
 	if err != nil {
-		log.Log.Error("Error checking if table exists", zap.Error(err))
-	}
-	if !exists {
-		query = `
-            create table urls (
-                id serial,
-				user_id text not null,
-				original_url text not null,
-                shorted_url text not null,
-				created_at timestamp not null default now(),
-				updated_at timestamp not null default now(),
-				deleted_at timestamp,
-				constraint urls_id_pk primary key(id),
-				constraint ulrs_original_url_uk unique (original_url)
-            );
-        `
-		_, err = db.Exec(query)
-		if err != nil {
-			log.Log.Error("Error creating table", zap.Error(err))
-		}
+		db.Close()
+		return nil, fmt.Errorf("load migrations: %w", err)
 	}
+	count, err := migrator.Up(context.Background())
 	if err != nil {
-		log.Log.Error("Error connecting to database", zap.Error(err))
+		db.Close()
+		return nil, fmt.Errorf("apply migrations: %w", err)
+	}
+	if count > 0 {
+		logger.NewLogger().Log.Info("Migrations applied", zap.Int("count", count))
 	}
-	store := &PostgresURLStore{cfg: cfg, db: db}
-	return store
+	return &PostgresURLStore{cfg: cfg, db: db}, nil
 }
 
 // SetURL сохраняет новый URL в базе данных.
-func (s *PostgresURLStore) SetURL(ctx context.Context, key, value, userID string) (string, error) {
+// Если пользователь уже сокращал этот URL, возвращается существующий ключ и ErrAlreadyExists.
+func (s *PostgresURLStore) SetURL(ctx context.Context, key, value, userID string, opts models.LinkOptions) (string, error) {
 	if len(key) == 0 {
 		return "", ErrEmptyKey
 	}
@@ -78,18 +77,35 @@ func (s *PostgresURLStore) SetURL(ctx context.Context, key, value, userID string
 		return "", ErrEmptyUserID
 	}
 	log := logger.NewLogger()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	if err := retireExpiredUserURL(ctx, tx, userID, value); err != nil {
+		tx.Rollback()
+		return "", err
+	}
 	query := `
-		insert into urls (original_url, shorted_url, user_id) values ($1, $2, $3)
-		on conflict (original_url) do update set updated_at = now() 
+		insert into urls (original_url, shorted_url, user_id, expires_at, redirect_type, forward_query, forward_path)
+		values ($1, $2, $3, $4, $5, $6, $7)
+		on conflict (user_id, original_url) where deleted_at is null do update set updated_at = now()
 		returning (created_at = updated_at) as is_new, shorted_url;
 	`
 	var (
 		isNew      bool
 		shorterURL string
 	)
-	err := s.db.QueryRowContext(ctx, query, value, key, userID).Scan(&isNew, &shorterURL)
+	err = tx.QueryRowContext(ctx, query, value, key, userID, opts.ExpiresAt, opts.RedirectType, opts.ForwardQuery, opts.ForwardPath).Scan(&isNew, &shorterURL)
 	if err != nil {
+		tx.Rollback()
+		if isUniqueViolation(err, shortedURLConstraint) {
+			return "", ErrKeyTaken
+		}
 		log.Log.Error("Error upserting record", zap.Error(err))
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
 	}
 	if !isNew {
 		return shorterURL, ErrAlreadyExists
@@ -97,7 +113,9 @@ func (s *PostgresURLStore) SetURL(ctx context.Context, key, value, userID string
 	return shorterURL, nil
 }
 
-// SetBatchURL сохраняет URL пользователю пачкой
+// SetBatchURL сохраняет URL пользователю пачкой.
+// Для URL, которые пользователь уже сокращал, в createDTO подставляется существующий ключ,
+// остальные URL сохраняются, а в конце возвращается ErrAlreadyExists.
 func (s *PostgresURLStore) SetBatchURL(ctx context.Context, createDTO []models.BatchShortURLCreateDTO, userID string) error {
 	log := logger.NewLogger()
 	tx, err := s.db.BeginTx(ctx, nil)
@@ -105,8 +123,9 @@ func (s *PostgresURLStore) SetBatchURL(ctx context.Context, createDTO []models.B
 		return err
 	}
 	query := `
-		insert into urls (original_url, shorted_url, user_id) values ($1, $2, $3)
-		on conflict (original_url) do update set updated_at = now()
+		insert into urls (original_url, shorted_url, user_id, expires_at, redirect_type, forward_query, forward_path)
+		values ($1, $2, $3, $4, $5, $6, $7)
+		on conflict (user_id, original_url) where deleted_at is null do update set updated_at = now()
 		returning (created_at = updated_at) as is_new, shorted_url;
 	`
 	hasSameURL := false
@@ -120,41 +139,71 @@ func (s *PostgresURLStore) SetBatchURL(ctx context.Context, createDTO []models.B
 		if len(userID) == 0 {
 			return ErrEmptyUserID
 		}
+		if err := retireExpiredUserURL(ctx, tx, userID, createDTO[i].OriginalURL); err != nil {
+			tx.Rollback()
+			return err
+		}
 		var (
 			isNew    bool
 			shortURL string
 		)
-		err := tx.QueryRowContext(ctx, query, createDTO[i].OriginalURL, createDTO[i].ShortURL, userID).Scan(&isNew, &shortURL)
+		err := tx.QueryRowContext(ctx, query, createDTO[i].OriginalURL, createDTO[i].ShortURL, userID, createDTO[i].ExpiresAt, createDTO[i].RedirectType,
+			createDTO[i].ForwardQuery, createDTO[i].ForwardPath).Scan(&isNew, &shortURL)
 		if err != nil {
-			log.Log.Error("Error upserting record", zap.Error(err))
 			tx.Rollback()
+			if isUniqueViolation(err, shortedURLConstraint) {
+				return ErrKeyTaken
+			}
+			log.Log.Error("Error upserting record", zap.Error(err))
+			return err
 		}
 		if !isNew {
 			createDTO[i].ShortURL = shortURL
 			hasSameURL = true
 		}
 	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
 	if hasSameURL {
 		return ErrAlreadyExists
 	}
-	return tx.Commit()
+	return nil
 }
 
 // GetURL возвращает оригинальный URL по короткому ключу.
 func (s *PostgresURLStore) GetURL(ctx context.Context, key string) (string, error) {
+	redirect, err := s.GetRedirect(ctx, key)
+	return redirect.URL, err
+}
+
+// GetRedirect возвращает оригинальный URL и код ответа при переходе по короткому ключу.
+func (s *PostgresURLStore) GetRedirect(ctx context.Context, key string) (models.RedirectDTO, error) {
 	query := `
-		select original_url, deleted_at is not null as is_deleted from urls where shorted_url = $1;
+		select original_url, redirect_type, forward_query, forward_path, expires_at,
+			deleted_at is not null as is_deleted, coalesce(expires_at <= now(), false) as is_expired
+		from urls where shorted_url = $1;
 	`
-	var value string
-	var isDeleted bool
-	err := s.db.QueryRowContext(ctx, query, key).Scan(&value, &isDeleted)
+	var redirect models.RedirectDTO
+	var expiresAt sql.NullTime
+	var isDeleted, isExpired bool
+	err := s.db.QueryRowContext(ctx, query, key).Scan(&redirect.URL, &redirect.Type, &redirect.ForwardQuery, &redirect.ForwardPath, &expiresAt, &isDeleted, &isExpired)
 	if err == sql.ErrNoRows {
-		return "", ErrNotFound
+		return models.RedirectDTO{}, ErrNotFound
+	}
+	if err != nil {
+		return models.RedirectDTO{}, err
 	}
 	if isDeleted {
-		return "", ErrAlreadyDeleted
+		return models.RedirectDTO{}, ErrAlreadyDeleted
+	}
+	if isExpired {
+		return models.RedirectDTO{}, ErrExpired
 	}
-	return value, nil
+	if expiresAt.Valid {
+		redirect.ExpiresAt = &expiresAt.Time
+	}
+	return redirect, nil
 }
 
 // GetUserURLs возвращает список URL пользователя.
@@ -184,6 +233,98 @@ func (s *PostgresURLStore) GetUserURLs(ctx context.Context, userID string) ([]mo
 	return readDTO, nil
 }
 
+// userURLsSortColumns сопоставляет полям сортировки ссылок пользователя столбцы таблицы urls.
+var userURLsSortColumns = map[string]string{
+	SortCreatedAt:   "created_at",
+	SortShortURL:    "shorted_url",
+	SortOriginalURL: "original_url",
+}
+
+// likeEscaper экранирует спецсимволы шаблона LIKE.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// GetUserURLsPage возвращает страницу ссылок пользователя, отфильтрованных и отсортированных согласно query.
+// Страница выбирается по ключу (keyset): курсор хранит значение поля сортировки и ключ последней ссылки,
+// поэтому запрос не зависит от глубины страницы.
+func (s *PostgresURLStore) GetUserURLsPage(ctx context.Context, userID string, query models.UserURLsQuery) (models.UserURLsPageDTO, error) {
+	cursor, err := prepareUserURLsQuery(&query)
+	if err != nil {
+		return models.UserURLsPageDTO{}, err
+	}
+	column := userURLsSortColumns[query.Sort]
+	cmp := ">"
+	if query.Order == OrderDesc {
+		cmp = "<"
+	}
+	var sb strings.Builder
+	args := []any{userID}
+	sb.WriteString("select shorted_url, original_url, created_at from urls where user_id = $1 and deleted_at is null")
+	if len(query.Query) > 0 {
+		args = append(args, "%"+likeEscaper.Replace(query.Query)+"%")
+		fmt.Fprintf(&sb, ` and original_url ilike $%d escape '\'`, len(args))
+	}
+	if cursor != nil {
+		args = append(args, cursor.Value, cursor.Key)
+		value := fmt.Sprintf("$%d", len(args)-1)
+		if query.Sort == SortCreatedAt {
+			value += "::timestamp"
+		}
+		fmt.Fprintf(&sb, " and (%s, shorted_url) %s (%s, $%d)", column, cmp, value, len(args))
+	}
+	fmt.Fprintf(&sb, " order by %s %s, shorted_url %s", column, query.Order, query.Order)
+	if query.Limit > 0 {
+		// Лишняя строка показывает, есть ли следующая страница.
+		args = append(args, query.Limit+1)
+		fmt.Fprintf(&sb, " limit $%d", len(args))
+	}
+
+	rows, err := s.db.QueryContext(ctx, sb.String(), args...)
+	if err != nil {
+		return models.UserURLsPageDTO{}, err
+	}
+	defer rows.Close()
+	var entries []userURLEntry
+	for rows.Next() {
+		var entry userURLEntry
+		if err := rows.Scan(&entry.key, &entry.url, &entry.createdAt); err != nil {
+			return models.UserURLsPageDTO{}, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return models.UserURLsPageDTO{}, err
+	}
+
+	return buildUserURLsPage(entries, query, s.cfg.BaseURL), nil
+}
+
+// LookupUserURLs возвращает неудалённые ссылки пользователя, ведущие на URL с нормализованным видом normalizedURL.
+// Поиск идёт по индексу (user_id, normalized_url); столбец normalized_url вычисляется функцией normalize_url.
+func (s *PostgresURLStore) LookupUserURLs(ctx context.Context, userID, normalizedURL string) ([]models.UserShortURLReadDTO, error) {
+	query := `
+		select shorted_url, original_url from urls
+		where user_id = $1 and normalized_url = $2 and deleted_at is null
+		order by shorted_url;
+	`
+	rows, err := s.db.QueryContext(ctx, query, userID, normalizedURL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var readDTO []models.UserShortURLReadDTO
+	for rows.Next() {
+		var shortURL, originalURL string
+		if err := rows.Scan(&shortURL, &originalURL); err != nil {
+			return nil, err
+		}
+		readDTO = append(readDTO, models.UserShortURLReadDTO{ShortURL: fmt.Sprintf("%s/%s", s.cfg.BaseURL, shortURL), OriginalURL: originalURL})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return readDTO, nil
+}
+
 // DeleteURLs удаляет список URL пользователя.
 func (s *PostgresURLStore) DeleteURLs(ctx context.Context, userID string, urls []string) error {
 	if len(userID) == 0 {
@@ -259,6 +400,428 @@ func (s *PostgresURLStore) DeleteURLs(ctx context.Context, userID string, urls [
 	return nil
 }
 
+// DeleteURLsBatch помечает удалёнными ссылки нескольких пользователей одним запросом.
+func (s *PostgresURLStore) DeleteURLsBatch(ctx context.Context, tasks []models.DeletionTask) error {
+	var userIDs, keys []string
+	for _, task := range tasks {
+		if len(task.UserID) == 0 {
+			return ErrEmptyUserID
+		}
+		if len(task.ShortURLs) == 0 {
+			return ErrEmptyURLs
+		}
+		for _, key := range task.ShortURLs {
+			userIDs = append(userIDs, task.UserID)
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	query := `
+		update urls set deleted_at = now()
+		from unnest($1::text[], $2::text[]) as d(user_id, shorted_url)
+		where urls.user_id = d.user_id and urls.shorted_url = d.shorted_url and urls.deleted_at is null;
+	`
+	_, err := s.db.ExecContext(ctx, query, pq.Array(userIDs), pq.Array(keys))
+	return err
+}
+
+// UpdateURL изменяет оригинальный URL и код ответа при переходе по ссылке пользователя в одной транзакции.
+// Незаполненные поля update не меняются.
+// Возвращает ErrNotFound, если ссылка не найдена, удалена или принадлежит другому пользователю,
+// и ErrAlreadyExists, если пользователь уже сократил новый URL под другим ключом.
+func (s *PostgresURLStore) UpdateURL(ctx context.Context, userID, key string, update models.ShortURLUpdateDTO) error {
+	if len(key) == 0 {
+		return ErrEmptyKey
+	}
+	if len(update.URL) == 0 && update.RedirectType == 0 {
+		return ErrEmptyValue
+	}
+	if len(userID) == 0 {
+		return ErrEmptyUserID
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if len(update.URL) > 0 {
+		if err := retireExpiredUserURL(ctx, tx, userID, update.URL); err != nil {
+			return err
+		}
+	}
+	query := `
+		update urls set original_url = coalesce(nullif($1, ''), original_url),
+			redirect_type = coalesce(nullif($2, 0), redirect_type), updated_at = now()
+		where shorted_url = $3 and user_id = $4 and deleted_at is null;
+	`
+	result, err := tx.ExecContext(ctx, query, update.URL, update.RedirectType, key, userID)
+	if err != nil {
+		if isUniqueViolation(err, userOriginalURLConstraint) {
+			return ErrAlreadyExists
+		}
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// DeleteExpired удаляет из базы данных ссылки с истёкшим сроком действия вместе с их переходами
+// и возвращает количество удалённых ссылок.
+func (s *PostgresURLStore) DeleteExpired(ctx context.Context) (int, error) {
+	query := `
+		with gone as (delete from urls where expires_at <= now() returning shorted_url),
+		gone_clicks as (delete from clicks where shorted_url in (select shorted_url from gone))
+		select count(*) from gone;
+	`
+	var count int
+	err := s.db.QueryRowContext(ctx, query).Scan(&count)
+	return count, err
+}
+
+// RestoreURLs снимает пометку удаления со ссылок пользователя, удалённых не раньше deletedSince,
+// и возвращает ключи восстановленных ссылок.
+func (s *PostgresURLStore) RestoreURLs(ctx context.Context, userID string, urls []string, deletedSince time.Time) ([]string, error) {
+	if len(userID) == 0 {
+		return nil, ErrEmptyUserID
+	}
+	if len(urls) == 0 {
+		return nil, ErrEmptyURLs
+	}
+	// Ссылка не восстанавливается, если пользователь уже заново сократил тот же URL,
+	// а из нескольких удалённых ссылок на один URL восстанавливается удалённая последней.
+	query := `
+		update urls set deleted_at = null
+		where id in (
+			select distinct on (d.original_url) d.id from urls d
+			where d.shorted_url = any($1) and d.user_id = $2 and d.deleted_at >= $3
+				and not exists (select 1 from urls l where l.user_id = d.user_id and l.original_url = d.original_url and l.deleted_at is null)
+			order by d.original_url, d.deleted_at desc
+		)
+		returning shorted_url;
+	`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(urls), userID, deletedSince)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	restored := make([]string, 0, len(urls))
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		restored = append(restored, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// PurgeDeleted окончательно удаляет из базы данных ссылки, удалённые раньше deletedBefore, вместе с их переходами
+// и возвращает количество удалённых ссылок. Переходы удаляются тем же запросом, чтобы ключ, выданный
+// заново, не унаследовал статистику прежнего владельца.
+func (s *PostgresURLStore) PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int, error) {
+	query := `
+		with gone as (delete from urls where deleted_at < $1 returning shorted_url),
+		gone_clicks as (delete from clicks where shorted_url in (select shorted_url from gone))
+		select count(*) from gone;
+	`
+	var count int
+	err := s.db.QueryRowContext(ctx, query, deletedBefore).Scan(&count)
+	return count, err
+}
+
+// SaveClicks сохраняет пачку событий переходов в одной транзакции.
+func (s *PostgresURLStore) SaveClicks(ctx context.Context, events []models.ClickEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	query := `
+		insert into clicks (shorted_url, clicked_at, referrer, user_agent, ip) values ($1, $2, $3, $4, $5);
+	`
+	for _, event := range events {
+		_, err = tx.ExecContext(ctx, query, event.ShortURL, event.Timestamp, event.Referrer, event.UserAgent, event.IP)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetURLStats возвращает статистику переходов по ссылке, принадлежащей пользователю.
+func (s *PostgresURLStore) GetURLStats(ctx context.Context, userID, key string) (models.URLStatsDTO, error) {
+	query := `select exists (select 1 from urls where shorted_url = $1 and user_id = $2);`
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, query, key, userID).Scan(&exists); err != nil {
+		return models.URLStatsDTO{}, err
+	}
+	if !exists {
+		return models.URLStatsDTO{}, ErrNotFound
+	}
+	stats := models.URLStatsDTO{ShortURL: key, Daily: []models.DailyClicksDTO{}}
+	query = `select count(*), count(distinct ip) from clicks where shorted_url = $1;`
+	if err := s.db.QueryRowContext(ctx, query, key).Scan(&stats.Clicks, &stats.UniqueVisitors); err != nil {
+		return models.URLStatsDTO{}, err
+	}
+	query = `
+		select to_char(clicked_at at time zone 'UTC', 'YYYY-MM-DD') as day, count(*)
+		from clicks where shorted_url = $1 group by day order by day;
+	`
+	rows, err := s.db.QueryContext(ctx, query, key)
+	if err != nil {
+		return models.URLStatsDTO{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var daily models.DailyClicksDTO
+		if err := rows.Scan(&daily.Date, &daily.Clicks); err != nil {
+			return models.URLStatsDTO{}, err
+		}
+		stats.Daily = append(stats.Daily, daily)
+	}
+	if err := rows.Err(); err != nil {
+		return models.URLStatsDTO{}, err
+	}
+	return stats, nil
+}
+
+// GetURLInfo возвращает сведения о ссылке по короткому ключу: владельца, оригинальный URL,
+// момент создания, срок действия и количество переходов. Для удалённой ссылки возвращается ErrAlreadyDeleted.
+func (s *PostgresURLStore) GetURLInfo(ctx context.Context, key string) (models.URLInfoDTO, error) {
+	query := `
+		select user_id, original_url, created_at, expires_at, redirect_type, forward_query, forward_path,
+			deleted_at is not null as is_deleted,
+			(select count(*) from clicks where clicks.shorted_url = urls.shorted_url) as clicks
+		from urls where shorted_url = $1;
+	`
+	info := models.URLInfoDTO{ShortURL: key}
+	var (
+		createdAt time.Time
+		expiresAt sql.NullTime
+		isDeleted bool
+	)
+	err := s.db.QueryRowContext(ctx, query, key).Scan(&info.UserID, &info.OriginalURL, &createdAt, &expiresAt, &info.RedirectType, &info.ForwardQuery, &info.ForwardPath, &isDeleted, &info.Clicks)
+	if err == sql.ErrNoRows {
+		return models.URLInfoDTO{}, ErrNotFound
+	}
+	if err != nil {
+		return models.URLInfoDTO{}, err
+	}
+	if isDeleted {
+		return models.URLInfoDTO{}, ErrAlreadyDeleted
+	}
+	info.CreatedAt = &createdAt
+	if expiresAt.Valid {
+		info.ExpiresAt = &expiresAt.Time
+	}
+	return info, nil
+}
+
+// CreateAPIKey сохраняет ключ API.
+func (s *PostgresURLStore) CreateAPIKey(ctx context.Context, key models.APIKey) error {
+	if len(key.UserID) == 0 {
+		return ErrEmptyUserID
+	}
+	if len(key.Hash) == 0 {
+		return ErrEmptyKey
+	}
+	query := `
+		insert into api_keys (id, user_id, name, prefix, key_hash, scopes, created_at)
+		values ($1, $2, $3, $4, $5, $6, $7);
+	`
+	_, err := s.db.ExecContext(ctx, query, key.ID, key.UserID, key.Name, key.Prefix, key.Hash, pq.Array(key.Scopes), key.CreatedAt)
+	if isUniqueViolation(err, apiKeyHashConstraint) || isUniqueViolation(err, apiKeyIDConstraint) {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+// GetUserAPIKeys возвращает ключи API пользователя в порядке создания.
+func (s *PostgresURLStore) GetUserAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	query := `
+		select id, user_id, name, prefix, key_hash, scopes, created_at, last_used_at
+		from api_keys where user_id = $1 order by created_at, id;
+	`
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// GetAPIKeyByHash возвращает ключ API по его хэшу.
+func (s *PostgresURLStore) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	query := `
+		select id, user_id, name, prefix, key_hash, scopes, created_at, last_used_at
+		from api_keys where key_hash = $1;
+	`
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, query, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, ErrNotFound
+	}
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	return key, nil
+}
+
+// RevokeAPIKey удаляет ключ API пользователя.
+// Возвращает ErrNotFound, если ключ не найден или принадлежит другому пользователю.
+func (s *PostgresURLStore) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	query := `delete from api_keys where id = $1 and user_id = $2;`
+	result, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// TouchAPIKey запоминает момент последнего использования ключа API.
+func (s *PostgresURLStore) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+	query := `update api_keys set last_used_at = $1 where id = $2;`
+	result, err := s.db.ExecContext(ctx, query, usedAt, id)
+	if err != nil {
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// rowScanner - общий интерфейс *sql.Row и *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanAPIKey читает ключ API из строки результата запроса.
+func scanAPIKey(row rowScanner) (models.APIKey, error) {
+	var key models.APIKey
+	var lastUsedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Hash, pq.Array(&key.Scopes), &key.CreatedAt, &lastUsedAt)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	return key, nil
+}
+
+// SaveDeletionJob сохраняет задачу удаления или обновляет состояние уже сохранённой задачи.
+func (s *PostgresURLStore) SaveDeletionJob(ctx context.Context, job models.DeletionJob) error {
+	query := `
+		insert into deletion_jobs (id, user_id, short_urls, status, error, created_at, finished_at)
+		values ($1, $2, $3, $4, $5, $6, $7)
+		on conflict (id) do update set status = excluded.status, error = excluded.error, finished_at = excluded.finished_at;
+	`
+	_, err := s.db.ExecContext(ctx, query, job.ID, job.UserID, pq.Array(job.ShortURLs), job.Status, job.Error, job.CreatedAt, job.FinishedAt)
+	return err
+}
+
+// GetDeletionJob возвращает задачу удаления по идентификатору или deletion.ErrJobNotFound.
+func (s *PostgresURLStore) GetDeletionJob(ctx context.Context, id string) (models.DeletionJob, error) {
+	query := `
+		select id, user_id, short_urls, status, error, created_at, finished_at
+		from deletion_jobs where id = $1;
+	`
+	job, err := scanDeletionJob(s.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.DeletionJob{}, deletion.ErrJobNotFound
+	}
+	if err != nil {
+		return models.DeletionJob{}, err
+	}
+	return job, nil
+}
+
+// PendingDeletionJobs возвращает невыполненные задачи удаления в порядке постановки в очередь.
+func (s *PostgresURLStore) PendingDeletionJobs(ctx context.Context) ([]models.DeletionJob, error) {
+	query := `
+		select id, user_id, short_urls, status, error, created_at, finished_at
+		from deletion_jobs where status = $1 order by created_at, id;
+	`
+	rows, err := s.db.QueryContext(ctx, query, models.DeletionJobPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var jobs []models.DeletionJob
+	for rows.Next() {
+		job, err := scanDeletionJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// PurgeDeletionJobs удаляет задачи, завершённые раньше finishedBefore, и возвращает их количество.
+func (s *PostgresURLStore) PurgeDeletionJobs(ctx context.Context, finishedBefore time.Time) (int, error) {
+	result, err := s.db.ExecContext(ctx, `delete from deletion_jobs where finished_at < $1;`, finishedBefore)
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	return int(count), err
+}
+
+// scanDeletionJob читает задачу удаления из строки результата запроса.
+func scanDeletionJob(row rowScanner) (models.DeletionJob, error) {
+	var job models.DeletionJob
+	var finishedAt sql.NullTime
+	err := row.Scan(&job.ID, &job.UserID, pq.Array(&job.ShortURLs), &job.Status, &job.Error, &job.CreatedAt, &finishedAt)
+	if err != nil {
+		return models.DeletionJob{}, err
+	}
+	job.URLs = len(job.ShortURLs)
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return job, nil
+}
+
 // Close закрывает соединение с базой данных.
 func (s *PostgresURLStore) Close() error {
 	if s.db != nil {
@@ -287,3 +850,30 @@ func (s *PostgresURLStore) CountUsers(ctx context.Context) (int, error) {
 	err := s.db.QueryRowContext(ctx, query).Scan(&count)
 	return count, err
 }
+
+// NextSequence возвращает следующее значение последовательности short_key_seq.
+func (s *PostgresURLStore) NextSequence(ctx context.Context) (uint64, error) {
+	var n int64
+	err := s.db.QueryRowContext(ctx, `select nextval('short_key_seq');`).Scan(&n)
+	return uint64(n), err
+}
+
+// retireExpiredUserURL помечает удалённой неудалённую ссылку пользователя на value с истёкшим сроком действия,
+// чтобы уникальный индекс по неудалённым ссылкам не принимал её за повтор.
+func retireExpiredUserURL(ctx context.Context, tx *sql.Tx, userID, value string) error {
+	query := `
+		update urls set deleted_at = now()
+		where user_id = $1 and original_url = $2 and deleted_at is null and expires_at <= now();
+	`
+	_, err := tx.ExecContext(ctx, query, userID, value)
+	return err
+}
+
+// isUniqueViolation проверяет, что ошибка вызвана нарушением указанного ограничения уникальности.
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr pgx.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == uniqueViolationCode && pgErr.ConstraintName == constraint
+}
@@ -22,7 +22,7 @@ func BenchmarkPostgresURLStore_SetURL(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _ = store.SetURL(context.Background(), "short_test", "https://example.com", "1")
+		_, _ = store.SetURL(context.Background(), "short_test", "https://example.com", "1", models.LinkOptions{})
 	}
 }
 
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	"github.com/shekshuev/shortener/internal/app/logger"
+)
+
+// ChangesChannel - канал PostgreSQL, в который триггер urls_notify_changes публикует ключи
+// созданных, изменённых и удалённых ссылок.
+const ChangesChannel = "url_changes"
+
+// Параметры переподключения и проверки соединения подписчика на изменения.
+const (
+	changeListenerMinReconnect = time.Second
+	changeListenerMaxReconnect = time.Minute
+	changeListenerPingInterval = 90 * time.Second
+)
+
+// Invalidator - кэш, из которого можно вытеснить отдельные ключи или все записи сразу.
+type Invalidator interface {
+	Invalidate(keys ...string)
+	Purge()
+}
+
+// changeListener - подписка на уведомления PostgreSQL, реализуемая pq.Listener.
+type changeListener interface {
+	Listen(channel string) error
+	Ping() error
+	NotificationChannel() <-chan *pq.Notification
+	Close() error
+}
+
+// RunChangeListener подписывается на уведомления об изменении ссылок в базе данных dsn
+// и вытесняет изменённые ключи из кэша. Так удаление или изменение ссылки через один экземпляр сервиса
+// сбрасывает её из кэшей остальных экземпляров. При потере соединения подписка восстанавливается
+// автоматически, а кэш очищается целиком, поскольку уведомления за время разрыва потеряны.
+// Работает до отмены контекста.
+func RunChangeListener(ctx context.Context, dsn string, cache Invalidator) {
+	log := logger.NewLogger()
+	listener := pq.NewListener(dsn, changeListenerMinReconnect, changeListenerMaxReconnect,
+		func(event pq.ListenerEventType, err error) {
+			switch event {
+			case pq.ListenerEventConnected:
+				log.Log.Info("Listening for url changes", zap.String("channel", ChangesChannel))
+			case pq.ListenerEventDisconnected:
+				log.Log.Warn("Url changes listener disconnected", zap.Error(err))
+			case pq.ListenerEventReconnected:
+				log.Log.Info("Url changes listener reconnected")
+			case pq.ListenerEventConnectionAttemptFailed:
+				log.Log.Error("Url changes listener failed to connect", zap.Error(err))
+			}
+		})
+	listenChanges(ctx, listener, cache, changeListenerPingInterval)
+}
+
+// listenChanges применяет уведомления подписки к кэшу и периодически проверяет соединение.
+// Закрывает подписку при отмене контекста.
+func listenChanges(ctx context.Context, listener changeListener, cache Invalidator, pingInterval time.Duration) {
+	log := logger.NewLogger()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-stop:
+		}
+		listener.Close()
+	}()
+
+	// Listen ждёт установки соединения, поэтому прерывается только закрытием подписки.
+	if err := listener.Listen(ChangesChannel); err != nil {
+		if ctx.Err() == nil {
+			log.Log.Error("Error subscribing to url changes", zap.Error(err))
+		}
+		return
+	}
+	// Ссылки могли измениться до начала подписки.
+	cache.Purge()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification, ok := <-listener.NotificationChannel():
+			if !ok {
+				return
+			}
+			// pq.Listener присылает nil после переподключения.
+			if notification == nil {
+				cache.Purge()
+				continue
+			}
+			cache.Invalidate(notification.Extra)
+		case <-ticker.C:
+			if err := listener.Ping(); err != nil {
+				log.Log.Warn("Url changes listener ping failed", zap.Error(err))
+			}
+		}
+	}
+}
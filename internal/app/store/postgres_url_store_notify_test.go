@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeChangeListener - подписка, уведомления в которую отправляет тест.
+type fakeChangeListener struct {
+	mx            sync.Mutex
+	notifications chan *pq.Notification
+	listenErr     error
+	channels      []string
+	pings         int
+	closed        bool
+}
+
+func (l *fakeChangeListener) Listen(channel string) error {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.channels = append(l.channels, channel)
+	return l.listenErr
+}
+
+func (l *fakeChangeListener) Ping() error {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.pings++
+	return nil
+}
+
+func (l *fakeChangeListener) NotificationChannel() <-chan *pq.Notification {
+	return l.notifications
+}
+
+func (l *fakeChangeListener) Close() error {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.closed = true
+	return nil
+}
+
+func (l *fakeChangeListener) isClosed() bool {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	return l.closed
+}
+
+// recordingInvalidator запоминает вытесненные ключи и количество полных очисток.
+type recordingInvalidator struct {
+	mx     sync.Mutex
+	keys   []string
+	purges int
+}
+
+func (c *recordingInvalidator) Invalidate(keys ...string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.keys = append(c.keys, keys...)
+}
+
+func (c *recordingInvalidator) Purge() {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.purges++
+}
+
+func (c *recordingInvalidator) state() ([]string, int) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return append([]string(nil), c.keys...), c.purges
+}
+
+func TestListenChanges(t *testing.T) {
+	listener := &fakeChangeListener{notifications: make(chan *pq.Notification)}
+	cache := &recordingInvalidator{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		listenChanges(ctx, listener, cache, 10*time.Millisecond)
+		close(done)
+	}()
+
+	listener.notifications <- &pq.Notification{Channel: ChangesChannel, Extra: "short1"}
+	listener.notifications <- nil
+	listener.notifications <- &pq.Notification{Channel: ChangesChannel, Extra: "short2"}
+	assert.Eventually(t, func() bool {
+		keys, _ := cache.state()
+		return len(keys) == 2
+	}, time.Second, 5*time.Millisecond)
+	keys, purges := cache.state()
+	assert.Equal(t, []string{"short1", "short2"}, keys)
+	assert.Equal(t, 2, purges, "Cache should be purged after subscribing and after reconnect")
+	assert.Equal(t, []string{ChangesChannel}, listener.channels)
+	assert.Eventually(t, func() bool {
+		listener.mx.Lock()
+		defer listener.mx.Unlock()
+		return listener.pings > 0
+	}, time.Second, 5*time.Millisecond, "Connection should be checked periodically")
+
+	cancel()
+	<-done
+	assert.Eventually(t, listener.isClosed, time.Second, 5*time.Millisecond, "Listener should be closed")
+}
+
+func TestListenChanges_ListenError(t *testing.T) {
+	listener := &fakeChangeListener{notifications: make(chan *pq.Notification), listenErr: assert.AnError}
+	cache := &recordingInvalidator{}
+	listenChanges(context.Background(), listener, cache, time.Minute)
+
+	keys, purges := cache.state()
+	assert.Empty(t, keys)
+	assert.Equal(t, 0, purges)
+	assert.Eventually(t, listener.isClosed, time.Second, 5*time.Millisecond, "Listener should be closed")
+}
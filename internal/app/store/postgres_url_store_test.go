@@ -3,15 +3,22 @@ package store
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx"
 	"github.com/lib/pq"
 	"github.com/shekshuev/shortener/internal/app/config"
+	"github.com/shekshuev/shortener/internal/app/deletion"
 	"github.com/shekshuev/shortener/internal/app/models"
 	"github.com/stretchr/testify/assert"
 )
 
+// retireExpiredQuery - запрос, которым перед сохранением URL помечается удалённой ссылка пользователя с истёкшим сроком.
+const retireExpiredQuery = `update urls set deleted_at = now\(\)\s+where user_id = \$1 and original_url = \$2 and deleted_at is null and expires_at <= now\(\);`
+
 func TestPostgresURLStore_SetURL(t *testing.T) {
 	testCases := []struct {
 		key      string
@@ -35,11 +42,14 @@ func TestPostgresURLStore_SetURL(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			if !tc.hasError {
-				mock.ExpectQuery(`(?i)insert into urls \(original_url, shorted_url, user_id\) values \(\$1, \$2, \$3\) on conflict \(original_url\) do update set updated_at = now\(\) returning \(created_at = updated_at\) as is_new, shorted_url;`).
-					WithArgs(tc.value, tc.key, tc.userID).
+				mock.ExpectBegin()
+				mock.ExpectExec(retireExpiredQuery).WithArgs(tc.userID, tc.value).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(`(?i)insert into urls \(original_url, shorted_url, user_id, expires_at, redirect_type, forward_query, forward_path\)\s+values \(\$1, \$2, \$3, \$4, \$5, \$6, \$7\) on conflict \(user_id, original_url\) where deleted_at is null do update set updated_at = now\(\) returning \(created_at = updated_at\) as is_new, shorted_url;`).
+					WithArgs(tc.value, tc.key, tc.userID, nil, 0, "", false).
 					WillReturnRows(sqlmock.NewRows([]string{"is_new", "shorted_url"}).AddRow(true, "test"))
+				mock.ExpectCommit()
 			}
-			_, err := s.SetURL(context.Background(), tc.key, tc.value, tc.userID)
+			_, err := s.SetURL(context.Background(), tc.key, tc.value, tc.userID, models.LinkOptions{})
 			if tc.hasError {
 				assert.NotNil(t, err, "Error is nil")
 			} else {
@@ -53,6 +63,27 @@ func TestPostgresURLStore_SetURL(t *testing.T) {
 	}
 }
 
+func TestPostgresURLStore_SetURL_KeyTaken(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+	mock.ExpectBegin()
+	mock.ExpectExec(retireExpiredQuery).WithArgs("1", "https://ya.ru").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`(?i)insert into urls`).
+		WithArgs("https://ya.ru", "my-link", "1", nil, 0, "", false).
+		WillReturnError(pgx.PgError{Code: uniqueViolationCode, ConstraintName: shortedURLConstraint})
+	mock.ExpectRollback()
+	_, err = s.SetURL(context.Background(), "my-link", "https://ya.ru", "1", models.LinkOptions{})
+	assert.ErrorIs(t, err, ErrKeyTaken)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
+
 func TestPostgresURLStore_SetBatchURL(t *testing.T) {
 	testCases := []struct {
 		name      string
@@ -88,8 +119,9 @@ func TestPostgresURLStore_SetBatchURL(t *testing.T) {
 			mock.ExpectBegin()
 			if !tc.hasError {
 				for _, dto := range tc.createDTO {
-					mock.ExpectQuery(`(?i)insert into urls \(original_url, shorted_url, user_id\) values \(\$1, \$2, \$3\) on conflict \(original_url\) do update set updated_at = now\(\) returning \(created_at = updated_at\) as is_new, shorted_url;`).
-						WithArgs(dto.OriginalURL, dto.ShortURL, tc.userID).
+					mock.ExpectExec(retireExpiredQuery).WithArgs(tc.userID, dto.OriginalURL).WillReturnResult(sqlmock.NewResult(0, 0))
+					mock.ExpectQuery(`(?i)insert into urls \(original_url, shorted_url, user_id, expires_at, redirect_type, forward_query, forward_path\)\s+values \(\$1, \$2, \$3, \$4, \$5, \$6, \$7\) on conflict \(user_id, original_url\) where deleted_at is null do update set updated_at = now\(\) returning \(created_at = updated_at\) as is_new, shorted_url;`).
+						WithArgs(dto.OriginalURL, dto.ShortURL, tc.userID, nil, 0, "", false).
 						WillReturnRows(sqlmock.NewRows([]string{"is_new", "shorted_url"}).AddRow(true, "test"))
 				}
 				mock.ExpectCommit()
@@ -107,6 +139,39 @@ func TestPostgresURLStore_SetBatchURL(t *testing.T) {
 	}
 }
 
+func TestPostgresURLStore_SetBatchURL_AlreadyExists(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+	createDTO := []models.BatchShortURLCreateDTO{
+		{CorrelationID: "test1", OriginalURL: "https://ya.ru", ShortURL: "test1"},
+		{CorrelationID: "test2", OriginalURL: "https://google.com", ShortURL: "test2"},
+	}
+	query := `(?i)insert into urls \(original_url, shorted_url, user_id, expires_at, redirect_type, forward_query, forward_path\)\s+values \(\$1, \$2, \$3, \$4, \$5, \$6, \$7\) on conflict \(user_id, original_url\) where deleted_at is null do update set updated_at = now\(\) returning \(created_at = updated_at\) as is_new, shorted_url;`
+	mock.ExpectBegin()
+	mock.ExpectExec(retireExpiredQuery).WithArgs("1", "https://ya.ru").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(query).
+		WithArgs("https://ya.ru", "test1", "1", nil, 0, "", false).
+		WillReturnRows(sqlmock.NewRows([]string{"is_new", "shorted_url"}).AddRow(false, "existing"))
+	mock.ExpectExec(retireExpiredQuery).WithArgs("1", "https://google.com").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(query).
+		WithArgs("https://google.com", "test2", "1", nil, 0, "", false).
+		WillReturnRows(sqlmock.NewRows([]string{"is_new", "shorted_url"}).AddRow(true, "test2"))
+	mock.ExpectCommit()
+
+	err = s.SetBatchURL(context.Background(), createDTO, "1")
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+	assert.Equal(t, "existing", createDTO[0].ShortURL, "Existing key of the user should be returned")
+	assert.Equal(t, "test2", createDTO[1].ShortURL)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
+
 func TestPostgresURLStore_GetURL(t *testing.T) {
 	testCases := []struct {
 		key    string
@@ -128,11 +193,11 @@ func TestPostgresURLStore_GetURL(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			if tc.key == tc.getKey {
-				mock.ExpectQuery(`select original_url, deleted_at is not null as is_deleted from urls where shorted_url = \$1`).
+				mock.ExpectQuery(`select original_url, redirect_type, forward_query, forward_path, expires_at,\s+deleted_at is not null as is_deleted, coalesce\(expires_at <= now\(\), false\) as is_expired\s+from urls where shorted_url = \$1`).
 					WithArgs(tc.getKey).
-					WillReturnRows(sqlmock.NewRows([]string{"original_url", "is_deleted"}).AddRow(tc.value, false))
+					WillReturnRows(sqlmock.NewRows([]string{"original_url", "redirect_type", "forward_query", "forward_path", "expires_at", "is_deleted", "is_expired"}).AddRow(tc.value, 0, "", false, nil, false, false))
 			} else {
-				mock.ExpectQuery(`select original_url, deleted_at is not null as is_deleted from urls where shorted_url = \$1`).
+				mock.ExpectQuery(`select original_url, redirect_type, forward_query, forward_path, expires_at,\s+deleted_at is not null as is_deleted, coalesce\(expires_at <= now\(\), false\) as is_expired\s+from urls where shorted_url = \$1`).
 					WithArgs(tc.getKey).
 					WillReturnError(sql.ErrNoRows)
 			}
@@ -197,6 +262,109 @@ func TestPostgresURLStore_GetUserURLs(t *testing.T) {
 	}
 }
 
+func TestPostgresURLStore_GetUserURLsPage(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+	ctx := context.Background()
+
+	first := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	second := first.Add(-time.Second)
+	mock.ExpectQuery(`select shorted_url, original_url, created_at from urls where user_id = \$1 and deleted_at is null `+
+		`and original_url ilike \$2 escape '\\' order by created_at desc, shorted_url desc limit \$3`).
+		WithArgs("1", `%50\%\_off%`, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"shorted_url", "original_url", "created_at"}).
+			AddRow("short3", "https://ya.ru/50%_off", first).
+			AddRow("short2", "https://ya.ru/50%_off?a", second).
+			AddRow("short1", "https://ya.ru/50%_off?b", second))
+
+	page, err := s.GetUserURLsPage(ctx, "1", models.UserURLsQuery{Limit: 2, Query: "50%_off"})
+	assert.Nil(t, err, "Error getting page")
+	assert.Len(t, page.URLs, 2)
+	assert.NotEmpty(t, page.NextCursor)
+
+	mock.ExpectQuery(`select shorted_url, original_url, created_at from urls where user_id = \$1 and deleted_at is null `+
+		`and \(created_at, shorted_url\) < \(\$2::timestamp, \$3\) order by created_at desc, shorted_url desc limit \$4`).
+		WithArgs("1", "2024-01-02T03:04:04.123456000Z", "short2", 3).
+		WillReturnRows(sqlmock.NewRows([]string{"shorted_url", "original_url", "created_at"}).
+			AddRow("short1", "https://ya.ru/50%_off?b", second))
+
+	page, err = s.GetUserURLsPage(ctx, "1", models.UserURLsQuery{Limit: 2, Cursor: page.NextCursor})
+	assert.Nil(t, err, "Error getting next page")
+	assert.Len(t, page.URLs, 1)
+	assert.Empty(t, page.NextCursor, "Last page should not have a cursor")
+
+	_, err = s.GetUserURLsPage(ctx, "1", models.UserURLsQuery{Order: "sideways"})
+	assert.ErrorIs(t, err, ErrInvalidOrder)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
+
+func TestPostgresURLStore_LookupUserURLs(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+
+	mock.ExpectQuery(`select shorted_url, original_url from urls\s+where user_id = \$1 and normalized_url = \$2 and deleted_at is null\s+order by shorted_url;`).
+		WithArgs("1", "https://example.com/x").
+		WillReturnRows(sqlmock.NewRows([]string{"shorted_url", "original_url"}).
+			AddRow("short1", "https://example.com/x").
+			AddRow("short2", "HTTPS://Example.com:443/x"))
+
+	found, err := s.LookupUserURLs(context.Background(), "1", "https://example.com/x")
+	assert.Nil(t, err, "Error looking up URLs")
+	assert.Len(t, found, 2)
+	assert.Equal(t, cfg.BaseURL+"/short2", found[1].ShortURL)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
+
+func TestPostgresURLStore_GetURLInfo(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+	ctx := context.Background()
+	query := `select user_id, original_url, created_at, expires_at, redirect_type, forward_query, forward_path,\s+deleted_at is not null as is_deleted,\s+` +
+		`\(select count\(\*\) from clicks where clicks.shorted_url = urls.shorted_url\) as clicks\s+from urls where shorted_url = \$1;`
+	columns := []string{"user_id", "original_url", "created_at", "expires_at", "redirect_type", "forward_query", "forward_path", "is_deleted", "clicks"}
+	createdAt := time.Now()
+
+	mock.ExpectQuery(query).WithArgs("short1").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("1", "https://ya.ru", createdAt, nil, 301, "merge", true, false, 3))
+	info, err := s.GetURLInfo(ctx, "short1")
+	assert.Nil(t, err, "Error getting url info")
+	assert.Equal(t, models.URLInfoDTO{ShortURL: "short1", OriginalURL: "https://ya.ru", UserID: "1", CreatedAt: &createdAt, Clicks: 3, RedirectType: 301, ForwardQuery: "merge", ForwardPath: true}, info)
+
+	mock.ExpectQuery(query).WithArgs("short2").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("1", "https://ya.ru", createdAt, nil, 0, "", false, true, 0))
+	_, err = s.GetURLInfo(ctx, "short2")
+	assert.ErrorIs(t, err, ErrAlreadyDeleted)
+
+	mock.ExpectQuery(query).WithArgs("short3").WillReturnError(sql.ErrNoRows)
+	_, err = s.GetURLInfo(ctx, "short3")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
+
 func TestPostgresURLStore_DeleteURLs(t *testing.T) {
 	testCases := []struct {
 		name         string
@@ -267,6 +435,57 @@ func TestPostgresURLStore_DeleteURLs(t *testing.T) {
 	}
 }
 
+func TestPostgresURLStore_DeleteURLsBatch(t *testing.T) {
+	testCases := []struct {
+		name     string
+		tasks    []models.DeletionTask
+		userIDs  []string
+		keys     []string
+		hasError bool
+	}{
+		{
+			name: "Several users",
+			tasks: []models.DeletionTask{
+				{UserID: "1", ShortURLs: []string{"short1", "short2"}},
+				{UserID: "2", ShortURLs: []string{"short3"}},
+			},
+			userIDs: []string{"1", "1", "2"},
+			keys:    []string{"short1", "short2", "short3"},
+		},
+		{name: "Empty batch"},
+		{name: "Empty URL list", tasks: []models.DeletionTask{{UserID: "1"}}, hasError: true},
+		{name: "Invalid userID", tasks: []models.DeletionTask{{ShortURLs: []string{"short1"}}}, hasError: true},
+	}
+
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+
+	for _, tc := range testCases {
+		s := &PostgresURLStore{cfg: &cfg, db: db}
+		t.Run(tc.name, func(t *testing.T) {
+			if len(tc.keys) > 0 {
+				mock.ExpectExec(`(?i)update urls set deleted_at = now\(\)\s+from unnest\(\$1::text\[\], \$2::text\[\]\)`).
+					WithArgs(pq.Array(tc.userIDs), pq.Array(tc.keys)).
+					WillReturnResult(sqlmock.NewResult(0, int64(len(tc.keys))))
+			}
+
+			err := s.DeleteURLsBatch(context.Background(), tc.tasks)
+			if tc.hasError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Not all expectations were met: %v", err)
+			}
+		})
+	}
+}
+
 func TestPostgresURLStore_CheckDBConnection(t *testing.T) {
 	testCases := []struct {
 		hasError bool
@@ -340,3 +559,443 @@ func TestPostgresURLStore_CountUsers(t *testing.T) {
 		t.Errorf("Not all expectations were met: %v", err)
 	}
 }
+
+func TestPostgresURLStore_GetURL_Expired(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+
+	mock.ExpectQuery(`select original_url, redirect_type, forward_query, forward_path, expires_at,\s+deleted_at is not null as is_deleted, coalesce\(expires_at <= now\(\), false\) as is_expired\s+from urls where shorted_url = \$1`).
+		WithArgs("expired").
+		WillReturnRows(sqlmock.NewRows([]string{"original_url", "redirect_type", "forward_query", "forward_path", "expires_at", "is_deleted", "is_expired"}).AddRow("https://ya.ru", 0, "", false, time.Now(), false, true))
+
+	_, err = s.GetURL(context.Background(), "expired")
+	assert.ErrorIs(t, err, ErrExpired)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
+
+func TestPostgresURLStore_DeleteExpired(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+
+	mock.ExpectQuery(`with gone as \(delete from urls where expires_at <= now\(\) returning shorted_url\),\s+` +
+		`gone_clicks as \(delete from clicks where shorted_url in \(select shorted_url from gone\)\)\s+select count\(\*\) from gone;`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	count, err := s.DeleteExpired(context.Background())
+	assert.Nil(t, err, "Error deleting expired URLs")
+	assert.Equal(t, 3, count, "Unexpected number of deleted URLs")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
+
+func TestPostgresURLStore_RestoreURLs(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+
+	deletedSince := time.Now().Add(-time.Hour)
+	mock.ExpectQuery(`update urls set deleted_at = null\s+where id in \(\s+select distinct on \(d.original_url\) d.id from urls d\s+`+
+		`where d.shorted_url = any\(\$1\) and d.user_id = \$2 and d.deleted_at >= \$3\s+`+
+		`and not exists \(select 1 from urls l where l.user_id = d.user_id and l.original_url = d.original_url and l.deleted_at is null\)\s+`+
+		`order by d.original_url, d.deleted_at desc\s+\)\s+returning shorted_url;`).
+		WithArgs(pq.Array([]string{"short1", "short2"}), "1", deletedSince).
+		WillReturnRows(sqlmock.NewRows([]string{"shorted_url"}).AddRow("short1"))
+
+	restored, err := s.RestoreURLs(context.Background(), "1", []string{"short1", "short2"}, deletedSince)
+	assert.Nil(t, err, "Error restoring URLs")
+	assert.Equal(t, []string{"short1"}, restored)
+
+	_, err = s.RestoreURLs(context.Background(), "", []string{"short1"}, deletedSince)
+	assert.ErrorIs(t, err, ErrEmptyUserID)
+	_, err = s.RestoreURLs(context.Background(), "1", nil, deletedSince)
+	assert.ErrorIs(t, err, ErrEmptyURLs)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
+
+func TestPostgresURLStore_PurgeDeleted(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+
+	deletedBefore := time.Now().Add(-time.Hour)
+	mock.ExpectQuery(`with gone as \(delete from urls where deleted_at < \$1 returning shorted_url\),\s+` +
+		`gone_clicks as \(delete from clicks where shorted_url in \(select shorted_url from gone\)\)\s+select count\(\*\) from gone;`).
+		WithArgs(deletedBefore).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	count, err := s.PurgeDeleted(context.Background(), deletedBefore)
+	assert.Nil(t, err, "Error purging deleted URLs")
+	assert.Equal(t, 2, count, "Unexpected number of purged URLs")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
+
+func TestPostgresURLStore_SaveClicks(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+
+	events := []models.ClickEvent{
+		{ShortURL: "short1", Timestamp: time.Now(), Referrer: "https://google.com", UserAgent: "curl", IP: "10.0.0.1"},
+		{ShortURL: "short1", Timestamp: time.Now(), IP: "10.0.0.2"},
+	}
+	mock.ExpectBegin()
+	for _, event := range events {
+		mock.ExpectExec(`insert into clicks \(shorted_url, clicked_at, referrer, user_agent, ip\) values \(\$1, \$2, \$3, \$4, \$5\);`).
+			WithArgs(event.ShortURL, event.Timestamp, event.Referrer, event.UserAgent, event.IP).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	err = s.SaveClicks(context.Background(), events)
+	assert.Nil(t, err, "Error saving clicks")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
+
+func TestPostgresURLStore_GetURLStats(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+
+	t.Run("Owner gets stats", func(t *testing.T) {
+		mock.ExpectQuery(`select exists \(select 1 from urls where shorted_url = \$1 and user_id = \$2\);`).
+			WithArgs("short1", "1").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery(`select count\(\*\), count\(distinct ip\) from clicks where shorted_url = \$1;`).
+			WithArgs("short1").
+			WillReturnRows(sqlmock.NewRows([]string{"count", "count"}).AddRow(3, 2))
+		mock.ExpectQuery(`select to_char\(clicked_at at time zone 'UTC', 'YYYY-MM-DD'\) as day, count\(\*\)\s+from clicks where shorted_url = \$1 group by day order by day;`).
+			WithArgs("short1").
+			WillReturnRows(sqlmock.NewRows([]string{"day", "count"}).AddRow("2024-05-01", 2).AddRow("2024-05-02", 1))
+
+		stats, err := s.GetURLStats(context.Background(), "1", "short1")
+		assert.Nil(t, err)
+		assert.Equal(t, 3, stats.Clicks)
+		assert.Equal(t, 2, stats.UniqueVisitors)
+		assert.Len(t, stats.Daily, 2)
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Not all expectations were met: %v", err)
+		}
+	})
+
+	t.Run("Another user gets not found", func(t *testing.T) {
+		mock.ExpectQuery(`select exists \(select 1 from urls where shorted_url = \$1 and user_id = \$2\);`).
+			WithArgs("short1", "2").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		_, err := s.GetURLStats(context.Background(), "2", "short1")
+		assert.ErrorIs(t, err, ErrNotFound)
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Not all expectations were met: %v", err)
+		}
+	})
+}
+
+func TestPostgresURLStore_UpdateURL(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+	query := `update urls set original_url = coalesce\(nullif\(\$1, ''\), original_url\),\s+redirect_type = coalesce\(nullif\(\$2, 0\), redirect_type\), updated_at = now\(\)\s+where shorted_url = \$3 and user_id = \$4 and deleted_at is null;`
+
+	testCases := []struct {
+		name        string
+		userID      string
+		mockResult  driver.Result
+		mockErr     error
+		expectedErr error
+	}{
+		{name: "Owner updates url", userID: "1", mockResult: sqlmock.NewResult(0, 1), expectedErr: nil},
+		{name: "Another user", userID: "2", mockResult: sqlmock.NewResult(0, 0), expectedErr: ErrNotFound},
+		{
+			name:        "Url already shortened",
+			userID:      "1",
+			mockErr:     pgx.PgError{Code: uniqueViolationCode, ConstraintName: userOriginalURLConstraint},
+			expectedErr: ErrAlreadyExists,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mock.ExpectBegin()
+			mock.ExpectExec(retireExpiredQuery).WithArgs(tc.userID, "https://example.com").WillReturnResult(sqlmock.NewResult(0, 0))
+			exec := mock.ExpectExec(query).WithArgs("https://example.com", 0, "short1", tc.userID)
+			if tc.mockErr != nil {
+				exec.WillReturnError(tc.mockErr)
+			} else {
+				exec.WillReturnResult(tc.mockResult)
+			}
+			if tc.expectedErr == nil {
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+			err := s.UpdateURL(context.Background(), tc.userID, "short1", models.ShortURLUpdateDTO{URL: "https://example.com"})
+			assert.Equal(t, tc.expectedErr, err, "Unexpected update result")
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Not all expectations were met: %v", err)
+			}
+		})
+	}
+}
+
+func TestPostgresURLStore_UpdateURL_RedirectType(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+	query := `update urls set original_url = coalesce\(nullif\(\$1, ''\), original_url\),\s+redirect_type = coalesce\(nullif\(\$2, 0\), redirect_type\), updated_at = now\(\)\s+where shorted_url = \$3 and user_id = \$4 and deleted_at is null;`
+
+	mock.ExpectBegin()
+	mock.ExpectExec(query).WithArgs("", 301, "short1", "1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	assert.Nil(t, s.UpdateURL(context.Background(), "1", "short1", models.ShortURLUpdateDTO{RedirectType: 301}), "Error setting redirect type")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(retireExpiredQuery).WithArgs("1", "https://example.com").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(query).WithArgs("https://example.com", 308, "short1", "1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	update := models.ShortURLUpdateDTO{URL: "https://example.com", RedirectType: 308}
+	assert.Nil(t, s.UpdateURL(context.Background(), "1", "short1", update), "Url and redirect type should be updated together")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(query).WithArgs("", 301, "short1", "2").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	assert.ErrorIs(t, s.UpdateURL(context.Background(), "2", "short1", models.ShortURLUpdateDTO{RedirectType: 301}), ErrNotFound)
+
+	assert.ErrorIs(t, s.UpdateURL(context.Background(), "1", "short1", models.ShortURLUpdateDTO{}), ErrEmptyValue)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
+
+func TestPostgresURLStore_NextSequence(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+
+	mock.ExpectQuery(`select nextval\('short_key_seq'\);`).
+		WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(42))
+
+	n, err := s.NextSequence(context.Background())
+	assert.Nil(t, err, "Error getting next sequence value")
+	assert.Equal(t, uint64(42), n)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
+
+func TestPostgresURLStore_CreateAPIKey(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+	query := `insert into api_keys \(id, user_id, name, prefix, key_hash, scopes, created_at\)\s+values \(\$1, \$2, \$3, \$4, \$5, \$6, \$7\);`
+	key := models.APIKey{ID: "key1", UserID: "1", Name: "ci", Prefix: "shk_aaaa", Hash: "hash1", Scopes: []string{"read"}, CreatedAt: time.Now()}
+
+	mock.ExpectExec(query).
+		WithArgs(key.ID, key.UserID, key.Name, key.Prefix, key.Hash, pq.Array(key.Scopes), key.CreatedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.Nil(t, s.CreateAPIKey(context.Background(), key), "Error creating api key")
+
+	mock.ExpectExec(query).
+		WithArgs(key.ID, key.UserID, key.Name, key.Prefix, key.Hash, pq.Array(key.Scopes), key.CreatedAt).
+		WillReturnError(pgx.PgError{Code: uniqueViolationCode, ConstraintName: apiKeyHashConstraint})
+	assert.ErrorIs(t, s.CreateAPIKey(context.Background(), key), ErrAlreadyExists)
+
+	assert.ErrorIs(t, s.CreateAPIKey(context.Background(), models.APIKey{ID: "key2", Hash: "hash2"}), ErrEmptyUserID)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
+
+func TestPostgresURLStore_GetAPIKeys(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+	columns := []string{"id", "user_id", "name", "prefix", "key_hash", "scopes", "created_at", "last_used_at"}
+	createdAt := time.Now()
+	usedAt := createdAt.Add(time.Minute)
+
+	t.Run("User keys", func(t *testing.T) {
+		mock.ExpectQuery(`select id, user_id, name, prefix, key_hash, scopes, created_at, last_used_at\s+from api_keys where user_id = \$1 order by created_at, id;`).
+			WithArgs("1").
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("key1", "1", "ci", "shk_aaaa", "hash1", "{read}", createdAt, nil).
+				AddRow("key2", "1", "bot", "shk_bbbb", "hash2", "{create,read}", createdAt, usedAt))
+
+		keys, err := s.GetUserAPIKeys(context.Background(), "1")
+		assert.Nil(t, err)
+		if assert.Len(t, keys, 2) {
+			assert.Equal(t, []string{"read"}, keys[0].Scopes)
+			assert.Nil(t, keys[0].LastUsedAt)
+			assert.Equal(t, []string{"create", "read"}, keys[1].Scopes)
+			assert.Equal(t, &usedAt, keys[1].LastUsedAt)
+		}
+	})
+
+	t.Run("Key by hash", func(t *testing.T) {
+		query := `select id, user_id, name, prefix, key_hash, scopes, created_at, last_used_at\s+from api_keys where key_hash = \$1;`
+		mock.ExpectQuery(query).
+			WithArgs("hash1").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("key1", "1", "ci", "shk_aaaa", "hash1", "{read}", createdAt, nil))
+		key, err := s.GetAPIKeyByHash(context.Background(), "hash1")
+		assert.Nil(t, err)
+		assert.Equal(t, "key1", key.ID)
+		assert.Equal(t, "1", key.UserID)
+
+		mock.ExpectQuery(query).WithArgs("unknown").WillReturnRows(sqlmock.NewRows(columns))
+		_, err = s.GetAPIKeyByHash(context.Background(), "unknown")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
+
+func TestPostgresURLStore_RevokeAPIKey(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+	query := `delete from api_keys where id = \$1 and user_id = \$2;`
+
+	mock.ExpectExec(query).WithArgs("key1", "1").WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.Nil(t, s.RevokeAPIKey(context.Background(), "1", "key1"), "Error revoking api key")
+
+	mock.ExpectExec(query).WithArgs("key1", "2").WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.ErrorIs(t, s.RevokeAPIKey(context.Background(), "2", "key1"), ErrNotFound)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
+
+func TestPostgresURLStore_DeletionJobs(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+	ctx := context.Background()
+	created := time.Now()
+	job := models.DeletionJob{DeletionJobDTO: models.DeletionJobDTO{ID: "job1", Status: models.DeletionJobPending, URLs: 1, CreatedAt: created}, UserID: "1", ShortURLs: []string{"short1"}}
+
+	mock.ExpectExec(`insert into deletion_jobs \(id, user_id, short_urls, status, error, created_at, finished_at\)\s+values \(\$1, \$2, \$3, \$4, \$5, \$6, \$7\)\s+`+
+		`on conflict \(id\) do update set status = excluded.status, error = excluded.error, finished_at = excluded.finished_at;`).
+		WithArgs("job1", "1", pq.Array([]string{"short1"}), models.DeletionJobPending, "", created, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.Nil(t, s.SaveDeletionJob(ctx, job))
+
+	columns := []string{"id", "user_id", "short_urls", "status", "error", "created_at", "finished_at"}
+	query := `select id, user_id, short_urls, status, error, created_at, finished_at\s+from deletion_jobs where id = \$1;`
+	mock.ExpectQuery(query).WithArgs("job1").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("job1", "1", "{short1}", models.DeletionJobPending, "", created, nil))
+	mock.ExpectQuery(query).WithArgs("missing").WillReturnRows(sqlmock.NewRows(columns))
+	found, err := s.GetDeletionJob(ctx, "job1")
+	assert.Nil(t, err)
+	assert.Equal(t, job, found)
+	_, err = s.GetDeletionJob(ctx, "missing")
+	assert.ErrorIs(t, err, deletion.ErrJobNotFound)
+
+	mock.ExpectQuery(`select id, user_id, short_urls, status, error, created_at, finished_at\s+from deletion_jobs where status = \$1 order by created_at, id;`).
+		WithArgs(models.DeletionJobPending).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("job1", "1", "{short1}", models.DeletionJobPending, "", created, nil))
+	pending, err := s.PendingDeletionJobs(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []models.DeletionJob{job}, pending)
+
+	finishedBefore := time.Now().Add(-time.Hour)
+	mock.ExpectExec(`delete from deletion_jobs where finished_at < \$1;`).WithArgs(finishedBefore).WillReturnResult(sqlmock.NewResult(0, 3))
+	count, err := s.PurgeDeletionJobs(ctx, finishedBefore)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
+
+func TestPostgresURLStore_TouchAPIKey(t *testing.T) {
+	cfg := config.GetConfig()
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("Error creating db mock: %v", err)
+	}
+	defer db.Close()
+	s := &PostgresURLStore{cfg: &cfg, db: db}
+	usedAt := time.Now()
+
+	mock.ExpectExec(`update api_keys set last_used_at = \$1 where id = \$2;`).
+		WithArgs(usedAt, "key1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	assert.Nil(t, s.TouchAPIKey(context.Background(), "key1", usedAt), "Error touching api key")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Not all expectations were met: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/shekshuev/shortener/internal/app/logger"
+)
+
+// RunExpiredReaper периодически удаляет из хранилища ссылки с истёкшим сроком действия.
+// Работает до отмены контекста; при неположительном интервале сразу завершается.
+func RunExpiredReaper(ctx context.Context, s URLStore, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	log := logger.NewLogger()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := s.DeleteExpired(ctx)
+			if err != nil {
+				log.Log.Error("Error deleting expired urls", zap.Error(err))
+				continue
+			}
+			if count > 0 {
+				log.Log.Info("Expired urls deleted", zap.Int("count", count))
+			}
+		}
+	}
+}
+
+// RunDeletedPurger периодически окончательно удаляет из хранилища ссылки, удалённые раньше, чем retention назад.
+// Работает до отмены контекста; при неположительном интервале или сроке хранения сразу завершается.
+func RunDeletedPurger(ctx context.Context, s URLStore, interval, retention time.Duration) {
+	if interval <= 0 || retention <= 0 {
+		return
+	}
+	log := logger.NewLogger()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := s.PurgeDeleted(ctx, time.Now().Add(-retention))
+			if err != nil {
+				log.Log.Error("Error purging deleted urls", zap.Error(err))
+				continue
+			}
+			if count > 0 {
+				log.Log.Info("Deleted urls purged", zap.Int("count", count))
+			}
+		}
+	}
+}
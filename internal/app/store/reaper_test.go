@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shekshuev/shortener/internal/app/config"
+	"github.com/shekshuev/shortener/internal/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunExpiredReaper(t *testing.T) {
+	cfg := config.GetConfig()
+	s := &MemoryURLStore{urls: make(map[string]UserURL), cfg: &cfg}
+	expiresAt := time.Now().Add(20 * time.Millisecond)
+	_, err := s.SetURL(context.Background(), "short1", "https://ya.ru", "1", models.LinkOptions{ExpiresAt: &expiresAt})
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunExpiredReaper(ctx, s, 10*time.Millisecond)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		count, _ := s.CountURLs(context.Background())
+		return count == 0
+	}, time.Second, 10*time.Millisecond, "Expired url was not deleted")
+
+	cancel()
+	<-done
+}
+
+func TestRunDeletedPurger(t *testing.T) {
+	cfg := config.GetConfig()
+	s := &MemoryURLStore{urls: make(map[string]UserURL), cfg: &cfg}
+	_, err := s.SetURL(context.Background(), "short1", "https://ya.ru", "1", models.LinkOptions{})
+	assert.Nil(t, err)
+	_, err = s.SetURL(context.Background(), "short2", "https://google.com", "1", models.LinkOptions{})
+	assert.Nil(t, err)
+	assert.Nil(t, s.DeleteURLs(context.Background(), "1", []string{"short1"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunDeletedPurger(ctx, s, 10*time.Millisecond, time.Nanosecond)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		count, _ := s.CountURLs(context.Background())
+		return count == 1
+	}, time.Second, 10*time.Millisecond, "Deleted url was not purged")
+	_, err = s.GetURL(context.Background(), "short2")
+	assert.Nil(t, err, "Active url should be kept")
+
+	cancel()
+	<-done
+}
@@ -3,20 +3,75 @@ package store
 import (
 	"context"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/shekshuev/shortener/internal/app/models"
+	"github.com/shekshuev/shortener/internal/utils"
 )
 
 // URLStore - интерфейс для работы с хранилищем URL.
 type URLStore interface {
-	SetURL(ctx context.Context, key, value, userID string) (string, error)
+	SetURL(ctx context.Context, key, value, userID string, opts models.LinkOptions) (string, error)
 	SetBatchURL(ctx context.Context, createDTO []models.BatchShortURLCreateDTO, userID string) error
 	GetURL(ctx context.Context, key string) (string, error)
+	GetRedirect(ctx context.Context, key string) (models.RedirectDTO, error)
 	GetUserURLs(ctx context.Context, userID string) ([]models.UserShortURLReadDTO, error)
+	GetUserURLsPage(ctx context.Context, userID string, query models.UserURLsQuery) (models.UserURLsPageDTO, error)
+	LookupUserURLs(ctx context.Context, userID, normalizedURL string) ([]models.UserShortURLReadDTO, error)
 	DeleteURLs(ctx context.Context, userID string, urls []string) error
+	DeleteURLsBatch(ctx context.Context, tasks []models.DeletionTask) error
+	RestoreURLs(ctx context.Context, userID string, urls []string, deletedSince time.Time) ([]string, error)
+	PurgeDeleted(ctx context.Context, deletedBefore time.Time) (int, error)
+	UpdateURL(ctx context.Context, userID, key string, update models.ShortURLUpdateDTO) error
 	Close() error
 	CountURLs(ctx context.Context) (int, error)
 	CountUsers(ctx context.Context) (int, error)
+	DeleteExpired(ctx context.Context) (int, error)
+	SaveClicks(ctx context.Context, events []models.ClickEvent) error
+	GetURLStats(ctx context.Context, userID, key string) (models.URLStatsDTO, error)
+	GetURLInfo(ctx context.Context, key string) (models.URLInfoDTO, error)
+	CreateAPIKey(ctx context.Context, key models.APIKey) error
+	GetUserAPIKeys(ctx context.Context, userID string) ([]models.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id string) error
+	TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error
+}
+
+// Типы хранилищ, выбираемые настройкой StoreBackend.
+const (
+	BackendMemory   = "memory"   // Хранилище в памяти со снапшотом и журналом в файле.
+	BackendFile     = "file"     // Встраиваемое хранилище bbolt в одном файле.
+	BackendPostgres = "postgres" // Хранилище в PostgreSQL.
+)
+
+// normalizedURL возвращает нормализованный вид оригинального URL для поиска ссылок по нему.
+// Строки, не являющиеся абсолютным URL, остаются как есть, так же как в функции normalize_url в PostgreSQL.
+func normalizedURL(raw string) string {
+	if normalized, err := utils.NormalizeURL(raw); err == nil {
+		return normalized
+	}
+	return raw
+}
+
+// sortAPIKeys упорядочивает ключи API по моменту создания, а при равенстве - по идентификатору.
+func sortAPIKeys(keys []models.APIKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+}
+
+// sortDeletionJobs упорядочивает задачи удаления по моменту постановки в очередь, а при равенстве - по идентификатору.
+func sortDeletionJobs(jobs []models.DeletionJob) {
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
 }
 
 // DatabaseChecker - интерфейс для проверки соединения с базой данных.
@@ -34,4 +89,6 @@ var (
 	ErrNotInitialized = fmt.Errorf("store not initialized")   // Ошибка: хранилище не инициализировано
 	ErrEmptyURLs      = fmt.Errorf("no urls provided")        // Ошибка: список URL пуст
 	ErrAlreadyDeleted = fmt.Errorf("urls already deleted")    // Ошибка: URL уже удалены
+	ErrKeyTaken       = fmt.Errorf("short key already taken") // Ошибка: короткий ключ уже занят
+	ErrExpired        = fmt.Errorf("url expired")             // Ошибка: срок действия URL истёк
 )
@@ -0,0 +1,574 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shekshuev/shortener/internal/app/config"
+	"github.com/shekshuev/shortener/internal/app/deletion"
+	"github.com/shekshuev/shortener/internal/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// storeFactories возвращает конструкторы всех реализаций URLStore для общих поведенческих тестов.
+// Хранилище PostgreSQL проверяется, только если задана переменная окружения TEST_DATABASE_DSN.
+func storeFactories() map[string]func(t *testing.T) URLStore {
+	factories := map[string]func(t *testing.T) URLStore{
+		"memory": func(t *testing.T) URLStore {
+			cfg := config.GetConfig()
+			return &MemoryURLStore{urls: make(map[string]UserURL), clicks: make(map[string][]models.ClickEvent), cfg: &cfg}
+		},
+		"cached": func(t *testing.T) URLStore {
+			cfg := config.GetConfig()
+			next := &MemoryURLStore{urls: make(map[string]UserURL), clicks: make(map[string][]models.ClickEvent), cfg: &cfg}
+			return NewCachedURLStore(next, 100, time.Minute)
+		},
+		"file": func(t *testing.T) URLStore {
+			cfg := config.GetConfig()
+			cfg.FileStoragePath = filepath.Join(t.TempDir(), "storage.db")
+			s, err := NewBoltURLStore(&cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { s.Close() })
+			return s
+		},
+	}
+	if dsn := os.Getenv("TEST_DATABASE_DSN"); dsn != "" {
+		factories["postgres"] = func(t *testing.T) URLStore {
+			cfg := config.GetConfig()
+			cfg.DatabaseDSN = dsn
+			s, err := NewPostgresURLStore(&cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = s.db.Exec(`truncate urls, clicks, api_keys, deletion_jobs;`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { s.Close() })
+			return s
+		}
+	}
+	return factories
+}
+
+func TestURLStore_Behaviour(t *testing.T) {
+	ctx := context.Background()
+	for name, newStore := range storeFactories() {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Set and get url", func(t *testing.T) {
+				s := newStore(t)
+				key, err := s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{})
+				assert.NoError(t, err)
+				assert.Equal(t, "short1", key)
+				value, err := s.GetURL(ctx, "short1")
+				assert.NoError(t, err)
+				assert.Equal(t, "https://ya.ru", value)
+				_, err = s.GetURL(ctx, "missing")
+				assert.ErrorIs(t, err, ErrNotFound)
+			})
+
+			t.Run("Per-user de-duplication and key collisions", func(t *testing.T) {
+				s := newStore(t)
+				_, err := s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{})
+				assert.NoError(t, err)
+				key, err := s.SetURL(ctx, "short2", "https://ya.ru", "1", models.LinkOptions{})
+				assert.ErrorIs(t, err, ErrAlreadyExists)
+				assert.Equal(t, "short1", key)
+				key, err = s.SetURL(ctx, "short3", "https://ya.ru", "2", models.LinkOptions{})
+				assert.NoError(t, err)
+				assert.Equal(t, "short3", key)
+				_, err = s.SetURL(ctx, "short1", "https://google.com", "1", models.LinkOptions{})
+				assert.ErrorIs(t, err, ErrKeyTaken)
+			})
+
+			t.Run("Only live urls are duplicates", func(t *testing.T) {
+				s := newStore(t)
+				_, err := s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{})
+				assert.NoError(t, err)
+				assert.NoError(t, s.DeleteURLs(ctx, "1", []string{"short1"}))
+				key, err := s.SetURL(ctx, "short2", "https://ya.ru", "1", models.LinkOptions{})
+				assert.NoError(t, err, "Deleted url should not block shortening it again")
+				assert.Equal(t, "short2", key)
+				restored, err := s.RestoreURLs(ctx, "1", []string{"short1"}, time.Now().Add(-time.Hour))
+				assert.NoError(t, err)
+				assert.Empty(t, restored, "Url shortened again should not be restored")
+
+				assert.NoError(t, s.DeleteURLs(ctx, "1", []string{"short2"}))
+				batch := []models.BatchShortURLCreateDTO{{CorrelationID: "1", OriginalURL: "https://ya.ru", ShortURL: "short3"}}
+				assert.NoError(t, s.SetBatchURL(ctx, batch, "1"))
+				assert.Equal(t, "short3", batch[0].ShortURL)
+
+				assert.NoError(t, s.DeleteURLs(ctx, "1", []string{"short3"}))
+				restored, err = s.RestoreURLs(ctx, "1", []string{"short1", "short2"}, time.Now().Add(-time.Hour))
+				assert.NoError(t, err)
+				assert.Len(t, restored, 1, "Only one of the deleted duplicates should be restored")
+				if len(restored) == 1 {
+					key, err = s.SetURL(ctx, "short4", "https://ya.ru", "1", models.LinkOptions{})
+					assert.ErrorIs(t, err, ErrAlreadyExists)
+					assert.Equal(t, restored[0], key)
+				}
+
+				past := time.Now().Add(-time.Hour)
+				_, err = s.SetURL(ctx, "short5", "https://google.com", "1", models.LinkOptions{ExpiresAt: &past})
+				assert.NoError(t, err)
+				key, err = s.SetURL(ctx, "short6", "https://google.com", "1", models.LinkOptions{})
+				assert.NoError(t, err, "Expired url should not block shortening it again")
+				assert.Equal(t, "short6", key)
+			})
+
+			t.Run("Batch with existing url", func(t *testing.T) {
+				s := newStore(t)
+				_, err := s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{})
+				assert.NoError(t, err)
+				batch := []models.BatchShortURLCreateDTO{
+					{CorrelationID: "1", OriginalURL: "https://ya.ru", ShortURL: "short2"},
+					{CorrelationID: "2", OriginalURL: "https://google.com", ShortURL: "short3"},
+				}
+				err = s.SetBatchURL(ctx, batch, "1")
+				assert.ErrorIs(t, err, ErrAlreadyExists)
+				assert.Equal(t, "short1", batch[0].ShortURL)
+				assert.Equal(t, "short3", batch[1].ShortURL)
+				value, err := s.GetURL(ctx, "short3")
+				assert.NoError(t, err)
+				assert.Equal(t, "https://google.com", value)
+			})
+
+			t.Run("Delete urls", func(t *testing.T) {
+				s := newStore(t)
+				_, err := s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{})
+				assert.NoError(t, err)
+				_, err = s.SetURL(ctx, "short2", "https://google.com", "1", models.LinkOptions{})
+				assert.NoError(t, err)
+				assert.NoError(t, s.DeleteURLs(ctx, "2", []string{"short1"}))
+				_, err = s.GetURL(ctx, "short1")
+				assert.NoError(t, err, "Another user should not delete the url")
+				assert.NoError(t, s.DeleteURLs(ctx, "1", []string{"short1"}))
+				_, err = s.GetURL(ctx, "short1")
+				assert.ErrorIs(t, err, ErrAlreadyDeleted)
+				urls, err := s.GetUserURLs(ctx, "1")
+				assert.NoError(t, err)
+				assert.Len(t, urls, 1)
+				assert.Equal(t, "https://google.com", urls[0].OriginalURL)
+			})
+
+			t.Run("Delete urls batch", func(t *testing.T) {
+				s := newStore(t)
+				_, err := s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{})
+				assert.NoError(t, err)
+				_, err = s.SetURL(ctx, "short2", "https://google.com", "2", models.LinkOptions{})
+				assert.NoError(t, err)
+				_, err = s.SetURL(ctx, "short3", "https://example.com", "2", models.LinkOptions{})
+				assert.NoError(t, err)
+				err = s.DeleteURLsBatch(ctx, []models.DeletionTask{
+					{UserID: "1", ShortURLs: []string{"short1", "short3"}},
+					{UserID: "2", ShortURLs: []string{"short2"}},
+				})
+				assert.NoError(t, err)
+				for _, key := range []string{"short1", "short2"} {
+					_, err = s.GetURL(ctx, key)
+					assert.ErrorIs(t, err, ErrAlreadyDeleted)
+				}
+				_, err = s.GetURL(ctx, "short3")
+				assert.NoError(t, err, "Another user should not delete the url")
+				assert.ErrorIs(t, s.DeleteURLsBatch(ctx, []models.DeletionTask{{UserID: "1"}}), ErrEmptyURLs)
+			})
+
+			t.Run("Restore and purge deleted urls", func(t *testing.T) {
+				s := newStore(t)
+				_, err := s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{})
+				assert.NoError(t, err)
+				_, err = s.SetURL(ctx, "short2", "https://google.com", "1", models.LinkOptions{})
+				assert.NoError(t, err)
+				assert.NoError(t, s.DeleteURLs(ctx, "1", []string{"short1", "short2"}))
+
+				restored, err := s.RestoreURLs(ctx, "2", []string{"short1"}, time.Now().Add(-time.Hour))
+				assert.NoError(t, err)
+				assert.Empty(t, restored, "Another user should not restore the url")
+				restored, err = s.RestoreURLs(ctx, "1", []string{"short1"}, time.Now().Add(time.Hour))
+				assert.NoError(t, err)
+				assert.Empty(t, restored, "Url deleted before the grace period should not be restored")
+				restored, err = s.RestoreURLs(ctx, "1", []string{"short1", "missing"}, time.Now().Add(-time.Hour))
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"short1"}, restored)
+				value, err := s.GetURL(ctx, "short1")
+				assert.NoError(t, err)
+				assert.Equal(t, "https://ya.ru", value)
+
+				count, err := s.PurgeDeleted(ctx, time.Now().Add(-time.Hour))
+				assert.NoError(t, err)
+				assert.Zero(t, count, "Recently deleted url should be kept")
+				count, err = s.PurgeDeleted(ctx, time.Now().Add(time.Hour))
+				assert.NoError(t, err)
+				assert.Equal(t, 1, count)
+				_, err = s.GetURL(ctx, "short2")
+				assert.ErrorIs(t, err, ErrNotFound)
+				_, err = s.GetURL(ctx, "short1")
+				assert.NoError(t, err, "Restored url should not be purged")
+			})
+
+			t.Run("Update url", func(t *testing.T) {
+				s := newStore(t)
+				_, err := s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{})
+				assert.NoError(t, err)
+				_, err = s.SetURL(ctx, "short2", "https://google.com", "1", models.LinkOptions{})
+				assert.NoError(t, err)
+				assert.ErrorIs(t, s.UpdateURL(ctx, "2", "short1", models.ShortURLUpdateDTO{URL: "https://example.com"}), ErrNotFound)
+				assert.ErrorIs(t, s.UpdateURL(ctx, "1", "short1", models.ShortURLUpdateDTO{URL: "https://google.com"}), ErrAlreadyExists)
+				assert.NoError(t, s.UpdateURL(ctx, "1", "short1", models.ShortURLUpdateDTO{URL: "https://example.com"}))
+				value, err := s.GetURL(ctx, "short1")
+				assert.NoError(t, err)
+				assert.Equal(t, "https://example.com", value)
+				key, err := s.SetURL(ctx, "short3", "https://ya.ru", "1", models.LinkOptions{})
+				assert.NoError(t, err, "Old url should be free after update")
+				assert.Equal(t, "short3", key)
+			})
+
+			t.Run("User urls page", func(t *testing.T) {
+				s := newStore(t)
+				for _, link := range [][2]string{
+					{"short1", "https://ya.ru"},
+					{"short2", "https://example.com/a"},
+					{"short3", "https://google.com"},
+					{"short4", "https://Example.com/b"},
+					{"short5", "https://example.org"},
+				} {
+					_, err := s.SetURL(ctx, link[0], link[1], "1", models.LinkOptions{})
+					assert.NoError(t, err)
+				}
+				_, err := s.SetURL(ctx, "short6", "https://example.net", "2", models.LinkOptions{})
+				assert.NoError(t, err)
+
+				var keys []string
+				query := models.UserURLsQuery{Limit: 2, Sort: SortShortURL, Order: OrderAsc}
+				for pages := 0; pages < 5; pages++ {
+					page, err := s.GetUserURLsPage(ctx, "1", query)
+					assert.NoError(t, err)
+					for _, u := range page.URLs {
+						keys = append(keys, filepath.Base(u.ShortURL))
+					}
+					if len(page.NextCursor) == 0 {
+						break
+					}
+					query.Cursor = page.NextCursor
+				}
+				assert.Equal(t, []string{"short1", "short2", "short3", "short4", "short5"}, keys)
+
+				page, err := s.GetUserURLsPage(ctx, "1", models.UserURLsQuery{})
+				assert.NoError(t, err)
+				assert.Len(t, page.URLs, 5)
+				assert.Equal(t, "short5", filepath.Base(page.URLs[0].ShortURL), "Newest url should be first by default")
+				assert.Empty(t, page.NextCursor)
+
+				page, err = s.GetUserURLsPage(ctx, "1", models.UserURLsQuery{Sort: SortOriginalURL, Order: OrderDesc, Query: "EXAMPLE.com"})
+				assert.NoError(t, err)
+				assert.Len(t, page.URLs, 2, "Filter should be case-insensitive substring match")
+
+				page, err = s.GetUserURLsPage(ctx, "1", models.UserURLsQuery{Limit: 1, Sort: SortShortURL})
+				assert.NoError(t, err)
+				_, err = s.GetUserURLsPage(ctx, "1", models.UserURLsQuery{Limit: 1, Sort: SortShortURL, Order: OrderAsc, Cursor: page.NextCursor})
+				assert.ErrorIs(t, err, ErrInvalidCursor, "Cursor should not be accepted for another order")
+				_, err = s.GetUserURLsPage(ctx, "1", models.UserURLsQuery{Cursor: "garbage"})
+				assert.ErrorIs(t, err, ErrInvalidCursor)
+				_, err = s.GetUserURLsPage(ctx, "1", models.UserURLsQuery{Sort: "clicks"})
+				assert.ErrorIs(t, err, ErrInvalidSort)
+				_, err = s.GetUserURLsPage(ctx, "1", models.UserURLsQuery{Limit: MaxPageLimit + 1})
+				assert.ErrorIs(t, err, ErrInvalidLimit)
+			})
+
+			t.Run("User urls without limit", func(t *testing.T) {
+				s := newStore(t)
+				for i := 0; i < 105; i++ {
+					_, err := s.SetURL(ctx, fmt.Sprintf("short%03d", i), fmt.Sprintf("https://example.com/%d", i), "1", models.LinkOptions{})
+					assert.NoError(t, err)
+				}
+				assert.NoError(t, s.DeleteURLs(ctx, "1", []string{"short000", "short050"}))
+				_, err := s.PurgeDeleted(ctx, time.Now().Add(time.Hour))
+				assert.NoError(t, err)
+				assert.NoError(t, s.DeleteURLs(ctx, "1", []string{"short001"}))
+
+				page, err := s.GetUserURLsPage(ctx, "1", models.UserURLsQuery{})
+				assert.NoError(t, err)
+				assert.Len(t, page.URLs, 102, "Omitted limit should return the full list")
+				assert.Empty(t, page.NextCursor)
+
+				page, err = s.GetUserURLsPage(ctx, "1", models.UserURLsQuery{Limit: 100})
+				assert.NoError(t, err)
+				assert.Len(t, page.URLs, 100)
+				assert.NotEmpty(t, page.NextCursor)
+				page, err = s.GetUserURLsPage(ctx, "1", models.UserURLsQuery{Cursor: page.NextCursor})
+				assert.NoError(t, err)
+				assert.Empty(t, page.NextCursor)
+				var keys []string
+				for _, u := range page.URLs {
+					keys = append(keys, filepath.Base(u.ShortURL))
+				}
+				assert.Equal(t, []string{"short003", "short002"}, keys, "Purged and deleted urls should be skipped")
+			})
+
+			t.Run("Lookup urls", func(t *testing.T) {
+				s := newStore(t)
+				_, err := s.SetURL(ctx, "short1", "https://example.com/x", "1", models.LinkOptions{})
+				assert.NoError(t, err)
+				_, err = s.SetURL(ctx, "short2", "HTTPS://Example.com:443/x", "1", models.LinkOptions{})
+				assert.NoError(t, err)
+				_, err = s.SetURL(ctx, "short3", "https://example.com/X", "1", models.LinkOptions{})
+				assert.NoError(t, err)
+				_, err = s.SetURL(ctx, "short4", "https://example.com/x", "2", models.LinkOptions{})
+				assert.NoError(t, err)
+
+				found, err := s.LookupUserURLs(ctx, "1", "https://example.com/x")
+				assert.NoError(t, err)
+				assert.Len(t, found, 2, "Urls differing only in host case and default port should match")
+
+				assert.NoError(t, s.UpdateURL(ctx, "1", "short1", models.ShortURLUpdateDTO{URL: "https://example.org/"}))
+				assert.NoError(t, s.DeleteURLs(ctx, "1", []string{"short2"}))
+				found, err = s.LookupUserURLs(ctx, "1", "https://example.com/x")
+				assert.NoError(t, err)
+				assert.Empty(t, found, "Updated and deleted urls should not be found")
+				found, err = s.LookupUserURLs(ctx, "1", "https://example.org/")
+				assert.NoError(t, err)
+				assert.Len(t, found, 1)
+				assert.Equal(t, "short1", filepath.Base(found[0].ShortURL))
+			})
+
+			t.Run("Url info", func(t *testing.T) {
+				s := newStore(t)
+				past := time.Now().Add(-time.Minute)
+				_, err := s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{ExpiresAt: &past})
+				assert.NoError(t, err)
+				_, err = s.SetURL(ctx, "short2", "https://google.com", "1", models.LinkOptions{})
+				assert.NoError(t, err)
+				assert.NoError(t, s.SaveClicks(ctx, []models.ClickEvent{
+					{ShortURL: "short1", Timestamp: past, IP: "10.0.0.1"},
+					{ShortURL: "short1", Timestamp: past, IP: "10.0.0.2"},
+				}))
+				assert.NoError(t, s.DeleteURLs(ctx, "1", []string{"short2"}))
+
+				info, err := s.GetURLInfo(ctx, "short1")
+				assert.NoError(t, err, "Expired url info should be available")
+				assert.Equal(t, "https://ya.ru", info.OriginalURL)
+				assert.Equal(t, "1", info.UserID)
+				assert.Equal(t, 2, info.Clicks)
+				assert.NotNil(t, info.CreatedAt)
+				assert.NotNil(t, info.ExpiresAt)
+				_, err = s.GetURLInfo(ctx, "short2")
+				assert.ErrorIs(t, err, ErrAlreadyDeleted)
+				_, err = s.GetURLInfo(ctx, "short3")
+				assert.ErrorIs(t, err, ErrNotFound)
+			})
+
+			t.Run("Redirect type", func(t *testing.T) {
+				s := newStore(t)
+				_, err := s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{RedirectType: 301})
+				assert.NoError(t, err)
+				err = s.SetBatchURL(ctx, []models.BatchShortURLCreateDTO{
+					{CorrelationID: "1", OriginalURL: "https://google.com", ShortURL: "short2", LinkOptions: models.LinkOptions{RedirectType: 302}},
+					{CorrelationID: "2", OriginalURL: "https://example.com", ShortURL: "short3"},
+				}, "1")
+				assert.NoError(t, err)
+
+				redirect, err := s.GetRedirect(ctx, "short1")
+				assert.NoError(t, err)
+				assert.Equal(t, models.RedirectDTO{URL: "https://ya.ru", Type: 301}, redirect)
+				redirect, err = s.GetRedirect(ctx, "short2")
+				assert.NoError(t, err)
+				assert.Equal(t, 302, redirect.Type)
+				redirect, err = s.GetRedirect(ctx, "short3")
+				assert.NoError(t, err)
+				assert.Equal(t, 0, redirect.Type, "Link without redirect type should use server default")
+
+				assert.NoError(t, s.UpdateURL(ctx, "1", "short3", models.ShortURLUpdateDTO{RedirectType: 308}))
+				assert.ErrorIs(t, s.UpdateURL(ctx, "2", "short1", models.ShortURLUpdateDTO{RedirectType: 308}), ErrNotFound)
+				assert.NoError(t, s.UpdateURL(ctx, "1", "short3", models.ShortURLUpdateDTO{URL: "https://example.org"}))
+				redirect, err = s.GetRedirect(ctx, "short3")
+				assert.NoError(t, err)
+				assert.Equal(t, models.RedirectDTO{URL: "https://example.org", Type: 308}, redirect, "Url update should keep redirect type")
+				assert.NoError(t, s.UpdateURL(ctx, "1", "short3", models.ShortURLUpdateDTO{URL: "https://example.net", RedirectType: 307}))
+				redirect, err = s.GetRedirect(ctx, "short3")
+				assert.NoError(t, err)
+				assert.Equal(t, models.RedirectDTO{URL: "https://example.net", Type: 307}, redirect, "Url and redirect type should change together")
+				assert.ErrorIs(t, s.UpdateURL(ctx, "1", "short3", models.ShortURLUpdateDTO{}), ErrEmptyValue)
+				info, err := s.GetURLInfo(ctx, "short1")
+				assert.NoError(t, err)
+				assert.Equal(t, 301, info.RedirectType)
+
+				assert.NoError(t, s.DeleteURLs(ctx, "1", []string{"short1"}))
+				_, err = s.GetRedirect(ctx, "short1")
+				assert.ErrorIs(t, err, ErrAlreadyDeleted)
+				assert.ErrorIs(t, s.UpdateURL(ctx, "1", "short1", models.ShortURLUpdateDTO{RedirectType: 302}), ErrNotFound)
+			})
+
+			t.Run("Redirect forwarding", func(t *testing.T) {
+				s := newStore(t)
+				opts := models.LinkOptions{ForwardQuery: "merge", ForwardPath: true}
+				_, err := s.SetURL(ctx, "short1", "https://docs.example.com", "1", opts)
+				assert.NoError(t, err)
+				err = s.SetBatchURL(ctx, []models.BatchShortURLCreateDTO{
+					{CorrelationID: "1", OriginalURL: "https://google.com", ShortURL: "short2", LinkOptions: models.LinkOptions{ForwardQuery: "override"}},
+				}, "1")
+				assert.NoError(t, err)
+
+				redirect, err := s.GetRedirect(ctx, "short1")
+				assert.NoError(t, err)
+				assert.Equal(t, models.RedirectDTO{URL: "https://docs.example.com", ForwardQuery: "merge", ForwardPath: true}, redirect)
+				redirect, err = s.GetRedirect(ctx, "short2")
+				assert.NoError(t, err)
+				assert.Equal(t, models.RedirectDTO{URL: "https://google.com", ForwardQuery: "override"}, redirect)
+				info, err := s.GetURLInfo(ctx, "short1")
+				assert.NoError(t, err)
+				assert.Equal(t, "merge", info.ForwardQuery)
+				assert.True(t, info.ForwardPath)
+			})
+
+			t.Run("Expired urls", func(t *testing.T) {
+				s := newStore(t)
+				past := time.Now().Add(-time.Minute)
+				_, err := s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{ExpiresAt: &past})
+				assert.NoError(t, err)
+				_, err = s.GetURL(ctx, "short1")
+				assert.ErrorIs(t, err, ErrExpired)
+				count, err := s.DeleteExpired(ctx)
+				assert.NoError(t, err)
+				assert.Equal(t, 1, count)
+				_, err = s.GetURL(ctx, "short1")
+				assert.ErrorIs(t, err, ErrNotFound)
+			})
+
+			t.Run("Click stats", func(t *testing.T) {
+				s := newStore(t)
+				_, err := s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{})
+				assert.NoError(t, err)
+				now := time.Now()
+				err = s.SaveClicks(ctx, []models.ClickEvent{
+					{ShortURL: "short1", Timestamp: now, IP: "10.0.0.1"},
+					{ShortURL: "short1", Timestamp: now, IP: "10.0.0.1"},
+					{ShortURL: "short1", Timestamp: now, IP: "10.0.0.2"},
+				})
+				assert.NoError(t, err)
+				stats, err := s.GetURLStats(ctx, "1", "short1")
+				assert.NoError(t, err)
+				assert.Equal(t, 3, stats.Clicks)
+				assert.Equal(t, 2, stats.UniqueVisitors)
+				_, err = s.GetURLStats(ctx, "2", "short1")
+				assert.ErrorIs(t, err, ErrNotFound)
+			})
+
+			t.Run("Click stats of removed urls", func(t *testing.T) {
+				s := newStore(t)
+				past := time.Now().Add(-time.Minute)
+				_, err := s.SetURL(ctx, "short1", "https://ya.ru", "1", models.LinkOptions{})
+				assert.NoError(t, err)
+				_, err = s.SetURL(ctx, "short2", "https://google.com", "1", models.LinkOptions{ExpiresAt: &past})
+				assert.NoError(t, err)
+				assert.NoError(t, s.SaveClicks(ctx, []models.ClickEvent{
+					{ShortURL: "short1", Timestamp: time.Now(), IP: "10.0.0.1"},
+					{ShortURL: "short2", Timestamp: time.Now(), IP: "10.0.0.2"},
+				}))
+				assert.NoError(t, s.DeleteURLs(ctx, "1", []string{"short1"}))
+				_, err = s.PurgeDeleted(ctx, time.Now().Add(time.Hour))
+				assert.NoError(t, err)
+				_, err = s.DeleteExpired(ctx)
+				assert.NoError(t, err)
+
+				for _, key := range []string{"short1", "short2"} {
+					_, err = s.SetURL(ctx, key, "https://example.com/"+key, "2", models.LinkOptions{})
+					assert.NoError(t, err)
+					stats, err := s.GetURLStats(ctx, "2", key)
+					assert.NoError(t, err)
+					assert.Zero(t, stats.Clicks, "Reissued key should not inherit clicks of the removed url")
+				}
+			})
+
+			t.Run("Deletion jobs", func(t *testing.T) {
+				jobs, ok := newStore(t).(deletion.JobStore)
+				if !ok {
+					t.Skip("Store does not persist deletion jobs")
+				}
+				created := time.Now().Add(-2 * time.Hour).UTC().Truncate(time.Microsecond)
+				first := models.DeletionJob{DeletionJobDTO: models.DeletionJobDTO{ID: "job1", Status: models.DeletionJobPending, URLs: 2, CreatedAt: created}, UserID: "1", ShortURLs: []string{"short1", "short2"}}
+				second := models.DeletionJob{DeletionJobDTO: models.DeletionJobDTO{ID: "job2", Status: models.DeletionJobPending, URLs: 1, CreatedAt: created.Add(time.Second)}, UserID: "2", ShortURLs: []string{"short3"}}
+				assert.NoError(t, jobs.SaveDeletionJob(ctx, second))
+				assert.NoError(t, jobs.SaveDeletionJob(ctx, first))
+
+				pending, err := jobs.PendingDeletionJobs(ctx)
+				assert.NoError(t, err)
+				if assert.Len(t, pending, 2) {
+					assert.Equal(t, "job1", pending[0].ID, "Pending jobs should be ordered by creation time")
+					assert.Equal(t, []string{"short1", "short2"}, pending[0].ShortURLs)
+					assert.Equal(t, "1", pending[0].UserID)
+				}
+
+				finished := created.Add(time.Minute)
+				first.Status = models.DeletionJobDone
+				first.FinishedAt = &finished
+				assert.NoError(t, jobs.SaveDeletionJob(ctx, first))
+				job, err := jobs.GetDeletionJob(ctx, "job1")
+				assert.NoError(t, err)
+				assert.Equal(t, models.DeletionJobDone, job.Status)
+				assert.Equal(t, 2, job.URLs)
+				pending, err = jobs.PendingDeletionJobs(ctx)
+				assert.NoError(t, err)
+				assert.Len(t, pending, 1)
+
+				count, err := jobs.PurgeDeletionJobs(ctx, time.Now().Add(-time.Hour))
+				assert.NoError(t, err)
+				assert.Equal(t, 1, count)
+				_, err = jobs.GetDeletionJob(ctx, "job1")
+				assert.ErrorIs(t, err, deletion.ErrJobNotFound)
+				_, err = jobs.GetDeletionJob(ctx, "job2")
+				assert.NoError(t, err, "Pending job should not be purged")
+			})
+
+			t.Run("API keys", func(t *testing.T) {
+				s := newStore(t)
+				createdAt := time.Now().UTC().Truncate(time.Microsecond)
+				first := models.APIKey{ID: "key1", UserID: "1", Name: "ci", Prefix: "shk_aaaa", Hash: "hash1", Scopes: []string{"read"}, CreatedAt: createdAt}
+				second := models.APIKey{ID: "key2", UserID: "1", Name: "bot", Prefix: "shk_bbbb", Hash: "hash2", Scopes: []string{"create", "read"}, CreatedAt: createdAt.Add(time.Second)}
+				assert.NoError(t, s.CreateAPIKey(ctx, second))
+				assert.NoError(t, s.CreateAPIKey(ctx, first))
+				assert.ErrorIs(t, s.CreateAPIKey(ctx, models.APIKey{ID: "key3", UserID: "2", Hash: "hash1"}), ErrAlreadyExists)
+
+				keys, err := s.GetUserAPIKeys(ctx, "1")
+				assert.NoError(t, err)
+				if assert.Len(t, keys, 2) {
+					assert.Equal(t, "key1", keys[0].ID)
+					assert.Equal(t, "key2", keys[1].ID)
+					assert.Equal(t, []string{"create", "read"}, keys[1].Scopes)
+					assert.True(t, createdAt.Equal(keys[0].CreatedAt))
+					assert.Nil(t, keys[0].LastUsedAt)
+				}
+				keys, err = s.GetUserAPIKeys(ctx, "2")
+				assert.NoError(t, err)
+				assert.Empty(t, keys)
+
+				key, err := s.GetAPIKeyByHash(ctx, "hash2")
+				assert.NoError(t, err)
+				assert.Equal(t, "key2", key.ID)
+				assert.Equal(t, "1", key.UserID)
+				_, err = s.GetAPIKeyByHash(ctx, "unknown")
+				assert.ErrorIs(t, err, ErrNotFound)
+
+				usedAt := createdAt.Add(time.Minute)
+				assert.NoError(t, s.TouchAPIKey(ctx, "key2", usedAt))
+				key, err = s.GetAPIKeyByHash(ctx, "hash2")
+				assert.NoError(t, err)
+				if assert.NotNil(t, key.LastUsedAt) {
+					assert.True(t, usedAt.Equal(*key.LastUsedAt))
+				}
+				assert.ErrorIs(t, s.TouchAPIKey(ctx, "unknown", usedAt), ErrNotFound)
+
+				assert.ErrorIs(t, s.RevokeAPIKey(ctx, "2", "key2"), ErrNotFound)
+				assert.NoError(t, s.RevokeAPIKey(ctx, "1", "key2"))
+				assert.ErrorIs(t, s.RevokeAPIKey(ctx, "1", "key2"), ErrNotFound)
+				_, err = s.GetAPIKeyByHash(ctx, "hash2")
+				assert.ErrorIs(t, err, ErrNotFound)
+			})
+		})
+	}
+}
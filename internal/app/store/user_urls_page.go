@@ -0,0 +1,170 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shekshuev/shortener/internal/app/models"
+)
+
+// Поля сортировки ссылок пользователя.
+const (
+	SortCreatedAt   = "created_at"   // По моменту создания ссылки.
+	SortShortURL    = "short_url"    // По короткому ключу.
+	SortOriginalURL = "original_url" // По оригинальному URL.
+)
+
+// Направления сортировки ссылок пользователя.
+const (
+	OrderAsc  = "asc"  // По возрастанию.
+	OrderDesc = "desc" // По убыванию.
+)
+
+// MaxPageLimit - наибольший допустимый размер страницы ссылок пользователя.
+const MaxPageLimit = 1000
+
+// cursorTimeLayout - формат момента создания в курсоре. Фиксированная ширина позволяет
+// сравнивать значения как строки, а PostgreSQL разбирает его как timestamp.
+const cursorTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// Ошибки параметров выборки ссылок пользователя.
+var (
+	ErrInvalidLimit  = fmt.Errorf("limit must be between 0 and %d", MaxPageLimit)             // Ошибка: недопустимый размер страницы
+	ErrInvalidSort   = fmt.Errorf("sort must be one of: created_at, short_url, original_url") // Ошибка: неизвестное поле сортировки
+	ErrInvalidOrder  = fmt.Errorf("order must be one of: asc, desc")                          // Ошибка: неизвестное направление сортировки
+	ErrInvalidCursor = fmt.Errorf("invalid cursor")                                           // Ошибка: курсор повреждён или от другой сортировки
+)
+
+// pageCursor - содержимое курсора: последняя ссылка страницы и сортировка, для которой он выдан.
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	Key   string `json:"k"`
+}
+
+// encode возвращает курсор в непрозрачном для клиента виде.
+func (c pageCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// prepareUserURLsQuery подставляет значения по умолчанию, проверяет параметры выборки и разбирает курсор.
+// Если курсор не задан, возвращается nil.
+func prepareUserURLsQuery(query *models.UserURLsQuery) (*pageCursor, error) {
+	if query.Limit < 0 || query.Limit > MaxPageLimit {
+		return nil, ErrInvalidLimit
+	}
+	if len(query.Sort) == 0 {
+		query.Sort = SortCreatedAt
+	}
+	if len(query.Order) == 0 {
+		query.Order = OrderDesc
+	}
+	switch query.Sort {
+	case SortCreatedAt, SortShortURL, SortOriginalURL:
+	default:
+		return nil, ErrInvalidSort
+	}
+	if query.Order != OrderAsc && query.Order != OrderDesc {
+		return nil, ErrInvalidOrder
+	}
+	if len(query.Cursor) == 0 {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || len(cursor.Key) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != query.Sort || cursor.Order != query.Order {
+		return nil, ErrInvalidCursor
+	}
+	if query.Sort == SortCreatedAt {
+		if _, err := time.Parse(cursorTimeLayout, cursor.Value); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &cursor, nil
+}
+
+// cursorTime форматирует момент создания ссылки для курсора.
+func cursorTime(t time.Time) string {
+	return t.UTC().Format(cursorTimeLayout)
+}
+
+// userURLEntry - ссылка пользователя, подготовленная к постраничной выдаче.
+type userURLEntry struct {
+	key       string
+	url       string
+	createdAt time.Time
+}
+
+// sortValue возвращает значение поля сортировки ссылки.
+func (e userURLEntry) sortValue(field string) string {
+	switch field {
+	case SortShortURL:
+		return e.key
+	case SortOriginalURL:
+		return e.url
+	default:
+		return cursorTime(e.createdAt)
+	}
+}
+
+// matchesURLQuery сообщает, содержит ли оригинальный URL подстроку q без учёта регистра.
+func matchesURLQuery(url, q string) bool {
+	return len(q) == 0 || strings.Contains(strings.ToLower(url), strings.ToLower(q))
+}
+
+// paginateUserURLs сортирует ссылки, пропускает ссылки до курсора и возвращает страницу вместе с курсором следующей.
+// Используется хранилищами, которые не умеют сортировать ссылки сами. Ссылки уже должны быть отфильтрованы.
+func paginateUserURLs(entries []userURLEntry, query models.UserURLsQuery, cursor *pageCursor, baseURL string) models.UserURLsPageDTO {
+	less := func(a, b userURLEntry) bool {
+		av, bv := a.sortValue(query.Sort), b.sortValue(query.Sort)
+		if av != bv {
+			return av < bv
+		}
+		return a.key < b.key
+	}
+	if query.Order == OrderDesc {
+		asc := less
+		less = func(a, b userURLEntry) bool { return asc(b, a) }
+	}
+	sort.Slice(entries, func(i, j int) bool { return less(entries[i], entries[j]) })
+
+	start := 0
+	if cursor != nil {
+		start = sort.Search(len(entries), func(i int) bool {
+			// Сравнение с курсором идёт по уже сериализованному значению поля сортировки.
+			v, key := entries[i].sortValue(query.Sort), entries[i].key
+			if query.Order == OrderDesc {
+				return v < cursor.Value || (v == cursor.Value && key < cursor.Key)
+			}
+			return v > cursor.Value || (v == cursor.Value && key > cursor.Key)
+		})
+	}
+	return buildUserURLsPage(entries[start:], query, baseURL)
+}
+
+// buildUserURLsPage обрезает отсортированные ссылки после курсора до размера страницы
+// и выдаёт курсор следующей страницы, если ссылок больше, чем помещается на страницу.
+func buildUserURLsPage(entries []userURLEntry, query models.UserURLsQuery, baseURL string) models.UserURLsPageDTO {
+	var page models.UserURLsPageDTO
+	if query.Limit > 0 && len(entries) > query.Limit {
+		entries = entries[:query.Limit]
+		last := entries[len(entries)-1]
+		page.NextCursor = pageCursor{Sort: query.Sort, Order: query.Order, Value: last.sortValue(query.Sort), Key: last.key}.encode()
+	}
+	for _, e := range entries {
+		page.URLs = append(page.URLs, models.UserShortURLReadDTO{ShortURL: fmt.Sprintf("%s/%s", baseURL, e.key), OriginalURL: e.url})
+	}
+	return page
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Ограничения на длину пользовательского алиаса.
+const (
+	AliasMinLength = 3
+	AliasMaxLength = 32
+)
+
+// Ошибки валидации пользовательского алиаса.
+var (
+	ErrInvalidAlias  = fmt.Errorf("alias must be %d-%d characters long and contain only latin letters, digits, '-' or '_'", AliasMinLength, AliasMaxLength) // Ошибка: недопустимый формат алиаса
+	ErrReservedAlias = fmt.Errorf("alias is reserved")                                                                                                      // Ошибка: алиас совпадает с зарезервированным словом
+)
+
+// reservedAliases содержит слова, которые нельзя использовать в качестве алиаса,
+// так как они пересекаются с маршрутами сервиса.
+var reservedAliases = map[string]struct{}{
+	"api":     {},
+	"ping":    {},
+	"admin":   {},
+	"debug":   {},
+	"health":  {},
+	"metrics": {},
+	"static":  {},
+	"jobs":    {},
+}
+
+// ValidateAlias проверяет, что алиас имеет допустимую длину, состоит только из разрешённых символов
+// и не совпадает с зарезервированными словами.
+func ValidateAlias(alias string) error {
+	if len(alias) < AliasMinLength || len(alias) > AliasMaxLength {
+		return ErrInvalidAlias
+	}
+	for _, r := range alias {
+		if !isAliasRune(r) {
+			return ErrInvalidAlias
+		}
+	}
+	if IsReservedAlias(alias) {
+		return ErrReservedAlias
+	}
+	return nil
+}
+
+// IsReservedAlias сообщает, совпадает ли ключ с зарезервированным словом без учёта регистра.
+// Используется и для сгенерированных ключей, чтобы короткая ссылка не перекрывала маршрут сервиса.
+func IsReservedAlias(key string) bool {
+	_, reserved := reservedAliases[strings.ToLower(key)]
+	return reserved
+}
+
+func isAliasRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_'
+}
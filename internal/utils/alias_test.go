@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateAlias(t *testing.T) {
+	testCases := []struct {
+		name        string
+		alias       string
+		expectedErr error
+	}{
+		{name: "Correct alias", alias: "my-link_1", expectedErr: nil},
+		{name: "Too short", alias: "ab", expectedErr: ErrInvalidAlias},
+		{name: "Too long", alias: "abcdefghijklmnopqrstuvwxyz0123456789", expectedErr: ErrInvalidAlias},
+		{name: "Forbidden characters", alias: "my/link", expectedErr: ErrInvalidAlias},
+		{name: "Non-latin characters", alias: "ссылка", expectedErr: ErrInvalidAlias},
+		{name: "Reserved word", alias: "api", expectedErr: ErrReservedAlias},
+		{name: "Reserved word in upper case", alias: "PING", expectedErr: ErrReservedAlias},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateAlias(tc.alias)
+			assert.Equal(t, tc.expectedErr, err, "Unexpected validation result")
+		})
+	}
+}
+
+func TestIsReservedAlias(t *testing.T) {
+	assert.True(t, IsReservedAlias("api"))
+	assert.True(t, IsReservedAlias("Ping"))
+	assert.False(t, IsReservedAlias("abc"))
+}
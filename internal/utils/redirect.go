@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Режимы передачи параметров запроса посетителя в адрес назначения.
+const (
+	QueryForwardOff      = ""         // Параметры запроса посетителя отбрасываются.
+	QueryForwardMerge    = "merge"    // Добавляются параметры, которых нет в адресе назначения; параметры назначения главнее.
+	QueryForwardOverride = "override" // Параметры посетителя заменяют одноимённые параметры адреса назначения.
+)
+
+// Ошибки параметров перенаправления по ссылке.
+var (
+	ErrInvalidRedirectType = fmt.Errorf("redirect type must be one of: 301, 302, 307, 308") // Ошибка: недопустимый код ответа
+	ErrInvalidQueryForward = fmt.Errorf("forward query must be one of: merge, override")    // Ошибка: неизвестный режим передачи параметров
+	ErrInvalidDestination  = fmt.Errorf("destination is not a valid url")                   // Ошибка: адрес назначения не разбирается как URL
+)
+
+// ValidateRedirectType проверяет, что код ответа подходит для перенаправления по сокращённой ссылке.
+func ValidateRedirectType(redirectType int) error {
+	switch redirectType {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return nil
+	default:
+		return ErrInvalidRedirectType
+	}
+}
+
+// ValidateQueryForward проверяет режим передачи параметров запроса посетителя.
+func ValidateQueryForward(mode string) error {
+	switch mode {
+	case QueryForwardOff, QueryForwardMerge, QueryForwardOverride:
+		return nil
+	default:
+		return ErrInvalidQueryForward
+	}
+}
+
+// BuildRedirectURL собирает адрес перенаправления: дописывает к пути назначения остаток пути запроса
+// и переносит параметры запроса посетителя согласно режиму mode.
+// Остаток пути очищается от "." и "..", поэтому не может выйти за пределы пути назначения.
+// Если дописывать нечего, адрес назначения возвращается без изменений.
+func BuildRedirectURL(destination, extraPath, rawQuery, mode string) (string, error) {
+	forwardQuery := mode != QueryForwardOff && len(rawQuery) > 0
+	if len(extraPath) == 0 && !forwardQuery {
+		return destination, nil
+	}
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", ErrInvalidDestination
+	}
+	if len(extraPath) > 0 {
+		cleaned := strings.TrimPrefix(path.Clean("/"+extraPath), "/")
+		if strings.HasSuffix(extraPath, "/") && len(cleaned) > 0 {
+			cleaned += "/"
+		}
+		u = u.JoinPath(cleaned)
+	}
+	if forwardQuery {
+		// Некорректные пары параметров посетителя пропускаются, остальные переносятся.
+		visitor, _ := url.ParseQuery(rawQuery)
+		query := u.Query()
+		for key, values := range visitor {
+			if _, exists := query[key]; exists && mode == QueryForwardMerge {
+				continue
+			}
+			query[key] = values
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String(), nil
+}
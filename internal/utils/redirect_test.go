@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRedirectType(t *testing.T) {
+	testCases := []struct {
+		name         string
+		redirectType int
+		expectedErr  error
+	}{
+		{name: "Moved permanently", redirectType: 301, expectedErr: nil},
+		{name: "Found", redirectType: 302, expectedErr: nil},
+		{name: "Temporary redirect", redirectType: 307, expectedErr: nil},
+		{name: "Permanent redirect", redirectType: 308, expectedErr: nil},
+		{name: "See other", redirectType: 303, expectedErr: ErrInvalidRedirectType},
+		{name: "Not a redirect", redirectType: 200, expectedErr: ErrInvalidRedirectType},
+		{name: "Zero", redirectType: 0, expectedErr: ErrInvalidRedirectType},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateRedirectType(tc.redirectType)
+			assert.Equal(t, tc.expectedErr, err, "Unexpected validation result")
+		})
+	}
+}
+
+func TestValidateQueryForward(t *testing.T) {
+	assert.NoError(t, ValidateQueryForward(QueryForwardOff))
+	assert.NoError(t, ValidateQueryForward(QueryForwardMerge))
+	assert.NoError(t, ValidateQueryForward(QueryForwardOverride))
+	assert.Equal(t, ErrInvalidQueryForward, ValidateQueryForward("append"))
+}
+
+func TestBuildRedirectURL(t *testing.T) {
+	testCases := []struct {
+		name        string
+		destination string
+		extraPath   string
+		rawQuery    string
+		mode        string
+		expected    string
+	}{
+		{name: "Nothing to forward", destination: "https://ya.ru/search?q=go", expected: "https://ya.ru/search?q=go"},
+		{name: "Query dropped when forwarding is off", destination: "https://ya.ru/search?q=go", rawQuery: "utm=1", expected: "https://ya.ru/search?q=go"},
+		{name: "Merge keeps destination params", destination: "https://ya.ru/search?q=go", rawQuery: "q=rust&utm=1", mode: QueryForwardMerge, expected: "https://ya.ru/search?q=go&utm=1"},
+		{name: "Override replaces destination params", destination: "https://ya.ru/search?q=go&lang=ru", rawQuery: "q=rust&utm=1", mode: QueryForwardOverride, expected: "https://ya.ru/search?lang=ru&q=rust&utm=1"},
+		{name: "Path appended", destination: "https://docs.example.com/v1", extraPath: "guide/install", expected: "https://docs.example.com/v1/guide/install"},
+		{name: "Path appended to root", destination: "https://docs.example.com", extraPath: "guide", expected: "https://docs.example.com/guide"},
+		{name: "Trailing slash kept", destination: "https://docs.example.com/v1/", extraPath: "guide/", expected: "https://docs.example.com/v1/guide/"},
+		{name: "Path cannot escape destination", destination: "https://docs.example.com/v1", extraPath: "../../admin", expected: "https://docs.example.com/v1/admin"},
+		{name: "Path with special characters", destination: "https://docs.example.com", extraPath: "a b/ц", expected: "https://docs.example.com/a%20b/%D1%86"},
+		{name: "Path and query", destination: "https://docs.example.com/v1?ref=short", extraPath: "guide", rawQuery: "page=2", mode: QueryForwardMerge, expected: "https://docs.example.com/v1/guide?page=2&ref=short"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := BuildRedirectURL(tc.destination, tc.extraPath, tc.rawQuery, tc.mode)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
@@ -15,6 +15,8 @@ const (
 var ErrEmptyString = fmt.Errorf("string should not be empty")
 
 // Shorten генерирует случайную строку длиной ShortenLength для сокращения URL.
+//
+// Deprecated: сервис генерирует ключи через keygen.KeyGenerator.
 func Shorten(s string) (string, error) {
 	if len(s) == 0 {
 		return "", ErrEmptyString
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidURL - ошибка, указывающая на строку, не являющуюся абсолютным URL.
+var ErrInvalidURL = fmt.Errorf("url must be absolute: scheme://host[/path][?query][#fragment]")
+
+// absoluteURLPattern разбирает абсолютный URL на схему, полномочия (userinfo@host:port) и остаток.
+var absoluteURLPattern = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9+.\-]*)://([^/?#]*)(.*)$`)
+
+// defaultPorts - порты, которые не меняют адрес, если совпадают с портом схемы по умолчанию.
+var defaultPorts = map[string]string{
+	"http":  ":80",
+	"https": ":443",
+}
+
+// NormalizeURL приводит URL к каноническому виду для сравнения: схема и хост в нижнем регистре,
+// порт по умолчанию для схемы отброшен, пустой путь заменён на "/". Путь, запрос и фрагмент не меняются.
+// Те же правила реализует функция normalize_url в схеме PostgreSQL, поэтому их нужно менять вместе.
+func NormalizeURL(raw string) (string, error) {
+	parts := absoluteURLPattern.FindStringSubmatch(raw)
+	if parts == nil {
+		return "", ErrInvalidURL
+	}
+	scheme, authority, rest := strings.ToLower(parts[1]), parts[2], parts[3]
+	userinfo := ""
+	if i := strings.LastIndex(authority, "@"); i >= 0 {
+		userinfo, authority = authority[:i+1], authority[i+1:]
+	}
+	host := strings.TrimSuffix(strings.ToLower(authority), defaultPorts[scheme])
+	if len(host) == 0 {
+		return "", ErrInvalidURL
+	}
+	if len(rest) == 0 || rest[0] == '?' || rest[0] == '#' {
+		rest = "/" + rest
+	}
+	return scheme + "://" + userinfo + host + rest, nil
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	testCases := []struct {
+		name        string
+		raw         string
+		expected    string
+		expectedErr error
+	}{
+		{name: "Already normalized", raw: "https://example.com/x", expected: "https://example.com/x"},
+		{name: "Scheme and host case", raw: "HTTPS://Example.COM/Path", expected: "https://example.com/Path"},
+		{name: "Default http port", raw: "http://example.com:80/x", expected: "http://example.com/x"},
+		{name: "Default https port", raw: "https://example.com:443", expected: "https://example.com/"},
+		{name: "Non-default port", raw: "https://example.com:8443/x", expected: "https://example.com:8443/x"},
+		{name: "Port of another scheme", raw: "http://example.com:443/x", expected: "http://example.com:443/x"},
+		{name: "Empty path with query", raw: "https://example.com?a=1#top", expected: "https://example.com/?a=1#top"},
+		{name: "Userinfo keeps case", raw: "ftp://User@Example.com/f", expected: "ftp://User@example.com/f"},
+		{name: "Relative url", raw: "example.com/x", expectedErr: ErrInvalidURL},
+		{name: "Empty host", raw: "https:///x", expectedErr: ErrInvalidURL},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			normalized, err := NormalizeURL(tc.raw)
+			assert.Equal(t, tc.expectedErr, err, "Unexpected normalization error")
+			assert.Equal(t, tc.expected, normalized, "Unexpected normalized url")
+		})
+	}
+}